@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gabrielg2020/monitor-api/internal/config"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/pkg/database"
+)
+
+const usage = `Usage: monitor-api [command]
+
+Without a command the API server is started.
+
+Commands:
+  backup               Snapshot the database into BACKUP_DIR (or -dir) and apply BACKUP_KEEP rotation
+  restore <file>       Replace DB_PATH with a backup after validating it; stop the server first
+  config print         Print the effective configuration as YAML (or -format toml), with secrets masked
+`
+
+var errSQLiteOnly = errors.New("backups are only supported with DB_DRIVER=sqlite; use pg_dump for PostgreSQL")
+
+// runCommand runs an administrative subcommand
+func runCommand(cfg *config.Config, name string, args []string) error {
+	switch name {
+	case "backup":
+		return runBackup(cfg, args)
+	case "restore":
+		return runRestore(cfg, args)
+	case "config":
+		return runConfig(cfg, args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runBackup takes an online snapshot of the database
+func runBackup(cfg *config.Config, args []string) error {
+	if cfg.Database.Driver != "sqlite" {
+		return errSQLiteOnly
+	}
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.Backup.Dir, "directory to write the backup to")
+	keep := flags.Int("keep", cfg.Backup.Keep, "number of backups to keep, 0 keeps all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = database.Close(db)
+	}()
+
+	service := services.NewBackupService(repository.NewBackupRepository(db.Read), *dir, *keep)
+	backup, err := service.CreateBackup(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup written to %s (%d bytes)\n", filepath.Join(*dir, backup.Name), backup.SizeBytes)
+	return nil
+}
+
+// runConfig prints the configuration the server would run with, after the file and
+// environment variables have been merged
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("expected config print")
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("format", config.FormatYAML, "output format, yaml or toml")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	output, err := config.Print(cfg, *format)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(output)
+	return err
+}
+
+// runRestore swaps the database file for a validated backup
+func runRestore(cfg *config.Config, args []string) error {
+	if cfg.Database.Driver != "sqlite" {
+		return errSQLiteOnly
+	}
+
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected the backup file to restore")
+	}
+
+	// A bare backup name is looked up in BACKUP_DIR
+	source := flags.Arg(0)
+	if _, err := os.Stat(source); errors.Is(err, os.ErrNotExist) && cfg.Backup.Dir != "" {
+		source = filepath.Join(cfg.Backup.Dir, source)
+	}
+
+	kept, version, err := database.Restore(source, cfg.Database.Path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s (schema version %d) to %s\n", source, version, cfg.Database.Path)
+	if kept != "" {
+		fmt.Printf("The previous database was kept at %s\n", kept)
+	}
+	if version < database.LatestSchemaVersion() {
+		fmt.Printf("Pending migrations up to version %d will be applied when the server starts\n", database.LatestSchemaVersion())
+	}
+	return nil
+}
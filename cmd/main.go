@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gabrielg2020/monitor-api/internal/api"
+	"github.com/gabrielg2020/monitor-api/internal/app"
 	"github.com/gabrielg2020/monitor-api/internal/config"
+	"github.com/gabrielg2020/monitor-api/internal/logging"
+	"github.com/gabrielg2020/monitor-api/internal/tracing"
 	"github.com/gabrielg2020/monitor-api/pkg/database"
+	"github.com/gabrielg2020/monitor-api/pkg/spool"
 	"github.com/gin-gonic/gin"
 
 	_ "github.com/gabrielg2020/monitor-api/docs"
@@ -37,29 +48,197 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Log structured records from here on, including those of the standard log package
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+	logger, err := logging.New(os.Stdout, logLevel, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Administrative subcommands run once and exit
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1], os.Args[2:]); err != nil {
+			slog.Error("command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := serve(cfg, logLevel); err != nil {
+		slog.Error("server error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the API server until SIGINT or SIGTERM, then drains in-flight requests
+// and background workers before closing the database. SIGHUP reloads the configuration.
+func serve(cfg *config.Config, logLevel *slog.LevelVar) error {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.Mode)
 
+	// Export traces before the database is opened, so statements are traced too
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// Flush spans after the server and workers have stopped
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Warn("failed to flush traces", "error", err)
+			}
+		}()
+		slog.Info("tracing enabled", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+
 	// Connect to database
-	db, err := database.Connect(cfg.Database.Path)
+	db, err := connect(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer func() {
 		if err := database.Close(db); err != nil {
-			log.Printf("Error closing database: %v", err)
+			slog.Error("failed to close database", "error", err)
 		}
 	}()
 
-	// Setup router with all routes
-	router := api.SetupRouterWithDB(db, cfg.CORS.AllowedOrigins)
+	// Apply pending schema migrations
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// Metrics acknowledged from the spool before a crash are written when the app starts
+	var ingestSpool *spool.Log
+	if cfg.Ingest.SpoolDir != "" {
+		ingestSpool, err = spool.Open(cfg.Ingest.SpoolDir, int64(cfg.Ingest.SpoolSegmentMiB)<<20)
+		if err != nil {
+			return fmt.Errorf("failed to open ingest spool: %w", err)
+		}
+		defer func() {
+			// Runs before the database is closed, once the ingest queue has drained
+			if err := ingestSpool.Close(); err != nil {
+				slog.Error("failed to close ingest spool", "error", err)
+			}
+		}()
+	}
+
+	// Handle the signals sent by Ctrl+C, docker stop and systemd
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Wire up routes and background workers
+	application := app.New(db, ingestSpool, cfg)
+	application.Start(context.Background())
 
 	// Start server
 	addr := ":" + cfg.Server.Port
-	fmt.Printf("Starting server on %s\n", addr)
-	fmt.Printf("Swagger documentation: http://localhost%s/swagger/index.html\n", addr)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           application.Router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("starting server", "addr", addr, "swagger", "http://localhost"+addr+"/swagger/index.html")
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	defer signal.Stop(reloads)
+
+	running := *cfg
+serving:
+	for {
+		select {
+		case err := <-serverErr:
+			_ = application.Stop(context.Background())
+			return fmt.Errorf("failed to start server: %w", err)
+		case <-reloads:
+			reload(&running, logLevel, application)
+		case <-ctx.Done():
+			break serving
+		}
+	}
+
+	// A second signal kills the process straight away
+	stop()
+	slog.Info("shutting down, waiting for requests and workers to finish", "timeout", cfg.Server.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests; streams are ended first
+	// as they would otherwise hold the server open until the deadline
+	application.CloseStreams()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests still running at the shutdown deadline were cut off", "error", err)
+		_ = server.Close()
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server error", "error", err)
+	}
+
+	// Returns only once the workers have stopped, so the database is closed after the last write
+	if err := application.Stop(shutdownCtx); err != nil {
+		slog.Warn("background workers finished after the shutdown deadline", "error", err)
+	}
+
+	return nil
+}
+
+// reload loads the configuration again and applies the settings that are safe to
+// change while running. An invalid configuration is logged and the running one kept.
+func reload(running *config.Config, logLevel *slog.LevelVar, application *app.App) {
+	next, err := config.Load()
+	if err != nil {
+		slog.Error("failed to reload configuration, keeping the running one", "error", err)
+		return
+	}
+
+	applied, restart := config.Changed(running, next)
+	if len(restart) > 0 {
+		slog.Warn("changed settings ignored until restart", "ignored", restart)
+	}
+
+	level, err := logging.ParseLevel(next.Log.Level)
+	if err != nil {
+		slog.Error("failed to reload configuration, keeping the running one", "error", err)
+		return
+	}
+	logLevel.Set(level)
+	application.Reload(next)
+
+	running.Log.Level = next.Log.Level
+	running.CORS = next.CORS
+	slog.Info("configuration reloaded", "applied", applied)
+}
+
+// connect opens the configured database
+func connect(cfg *config.Config) (*database.DB, error) {
+	opts := database.Options{
+		JournalMode:  cfg.Database.JournalMode,
+		Synchronous:  cfg.Database.Synchronous,
+		BusyTimeout:  cfg.Database.BusyTimeout,
+		CacheSizeKiB: cfg.Database.CacheSizeKiB,
+		ForeignKeys:  cfg.Database.ForeignKeys,
+		MaxOpenConns: cfg.Database.MaxOpenConns,
+		MaxIdleConns: cfg.Database.MaxIdleConns,
+		Trace:        cfg.Tracing.Enabled,
+	}
 
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if cfg.Database.Driver == "postgres" {
+		return database.ConnectPostgres(cfg.Database.URL, opts)
 	}
+	return database.Connect(cfg.Database.Path, opts)
 }
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveHandler struct {
+	service services.ArchiveServiceInterface
+}
+
+func NewArchiveHandler(service services.ArchiveServiceInterface) *ArchiveHandler {
+	return &ArchiveHandler{service: service}
+}
+
+// Archive godoc
+// @Summary      Archive metrics to Parquet
+// @Description  Write metrics in a time range, joined with their host, to one Parquet file per UTC month under ARCHIVE_DIR. With delete set, the archived metrics are removed once every file has been written.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request  body  models.ArchiveRequest  true  "Time range to archive"
+// @Success      200  {object}  models.ArchiveResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Router       /admin/archive [post]
+func (handler *ArchiveHandler) Archive(ctx *gin.Context) {
+	var requestBody struct {
+		Archive entities.ArchiveRequest `json:"archive"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.service.Archive(ctx.Request.Context(), &requestBody.Archive)
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, archiveErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to archive metrics",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	files := make([]models.ArchiveFile, len(result.Files))
+	for i, file := range result.Files {
+		files[i] = models.ArchiveFile{
+			Path:           file.Path,
+			Month:          file.Month,
+			Rows:           file.Rows,
+			FirstTimestamp: file.FirstTimestamp,
+			LastTimestamp:  file.LastTimestamp,
+		}
+	}
+
+	ctx.JSON(200, models.ArchiveResponse{
+		Files:   files,
+		Rows:    result.Rows,
+		Deleted: result.Deleted,
+	})
+}
+
+// Import godoc
+// @Summary      Import Parquet archives
+// @Description  Load an archive file, or every .parquet file below a directory, from ARCHIVE_DIR back into the database. Hosts are matched by hostname and created when missing; metrics already stored for the same host and timestamp are skipped, so imports can be repeated safely.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request  body  models.ImportRequest  true  "Archive path relative to ARCHIVE_DIR"
+// @Success      200  {object}  models.ImportResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Failure      507  {object}  models.ErrorResponse
+// @Router       /admin/archive/import [post]
+func (handler *ArchiveHandler) Import(ctx *gin.Context) {
+	var requestBody struct {
+		Import entities.ImportRequest `json:"import"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	result, err := handler.service.Import(ctx.Request.Context(), &requestBody.Import)
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, archiveErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to import archive",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, models.ImportResponse{
+		Files:        result.Files,
+		Rows:         result.Rows,
+		Inserted:     result.Inserted,
+		Skipped:      result.Skipped,
+		HostsCreated: result.HostsCreated,
+	})
+}
+
+// archiveErrorStatus maps archive service errors to HTTP status codes
+func archiveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrArchiveDisabled):
+		return 503
+	case errors.Is(err, services.ErrLowDiskSpace):
+		return 507
+	case errors.Is(err, services.ErrArchiveNotFound):
+		return 404
+	case errors.Is(err, services.ErrArchiveInProgress),
+		errors.Is(err, services.ErrArchiveExists):
+		return 409
+	case errors.Is(err, services.ErrInvalidTimeRange),
+		errors.Is(err, services.ErrInvalidArchivePath),
+		errors.Is(err, services.ErrInvalidArchiveFile):
+		return 400
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,240 @@
+// nolint
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// ArchiveHandlerTestSuite is the test suite for ArchiveHandler
+type ArchiveHandlerTestSuite struct {
+	suite.Suite
+	router      *gin.Engine
+	mockService *mocks.MockArchiveService
+	handler     *ArchiveHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ArchiveHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockService = new(mocks.MockArchiveService)
+	suite.handler = NewArchiveHandler(suite.mockService)
+
+	// Register routes
+	suite.router.POST("/admin/archive", suite.handler.Archive)
+	suite.router.POST("/admin/archive/import", suite.handler.Import)
+}
+
+// TearDownTest runs after each test
+func (suite *ArchiveHandlerTestSuite) TearDownTest() {
+	suite.mockService.AssertExpectations(suite.T())
+}
+
+// post sends a JSON body, or a raw string, to the given path
+func (suite *ArchiveHandlerTestSuite) post(path string, requestBody interface{}) *httptest.ResponseRecorder {
+	var body []byte
+	if str, ok := requestBody.(string); ok {
+		body = []byte(str)
+	} else {
+		body, _ = json.Marshal(requestBody)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestArchive tests the Archive endpoint
+func (suite *ArchiveHandlerTestSuite) TestArchive() {
+	validBody := map[string]interface{}{
+		"archive": map[string]interface{}{"start_time": 1000, "end_time": 2000, "delete": true},
+	}
+	validRequest := &entities.ArchiveRequest{StartTime: 1000, EndTime: 2000, Delete: true}
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name:        "successful_archive",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Archive", mock.Anything, validRequest).Return(&entities.ArchiveResult{
+					Files:   []entities.ArchiveFile{{Path: "year=1970/month=01/metrics-1000-2000.parquet", Month: "1970-01", Rows: 2}},
+					Rows:    2,
+					Deleted: 2,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid_json_body",
+			requestBody:    "invalid json",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "invalid_time_range",
+			requestBody: map[string]interface{}{"archive": map[string]interface{}{"start_time": 2000, "end_time": 1000}},
+			setupMock: func() {
+				suite.mockService.On("Archive", mock.Anything, &entities.ArchiveRequest{StartTime: 2000, EndTime: 1000}).
+					Return(nil, services.ErrInvalidTimeRange).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "archive_exists",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Archive", mock.Anything, validRequest).
+					Return(nil, fmt.Errorf("%w: year=1970/month=01/metrics-1000-2000.parquet", services.ErrArchiveExists)).Once()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:        "archiving_disabled",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Archive", mock.Anything, validRequest).Return(nil, services.ErrArchiveDisabled).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:        "service_error",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Archive", mock.Anything, validRequest).Return(nil, errors.New("disk full")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			w := suite.post("/admin/archive", test.requestBody)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusOK {
+				var response models.ArchiveResponse
+				assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(suite.T(), int64(2), response.Deleted)
+				assert.Equal(suite.T(), "1970-01", response.Files[0].Month)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestImport tests the Import endpoint
+func (suite *ArchiveHandlerTestSuite) TestImport() {
+	validBody := map[string]interface{}{"import": map[string]interface{}{"path": "year=2025"}}
+	validRequest := &entities.ImportRequest{Path: "year=2025"}
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name:        "successful_import",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Import", mock.Anything, validRequest).Return(&entities.ImportResult{
+					Files:    []string{"year=2025/month=01/metrics-1-2.parquet"},
+					Rows:     3,
+					Inserted: 2,
+					Skipped:  1,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid_json_body",
+			requestBody:    "invalid json",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "path_outside_archive",
+			requestBody: map[string]interface{}{"import": map[string]interface{}{"path": "../etc"}},
+			setupMock: func() {
+				suite.mockService.On("Import", mock.Anything, &entities.ImportRequest{Path: "../etc"}).Return(nil, services.ErrInvalidArchivePath).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "not_found",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Import", mock.Anything, validRequest).Return(nil, services.ErrArchiveNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "already_running",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Import", mock.Anything, validRequest).Return(nil, services.ErrArchiveInProgress).Once()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:        "paused_for_disk_space",
+			requestBody: validBody,
+			setupMock: func() {
+				suite.mockService.On("Import", mock.Anything, validRequest).Return(nil, services.ErrLowDiskSpace).Once()
+			},
+			expectedStatus: http.StatusInsufficientStorage,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			w := suite.post("/admin/archive/import", test.requestBody)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusOK {
+				var response models.ImportResponse
+				assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(suite.T(), int64(2), response.Inserted)
+				assert.Equal(suite.T(), int64(1), response.Skipped)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// Run the test suite
+func TestArchiveHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveHandlerTestSuite))
+}
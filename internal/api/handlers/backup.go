@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type BackupHandler struct {
+	service services.BackupServiceInterface
+}
+
+func NewBackupHandler(service services.BackupServiceInterface) *BackupHandler {
+	return &BackupHandler{service: service}
+}
+
+// Create godoc
+// @Summary      Back up the database
+// @Description  Take a consistent snapshot of the live database into BACKUP_DIR without blocking writers. The oldest backups beyond BACKUP_KEEP are removed afterwards.
+// @Tags         admin
+// @Produce      json
+// @Success      201  {object}  models.BackupResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Router       /admin/backups [post]
+func (handler *BackupHandler) Create(ctx *gin.Context) {
+	backup, err := handler.service.CreateBackup(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, backupErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to back up database",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(201, models.BackupResponse{Backup: toModelBackup(*backup)})
+}
+
+// Get godoc
+// @Summary      List database backups
+// @Description  List the backups in BACKUP_DIR, newest first
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  models.BackupListResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Router       /admin/backups [get]
+func (handler *BackupHandler) Get(ctx *gin.Context) {
+	backups, err := handler.service.ListBackups()
+	if err != nil {
+		ctx.JSON(backupErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to list backups",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	modelBackups := make([]models.Backup, len(backups))
+	for i, backup := range backups {
+		modelBackups[i] = toModelBackup(backup)
+	}
+
+	ctx.JSON(200, models.BackupListResponse{
+		Backups: modelBackups,
+		Meta: models.Meta{
+			Count: len(modelBackups),
+		},
+	})
+}
+
+func toModelBackup(backup entities.Backup) models.Backup {
+	return models.Backup{
+		Name:      backup.Name,
+		SizeBytes: backup.SizeBytes,
+		CreatedAt: backup.CreatedAt,
+	}
+}
+
+// backupErrorStatus maps backup service errors to HTTP status codes
+func backupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBackupDisabled):
+		return 503
+	case errors.Is(err, services.ErrBackupInProgress),
+		errors.Is(err, services.ErrBackupExists):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,139 @@
+// nolint
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// BackupHandlerTestSuite is the test suite for BackupHandler
+type BackupHandlerTestSuite struct {
+	suite.Suite
+	router      *gin.Engine
+	mockService *mocks.MockBackupService
+	handler     *BackupHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *BackupHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockService = new(mocks.MockBackupService)
+	suite.handler = NewBackupHandler(suite.mockService)
+
+	// Register routes
+	suite.router.POST("/admin/backups", suite.handler.Create)
+	suite.router.GET("/admin/backups", suite.handler.Get)
+}
+
+// TearDownTest runs after each test
+func (suite *BackupHandlerTestSuite) TearDownTest() {
+	suite.mockService.AssertExpectations(suite.T())
+}
+
+// TestCreate tests the Create endpoint
+func (suite *BackupHandlerTestSuite) TestCreate() {
+	tests := []struct {
+		name           string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "successful_backup",
+			setupMock: func() {
+				suite.mockService.On("CreateBackup", mock.Anything).
+					Return(&entities.Backup{Name: "monitor-20251018T120000Z.db", SizeBytes: 4096, CreatedAt: 1760788800}, nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "backups_disabled",
+			setupMock: func() {
+				suite.mockService.On("CreateBackup", mock.Anything).Return(nil, services.ErrBackupDisabled).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "backup_running",
+			setupMock: func() {
+				suite.mockService.On("CreateBackup", mock.Anything).Return(nil, services.ErrBackupInProgress).Once()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "snapshot_error",
+			setupMock: func() {
+				suite.mockService.On("CreateBackup", mock.Anything).Return(nil, errors.New("disk I/O error")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/backups", nil)
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusCreated {
+				var response models.BackupResponse
+				assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(suite.T(), "monitor-20251018T120000Z.db", response.Backup.Name)
+				assert.Equal(suite.T(), int64(4096), response.Backup.SizeBytes)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGet tests the Get endpoint
+func (suite *BackupHandlerTestSuite) TestGet() {
+	suite.mockService.On("ListBackups").Return([]entities.Backup{
+		{Name: "monitor-20251018T120000Z.db", SizeBytes: 4096, CreatedAt: 1760788800},
+		{Name: "monitor-20251017T120000Z.db", SizeBytes: 2048, CreatedAt: 1760702400},
+	}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/backups", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response models.BackupListResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(suite.T(), response.Backups, 2)
+	assert.Equal(suite.T(), 2, response.Meta.Count)
+
+	suite.TearDownTest()
+	suite.SetupTest()
+
+	suite.mockService.On("ListBackups").Return(nil, services.ErrBackupDisabled).Once()
+
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+}
+
+// Run the test suite
+func TestBackupHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupHandlerTestSuite))
+}
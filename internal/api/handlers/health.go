@@ -3,6 +3,7 @@ package handlers
 import (
 	"time"
 
+	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/models"
 	"github.com/gabrielg2020/monitor-api/internal/services"
 	"github.com/gin-gonic/gin"
@@ -30,7 +31,7 @@ func (handler *HealthHandler) GetHealth(ctx *gin.Context) {
 	status := "healthy"
 	statusCode := 200
 
-	if err := handler.service.CheckHealth(); err != nil {
+	if err := handler.service.CheckHealth(ctx.Request.Context()); err != nil {
 		status = "unhealthy"
 		checks["database"] = "unhealthy: " + err.Error()
 		statusCode = 503
@@ -45,35 +46,78 @@ func (handler *HealthHandler) GetHealth(ctx *gin.Context) {
 	})
 }
 
+// GetLiveness godoc
+// @Summary      Liveness probe
+// @Description  Reports that the process is up without checking its dependencies, for Kubernetes liveness probes
+// @Tags         system
+// @Produce      json
+// @Success      200  {object}  models.LivenessResponse
+// @Router       /health/live [get]
+func (handler *HealthHandler) GetLiveness(ctx *gin.Context) {
+	ctx.JSON(200, models.LivenessResponse{
+		Status:    "alive",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetReadiness godoc
+// @Summary      Readiness probe
+// @Description  Runs every registered health check. Failing critical checks return 503; failing warning checks report the API as degraded but keep it ready.
+// @Tags         system
+// @Produce      json
+// @Success      200  {object}  models.ReadinessResponse
+// @Failure      503  {object}  models.ReadinessResponse
+// @Router       /health/ready [get]
+func (handler *HealthHandler) GetReadiness(ctx *gin.Context) {
+	report := handler.service.CheckReadiness(ctx.Request.Context())
+
+	ctx.JSON(healthStatusCode(report.Status), toModelReadiness(report))
+}
+
 // GetDetailedHealth godoc
 // @Summary      Detailed health check
-// @Description  Get detailed health information including database stats and table counts
+// @Description  Get every health check's result with database stats, settings and table counts
 // @Tags         system
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  object{status=string,timestamp=string,database=object,database_stats=object,table_counts=object}
-// @Failure      503  {object}  models.ErrorResponse
+// @Success      200  {object}  models.DetailedHealthResponse
+// @Failure      503  {object}  models.DetailedHealthResponse
 // @Router       /health/detailed [get]
 func (handler *HealthHandler) GetDetailedHealth(ctx *gin.Context) {
-	status := "healthy"
-	statusCode := 200
+	health := handler.service.GetDetailedHealth(ctx.Request.Context())
 
-	health, err := handler.service.GetDetailedHealth()
-	if err != nil {
-		status = "unhealthy"
-		statusCode = 503
-	}
+	ctx.JSON(healthStatusCode(health.Status), models.DetailedHealthResponse{
+		ReadinessResponse: toModelReadiness(&health.HealthReport),
+		DatabaseStats:     health.DatabaseStats,
+		DatabaseSettings:  health.DatabaseSettings,
+		TableCounts:       health.TableCounts,
+	})
+}
 
-	// Add status and timestamp
-	response := gin.H{
-		"status":    status,
-		"timestamp": time.Now().Format(time.RFC3339),
+// healthStatusCode maps a health status to 503 when the API can't serve requests
+func healthStatusCode(status string) int {
+	if status == entities.HealthStatusUnhealthy {
+		return 503
 	}
+	return 200
+}
 
-	// Merge detailed health info
-	for key, value := range health {
-		response[key] = value
+func toModelReadiness(report *entities.HealthReport) models.ReadinessResponse {
+	checks := make([]models.HealthCheck, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		checks = append(checks, models.HealthCheck{
+			Name:       check.Name,
+			Severity:   check.Severity,
+			Status:     check.Status,
+			Error:      check.Error,
+			DurationMs: check.DurationMs,
+			Details:    check.Details,
+		})
 	}
 
-	ctx.JSON(statusCode, response)
+	return models.ReadinessResponse{
+		Status:    report.Status,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Checks:    checks,
+	}
 }
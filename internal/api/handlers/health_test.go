@@ -8,10 +8,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/models"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -32,6 +34,8 @@ func (suite *HealthHandlerTestSuite) SetupTest() {
 
 	// Register routes
 	suite.router.GET("/health", suite.handler.GetHealth)
+	suite.router.GET("/health/live", suite.handler.GetLiveness)
+	suite.router.GET("/health/ready", suite.handler.GetReadiness)
 	suite.router.GET("/health/detailed", suite.handler.GetDetailedHealth)
 }
 
@@ -59,7 +63,7 @@ func (suite *HealthHandlerTestSuite) TestGetHealth() {
 		{
 			name: "healthy_database",
 			setupMock: func() {
-				suite.mockService.On("CheckHealth").Return(nil).Once()
+				suite.mockService.On("CheckHealth", mock.Anything).Return(nil).Once()
 			},
 			expectedStatus:     http.StatusOK,
 			expectedHealthy:    true,
@@ -69,7 +73,7 @@ func (suite *HealthHandlerTestSuite) TestGetHealth() {
 		{
 			name: "unhealthy_database",
 			setupMock: func() {
-				suite.mockService.On("CheckHealth").Return(errors.New("connection timeout")).Once()
+				suite.mockService.On("CheckHealth", mock.Anything).Return(errors.New("connection timeout")).Once()
 			},
 			expectedStatus:     http.StatusServiceUnavailable,
 			expectedHealthy:    false,
@@ -79,7 +83,7 @@ func (suite *HealthHandlerTestSuite) TestGetHealth() {
 		{
 			name: "database_connection_refused",
 			setupMock: func() {
-				suite.mockService.On("CheckHealth").Return(errors.New("connection refused")).Once()
+				suite.mockService.On("CheckHealth", mock.Anything).Return(errors.New("connection refused")).Once()
 			},
 			expectedStatus:     http.StatusServiceUnavailable,
 			expectedHealthy:    false,
@@ -131,162 +135,166 @@ func (suite *HealthHandlerTestSuite) TestGetHealth() {
 	}
 }
 
-// TestGetDetailedHealth tests the GetDetailedHealth endpoint
-func (suite *HealthHandlerTestSuite) TestGetDetailedHealth() {
+// TestGetLiveness tests that the liveness probe never touches the health service
+func (suite *HealthHandlerTestSuite) TestGetLiveness() {
+	req, err := http.NewRequest(http.MethodGet, "/health/live", nil)
+	assert.NoError(suite.T(), err)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response models.LivenessResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "alive", response.Status)
+	assert.NotEmpty(suite.T(), response.Timestamp)
+	suite.mockService.AssertNotCalled(suite.T(), "CheckReadiness", mock.Anything)
+}
+
+// TestGetReadiness tests the GetReadiness endpoint
+func (suite *HealthHandlerTestSuite) TestGetReadiness() {
 	tests := []struct {
 		name           string
-		setupMock      func()
+		report         *entities.HealthReport
 		expectedStatus int
-		expectedHealth bool
-		checkResponse  func(*testing.T, map[string]interface{})
 	}{
 		{
-			name: "healthy_with_detailed_info",
-			setupMock: func() {
-				detailedHealth := map[string]interface{}{
-					"database": map[string]interface{}{
-						"status": "connected",
-					},
-					"database_stats": map[string]interface{}{
-						"open_connections": 1,
-						"in_use":           0,
-						"idle":             1,
-						"max_open":         10,
-					},
-					"table_counts": map[string]interface{}{
-						"hosts":   5,
-						"metrics": 100,
-					},
-				}
-				suite.mockService.On("GetDetailedHealth").Return(detailedHealth, nil).Once()
+			name: "healthy",
+			report: &entities.HealthReport{
+				Status: entities.HealthStatusHealthy,
+				Checks: []entities.HealthCheckResult{
+					{Name: "database", Severity: entities.SeverityCritical, Status: entities.HealthStatusHealthy, DurationMs: 0.4},
+				},
 			},
 			expectedStatus: http.StatusOK,
-			expectedHealth: true,
-			checkResponse: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "healthy", response["status"])
-				assert.NotEmpty(t, response["timestamp"])
-
-				// Check database info
-				database, ok := response["database"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, "connected", database["status"])
-
-				// Check database stats
-				dbStats, ok := response["database_stats"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, float64(1), dbStats["open_connections"])
-				assert.Equal(t, float64(0), dbStats["in_use"])
-				assert.Equal(t, float64(1), dbStats["idle"])
-				assert.Equal(t, float64(10), dbStats["max_open"])
-
-				// Check table counts
-				tableCounts, ok := response["table_counts"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, float64(5), tableCounts["hosts"])
-				assert.Equal(t, float64(100), tableCounts["metrics"])
+		},
+		{
+			name: "degraded_stays_ready",
+			report: &entities.HealthReport{
+				Status: entities.HealthStatusDegraded,
+				Checks: []entities.HealthCheckResult{
+					{Name: "database", Severity: entities.SeverityCritical, Status: entities.HealthStatusHealthy},
+					{Name: "scraper", Severity: entities.SeverityWarning, Status: entities.HealthStatusDegraded, Error: "scraper has not run for 45s"},
+				},
 			},
+			expectedStatus: http.StatusOK,
 		},
 		{
-			name: "unhealthy_with_error",
-			setupMock: func() {
-				suite.mockService.On("GetDetailedHealth").Return(nil, errors.New("database error")).Once()
+			name: "unhealthy_is_not_ready",
+			report: &entities.HealthReport{
+				Status: entities.HealthStatusUnhealthy,
+				Checks: []entities.HealthCheckResult{
+					{Name: "database", Severity: entities.SeverityCritical, Status: entities.HealthStatusUnhealthy, Error: "connection refused"},
+				},
 			},
 			expectedStatus: http.StatusServiceUnavailable,
-			expectedHealth: false,
-			checkResponse: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "unhealthy", response["status"])
-				assert.NotEmpty(t, response["timestamp"])
-			},
 		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mockService.On("CheckReadiness", mock.Anything).Return(test.report).Once()
+
+			req, err := http.NewRequest(http.MethodGet, "/health/ready", nil)
+			assert.NoError(suite.T(), err)
+
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+
+			var response models.ReadinessResponse
+			assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(suite.T(), test.report.Status, response.Status)
+			assert.NotEmpty(suite.T(), response.Timestamp)
+			assert.Len(suite.T(), response.Checks, len(test.report.Checks))
+			for i, check := range test.report.Checks {
+				assert.Equal(suite.T(), check.Name, response.Checks[i].Name)
+				assert.Equal(suite.T(), check.Severity, response.Checks[i].Severity)
+				assert.Equal(suite.T(), check.Status, response.Checks[i].Status)
+				assert.Equal(suite.T(), check.Error, response.Checks[i].Error)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGetDetailedHealth tests the GetDetailedHealth endpoint
+func (suite *HealthHandlerTestSuite) TestGetDetailedHealth() {
+	tests := []struct {
+		name           string
+		health         *entities.DetailedHealth
+		expectedStatus int
+		checkResponse  func(*testing.T, models.DetailedHealthResponse)
+	}{
 		{
-			name: "healthy_with_empty_tables",
-			setupMock: func() {
-				detailedHealth := map[string]interface{}{
-					"database": map[string]interface{}{
-						"status": "connected",
-					},
-					"database_stats": map[string]interface{}{
-						"open_connections": 1,
-						"in_use":           0,
-						"idle":             1,
-						"max_open":         10,
-					},
-					"table_counts": map[string]interface{}{
-						"hosts":   0,
-						"metrics": 0,
+			name: "healthy_with_detailed_info",
+			health: &entities.DetailedHealth{
+				HealthReport: entities.HealthReport{
+					Status: entities.HealthStatusHealthy,
+					Checks: []entities.HealthCheckResult{
+						{Name: "database", Severity: entities.SeverityCritical, Status: entities.HealthStatusHealthy},
+						{Name: "migrations", Severity: entities.SeverityCritical, Status: entities.HealthStatusHealthy, Details: map[string]interface{}{"version": 3, "expected": 3}},
 					},
-				}
-				suite.mockService.On("GetDetailedHealth").Return(detailedHealth, nil).Once()
+				},
+				DatabaseStats:    map[string]interface{}{"open_connections": 1, "in_use": 0, "idle": 1, "max_open": 10},
+				DatabaseSettings: map[string]interface{}{"journal_mode": "wal"},
+				TableCounts:      map[string]int{"hosts": 5, "system_metrics": 100},
 			},
 			expectedStatus: http.StatusOK,
-			expectedHealth: true,
-			checkResponse: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "healthy", response["status"])
-
-				tableCounts, ok := response["table_counts"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, float64(0), tableCounts["hosts"])
-				assert.Equal(t, float64(0), tableCounts["metrics"])
+			checkResponse: func(t *testing.T, response models.DetailedHealthResponse) {
+				assert.Equal(t, "healthy", response.Status)
+				assert.NotEmpty(t, response.Timestamp)
+				assert.Len(t, response.Checks, 2)
+				assert.Equal(t, float64(3), response.Checks[1].Details["version"])
+				assert.Equal(t, float64(10), response.DatabaseStats["max_open"])
+				assert.Equal(t, "wal", response.DatabaseSettings["journal_mode"])
+				assert.Equal(t, map[string]int{"hosts": 5, "system_metrics": 100}, response.TableCounts)
 			},
 		},
 		{
-			name: "healthy_with_high_connection_count",
-			setupMock: func() {
-				detailedHealth := map[string]interface{}{
-					"database": map[string]interface{}{
-						"status": "connected",
-					},
-					"database_stats": map[string]interface{}{
-						"open_connections": 50,
-						"in_use":           25,
-						"idle":             25,
-						"max_open":         100,
+			name: "degraded_is_still_200",
+			health: &entities.DetailedHealth{
+				HealthReport: entities.HealthReport{
+					Status: entities.HealthStatusDegraded,
+					Checks: []entities.HealthCheckResult{
+						{Name: "ingest_lag", Severity: entities.SeverityWarning, Status: entities.HealthStatusDegraded, Error: "no metrics received for 15m0s"},
 					},
-					"table_counts": map[string]interface{}{
-						"hosts":          1000,
-						"system_metrics": 50000,
-					},
-				}
-				suite.mockService.On("GetDetailedHealth").Return(detailedHealth, nil).Once()
+				},
 			},
 			expectedStatus: http.StatusOK,
-			expectedHealth: true,
-			checkResponse: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "healthy", response["status"])
-
-				dbStats, ok := response["database_stats"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, float64(50), dbStats["open_connections"])
-				assert.Equal(t, float64(25), dbStats["in_use"])
-				assert.Equal(t, float64(25), dbStats["idle"])
-				assert.Equal(t, float64(100), dbStats["max_open"])
+			checkResponse: func(t *testing.T, response models.DetailedHealthResponse) {
+				assert.Equal(t, "degraded", response.Status)
+				assert.Equal(t, "no metrics received for 15m0s", response.Checks[0].Error)
 			},
 		},
 		{
-			name: "partial_data_returned",
-			setupMock: func() {
-				detailedHealth := map[string]interface{}{
-					"database": map[string]interface{}{
-						"status": "connected",
+			name: "unhealthy_without_stats",
+			health: &entities.DetailedHealth{
+				HealthReport: entities.HealthReport{
+					Status: entities.HealthStatusUnhealthy,
+					Checks: []entities.HealthCheckResult{
+						{Name: "database", Severity: entities.SeverityCritical, Status: entities.HealthStatusUnhealthy, Error: "database error"},
 					},
-				}
-				suite.mockService.On("GetDetailedHealth").Return(detailedHealth, nil).Once()
+				},
 			},
-			expectedStatus: http.StatusOK,
-			expectedHealth: true,
-			checkResponse: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "healthy", response["status"])
-
-				database, ok := response["database"].(map[string]interface{})
-				assert.True(t, ok)
-				assert.Equal(t, "connected", database["status"])
+			expectedStatus: http.StatusServiceUnavailable,
+			checkResponse: func(t *testing.T, response models.DetailedHealthResponse) {
+				assert.Equal(t, "unhealthy", response.Status)
+				assert.Equal(t, "database error", response.Checks[0].Error)
+				assert.Nil(t, response.DatabaseStats)
+				assert.Nil(t, response.TableCounts)
 			},
 		},
 	}
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
-			test.setupMock()
+			suite.mockService.On("GetDetailedHealth", mock.Anything).Return(test.health).Once()
 
 			// Create request
 			req, err := http.NewRequest(http.MethodGet, "/health/detailed", nil)
@@ -302,7 +310,7 @@ func (suite *HealthHandlerTestSuite) TestGetDetailedHealth() {
 			assert.Equal(suite.T(), test.expectedStatus, w.Code)
 
 			// Parse response
-			var response map[string]interface{}
+			var response models.DetailedHealthResponse
 			err = json.Unmarshal(w.Body.Bytes(), &response)
 			assert.NoError(suite.T(), err)
 
@@ -318,7 +326,7 @@ func (suite *HealthHandlerTestSuite) TestGetDetailedHealth() {
 
 // TestGetHealthContentType tests that the correct content type is returned
 func (suite *HealthHandlerTestSuite) TestGetHealthContentType() {
-	suite.mockService.On("CheckHealth").Return(nil).Once()
+	suite.mockService.On("CheckHealth", mock.Anything).Return(nil).Once()
 
 	req, err := http.NewRequest(http.MethodGet, "/health", nil)
 	assert.NoError(suite.T(), err)
@@ -331,12 +339,10 @@ func (suite *HealthHandlerTestSuite) TestGetHealthContentType() {
 
 // TestGetDetailedHealthContentType tests that the correct content type is returned
 func (suite *HealthHandlerTestSuite) TestGetDetailedHealthContentType() {
-	detailedHealth := map[string]interface{}{
-		"database": map[string]interface{}{
-			"status": "connected",
-		},
+	detailedHealth := &entities.DetailedHealth{
+		HealthReport: entities.HealthReport{Status: entities.HealthStatusHealthy},
 	}
-	suite.mockService.On("GetDetailedHealth").Return(detailedHealth, nil).Once()
+	suite.mockService.On("GetDetailedHealth", mock.Anything).Return(detailedHealth).Once()
 
 	req, err := http.NewRequest(http.MethodGet, "/health/detailed", nil)
 	assert.NoError(suite.T(), err)
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gin-gonic/gin"
 )
 
 // toModelHost converts entity to model
@@ -14,6 +18,7 @@ func toModelHost(host entities.Host) models.Host {
 		Hostname:  host.Hostname,
 		IPAddress: host.IPAddress,
 		Role:      host.Role,
+		ClockSkew: (*models.ClockSkew)(host.ClockSkew),
 	}
 }
 
@@ -32,9 +37,54 @@ func toModelMetric(metric entities.SystemMetric) models.SystemMetric {
 		DiskTotalBytes:       metric.DiskTotalBytes,
 		DiskUsedBytes:        metric.DiskUsedBytes,
 		DiskAvailableBytes:   metric.DiskAvailableBytes,
+		ReceivedAt:           metric.ReceivedAt,
 	}
 }
 
+// toModelViolations converts entities to models
+func toModelViolations(violations []entities.FieldViolation) []models.FieldViolation {
+	if len(violations) == 0 {
+		return nil
+	}
+	converted := make([]models.FieldViolation, len(violations))
+	for i, violation := range violations {
+		converted[i] = models.FieldViolation(violation)
+	}
+	return converted
+}
+
+// toModelScrapeTarget converts entity to model
+func toModelScrapeTarget(target entities.ScrapeTarget) models.ScrapeTarget {
+	return models.ScrapeTarget{
+		ID:                  target.ID,
+		Name:                target.Name,
+		URL:                 target.URL,
+		HostID:              target.HostID,
+		Format:              target.Format,
+		IntervalSeconds:     target.IntervalSeconds,
+		TimeoutSeconds:      target.TimeoutSeconds,
+		JSONMapping:         target.JSONMapping,
+		Paused:              target.Paused,
+		LastScrapeAt:        target.LastScrapeAt,
+		LastStatus:          target.LastStatus,
+		LastError:           target.LastError,
+		LastDurationMs:      target.LastDurationMs,
+		ConsecutiveFailures: target.ConsecutiveFailures,
+	}
+}
+
+// parseIDParam parses the :id path parameter
+func parseIDParam(ctx *gin.Context) (int64, *models.ErrorResponse) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, &models.ErrorResponse{
+			Error:   "Invalid ID",
+			Details: "ID must be a positive integer",
+		}
+	}
+	return id, nil
+}
+
 // setMetricQueryDefaults validates and sets defaults for metric query params
 func setMetricQueryDefaults(params *entities.MetricQueryParams) *models.ErrorResponse {
 	// Set defaults
@@ -44,6 +94,31 @@ func setMetricQueryDefaults(params *entities.MetricQueryParams) *models.ErrorRes
 	if params.Limit > 1000 {
 		params.Limit = 1000
 	}
+
+	return normaliseMetricOrder(params)
+}
+
+// setMetricExportDefaults validates and sets defaults for metric exports, which
+// are not paginated and have no row cap
+func setMetricExportDefaults(params *entities.MetricQueryParams) *models.ErrorResponse {
+	if params.Limit < 0 {
+		return &models.ErrorResponse{
+			Error:   "Invalid limit parameter",
+			Details: "Must not be negative",
+		}
+	}
+	if params.Cursor != "" {
+		return &models.ErrorResponse{
+			Error:   "Invalid cursor parameter",
+			Details: "Exports are not paginated",
+		}
+	}
+
+	return normaliseMetricOrder(params)
+}
+
+// normaliseMetricOrder defaults the sort order to DESC and validates it
+func normaliseMetricOrder(params *entities.MetricQueryParams) *models.ErrorResponse {
 	if params.Order == "" {
 		params.Order = "DESC"
 	} else {
@@ -58,3 +133,12 @@ func setMetricQueryDefaults(params *entities.MetricQueryParams) *models.ErrorRes
 
 	return nil
 }
+
+// deadlineStatus returns 504 in place of a 500 once the request's deadline has passed,
+// since the error is then the query being cancelled rather than a fault
+func deadlineStatus(ctx *gin.Context, status int) int {
+	if status == 500 && errors.Is(ctx.Request.Context().Err(), context.DeadlineExceeded) {
+		return 504
+	}
+	return status
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
@@ -27,6 +28,7 @@ func NewHostHandler(service services.HostServiceInterface) *HostHandler {
 // @Success      201  {object}  object{message=string,id=int64}
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /hosts [post]
 func (handler *HostHandler) Create(ctx *gin.Context) {
 	var requestBody struct {
@@ -41,9 +43,9 @@ func (handler *HostHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	id, err := handler.service.CreateHost(&requestBody.Host)
+	id, err := handler.service.CreateHost(ctx.Request.Context(), &requestBody.Host)
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to create host",
 			Details: err.Error(),
 		})
@@ -58,16 +60,20 @@ func (handler *HostHandler) Create(ctx *gin.Context) {
 
 // Get List godoc
 // @Summary      List all hosts
-// @Description  Get a list of all registered hosts in the monitoring system
+// @Description  Get a list of all registered hosts in the monitoring system. Hosts that have pushed metrics since the API started include their estimated clock skew.
 // @Tags         hosts
 // @Accept       json
 // @Produce      json
 // @Param        id          query  int     false  "Filter by host ID"
 // @Param        hostname    query  string  false  "Filter by hostname"
 // @Param        ip_address  query  string  false  "Filter by IP address"
+// @Param        role        query  string  false  "Filter by role"
+// @Param        limit       query  int     false  "Page size (max 1000); all hosts are returned when omitted"
+// @Param        cursor      query  string  false  "Continue from the next_cursor of a previous page"
 // @Success      200  {object}  models.HostListResponse
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /hosts [get]
 func (handler *HostHandler) Get(ctx *gin.Context) {
 	var queryParams entities.HostQueryParams
@@ -79,9 +85,27 @@ func (handler *HostHandler) Get(ctx *gin.Context) {
 		return
 	}
 
-	hosts, err := handler.service.GetHosts(&queryParams)
+	if queryParams.Limit < 0 {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: "limit must not be negative",
+		})
+		return
+	}
+	if queryParams.Limit > 1000 {
+		queryParams.Limit = 1000
+	}
+
+	hosts, page, err := handler.service.GetHosts(ctx.Request.Context(), &queryParams)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to retrieve hosts",
 			Details: err.Error(),
 		})
@@ -97,7 +121,10 @@ func (handler *HostHandler) Get(ctx *gin.Context) {
 	ctx.JSON(200, models.HostListResponse{
 		Hosts: modelHosts,
 		Meta: models.Meta{
-			Count: len(modelHosts),
+			Count:      len(modelHosts),
+			Limit:      queryParams.Limit,
+			NextCursor: page.NextCursor,
+			HasMore:    page.HasMore,
 		},
 	})
 }
@@ -114,6 +141,7 @@ func (handler *HostHandler) Get(ctx *gin.Context) {
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      404  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /hosts/{id} [put]
 func (handler *HostHandler) Update(ctx *gin.Context) {
 	id := ctx.Param("id")
@@ -139,9 +167,9 @@ func (handler *HostHandler) Update(ctx *gin.Context) {
 		return
 	}
 
-	err := handler.service.UpdateHost(hostID, &requestBody.Host)
+	err := handler.service.UpdateHost(ctx.Request.Context(), hostID, &requestBody.Host)
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to update host",
 			Details: err.Error(),
 		})
@@ -164,6 +192,7 @@ func (handler *HostHandler) Update(ctx *gin.Context) {
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      404  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /hosts/{id} [delete]
 func (handler *HostHandler) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
@@ -177,9 +206,9 @@ func (handler *HostHandler) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err := handler.service.DeleteHost(hostID)
+	err := handler.service.DeleteHost(ctx.Request.Context(), hostID)
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to delete host",
 			Details: err.Error(),
 		})
@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -11,9 +12,11 @@ import (
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -69,7 +72,7 @@ func (suite *HostHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateHost", &entities.Host{
+				suite.mockService.On("CreateHost", mock.Anything, &entities.Host{
 					Hostname:  "pi-monitor-01",
 					IPAddress: "192.168.1.100",
 					Role:      "monitor",
@@ -106,7 +109,7 @@ func (suite *HostHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateHost", &entities.Host{
+				suite.mockService.On("CreateHost", mock.Anything, &entities.Host{
 					Hostname: "pi-monitor-01",
 				}).Return(int64(0), errors.New("missing required fields")).Once()
 			},
@@ -128,7 +131,7 @@ func (suite *HostHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateHost", &entities.Host{
+				suite.mockService.On("CreateHost", mock.Anything, &entities.Host{
 					Hostname:  "existing-host",
 					IPAddress: "192.168.1.200",
 					Role:      "monitor",
@@ -153,7 +156,7 @@ func (suite *HostHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateHost", &entities.Host{
+				suite.mockService.On("CreateHost", mock.Anything, &entities.Host{
 					Hostname:  "pi-monitor-02",
 					IPAddress: "192.168.1.101",
 					Role:      "monitor",
@@ -223,9 +226,12 @@ func (suite *HostHandlerTestSuite) TestGet() {
 			setupMock: func() {
 				hosts := []entities.Host{
 					{ID: 1, Hostname: "pi-monitor-01", IPAddress: "192.168.1.100", Role: "monitor"},
-					{ID: 2, Hostname: "pi-monitor-02", IPAddress: "192.168.1.101", Role: "monitor"},
+					{
+						ID: 2, Hostname: "pi-monitor-02", IPAddress: "192.168.1.101", Role: "monitor",
+						ClockSkew: &entities.ClockSkew{Seconds: -42, Samples: 16, ObservedAt: 1729350001},
+					},
 				}
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{}).Return(hosts, nil).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{}).Return(hosts, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -236,6 +242,8 @@ func (suite *HostHandlerTestSuite) TestGet() {
 				assert.Equal(t, 2, response.Meta.Count)
 				assert.Equal(t, "pi-monitor-01", response.Hosts[0].Hostname)
 				assert.Equal(t, "pi-monitor-02", response.Hosts[1].Hostname)
+				assert.Nil(t, response.Hosts[0].ClockSkew)
+				assert.Equal(t, &models.ClockSkew{Seconds: -42, Samples: 16, ObservedAt: 1729350001}, response.Hosts[1].ClockSkew)
 			},
 		},
 		{
@@ -245,7 +253,7 @@ func (suite *HostHandlerTestSuite) TestGet() {
 				hosts := []entities.Host{
 					{ID: 1, Hostname: "pi-monitor-01", IPAddress: "192.168.1.100", Role: "monitor"},
 				}
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{ID: 1}).Return(hosts, nil).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{ID: 1}).Return(hosts, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -263,7 +271,7 @@ func (suite *HostHandlerTestSuite) TestGet() {
 				hosts := []entities.Host{
 					{ID: 1, Hostname: "pi-monitor-01", IPAddress: "192.168.1.100", Role: "monitor"},
 				}
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{Hostname: "pi-monitor-01"}).Return(hosts, nil).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Hostname: "pi-monitor-01"}).Return(hosts, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -281,7 +289,7 @@ func (suite *HostHandlerTestSuite) TestGet() {
 				hosts := []entities.Host{
 					{ID: 1, Hostname: "pi-monitor-01", IPAddress: "192.168.1.100", Role: "monitor"},
 				}
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{IPAddress: "192.168.1.100"}).Return(hosts, nil).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{IPAddress: "192.168.1.100"}).Return(hosts, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -297,7 +305,7 @@ func (suite *HostHandlerTestSuite) TestGet() {
 			queryParams: "",
 			setupMock: func() {
 				var hosts []entities.Host
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{}).Return(hosts, nil).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{}).Return(hosts, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -308,6 +316,49 @@ func (suite *HostHandlerTestSuite) TestGet() {
 				assert.Equal(t, 0, response.Meta.Count)
 			},
 		},
+		{
+			name:        "paginated_hosts",
+			queryParams: "?limit=5000&cursor=Mg",
+			setupMock: func() {
+				hosts := []entities.Host{{ID: 3, Hostname: "pi-monitor-03", IPAddress: "192.168.1.102", Role: "monitor"}}
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Limit: 1000, Cursor: "Mg"}).
+					Return(hosts, entities.PageInfo{NextCursor: "Mw", HasMore: true}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.HostListResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, models.Meta{Count: 1, Limit: 1000, NextCursor: "Mw", HasMore: true}, response.Meta)
+			},
+		},
+		{
+			name:        "invalid_cursor",
+			queryParams: "?cursor=bogus",
+			setupMock: func() {
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Cursor: "bogus"}).
+					Return(nil, entities.PageInfo{}, services.ErrInvalidCursor).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "Invalid query parameters", response.Error)
+			},
+		},
+		{
+			name:           "negative_limit",
+			queryParams:    "?limit=-1",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "Invalid query parameters", response.Error)
+			},
+		},
 		{
 			name:           "invalid_query_parameter",
 			queryParams:    "?id=invalid",
@@ -324,7 +375,7 @@ func (suite *HostHandlerTestSuite) TestGet() {
 			name:        "database_error",
 			queryParams: "",
 			setupMock: func() {
-				suite.mockService.On("GetHosts", &entities.HostQueryParams{}).Return(nil, errors.New("database connection lost")).Once()
+				suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{}).Return(nil, entities.PageInfo{}, errors.New("database connection lost")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -383,7 +434,7 @@ func (suite *HostHandlerTestSuite) TestUpdate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("UpdateHost", int64(1), &entities.Host{
+				suite.mockService.On("UpdateHost", mock.Anything, int64(1), &entities.Host{
 					Role: "updated-role",
 				}).Return(nil).Once()
 			},
@@ -436,7 +487,7 @@ func (suite *HostHandlerTestSuite) TestUpdate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("UpdateHost", int64(999), &entities.Host{
+				suite.mockService.On("UpdateHost", mock.Anything, int64(999), &entities.Host{
 					Role: "monitor",
 				}).Return(errors.New("host not found")).Once()
 			},
@@ -457,7 +508,7 @@ func (suite *HostHandlerTestSuite) TestUpdate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("UpdateHost", int64(1), &entities.Host{
+				suite.mockService.On("UpdateHost", mock.Anything, int64(1), &entities.Host{
 					Role: "monitor",
 				}).Return(errors.New("database connection lost")).Once()
 			},
@@ -523,7 +574,7 @@ func (suite *HostHandlerTestSuite) TestDelete() {
 			name:   "successful_deletion",
 			hostID: "1",
 			setupMock: func() {
-				suite.mockService.On("DeleteHost", int64(1)).Return(nil).Once()
+				suite.mockService.On("DeleteHost", mock.Anything, int64(1)).Return(nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -549,7 +600,7 @@ func (suite *HostHandlerTestSuite) TestDelete() {
 			name:   "host_not_found",
 			hostID: "999",
 			setupMock: func() {
-				suite.mockService.On("DeleteHost", int64(999)).Return(errors.New("host not found")).Once()
+				suite.mockService.On("DeleteHost", mock.Anything, int64(999)).Return(errors.New("host not found")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -563,7 +614,7 @@ func (suite *HostHandlerTestSuite) TestDelete() {
 			name:   "database_error",
 			hostID: "1",
 			setupMock: func() {
-				suite.mockService.On("DeleteHost", int64(1)).Return(errors.New("database connection lost")).Once()
+				suite.mockService.On("DeleteHost", mock.Anything, int64(1)).Return(errors.New("database connection lost")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -578,7 +629,7 @@ func (suite *HostHandlerTestSuite) TestDelete() {
 			name:   "foreign_key_constraint_error",
 			hostID: "1",
 			setupMock: func() {
-				suite.mockService.On("DeleteHost", int64(1)).Return(errors.New("FOREIGN KEY constraint failed")).Once()
+				suite.mockService.On("DeleteHost", mock.Anything, int64(1)).Return(errors.New("FOREIGN KEY constraint failed")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -618,12 +669,31 @@ func (suite *HostHandlerTestSuite) TestDelete() {
 	}
 }
 
+// TestGetTimeout tests that a query failing after the request deadline returns 504
+func (suite *HostHandlerTestSuite) TestGetTimeout() {
+	suite.mockService.On("GetHosts", mock.Anything, mock.AnythingOfType("*entities.HostQueryParams")).
+		Return(nil, entities.PageInfo{}, context.DeadlineExceeded).Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/hosts", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusGatewayTimeout, w.Code)
+
+	var response models.ErrorResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "Failed to retrieve hosts", response.Error)
+}
+
 // TestContentType tests that the correct content type is returned
 func (suite *HostHandlerTestSuite) TestContentType() {
 	hosts := []entities.Host{
 		{ID: 1, Hostname: "pi-monitor-01", IPAddress: "192.168.1.100", Role: "monitor"},
 	}
-	suite.mockService.On("GetHosts", &entities.HostQueryParams{}).Return(hosts, nil).Once()
+	suite.mockService.On("GetHosts", mock.Anything, &entities.HostQueryParams{}).Return(hosts, entities.PageInfo{}, nil).Once()
 
 	req, err := http.NewRequest(http.MethodGet, "/hosts", nil)
 	assert.NoError(suite.T(), err)
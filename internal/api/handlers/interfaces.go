@@ -2,9 +2,23 @@ package handlers
 
 import "github.com/gin-gonic/gin"
 
+// ArchiveHandlerInterface defines methods for Parquet archive handlers
+type ArchiveHandlerInterface interface {
+	Archive(ctx *gin.Context)
+	Import(ctx *gin.Context)
+}
+
+// BackupHandlerInterface defines methods for database backup handlers
+type BackupHandlerInterface interface {
+	Create(ctx *gin.Context)
+	Get(ctx *gin.Context)
+}
+
 // HealthHandlerInterface defines methods for health check handlers
 type HealthHandlerInterface interface {
 	GetHealth(ctx *gin.Context)
+	GetLiveness(ctx *gin.Context)
+	GetReadiness(ctx *gin.Context)
 	GetDetailedHealth(ctx *gin.Context)
 }
 
@@ -16,6 +30,12 @@ type HostHandlerInterface interface {
 	Delete(ctx *gin.Context)
 }
 
+// MaintenanceHandlerInterface defines methods for database maintenance handlers
+type MaintenanceHandlerInterface interface {
+	Run(ctx *gin.Context)
+	Get(ctx *gin.Context)
+}
+
 // MetricHandlerInterface defines methods for metric handlers
 type MetricHandlerInterface interface {
 	Create(ctx *gin.Context)
@@ -23,6 +43,36 @@ type MetricHandlerInterface interface {
 	GetLatest(ctx *gin.Context)
 }
 
+// ScrapeTargetHandlerInterface defines methods for scrape target handlers
+type ScrapeTargetHandlerInterface interface {
+	Create(ctx *gin.Context)
+	Get(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+// StreamHandlerInterface defines methods for live streaming handlers
+type StreamHandlerInterface interface {
+	Metrics(ctx *gin.Context)
+}
+
+// TelemetryHandlerInterface defines methods for the API's own metrics handlers
+type TelemetryHandlerInterface interface {
+	Metrics(ctx *gin.Context)
+}
+
+// WebSocketHandlerInterface defines methods for WebSocket subscription handlers
+type WebSocketHandlerInterface interface {
+	Connect(ctx *gin.Context)
+}
+
+var _ ArchiveHandlerInterface = &ArchiveHandler{}
+var _ BackupHandlerInterface = &BackupHandler{}
 var _ HealthHandlerInterface = &HealthHandler{}
 var _ HostHandlerInterface = &HostHandler{}
+var _ MaintenanceHandlerInterface = &MaintenanceHandler{}
 var _ MetricHandlerInterface = &MetricHandler{}
+var _ ScrapeTargetHandlerInterface = &ScrapeTargetHandler{}
+var _ StreamHandlerInterface = &StreamHandler{}
+var _ TelemetryHandlerInterface = &TelemetryHandler{}
+var _ WebSocketHandlerInterface = &WebSocketHandler{}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MaintenanceHandler struct {
+	service services.MaintenanceServiceInterface
+}
+
+func NewMaintenanceHandler(service services.MaintenanceServiceInterface) *MaintenanceHandler {
+	return &MaintenanceHandler{service: service}
+}
+
+// Run godoc
+// @Summary      Run database maintenance
+// @Description  Run incremental vacuum, ANALYZE, PRAGMA optimize and PRAGMA integrity_check now, or only the tasks listed. A failed task is reported in the run and doesn't stop the others.
+// @Tags         admin
+// @Produce      json
+// @Param        tasks  query     string  false  "Comma-separated tasks to run: incremental_vacuum, analyze, optimize, integrity_check"
+// @Success      200    {object}  models.MaintenanceResponse
+// @Failure      400    {object}  models.ErrorResponse
+// @Failure      409    {object}  models.ErrorResponse
+// @Failure      500    {object}  models.ErrorResponse
+// @Failure      504    {object}  models.ErrorResponse
+// @Failure      503    {object}  models.ErrorResponse
+// @Router       /admin/maintenance [post]
+func (handler *MaintenanceHandler) Run(ctx *gin.Context) {
+	var tasks []string
+	if raw := ctx.Query("tasks"); raw != "" {
+		for _, task := range strings.Split(raw, ",") {
+			tasks = append(tasks, strings.TrimSpace(task))
+		}
+	}
+
+	run, err := handler.service.RunMaintenance(ctx.Request.Context(), entities.MaintenanceTriggerManual, tasks)
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, maintenanceErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to run maintenance",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, models.MaintenanceResponse{Run: toModelMaintenanceRun(run)})
+}
+
+// Get godoc
+// @Summary      Get the last maintenance run
+// @Description  Get the results and durations of the most recent maintenance run, scheduled or manual
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  models.MaintenanceResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Router       /admin/maintenance [get]
+func (handler *MaintenanceHandler) Get(ctx *gin.Context) {
+	run, err := handler.service.LastRun()
+	if err != nil {
+		ctx.JSON(maintenanceErrorStatus(err), models.ErrorResponse{
+			Error:   "Failed to get maintenance run",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, models.MaintenanceResponse{Run: toModelMaintenanceRun(run)})
+}
+
+func toModelMaintenanceRun(run *entities.MaintenanceRun) models.MaintenanceRun {
+	tasks := make([]models.MaintenanceTask, len(run.Tasks))
+	for i, task := range run.Tasks {
+		tasks[i] = models.MaintenanceTask{
+			Task:       task.Task,
+			Status:     task.Status,
+			Error:      task.Error,
+			DurationMs: task.DurationMs,
+			Details:    task.Details,
+		}
+	}
+
+	return models.MaintenanceRun{
+		Trigger:    run.Trigger,
+		StartedAt:  run.StartedAt,
+		DurationMs: run.DurationMs,
+		Tasks:      tasks,
+	}
+}
+
+// maintenanceErrorStatus maps maintenance service errors to HTTP status codes
+func maintenanceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrMaintenanceDisabled):
+		return 503
+	case errors.Is(err, services.ErrNoMaintenanceRun):
+		return 404
+	case errors.Is(err, services.ErrMaintenanceInProgress):
+		return 409
+	case errors.Is(err, services.ErrInvalidMaintenanceTask):
+		return 400
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,182 @@
+// nolint
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MaintenanceHandlerTestSuite is the test suite for MaintenanceHandler
+type MaintenanceHandlerTestSuite struct {
+	suite.Suite
+	router      *gin.Engine
+	mockService *mocks.MockMaintenanceService
+	handler     *MaintenanceHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *MaintenanceHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockService = new(mocks.MockMaintenanceService)
+	suite.handler = NewMaintenanceHandler(suite.mockService)
+
+	// Register routes
+	suite.router.POST("/admin/maintenance", suite.handler.Run)
+	suite.router.GET("/admin/maintenance", suite.handler.Get)
+}
+
+// TearDownTest runs after each test
+func (suite *MaintenanceHandlerTestSuite) TearDownTest() {
+	suite.mockService.AssertExpectations(suite.T())
+}
+
+// testMaintenanceRun returns a manual run with one task
+func testMaintenanceRun() *entities.MaintenanceRun {
+	return &entities.MaintenanceRun{
+		Trigger:    entities.MaintenanceTriggerManual,
+		StartedAt:  1760756400,
+		DurationMs: 12.5,
+		Tasks: []entities.MaintenanceTaskResult{
+			{Task: entities.MaintenanceAnalyze, Status: entities.MaintenanceStatusOK, DurationMs: 12.5},
+		},
+	}
+}
+
+// TestRun tests the Run endpoint
+func (suite *MaintenanceHandlerTestSuite) TestRun() {
+	tests := []struct {
+		name           string
+		query          string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "all_tasks",
+			setupMock: func() {
+				suite.mockService.On("RunMaintenance", mock.Anything, entities.MaintenanceTriggerManual, []string(nil)).
+					Return(testMaintenanceRun(), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "selected_tasks",
+			query: "?tasks=analyze,%20optimize",
+			setupMock: func() {
+				suite.mockService.On("RunMaintenance", mock.Anything, entities.MaintenanceTriggerManual, []string{"analyze", "optimize"}).
+					Return(testMaintenanceRun(), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "invalid_task",
+			query: "?tasks=reindex",
+			setupMock: func() {
+				suite.mockService.On("RunMaintenance", mock.Anything, entities.MaintenanceTriggerManual, []string{"reindex"}).
+					Return(nil, fmt.Errorf("%w, got %q", services.ErrInvalidMaintenanceTask, "reindex")).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "maintenance_disabled",
+			setupMock: func() {
+				suite.mockService.On("RunMaintenance", mock.Anything, mock.Anything, mock.Anything).Return(nil, services.ErrMaintenanceDisabled).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "maintenance_running",
+			setupMock: func() {
+				suite.mockService.On("RunMaintenance", mock.Anything, mock.Anything, mock.Anything).Return(nil, services.ErrMaintenanceInProgress).Once()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/maintenance"+test.query, nil)
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusOK {
+				var response models.MaintenanceResponse
+				assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(suite.T(), entities.MaintenanceTriggerManual, response.Run.Trigger)
+				assert.Len(suite.T(), response.Run.Tasks, 1)
+				assert.Equal(suite.T(), entities.MaintenanceStatusOK, response.Run.Tasks[0].Status)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGet tests the Get endpoint
+func (suite *MaintenanceHandlerTestSuite) TestGet() {
+	tests := []struct {
+		name           string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "last_run",
+			setupMock: func() {
+				suite.mockService.On("LastRun").Return(testMaintenanceRun(), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "not_run_yet",
+			setupMock: func() {
+				suite.mockService.On("LastRun").Return(nil, services.ErrNoMaintenanceRun).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "maintenance_disabled",
+			setupMock: func() {
+				suite.mockService.On("LastRun").Return(nil, services.ErrMaintenanceDisabled).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			req, _ := http.NewRequest(http.MethodGet, "/admin/maintenance", nil)
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// Run the test suite
+func TestMaintenanceHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceHandlerTestSuite))
+}
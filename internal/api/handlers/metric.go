@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
@@ -19,14 +20,19 @@ func NewMetricHandler(service services.MetricServiceInterface) *MetricHandler {
 
 // Create godoc
 // @Summary      Submit system metrics
-// @Description  Submit new system metrics from a monitoring agent
+// @Description  Submit new system metrics from a monitoring agent. Fields out of range, and a timestamp too far behind or ahead of the server clock, get 400 listing every violation. Memory and disk fields that contradict each other are rejected the same way, stored and listed in violations, or corrected, depending on the validation mode. A metric without a timestamp is given the time it was received. A host has one metric per timestamp: a repeat gets 200 with the stored metric's ID and deduplicated set under the ignore duplicate policy, 200 with replaced set once the stored values are overwritten under the replace policy, or 409 under the reject policy. With an ingest spool the metric is accepted with 202 once it is safely on disk, before it has an ID, and duplicates are resolved when it is written.
 // @Tags         metrics
 // @Accept       json
 // @Produce      json
 // @Param        request  body  models.CreateMetricRequest  true  "Metric data"
-// @Success      201  {object}  object{message=string,id=int64}
+// @Success      200  {object}  object{message=string,id=int64,deduplicated=bool,replaced=bool,violations=[]models.FieldViolation}
+// @Success      201  {object}  object{message=string,id=int64,violations=[]models.FieldViolation}
+// @Success      202  {object}  object{message=string,violations=[]models.FieldViolation}
 // @Failure      400  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /metrics [post]
 func (handler *MetricHandler) Create(ctx *gin.Context) {
 	var requestBody struct {
@@ -41,35 +47,72 @@ func (handler *MetricHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	id, err := handler.service.CreateMetric(&requestBody.Record)
+	write, spooled, err := handler.service.AcceptMetric(ctx.Request.Context(), &requestBody.Record)
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		status := metricErrorStatus(err)
+		if status == 503 {
+			// The ingest queue drains within a flush interval or two
+			ctx.Header("Retry-After", "1")
+		}
+		response := models.ErrorResponse{
 			Error:   "Failed to create metric record",
 			Details: err.Error(),
-		})
+		}
+		var invalid *services.ValidationError
+		if errors.As(err, &invalid) {
+			response.Violations = toModelViolations(invalid.Violations)
+		}
+		ctx.JSON(deadlineStatus(ctx, status), response)
 		return
 	}
 
-	ctx.JSON(201, gin.H{
+	status, response := 201, gin.H{
 		"message": "Metric create successfully",
-		"id":      id,
-	})
+		"id":      write.ID,
+	}
+	switch {
+	case spooled:
+		status, response = 202, gin.H{
+			"message": "Metric accepted",
+		}
+	case write.Deduplicated:
+		status, response = 200, gin.H{
+			"message":      "Metric already recorded",
+			"id":           write.ID,
+			"deduplicated": true,
+		}
+	case write.Replaced:
+		status, response = 200, gin.H{
+			"message":  "Metric replaced",
+			"id":       write.ID,
+			"replaced": true,
+		}
+	}
+
+	// Stored despite, or after correcting, fields that contradict each other
+	if violations := toModelViolations(write.Violations); violations != nil {
+		response["violations"] = violations
+	}
+	ctx.JSON(status, response)
 }
 
 // Get godoc
 // @Summary      Get system metrics
-// @Description  Retrieve system metrics with optional filtering and time range
+// @Description  Retrieve system metrics with optional filtering and time range. Request CSV or NDJSON with the format parameter or the Accept header to stream an export including host columns; exports are not paginated and have no row cap unless limit is set.
 // @Tags         metrics
 // @Accept       json
-// @Produce      json
+// @Produce      json,text/csv,application/x-ndjson
 // @Param        host_id     query  int     false  "Filter by host ID"
-// @Param        limit       query  int     false  "Limit results (max 1000)"  default(100)
+// @Param        format      query  string  false  "Response format (json, csv or ndjson)"
+// @Param        limit       query  int     false  "Limit results (max 1000 for JSON)"  default(100)
 // @Param        order       query  string  false  "Sort order (ASC or DESC)"  default(DESC)
-// @Param        start_time  query  int     false  "Start timestamp (Unix)"
-// @Param        end_time    query  int     false  "End timestamp (Unix)"
+// @Param        start_time  query  int     false  "Start timestamp (Unix); defaults to 30 days before end_time unless a cursor is given"
+// @Param        end_time    query  int     false  "End timestamp (Unix); defaults to now unless a cursor is given"
+// @Param        cursor      query  string  false  "Continue from the next_cursor of a previous page"
 // @Success      200  {object}  models.MetricListResponse
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /metrics [get]
 func (handler *MetricHandler) Get(ctx *gin.Context) {
 	var queryParams entities.MetricQueryParams
@@ -81,24 +124,51 @@ func (handler *MetricHandler) Get(ctx *gin.Context) {
 		return
 	}
 
+	format, errResp := negotiateMetricFormat(ctx, queryParams.Format)
+	if errResp != nil {
+		ctx.JSON(400, errResp)
+		return
+	}
+
 	// Validate and set defaults
-	if errResp := setMetricQueryDefaults(&queryParams); errResp != nil {
+	if format == metricFormatJSON {
+		errResp = setMetricQueryDefaults(&queryParams)
+	} else {
+		errResp = setMetricExportDefaults(&queryParams)
+	}
+	if errResp != nil {
 		ctx.JSON(400, errResp)
 		return
 	}
 
-	now := time.Now().Unix()
-	if queryParams.EndTime == nil {
-		queryParams.EndTime = &now
+	// The default window only applies to first pages. A cursor already fixes where the
+	// page starts, and re-deriving the window from now would move it between pages
+	if queryParams.Cursor == "" {
+		now := time.Now().Unix()
+		if queryParams.EndTime == nil {
+			queryParams.EndTime = &now
+		}
+		if queryParams.StartTime == nil {
+			thirtyDaysAgo := *queryParams.EndTime - (86400 * 30)
+			queryParams.StartTime = &thirtyDaysAgo
+		}
 	}
-	if queryParams.StartTime == nil {
-		thirtyDaysAgo := *queryParams.EndTime - (86400 * 30)
-		queryParams.StartTime = &thirtyDaysAgo
+
+	if format != metricFormatJSON {
+		handler.export(ctx, &queryParams, format)
+		return
 	}
 
-	records, err := handler.service.GetMetrics(&queryParams)
+	records, page, err := handler.service.GetMetrics(ctx.Request.Context(), &queryParams)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to retrieve metrics",
 			Details: err.Error(),
 		})
@@ -114,8 +184,10 @@ func (handler *MetricHandler) Get(ctx *gin.Context) {
 	ctx.JSON(200, models.MetricListResponse{
 		Records: modelMetrics,
 		Meta: models.Meta{
-			Count: len(modelMetrics),
-			Limit: queryParams.Limit,
+			Count:      len(modelMetrics),
+			Limit:      queryParams.Limit,
+			NextCursor: page.NextCursor,
+			HasMore:    page.HasMore,
 		},
 	})
 }
@@ -131,6 +203,7 @@ func (handler *MetricHandler) Get(ctx *gin.Context) {
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      404  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
 // @Router       /metrics/latest [get]
 func (handler *MetricHandler) GetLatest(ctx *gin.Context) {
 	var queryParams entities.MetricLatestQueryParams
@@ -142,9 +215,9 @@ func (handler *MetricHandler) GetLatest(ctx *gin.Context) {
 		return
 	}
 
-	metric, err := handler.service.GetLatestMetric(queryParams.HostID)
+	metric, err := handler.service.GetLatestMetric(ctx.Request.Context(), queryParams.HostID)
 	if err != nil {
-		ctx.JSON(500, models.ErrorResponse{
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
 			Error:   "Failed to retrieve latest metric",
 			Details: err.Error(),
 		})
@@ -163,3 +236,16 @@ func (handler *MetricHandler) GetLatest(ctx *gin.Context) {
 		"metric": metric,
 	})
 }
+
+func metricErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidMetric):
+		return 400
+	case errors.Is(err, services.ErrDuplicateMetric):
+		return 409
+	case errors.Is(err, services.ErrIngestQueueFull):
+		return 503
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Metric response formats
+const (
+	metricFormatJSON   = "json"
+	metricFormatCSV    = "csv"
+	metricFormatNDJSON = "ndjson"
+
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+
+	// exportFlushInterval is how many rows are written between flushes to the client
+	exportFlushInterval = 500
+)
+
+var metricCSVHeader = []string{
+	"id", "host_id", "hostname", "ip_address", "role", "timestamp",
+	"cpu_usage", "memory_usage_percent", "memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
+	"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
+}
+
+// negotiateMetricFormat picks the response format from the format parameter, falling
+// back to the Accept header
+func negotiateMetricFormat(ctx *gin.Context, format string) (string, *models.ErrorResponse) {
+	switch strings.ToLower(format) {
+	case "":
+	case metricFormatJSON:
+		return metricFormatJSON, nil
+	case metricFormatCSV:
+		return metricFormatCSV, nil
+	case metricFormatNDJSON:
+		return metricFormatNDJSON, nil
+	default:
+		return "", &models.ErrorResponse{
+			Error:   "Invalid format parameter",
+			Details: "Must be 'json', 'csv' or 'ndjson'",
+		}
+	}
+
+	switch ctx.NegotiateFormat(gin.MIMEJSON, mimeCSV, mimeNDJSON) {
+	case mimeCSV:
+		return metricFormatCSV, nil
+	case mimeNDJSON:
+		return metricFormatNDJSON, nil
+	default:
+		return metricFormatJSON, nil
+	}
+}
+
+// IsMetricExport reports whether a metrics request asks for a CSV or NDJSON export,
+// which streams for as long as it takes and so is exempt from the request timeout
+func IsMetricExport(ctx *gin.Context) bool {
+	format, errResp := negotiateMetricFormat(ctx, ctx.Query("format"))
+	return errResp == nil && format != metricFormatJSON
+}
+
+// metricExportWriter encodes export rows in a single format
+type metricExportWriter interface {
+	writeHeader() error
+	writeRow(row entities.MetricExportRow) error
+	flush() error
+}
+
+func newMetricExportWriter(writer io.Writer, format string) metricExportWriter {
+	if format == metricFormatCSV {
+		return &csvMetricWriter{writer: csv.NewWriter(writer)}
+	}
+	return &ndjsonMetricWriter{encoder: json.NewEncoder(writer)}
+}
+
+type csvMetricWriter struct {
+	writer *csv.Writer
+}
+
+func (exporter *csvMetricWriter) writeHeader() error {
+	return exporter.writer.Write(metricCSVHeader)
+}
+
+func (exporter *csvMetricWriter) writeRow(row entities.MetricExportRow) error {
+	metric := row.Metric
+	return exporter.writer.Write([]string{
+		strconv.FormatInt(metric.ID, 10),
+		strconv.FormatInt(metric.HostID, 10),
+		row.Host.Hostname,
+		row.Host.IPAddress,
+		row.Host.Role,
+		strconv.FormatInt(metric.Timestamp, 10),
+		strconv.FormatFloat(metric.CPUUsage, 'f', -1, 64),
+		strconv.FormatFloat(metric.MemoryUsagePercent, 'f', -1, 64),
+		strconv.FormatInt(metric.MemoryTotalBytes, 10),
+		strconv.FormatInt(metric.MemoryUsedBytes, 10),
+		strconv.FormatInt(metric.MemoryAvailableBytes, 10),
+		strconv.FormatFloat(metric.DiskUsagePercent, 'f', -1, 64),
+		strconv.FormatInt(metric.DiskTotalBytes, 10),
+		strconv.FormatInt(metric.DiskUsedBytes, 10),
+		strconv.FormatInt(metric.DiskAvailableBytes, 10),
+		strconv.FormatInt(metric.ReceivedAt, 10),
+	})
+}
+
+func (exporter *csvMetricWriter) flush() error {
+	exporter.writer.Flush()
+	return exporter.writer.Error()
+}
+
+type ndjsonMetricWriter struct {
+	encoder *json.Encoder
+}
+
+func (exporter *ndjsonMetricWriter) writeHeader() error {
+	return nil
+}
+
+func (exporter *ndjsonMetricWriter) writeRow(row entities.MetricExportRow) error {
+	return exporter.encoder.Encode(models.MetricExportRow{
+		SystemMetric: toModelMetric(row.Metric),
+		Hostname:     row.Host.Hostname,
+		IPAddress:    row.Host.IPAddress,
+		Role:         row.Host.Role,
+	})
+}
+
+func (exporter *ndjsonMetricWriter) flush() error {
+	return nil
+}
+
+// export streams metrics as CSV or NDJSON. The status and headers are only sent once
+// the first row is ready, so query errors can still be reported as JSON.
+func (handler *MetricHandler) export(ctx *gin.Context, params *entities.MetricQueryParams, format string) {
+	writer := newMetricExportWriter(ctx.Writer, format)
+
+	started := false
+	start := func() error {
+		started = true
+		contentType := mimeNDJSON
+		if format == metricFormatCSV {
+			contentType = mimeCSV + "; charset=utf-8"
+		}
+		ctx.Header("Content-Type", contentType)
+		ctx.Header("Content-Disposition", `attachment; filename="metrics.`+format+`"`)
+		ctx.Status(200)
+		return writer.writeHeader()
+	}
+
+	rows := 0
+	err := handler.service.ExportMetrics(ctx.Request.Context(), params, func(row entities.MetricExportRow) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.writeRow(row); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushInterval == 0 {
+			if err := writer.flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+
+	if err != nil && !started {
+		status := 500
+		if errors.Is(err, services.ErrInvalidHostID) || errors.Is(err, services.ErrInvalidTimeRange) {
+			status = 400
+		}
+		ctx.JSON(deadlineStatus(ctx, status), models.ErrorResponse{
+			Error:   "Failed to export metrics",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// An empty export still gets its CSV header row
+	if !started {
+		err = start()
+	}
+
+	// The status has already been sent, so a failure part way through can only be logged
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "metric export stopped", "rows", rows, "error", err)
+		return
+	}
+
+	if err := writer.flush(); err != nil {
+		slog.WarnContext(ctx.Request.Context(), "metric export stopped", "rows", rows, "error", err)
+	}
+}
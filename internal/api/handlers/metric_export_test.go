@@ -0,0 +1,192 @@
+// nolint
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MetricExportTestSuite is the test suite for CSV and NDJSON metric exports
+type MetricExportTestSuite struct {
+	suite.Suite
+	router      *gin.Engine
+	mockService *mocks.MockMetricService
+	rows        []entities.MetricExportRow
+}
+
+// SetupTest runs before each test in the suite
+func (suite *MetricExportTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockService = new(mocks.MockMetricService)
+	handler := NewMetricHandler(suite.mockService)
+
+	// Register routes
+	suite.router.GET("/metrics", handler.Get)
+
+	suite.rows = []entities.MetricExportRow{
+		{
+			Metric: entities.SystemMetric{ID: 1, HostID: 2, Timestamp: 1700000000, CPUUsage: 12.5, MemoryTotalBytes: 1024, ReceivedAt: 1700000002},
+			Host:   entities.Host{ID: 2, Hostname: "pi, the first", IPAddress: "192.168.1.2", Role: "nas"},
+		},
+		{
+			Metric: entities.SystemMetric{ID: 2, HostID: 2, Timestamp: 1700000060, CPUUsage: 99},
+			Host:   entities.Host{ID: 2, Hostname: "pi, the first", IPAddress: "192.168.1.2", Role: "nas"},
+		},
+	}
+}
+
+// TearDownTest runs after each test
+func (suite *MetricExportTestSuite) TearDownTest() {
+	suite.mockService.AssertExpectations(suite.T())
+}
+
+// get performs a GET /metrics request with an optional Accept header
+func (suite *MetricExportTestSuite) get(query, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/metrics"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestCSVExport tests CSV output, chosen by parameter or Accept header, with host columns
+func (suite *MetricExportTestSuite) TestCSVExport() {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+	}{
+		{name: "format_parameter", query: "?format=csv"},
+		{name: "accept_header", accept: "text/csv"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mockService.On("ExportMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				return params.Limit == 0 && params.Order == "DESC" && params.StartTime != nil
+			})).Return(suite.rows, nil).Once()
+
+			w := suite.get(test.query, test.accept)
+
+			assert.Equal(suite.T(), http.StatusOK, w.Code)
+			assert.Equal(suite.T(), "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(suite.T(), `attachment; filename="metrics.csv"`, w.Header().Get("Content-Disposition"))
+
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			assert.Len(suite.T(), lines, 3)
+			assert.Equal(suite.T(), strings.Join(metricCSVHeader, ","), lines[0])
+			assert.Equal(suite.T(), `1,2,"pi, the first",192.168.1.2,nas,1700000000,12.5,0,1024,0,0,0,0,0,0,1700000002`, lines[1])
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestNDJSONExport tests one JSON object per line with host fields
+func (suite *MetricExportTestSuite) TestNDJSONExport() {
+	suite.mockService.On("ExportMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+		return params.Limit == 5000 && params.Order == "ASC"
+	})).Return(suite.rows, nil).Once()
+
+	w := suite.get("?limit=5000&order=asc", "application/x-ndjson")
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(suite.T(), lines, 2)
+	assert.Contains(suite.T(), lines[1], `"id":2`)
+	assert.Contains(suite.T(), lines[1], `"hostname":"pi, the first"`)
+	assert.Contains(suite.T(), lines[1], `"role":"nas"`)
+}
+
+// TestEmptyCSVExport tests that an empty export still has a header row
+func (suite *MetricExportTestSuite) TestEmptyCSVExport() {
+	suite.mockService.On("ExportMetrics", mock.Anything, mock.Anything).Return(nil, nil).Once()
+
+	w := suite.get("?format=csv", "")
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), strings.Join(metricCSVHeader, ",")+"\n", w.Body.String())
+}
+
+// TestExportErrors tests errors reported before any row is written
+func (suite *MetricExportTestSuite) TestExportErrors() {
+	tests := []struct {
+		name           string
+		query          string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name:           "invalid_format",
+			query:          "?format=xml",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "cursor_not_supported",
+			query:          "?format=csv&cursor=abc",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative_limit",
+			query:          "?format=ndjson&limit=-1",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "invalid_time_range",
+			query: "?format=csv&start_time=20&end_time=10",
+			setupMock: func() {
+				suite.mockService.On("ExportMetrics", mock.Anything, mock.Anything).Return(nil, services.ErrInvalidTimeRange).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "database_error",
+			query: "?format=csv",
+			setupMock: func() {
+				suite.mockService.On("ExportMetrics", mock.Anything, mock.Anything).Return(nil, errors.New("database is locked")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			w := suite.get(test.query, "")
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+			assert.Contains(suite.T(), w.Header().Get("Content-Type"), "application/json")
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// Run the test suite
+func TestMetricExportTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricExportTestSuite))
+}
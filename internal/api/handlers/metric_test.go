@@ -11,6 +11,7 @@ import (
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -77,7 +78,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateMetric", &entities.SystemMetric{
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
 					HostID:               1,
 					Timestamp:            1609459200,
 					CPUUsage:             45.5,
@@ -89,7 +90,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 					DiskTotalBytes:       500000000000,
 					DiskUsedBytes:        375000000000,
 					DiskAvailableBytes:   125000000000,
-				}).Return(int64(1), nil).Once()
+				}).Return(entities.MetricWrite{ID: 1}, false, nil).Once()
 			},
 			expectedStatus: http.StatusCreated,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -122,9 +123,9 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateMetric", &entities.SystemMetric{
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
 					HostID: 1,
-				}).Return(int64(0), errors.New("missing required fields")).Once()
+				}).Return(entities.MetricWrite{ID: 0}, false, errors.New("missing required fields")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -152,7 +153,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateMetric", &entities.SystemMetric{
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
 					HostID:               999,
 					Timestamp:            1609459200,
 					CPUUsage:             45.5,
@@ -164,7 +165,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 					DiskTotalBytes:       500000000000,
 					DiskUsedBytes:        375000000000,
 					DiskAvailableBytes:   125000000000,
-				}).Return(int64(0), errors.New("FOREIGN KEY constraint failed")).Once()
+				}).Return(entities.MetricWrite{ID: 0}, false, errors.New("FOREIGN KEY constraint failed")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -193,7 +194,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 				},
 			},
 			setupMock: func() {
-				suite.mockService.On("CreateMetric", &entities.SystemMetric{
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
 					HostID:               1,
 					Timestamp:            1609459200,
 					CPUUsage:             45.5,
@@ -205,7 +206,7 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 					DiskTotalBytes:       500000000000,
 					DiskUsedBytes:        375000000000,
 					DiskAvailableBytes:   125000000000,
-				}).Return(int64(0), errors.New("database connection lost")).Once()
+				}).Return(entities.MetricWrite{ID: 0}, false, errors.New("database connection lost")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -216,6 +217,190 @@ func (suite *MetricHandlerTestSuite) TestCreate() {
 				assert.Equal(t, "database connection lost", response.Details)
 			},
 		},
+		{
+			name: "accepted_into_spool",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: -1}, true, nil).Once()
+			},
+			expectedStatus: http.StatusAccepted,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"message": "Metric accepted"}, response)
+			},
+		},
+		{
+			name: "deduplicated",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: 7, Deduplicated: true}, false, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"message": "Metric already recorded", "id": float64(7), "deduplicated": true}, response)
+			},
+		},
+		{
+			name: "replaced",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: 7, Replaced: true}, false, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"message": "Metric replaced", "id": float64(7), "replaced": true}, response)
+			},
+		},
+		{
+			name: "duplicate_rejected",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: -1}, false, services.ErrDuplicateMetric).Once()
+			},
+			expectedStatus: http.StatusConflict,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, services.ErrDuplicateMetric.Error(), response.Details)
+			},
+		},
+		{
+			name: "invalid_metric",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 86400,
+					"cpu_usage": 145.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 86400,
+					CPUUsage:  145.5,
+				}).Return(entities.MetricWrite{ID: -1}, false, &services.ValidationError{Violations: []entities.FieldViolation{
+					{Field: "cpu_usage", Message: "must be between 0 and 100, got 145.5"},
+					{Field: "timestamp", Message: "is 1759913600s behind the server clock, more than the 604800s allowed"},
+				}}).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "invalid metric: cpu_usage must be between 0 and 100, got 145.5; timestamp is 1759913600s behind the server clock, more than the 604800s allowed", response.Details)
+				assert.Equal(t, []models.FieldViolation{
+					{Field: "cpu_usage", Message: "must be between 0 and 100, got 145.5"},
+					{Field: "timestamp", Message: "is 1759913600s behind the server clock, more than the 604800s allowed"},
+				}, response.Violations)
+			},
+		},
+		{
+			name: "stored_with_violations",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: 8, Violations: []entities.FieldViolation{
+					{Field: "memory_usage_percent", Message: "is 20, but the byte counts give 75", Corrected: true},
+				}}, false, nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response struct {
+					ID         int64                   `json:"id"`
+					Violations []models.FieldViolation `json:"violations"`
+				}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(8), response.ID)
+				assert.Equal(t, []models.FieldViolation{
+					{Field: "memory_usage_percent", Message: "is 20, but the byte counts give 75", Corrected: true},
+				}, response.Violations)
+			},
+		},
+		{
+			name: "ingest_queue_full",
+			requestBody: map[string]interface{}{
+				"record": map[string]interface{}{
+					"host_id":   1,
+					"timestamp": 1609459200,
+					"cpu_usage": 45.5,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("AcceptMetric", mock.Anything, &entities.SystemMetric{
+					HostID:    1,
+					Timestamp: 1609459200,
+					CPUUsage:  45.5,
+				}).Return(entities.MetricWrite{ID: -1}, false, services.ErrIngestQueueFull).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+				assert.Equal(t, services.ErrIngestQueueFull.Error(), response.Details)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -286,9 +471,9 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 					},
 				}
 				// Use MatchedBy to match any params with Limit=100 and Order=DESC
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 100 && params.Order == "DESC" && params.HostID == nil
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -319,9 +504,9 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 						DiskAvailableBytes:   125000000000,
 					},
 				}
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 100 && params.Order == "DESC" && params.HostID != nil && *params.HostID == 1
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -351,12 +536,12 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 						DiskAvailableBytes:   100000000000,
 					},
 				}
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 100 &&
 						params.Order == "DESC" &&
 						params.StartTime != nil && *params.StartTime == 1609459200 &&
 						params.EndTime != nil && *params.EndTime == 1609545600
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -387,9 +572,9 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 						DiskAvailableBytes:   125000000000,
 					})
 				}
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 50 && params.Order == "DESC"
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -420,9 +605,9 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 						DiskAvailableBytes:   125000000000,
 					},
 				}
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 100 && params.Order == "ASC"
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -437,9 +622,9 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 			queryParams: "?host_id=999",
 			setupMock: func() {
 				var metrics []entities.SystemMetric
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.HostID != nil && *params.HostID == 999
-				})).Return(metrics, nil).Once()
+				})).Return(metrics, entities.PageInfo{}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -472,13 +657,57 @@ func (suite *MetricHandlerTestSuite) TestGet() {
 				assert.NoError(t, err)
 			},
 		},
+		{
+			name:        "next_page_cursor",
+			queryParams: "?limit=2&cursor=MzAwOjU",
+			setupMock: func() {
+				metrics := []entities.SystemMetric{{ID: 4, HostID: 1, Timestamp: 200}, {ID: 3, HostID: 1, Timestamp: 200}}
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+					return params.Limit == 2 && params.Cursor == "MzAwOjU" && params.StartTime == nil && params.EndTime == nil
+				})).Return(metrics, entities.PageInfo{NextCursor: "MjAwOjM", HasMore: true}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.MetricListResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, models.Meta{Count: 2, Limit: 2, NextCursor: "MjAwOjM", HasMore: true}, response.Meta)
+			},
+		},
+		{
+			name:        "next_page_cursor_keeps_explicit_window",
+			queryParams: "?limit=2&cursor=MzAwOjU&start_time=100&end_time=400",
+			setupMock: func() {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+					return params.Cursor == "MzAwOjU" && *params.StartTime == 100 && *params.EndTime == 400
+				})).Return([]entities.SystemMetric{}, entities.PageInfo{}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse:  func(t *testing.T, w *httptest.ResponseRecorder) {},
+		},
+		{
+			name:        "invalid_cursor",
+			queryParams: "?cursor=bogus",
+			setupMock: func() {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+					return params.Cursor == "bogus"
+				})).Return(nil, entities.PageInfo{}, services.ErrInvalidCursor).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response models.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "Invalid query parameters", response.Error)
+			},
+		},
 		{
 			name:        "database_error",
 			queryParams: "",
 			setupMock: func() {
-				suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+				suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 					return params.Limit == 100 && params.Order == "DESC"
-				})).Return(nil, errors.New("database connection lost")).Once()
+				})).Return(nil, entities.PageInfo{}, errors.New("database connection lost")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -546,7 +775,7 @@ func (suite *MetricHandlerTestSuite) TestGetLatest() {
 					DiskUsedBytes:        425000000000,
 					DiskAvailableBytes:   75000000000,
 				}
-				suite.mockService.On("GetLatestMetric", &hostID).Return(metric, nil).Once()
+				suite.mockService.On("GetLatestMetric", mock.Anything, &hostID).Return(metric, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -578,7 +807,7 @@ func (suite *MetricHandlerTestSuite) TestGetLatest() {
 					DiskAvailableBytes:   250000000000,
 				}
 				var nilHostID *int64
-				suite.mockService.On("GetLatestMetric", nilHostID).Return(metric, nil).Once()
+				suite.mockService.On("GetLatestMetric", mock.Anything, nilHostID).Return(metric, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -593,7 +822,7 @@ func (suite *MetricHandlerTestSuite) TestGetLatest() {
 			queryParams: "?host_id=999",
 			setupMock: func() {
 				nonExistentHostID := int64(999)
-				suite.mockService.On("GetLatestMetric", &nonExistentHostID).Return(nil, nil).Once()
+				suite.mockService.On("GetLatestMetric", mock.Anything, &nonExistentHostID).Return(nil, nil).Once()
 			},
 			expectedStatus: http.StatusNotFound,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -620,7 +849,7 @@ func (suite *MetricHandlerTestSuite) TestGetLatest() {
 			name:        "database_error",
 			queryParams: "?host_id=1",
 			setupMock: func() {
-				suite.mockService.On("GetLatestMetric", &hostID).Return(nil, errors.New("database connection lost")).Once()
+				suite.mockService.On("GetLatestMetric", mock.Anything, &hostID).Return(nil, errors.New("database connection lost")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -679,9 +908,9 @@ func (suite *MetricHandlerTestSuite) TestContentType() {
 		},
 	}
 
-	suite.mockService.On("GetMetrics", mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+	suite.mockService.On("GetMetrics", mock.Anything, mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
 		return params.Limit == 100 && params.Order == "DESC"
-	})).Return(metrics, nil).Once()
+	})).Return(metrics, entities.PageInfo{}, nil).Once()
 
 	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
 	assert.NoError(suite.T(), err)
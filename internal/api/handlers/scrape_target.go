@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ScrapeTargetHandler struct {
+	service services.ScrapeTargetServiceInterface
+}
+
+func NewScrapeTargetHandler(service services.ScrapeTargetServiceInterface) *ScrapeTargetHandler {
+	return &ScrapeTargetHandler{service: service}
+}
+
+// Create godoc
+// @Summary      Register a scrape target
+// @Description  Register a node_exporter or JSON endpoint that the API scrapes on an interval. The host must already exist.
+// @Tags         scrape-targets
+// @Accept       json
+// @Produce      json
+// @Param        request  body  models.CreateScrapeTargetRequest  true  "Scrape target configuration"
+// @Success      201  {object}  object{message=string,id=int64}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Router       /scrape-targets [post]
+func (handler *ScrapeTargetHandler) Create(ctx *gin.Context) {
+	var requestBody struct {
+		Target entities.ScrapeTarget `json:"target"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	id, err := handler.service.CreateTarget(ctx.Request.Context(), &requestBody.Target)
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, scrapeTargetErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to create scrape target",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(201, gin.H{
+		"message": "Scrape target created successfully",
+		"id":      id,
+	})
+}
+
+// Get godoc
+// @Summary      List scrape targets
+// @Description  Get registered scrape targets together with the health of their latest scrape
+// @Tags         scrape-targets
+// @Accept       json
+// @Produce      json
+// @Param        id           query  int   false  "Filter by target ID"
+// @Param        host_id      query  int   false  "Filter by host ID"
+// @Param        active_only  query  bool  false  "Only return targets that are not paused"
+// @Success      200  {object}  models.ScrapeTargetListResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Router       /scrape-targets [get]
+func (handler *ScrapeTargetHandler) Get(ctx *gin.Context) {
+	var queryParams entities.ScrapeTargetQueryParams
+	if err := ctx.ShouldBindQuery(&queryParams); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	targets, err := handler.service.GetTargets(ctx.Request.Context(), &queryParams)
+	if err != nil {
+		ctx.JSON(deadlineStatus(ctx, 500), models.ErrorResponse{
+			Error:   "Failed to retrieve scrape targets",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	modelTargets := make([]models.ScrapeTarget, len(targets))
+	for i, target := range targets {
+		modelTargets[i] = toModelScrapeTarget(target)
+	}
+
+	ctx.JSON(200, models.ScrapeTargetListResponse{
+		Targets: modelTargets,
+		Meta: models.Meta{
+			Count: len(modelTargets),
+		},
+	})
+}
+
+// Update godoc
+// @Summary      Update a scrape target
+// @Description  Replace the configuration of an existing scrape target. The host must already exist.
+// @Tags         scrape-targets
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                               true  "Scrape target ID"
+// @Param        request  body  models.CreateScrapeTargetRequest  true  "Scrape target configuration"
+// @Success      200  {object}  object{message=string}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Router       /scrape-targets/{id} [put]
+func (handler *ScrapeTargetHandler) Update(ctx *gin.Context) {
+	id, errResp := parseIDParam(ctx)
+	if errResp != nil {
+		ctx.JSON(400, errResp)
+		return
+	}
+
+	var requestBody struct {
+		Target entities.ScrapeTarget `json:"target"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := handler.service.UpdateTarget(ctx.Request.Context(), id, &requestBody.Target); err != nil {
+		ctx.JSON(deadlineStatus(ctx, scrapeTargetErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to update scrape target",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"message": "Scrape target updated successfully",
+	})
+}
+
+// Delete godoc
+// @Summary      Delete a scrape target
+// @Description  Stop scraping a target and remove it. Metrics already collected are kept.
+// @Tags         scrape-targets
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Scrape target ID"
+// @Success      200  {object}  object{message=string}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Failure      504  {object}  models.ErrorResponse
+// @Router       /scrape-targets/{id} [delete]
+func (handler *ScrapeTargetHandler) Delete(ctx *gin.Context) {
+	id, errResp := parseIDParam(ctx)
+	if errResp != nil {
+		ctx.JSON(400, errResp)
+		return
+	}
+
+	if err := handler.service.DeleteTarget(ctx.Request.Context(), id); err != nil {
+		ctx.JSON(deadlineStatus(ctx, scrapeTargetErrorStatus(err)), models.ErrorResponse{
+			Error:   "Failed to delete scrape target",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"message": "Scrape target deleted successfully",
+	})
+}
+
+// scrapeTargetErrorStatus maps scrape target service errors to HTTP status codes
+func scrapeTargetErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrScrapeTargetNotFound):
+		return 404
+	case errors.Is(err, services.ErrInvalidHostID),
+		errors.Is(err, services.ErrHostNotFound),
+		errors.Is(err, services.ErrInvalidScrapeURL),
+		errors.Is(err, services.ErrInvalidScrapeFormat),
+		errors.Is(err, services.ErrInvalidScrapeInterval),
+		errors.Is(err, services.ErrInvalidScrapeTimeout),
+		errors.Is(err, services.ErrInvalidScrapeJSONField),
+		errors.Is(err, services.ErrMissingScrapeJSONPath):
+		return 400
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,226 @@
+// nolint
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// ScrapeTargetHandlerTestSuite is the test suite for ScrapeTargetHandler
+type ScrapeTargetHandlerTestSuite struct {
+	suite.Suite
+	router      *gin.Engine
+	mockService *mocks.MockScrapeTargetService
+	handler     *ScrapeTargetHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ScrapeTargetHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockService = new(mocks.MockScrapeTargetService)
+	suite.handler = NewScrapeTargetHandler(suite.mockService)
+
+	// Register routes
+	suite.router.POST("/scrape-targets", suite.handler.Create)
+	suite.router.GET("/scrape-targets", suite.handler.Get)
+	suite.router.PUT("/scrape-targets/:id", suite.handler.Update)
+	suite.router.DELETE("/scrape-targets/:id", suite.handler.Delete)
+}
+
+// TearDownTest runs after each test
+func (suite *ScrapeTargetHandlerTestSuite) TearDownTest() {
+	suite.mockService.AssertExpectations(suite.T())
+}
+
+// TestCreate tests the Create endpoint
+func (suite *ScrapeTargetHandlerTestSuite) TestCreate() {
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "successful_creation",
+			requestBody: map[string]interface{}{
+				"target": map[string]interface{}{
+					"url":     "http://nas:9100/metrics",
+					"host_id": 1,
+				},
+			},
+			setupMock: func() {
+				suite.mockService.On("CreateTarget", mock.Anything, &entities.ScrapeTarget{
+					URL:    "http://nas:9100/metrics",
+					HostID: 1,
+				}).Return(int64(3), nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "validation_error",
+			requestBody: map[string]interface{}{
+				"target": map[string]interface{}{"url": "nas"},
+			},
+			setupMock: func() {
+				suite.mockService.On("CreateTarget", mock.Anything, &entities.ScrapeTarget{URL: "nas"}).
+					Return(int64(-1), services.ErrInvalidScrapeURL).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unknown_host",
+			requestBody: map[string]interface{}{
+				"target": map[string]interface{}{"url": "http://nas:9100/metrics", "host_id": 9},
+			},
+			setupMock: func() {
+				suite.mockService.On("CreateTarget", mock.Anything, &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 9}).
+					Return(int64(-1), services.ErrHostNotFound).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid_json_body",
+			requestBody:    "invalid json",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "service_error",
+			requestBody: map[string]interface{}{
+				"target": map[string]interface{}{"url": "http://nas:9100/metrics", "host_id": 1},
+			},
+			setupMock: func() {
+				suite.mockService.On("CreateTarget", mock.Anything, &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 1}).
+					Return(int64(-1), errors.New("FOREIGN KEY constraint failed")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			var body []byte
+			if str, ok := test.requestBody.(string); ok {
+				body = []byte(str)
+			} else {
+				body, _ = json.Marshal(test.requestBody)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, "/scrape-targets", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGet tests the Get endpoint
+func (suite *ScrapeTargetHandlerTestSuite) TestGet() {
+	suite.mockService.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{HostID: 2}).
+		Return([]entities.ScrapeTarget{{ID: 1, HostID: 2, LastStatus: "ok"}}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/scrape-targets?host_id=2", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response models.ScrapeTargetListResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, response.Meta.Count)
+	assert.Equal(suite.T(), "ok", response.Targets[0].LastStatus)
+}
+
+// TestUpdate tests the Update endpoint
+func (suite *ScrapeTargetHandlerTestSuite) TestUpdate() {
+	tests := []struct {
+		name           string
+		path           string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "successful_update",
+			path: "/scrape-targets/4",
+			setupMock: func() {
+				suite.mockService.On("UpdateTarget", mock.Anything, int64(4), &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 1}).
+					Return(nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "target_not_found",
+			path: "/scrape-targets/4",
+			setupMock: func() {
+				suite.mockService.On("UpdateTarget", mock.Anything, int64(4), &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 1}).
+					Return(services.ErrScrapeTargetNotFound).Once()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid_id",
+			path:           "/scrape-targets/abc",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"target": map[string]interface{}{"url": "http://nas:9100/metrics", "host_id": 1},
+			})
+			req, _ := http.NewRequest(http.MethodPut, test.path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), test.expectedStatus, w.Code)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestDelete tests the Delete endpoint
+func (suite *ScrapeTargetHandlerTestSuite) TestDelete() {
+	suite.mockService.On("DeleteTarget", mock.Anything, int64(6)).Return(nil).Once()
+
+	req, _ := http.NewRequest(http.MethodDelete, "/scrape-targets/6", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+// Run the test suite
+func TestScrapeTargetHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(ScrapeTargetHandlerTestSuite))
+}
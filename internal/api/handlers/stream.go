@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/events"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamBufferSize is how many live events a slow client may fall behind by
+	// before it is caught up from the database instead
+	streamBufferSize = 256
+
+	// replayBatchSize is the page size used when replaying missed metrics
+	replayBatchSize = 500
+
+	// defaultMaxReplayEvents bounds how much history a single resume may replay
+	defaultMaxReplayEvents = 10000
+
+	defaultHeartbeatInterval = 15 * time.Second
+)
+
+type StreamHandler struct {
+	metricService services.MetricServiceInterface
+	hostService   services.HostServiceInterface
+	hub           *events.Hub
+	heartbeat     time.Duration
+	maxReplay     int
+}
+
+func NewStreamHandler(
+	metricService services.MetricServiceInterface,
+	hostService services.HostServiceInterface,
+	hub *events.Hub,
+) *StreamHandler {
+	return &StreamHandler{
+		metricService: metricService,
+		hostService:   hostService,
+		hub:           hub,
+		heartbeat:     defaultHeartbeatInterval,
+		maxReplay:     defaultMaxReplayEvents,
+	}
+}
+
+// Metrics godoc
+// @Summary      Stream live metrics
+// @Description  Server-Sent Events stream of newly ingested metrics. Send Last-Event-ID (or last_event_id) to resume after a disconnect.
+// @Description  A resume replays at most 10000 metrics; if more were missed a "truncated" event carrying the last replayed ID is sent before live events, and the rest should be fetched over REST.
+// @Tags         metrics
+// @Produce      text/event-stream
+// @Param        host_id        query   int     false  "Only stream metrics for this host"
+// @Param        role           query   string  false  "Only stream metrics for hosts with this role"
+// @Param        last_event_id  query   int     false  "Replay metrics stored after this ID before streaming"
+// @Param        Last-Event-ID  header  int     false  "Replay metrics stored after this ID before streaming"
+// @Success      200  {string}  string  "event: metric"
+// @Failure      400  {object}  models.ErrorResponse
+// @Router       /metrics/stream [get]
+func (handler *StreamHandler) Metrics(ctx *gin.Context) {
+	var queryParams entities.MetricStreamQueryParams
+	if err := ctx.ShouldBindQuery(&queryParams); err != nil {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if queryParams.HostID != nil && *queryParams.HostID <= 0 {
+		ctx.JSON(400, models.ErrorResponse{
+			Error:   "Invalid query parameters",
+			Details: services.ErrInvalidHostID.Error(),
+		})
+		return
+	}
+
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		lastEventID, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || lastEventID < 0 {
+			ctx.JSON(400, models.ErrorResponse{
+				Error:   "Invalid Last-Event-ID header",
+				Details: "Must be a non-negative integer",
+			})
+			return
+		}
+		queryParams.LastEventID = &lastEventID
+	}
+
+	// Subscribe before replaying so nothing stored in between is missed
+	subscription := handler.hub.Subscribe(streamBufferSize)
+	defer subscription.Close()
+
+	filter := newHostFilter(ctx.Request.Context(), handler.hostService, queryParams.HostID, queryParams.Role)
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(200)
+	ctx.Writer.Flush()
+
+	stream := &metricStream{
+		writer:    ctx.Writer,
+		service:   handler.metricService,
+		hostID:    queryParams.HostID,
+		filter:    filter,
+		maxReplay: handler.maxReplay,
+	}
+
+	if queryParams.LastEventID != nil {
+		stream.lastSent = *queryParams.LastEventID
+		if err := stream.replay(ctx.Request.Context()); err != nil {
+			stream.writeError(err)
+			return
+		}
+	}
+
+	ticker := time.NewTicker(handler.heartbeat)
+	defer ticker.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+
+			// The client fell behind and events were dropped; catch up from the database
+			if current := subscription.Dropped(); current != dropped {
+				dropped = current
+				if err := stream.replay(ctx.Request.Context()); err != nil {
+					stream.writeError(err)
+					return
+				}
+			}
+
+			// Keep the role filter in step with hosts created, re-roled or deleted while streaming
+			if change, isHostChange := event.Data.(entities.HostStatusChange); isHostChange {
+				filter.hostChanged(change)
+				continue
+			}
+
+			metric, isMetric := event.Data.(entities.SystemMetric)
+			if !isMetric || !filter.matches(event.HostID) {
+				continue
+			}
+			if event.Replaced {
+				if err := stream.sendReplaced(metric); err != nil {
+					return
+				}
+				continue
+			}
+
+			// Only metrics a replay has already read are skipped. Live events can arrive
+			// slightly out of ID order, as each writer publishes once its own commit returns
+			if event.ID <= stream.replayedTo {
+				continue
+			}
+			if err := stream.send(metric); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// metricStream tracks the metrics written to a single SSE client. Replays rely on
+// metric IDs becoming visible in ID order, which both storage backends guarantee by
+// serialising metric writes.
+type metricStream struct {
+	writer     gin.ResponseWriter
+	service    services.MetricServiceInterface
+	hostID     *int64
+	filter     *hostFilter
+	maxReplay  int
+	lastSent   int64 // highest ID sent
+	replayedTo int64 // highest ID read by a replay; live events up to it were already sent
+}
+
+// replay sends metrics stored after the last one sent, in ID order. When more than
+// maxReplay are missing the client is told where replay stopped so it can backfill
+func (stream *metricStream) replay(ctx context.Context) error {
+	defer func() {
+		stream.replayedTo = stream.lastSent
+	}()
+
+	for replayed := 0; replayed < stream.maxReplay; {
+		batch, err := stream.service.GetMetricsAfterID(ctx, stream.lastSent, stream.hostID, replayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, metric := range batch {
+			if stream.filter.matches(metric.HostID) {
+				if err := stream.send(metric); err != nil {
+					return err
+				}
+			}
+			stream.lastSent = metric.ID
+		}
+
+		replayed += len(batch)
+		if len(batch) < replayBatchSize {
+			return nil
+		}
+	}
+	return stream.writeTruncated()
+}
+
+// send writes a metric as an SSE event and flushes it to the client
+func (stream *metricStream) send(metric entities.SystemMetric) error {
+	data, err := json.Marshal(toModelMetric(metric))
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stream.writer, "id: %d\nevent: metric\ndata: %s\n\n", metric.ID, data); err != nil {
+		return err
+	}
+	stream.writer.Flush()
+
+	stream.lastSent = max(stream.lastSent, metric.ID)
+	return nil
+}
+
+// sendReplaced writes the new values of a metric already stored. The event has no ID,
+// so the client's Last-Event-ID still marks the newest metric it has been sent.
+func (stream *metricStream) sendReplaced(metric entities.SystemMetric) error {
+	data, err := json.Marshal(toModelMetric(metric))
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stream.writer, "event: metric\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	stream.writer.Flush()
+	return nil
+}
+
+// writeTruncated tells the client that replay stopped early and live events resume from here
+func (stream *metricStream) writeTruncated() error {
+	data, err := json.Marshal(models.StreamTruncated{LastEventID: stream.lastSent})
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stream.writer, "event: truncated\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	stream.writer.Flush()
+	return nil
+}
+
+// writeError reports a failure to the client before the stream is closed
+func (stream *metricStream) writeError(err error) {
+	data, _ := json.Marshal(models.ErrorResponse{
+		Error:   "Failed to replay metrics",
+		Details: err.Error(),
+	})
+	_, _ = fmt.Fprintf(stream.writer, "event: error\ndata: %s\n\n", data)
+	stream.writer.Flush()
+}
+
+// hostFilter decides whether events for a host should be delivered to a subscriber
+type hostFilter struct {
+	ctx         context.Context // scoped to the subscriber's connection
+	hostService services.HostServiceInterface
+	hostID      *int64
+	role        string
+	roleMatches map[int64]bool
+}
+
+func newHostFilter(ctx context.Context, hostService services.HostServiceInterface, hostID *int64, role string) *hostFilter {
+	filter := &hostFilter{
+		ctx:         ctx,
+		hostService: hostService,
+		hostID:      hostID,
+		role:        role,
+		roleMatches: make(map[int64]bool),
+	}
+
+	if role != "" {
+		if hosts, _, err := hostService.GetHosts(ctx, &entities.HostQueryParams{Role: role}); err == nil {
+			for _, host := range hosts {
+				filter.roleMatches[host.ID] = true
+			}
+		}
+	}
+
+	return filter
+}
+
+// matches reports whether a host passes the filter, looking up hosts not seen before
+func (filter *hostFilter) matches(hostID int64) bool {
+	if filter.hostID != nil && hostID != *filter.hostID {
+		return false
+	}
+	if filter.role == "" {
+		return true
+	}
+
+	match, known := filter.roleMatches[hostID]
+	if !known {
+		hosts, _, err := filter.hostService.GetHosts(filter.ctx, &entities.HostQueryParams{ID: hostID})
+		if err != nil {
+			return false
+		}
+		match = len(hosts) == 1 && hosts[0].Role == filter.role
+		filter.roleMatches[hostID] = match
+	}
+
+	return match
+}
+
+// hostChanged updates the role cache from a host status change. It reports whether the
+// change concerns a matching host; a host that had the role before the change still
+// matches, so subscribers see hosts leave the role as well as join it
+func (filter *hostFilter) hostChanged(change entities.HostStatusChange) bool {
+	host := change.Host
+	if filter.hostID != nil && host.ID != *filter.hostID {
+		return false
+	}
+	if filter.role == "" {
+		return true
+	}
+
+	had := filter.roleMatches[host.ID]
+	has := host.Role == filter.role
+	if change.Status == entities.HostStatusDeleted {
+		delete(filter.roleMatches, host.ID)
+	} else {
+		filter.roleMatches[host.ID] = has
+	}
+
+	return had || has
+}
@@ -0,0 +1,281 @@
+// nolint
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/events"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// StreamHandlerTestSuite is the test suite for StreamHandler
+type StreamHandlerTestSuite struct {
+	suite.Suite
+	router            *gin.Engine
+	mockMetricService *mocks.MockMetricService
+	mockHostService   *mocks.MockHostService
+	hub               *events.Hub
+	handler           *StreamHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *StreamHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.mockMetricService = new(mocks.MockMetricService)
+	suite.mockHostService = new(mocks.MockHostService)
+	suite.hub = events.NewHub()
+	suite.handler = NewStreamHandler(suite.mockMetricService, suite.mockHostService, suite.hub)
+
+	// Register routes
+	suite.router.GET("/metrics/stream", suite.handler.Metrics)
+}
+
+// TearDownTest runs after each test
+func (suite *StreamHandlerTestSuite) TearDownTest() {
+	suite.mockMetricService.AssertExpectations(suite.T())
+	suite.mockHostService.AssertExpectations(suite.T())
+}
+
+// stream opens the stream, runs publish once subscribed, then disconnects and returns the body
+func (suite *StreamHandlerTestSuite) stream(req *http.Request, publish func()) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+	req = req.WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		suite.router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	assert.Eventually(suite.T(), func() bool { return suite.hub.SubscriberCount() == 1 }, time.Second, time.Millisecond)
+	publish()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	return w
+}
+
+// TestStreamsLiveMetrics tests that published metrics are written as SSE events
+func (suite *StreamHandlerTestSuite) TestStreamsLiveMetrics() {
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?host_id=2", nil)
+
+	w := suite.stream(req, func() {
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 10, HostID: 1, CPUUsage: 5})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 11, HostID: 2, CPUUsage: 42.5})
+	})
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "text/event-stream", w.Header().Get("Content-Type"))
+	assert.NotContains(suite.T(), w.Body.String(), "id: 10\n")
+	assert.Contains(suite.T(), w.Body.String(), "id: 11\nevent: metric\ndata: {\"id\":11,\"host_id\":2")
+}
+
+// TestResumeFromLastEventID tests that missed metrics are replayed from the database first
+func (suite *StreamHandlerTestSuite) TestResumeFromLastEventID() {
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(20), (*int64)(nil), replayBatchSize).
+		Return([]entities.SystemMetric{{ID: 21, HostID: 1}, {ID: 22, HostID: 1}}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream", nil)
+	req.Header.Set("Last-Event-ID", "20")
+
+	w := suite.stream(req, func() {
+		// Already replayed, so it must not be sent twice
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 22, HostID: 1})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 23, HostID: 1})
+	})
+
+	body := w.Body.String()
+	assert.Less(suite.T(), strings.Index(body, "id: 21\n"), strings.Index(body, "id: 22\n"))
+	assert.Equal(suite.T(), 1, strings.Count(body, "id: 22\n"))
+	assert.Contains(suite.T(), body, "id: 23\n")
+}
+
+// TestStreamsReplacedMetrics tests that new values for a stored metric are sent without
+// an event ID, even when a replay has already passed the metric
+func (suite *StreamHandlerTestSuite) TestStreamsReplacedMetrics() {
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(20), (*int64)(nil), replayBatchSize).
+		Return([]entities.SystemMetric{{ID: 21, HostID: 1}}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream", nil)
+	req.Header.Set("Last-Event-ID", "20")
+
+	w := suite.stream(req, func() {
+		suite.hub.PublishReplacedMetric(entities.SystemMetric{ID: 21, HostID: 1, CPUUsage: 99})
+	})
+
+	body := w.Body.String()
+	assert.Equal(suite.T(), 1, strings.Count(body, "id: 21\n"))
+	assert.Contains(suite.T(), body, "\n\nevent: metric\ndata: {\"id\":21,\"host_id\":1,\"timestamp\":0,\"cpu_usage\":99")
+}
+
+// TestLiveMetricsOutOfOrder tests that a live metric published after one with a higher ID is still sent
+func (suite *StreamHandlerTestSuite) TestLiveMetricsOutOfOrder() {
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(20), (*int64)(nil), replayBatchSize).
+		Return([]entities.SystemMetric{{ID: 21, HostID: 1}}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream", nil)
+	req.Header.Set("Last-Event-ID", "20")
+
+	w := suite.stream(req, func() {
+		// Two writers whose commits returned close together can publish in either order
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 21, HostID: 1})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 23, HostID: 1})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 22, HostID: 1})
+	})
+
+	body := w.Body.String()
+	assert.Equal(suite.T(), 1, strings.Count(body, "id: 21\n"))
+	assert.Less(suite.T(), strings.Index(body, "id: 23\n"), strings.Index(body, "id: 22\n"))
+}
+
+// TestRoleFilter tests that only metrics for hosts with the requested role are streamed
+func (suite *StreamHandlerTestSuite) TestRoleFilter() {
+	suite.mockHostService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Role: "nas"}).
+		Return([]entities.Host{{ID: 3, Role: "nas"}}, entities.PageInfo{}, nil).Once()
+	suite.mockHostService.On("GetHosts", mock.Anything, &entities.HostQueryParams{ID: 4}).
+		Return([]entities.Host{{ID: 4, Role: "web"}}, entities.PageInfo{}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?role=nas", nil)
+
+	w := suite.stream(req, func() {
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 30, HostID: 3})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 31, HostID: 4})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 32, HostID: 4})
+	})
+
+	assert.Contains(suite.T(), w.Body.String(), "id: 30\n")
+	assert.NotContains(suite.T(), w.Body.String(), "id: 31\n")
+	assert.NotContains(suite.T(), w.Body.String(), "id: 32\n")
+}
+
+// TestRoleFilterFollowsHostChanges tests that the role filter is refreshed when a host's role changes
+func (suite *StreamHandlerTestSuite) TestRoleFilterFollowsHostChanges() {
+	suite.mockHostService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Role: "nas"}).
+		Return([]entities.Host{{ID: 3, Role: "nas"}}, entities.PageInfo{}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?role=nas", nil)
+
+	w := suite.stream(req, func() {
+		suite.hub.PublishHostStatus(entities.HostStatusChange{
+			Status: entities.HostStatusUpdated,
+			Host:   entities.Host{ID: 3, Role: "web"},
+		})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 40, HostID: 3})
+		suite.hub.PublishHostStatus(entities.HostStatusChange{
+			Status: entities.HostStatusCreated,
+			Host:   entities.Host{ID: 5, Role: "nas"},
+		})
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 41, HostID: 5})
+	})
+
+	assert.NotContains(suite.T(), w.Body.String(), "id: 40\n")
+	assert.Contains(suite.T(), w.Body.String(), "id: 41\n")
+}
+
+// TestReplayTruncated tests that a resume with more missed metrics than can be replayed says where it stopped
+func (suite *StreamHandlerTestSuite) TestReplayTruncated() {
+	suite.handler.maxReplay = 2 * replayBatchSize
+
+	firstBatch := make([]entities.SystemMetric, replayBatchSize)
+	secondBatch := make([]entities.SystemMetric, replayBatchSize)
+	for i := range firstBatch {
+		firstBatch[i] = entities.SystemMetric{ID: int64(i + 1), HostID: 1}
+		secondBatch[i] = entities.SystemMetric{ID: int64(replayBatchSize + i + 1), HostID: 1}
+	}
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(0), (*int64)(nil), replayBatchSize).
+		Return(firstBatch, nil).Once()
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(replayBatchSize), (*int64)(nil), replayBatchSize).
+		Return(secondBatch, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?last_event_id=0", nil)
+
+	w := suite.stream(req, func() {
+		suite.hub.PublishMetric(entities.SystemMetric{ID: 5000, HostID: 1})
+	})
+
+	body := w.Body.String()
+	truncated := strings.Index(body, "event: truncated\ndata: {\"last_event_id\":1000}\n\n")
+	assert.Greater(suite.T(), truncated, strings.Index(body, "id: 1000\n"))
+	assert.Less(suite.T(), truncated, strings.Index(body, "id: 5000\n"))
+}
+
+// TestReplayComplete tests that a resume which catches up fully is not reported as truncated
+func (suite *StreamHandlerTestSuite) TestReplayComplete() {
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(20), (*int64)(nil), replayBatchSize).
+		Return([]entities.SystemMetric{{ID: 21, HostID: 1}}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?last_event_id=20", nil)
+
+	w := suite.stream(req, func() {})
+
+	assert.Contains(suite.T(), w.Body.String(), "id: 21\n")
+	assert.NotContains(suite.T(), w.Body.String(), "event: truncated")
+}
+
+// TestHeartbeat tests that idle streams receive heartbeat comments
+func (suite *StreamHandlerTestSuite) TestHeartbeat() {
+	suite.handler.heartbeat = 10 * time.Millisecond
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream", nil)
+
+	w := suite.stream(req, func() {})
+
+	assert.Contains(suite.T(), w.Body.String(), ": heartbeat\n\n")
+}
+
+// TestReplayError tests that a database failure during resume is reported as an error event
+func (suite *StreamHandlerTestSuite) TestReplayError() {
+	suite.mockMetricService.On("GetMetricsAfterID", mock.Anything, int64(5), (*int64)(nil), replayBatchSize).
+		Return(nil, errors.New("database is locked")).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics/stream?last_event_id=5", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Contains(suite.T(), w.Body.String(), "event: error\n")
+	assert.Contains(suite.T(), w.Body.String(), "database is locked")
+}
+
+// TestInvalidParameters tests that malformed filters are rejected before streaming
+func (suite *StreamHandlerTestSuite) TestInvalidParameters() {
+	tests := []struct {
+		name   string
+		path   string
+		header string
+	}{
+		{name: "invalid_host_id", path: "/metrics/stream?host_id=0"},
+		{name: "non_numeric_host_id", path: "/metrics/stream?host_id=abc"},
+		{name: "invalid_last_event_id_header", path: "/metrics/stream", header: "abc"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			req, _ := http.NewRequest(http.MethodGet, test.path, nil)
+			if test.header != "" {
+				req.Header.Set("Last-Event-ID", test.header)
+			}
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+// Run the test suite
+func TestStreamHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(StreamHandlerTestSuite))
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gin-gonic/gin"
+)
+
+type TelemetryHandler struct {
+	metrics *telemetry.Metrics
+}
+
+func NewTelemetryHandler(metrics *telemetry.Metrics) *TelemetryHandler {
+	return &TelemetryHandler{metrics: metrics}
+}
+
+// Metrics godoc
+// @Summary      API self-metrics
+// @Description  Request counts and latency per route and status, database call latency per repository method, connection pool stats, ingest throughput and background job outcomes for the API itself, in the Prometheus text format
+// @Tags         system
+// @Produce      plain
+// @Success      200  {string}  string
+// @Failure      500  {string}  string
+// @Router       /internal/metrics [get]
+func (handler *TelemetryHandler) Metrics(ctx *gin.Context) {
+	handler.metrics.Handler().ServeHTTP(ctx.Writer, ctx.Request)
+}
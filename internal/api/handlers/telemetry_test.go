@@ -0,0 +1,59 @@
+// nolint
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// TelemetryHandlerTestSuite is the test suite for TelemetryHandler
+type TelemetryHandlerTestSuite struct {
+	suite.Suite
+	router  *gin.Engine
+	metrics *telemetry.Metrics
+	handler *TelemetryHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *TelemetryHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.metrics = telemetry.New()
+	suite.handler = NewTelemetryHandler(suite.metrics)
+
+	// Register routes
+	suite.router.GET("/internal/metrics", suite.handler.Metrics)
+}
+
+// TestNewTelemetryHandler tests the constructor
+func (suite *TelemetryHandlerTestSuite) TestNewTelemetryHandler() {
+	assert.NotNil(suite.T(), suite.handler)
+	assert.Equal(suite.T(), suite.metrics, suite.handler.metrics)
+}
+
+// TestMetrics tests the Metrics endpoint
+func (suite *TelemetryHandlerTestSuite) TestMetrics() {
+	suite.metrics.ObserveJob("backup", telemetry.OutcomeOK, time.Second)
+
+	req, err := http.NewRequest(http.MethodGet, "/internal/metrics", nil)
+	assert.NoError(suite.T(), err)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(suite.T(), w.Body.String(), `monitor_api_job_runs_total{job="backup",outcome="ok"} 1`)
+}
+
+// Run the test suite
+func TestTelemetryHandlerTestSuite(test *testing.T) {
+	suite.Run(test, new(TelemetryHandlerTestSuite))
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/events"
+	"github.com/gabrielg2020/monitor-api/internal/middleware"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxWebSocketMessageBytes bounds the size of a single client message
+	maxWebSocketMessageBytes = 4096
+
+	defaultPingInterval = 30 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+)
+
+// WebSocket protocol actions and message types
+const (
+	wsActionSubscribe   = "subscribe"
+	wsActionUnsubscribe = "unsubscribe"
+
+	wsTypeSubscribed   = "subscribed"
+	wsTypeUnsubscribed = "unsubscribed"
+	wsTypeEvent        = "event"
+	wsTypeDropped      = "dropped"
+	wsTypeError        = "error"
+)
+
+var errUnknownTopic = errors.New("unknown topic, expected metrics or host_status")
+
+type WebSocketHandler struct {
+	hostService    services.HostServiceInterface
+	hub            *events.Hub
+	upgrader       websocket.Upgrader
+	maxConnections int64
+	connections    atomic.Int64
+	bufferSize     int
+	pingInterval   time.Duration
+	writeTimeout   time.Duration
+}
+
+func NewWebSocketHandler(
+	hostService services.HostServiceInterface,
+	hub *events.Hub,
+	allowedOrigins *middleware.Origins,
+	maxConnections int,
+) *WebSocketHandler {
+	return &WebSocketHandler{
+		hostService: hostService,
+		hub:         hub,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(req *http.Request) bool {
+				return originAllowed(req, allowedOrigins)
+			},
+		},
+		maxConnections: int64(maxConnections),
+		bufferSize:     streamBufferSize,
+		pingInterval:   defaultPingInterval,
+		writeTimeout:   defaultWriteTimeout,
+	}
+}
+
+// Connect godoc
+// @Summary      Subscribe to live events over WebSocket
+// @Description  Upgrades to a WebSocket. Send models.WebSocketRequest messages to subscribe to or unsubscribe from the metrics and host_status topics; events arrive as models.WebSocketMessage. Clients that fall behind receive a "dropped" message with the number of events they missed.
+// @Tags         stream
+// @Success      101  {object}  models.WebSocketMessage
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      503  {object}  models.ErrorResponse
+// @Router       /ws [get]
+func (handler *WebSocketHandler) Connect(ctx *gin.Context) {
+	if handler.connections.Add(1) > handler.maxConnections {
+		handler.connections.Add(-1)
+		ctx.JSON(503, models.ErrorResponse{
+			Error:   "Too many WebSocket connections",
+			Details: fmt.Sprintf("The server accepts at most %d concurrent connections", handler.maxConnections),
+		})
+		return
+	}
+	defer handler.connections.Add(-1)
+
+	// Upgrade writes its own error response on failure
+	conn, err := handler.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	subscription := handler.hub.Subscribe(handler.bufferSize)
+	defer subscription.Close()
+
+	session := &wsSession{
+		ctx:           ctx.Request.Context(),
+		conn:          conn,
+		hostService:   handler.hostService,
+		writeTimeout:  handler.writeTimeout,
+		subscriptions: make(map[string]*hostFilter),
+		closed:        make(chan struct{}),
+	}
+
+	requests := make(chan wsIncoming)
+	readerDone := make(chan struct{})
+	defer close(session.closed)
+	go session.readRequests(requests, readerDone, 2*handler.pingInterval)
+
+	ticker := time.NewTicker(handler.pingInterval)
+	defer ticker.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-readerDone:
+			return
+		case request := <-requests:
+			if err := session.handleRequest(request); err != nil {
+				return
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+
+			// Tell slow clients how much they missed so they can refetch
+			if current := subscription.Dropped(); current != dropped {
+				missed := current - dropped
+				dropped = current
+				if err := session.write(models.WebSocketMessage{Type: wsTypeDropped, Dropped: missed}); err != nil {
+					return
+				}
+			}
+
+			if err := session.deliver(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(handler.writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsSession holds the topic subscriptions of a single WebSocket connection. It is
+// only used from the connection's write loop.
+type wsSession struct {
+	ctx           context.Context // the request context, cancelled when Connect returns
+	conn          *websocket.Conn
+	hostService   services.HostServiceInterface
+	writeTimeout  time.Duration
+	subscriptions map[string]*hostFilter
+	closed        chan struct{}
+}
+
+// wsIncoming is a client message, or the reason it could not be decoded
+type wsIncoming struct {
+	request models.WebSocketRequest
+	err     error
+}
+
+// readRequests forwards client messages to the write loop until the connection closes
+func (session *wsSession) readRequests(requests chan<- wsIncoming, done chan<- struct{}, pongWait time.Duration) {
+	defer close(done)
+
+	session.conn.SetReadLimit(maxWebSocketMessageBytes)
+	_ = session.conn.SetReadDeadline(time.Now().Add(pongWait))
+	session.conn.SetPongHandler(func(string) error {
+		return session.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := session.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var incoming wsIncoming
+		if err := json.Unmarshal(message, &incoming.request); err != nil {
+			incoming.err = fmt.Errorf("invalid message: %w", err)
+		}
+
+		// The write loop stops reading requests once it returns
+		select {
+		case requests <- incoming:
+		case <-session.closed:
+			return
+		}
+	}
+}
+
+// handleRequest applies a subscribe or unsubscribe request and acknowledges it
+func (session *wsSession) handleRequest(incoming wsIncoming) error {
+	request := incoming.request
+	if incoming.err != nil {
+		return session.writeError("", incoming.err)
+	}
+
+	switch request.Action {
+	case wsActionSubscribe:
+		if !isKnownTopic(request.Topic) {
+			return session.writeError(request.Topic, errUnknownTopic)
+		}
+		if request.HostID != nil && *request.HostID <= 0 {
+			return session.writeError(request.Topic, services.ErrInvalidHostID)
+		}
+
+		// Subscribing again replaces the filter for the topic
+		session.subscriptions[request.Topic] = newHostFilter(session.ctx, session.hostService, request.HostID, request.Role)
+		return session.write(models.WebSocketMessage{Type: wsTypeSubscribed, Topic: request.Topic})
+	case wsActionUnsubscribe:
+		if !isKnownTopic(request.Topic) {
+			return session.writeError(request.Topic, errUnknownTopic)
+		}
+
+		delete(session.subscriptions, request.Topic)
+		return session.write(models.WebSocketMessage{Type: wsTypeUnsubscribed, Topic: request.Topic})
+	default:
+		return session.writeError(request.Topic, errors.New("invalid action, expected subscribe or unsubscribe"))
+	}
+}
+
+// deliver writes an event if the client is subscribed to its topic and host
+func (session *wsSession) deliver(event events.Event) error {
+	if change, isHostChange := event.Data.(entities.HostStatusChange); isHostChange {
+		// Every subscription's role cache follows host changes, not just host_status ones
+		deliver := false
+		for topic, filter := range session.subscriptions {
+			if filter.hostChanged(change) && topic == event.Topic {
+				deliver = true
+			}
+		}
+		if !deliver {
+			return nil
+		}
+	} else if filter, subscribed := session.subscriptions[event.Topic]; !subscribed || !filter.matches(event.HostID) {
+		return nil
+	}
+
+	var data interface{}
+	switch payload := event.Data.(type) {
+	case entities.SystemMetric:
+		data = toModelMetric(payload)
+	case entities.HostStatusChange:
+		data = models.HostStatusChange{Status: payload.Status, Host: toModelHost(payload.Host)}
+	default:
+		return nil
+	}
+
+	return session.write(models.WebSocketMessage{Type: wsTypeEvent, Topic: event.Topic, Data: data})
+}
+
+// write sends a message, failing if the client does not accept it in time
+func (session *wsSession) write(message models.WebSocketMessage) error {
+	if err := session.conn.SetWriteDeadline(time.Now().Add(session.writeTimeout)); err != nil {
+		return err
+	}
+	return session.conn.WriteJSON(message)
+}
+
+func (session *wsSession) writeError(topic string, err error) error {
+	return session.write(models.WebSocketMessage{Type: wsTypeError, Topic: topic, Error: err.Error()})
+}
+
+func isKnownTopic(topic string) bool {
+	return topic == events.TopicMetrics || topic == events.TopicHostStatus
+}
+
+// originAllowed accepts requests without an Origin header, same-origin requests and
+// origins allowed by the CORS configuration
+func originAllowed(req *http.Request, allowedOrigins *middleware.Origins) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" || allowedOrigins.Allowed(origin) {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == req.Host
+}
@@ -0,0 +1,271 @@
+// nolint
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/events"
+	"github.com/gabrielg2020/monitor-api/internal/middleware"
+	"github.com/gabrielg2020/monitor-api/internal/models"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// WebSocketHandlerTestSuite is the test suite for WebSocketHandler
+type WebSocketHandlerTestSuite struct {
+	suite.Suite
+	server          *httptest.Server
+	mockHostService *mocks.MockHostService
+	hub             *events.Hub
+	handler         *WebSocketHandler
+}
+
+// SetupTest runs before each test in the suite
+func (suite *WebSocketHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	suite.mockHostService = new(mocks.MockHostService)
+	suite.hub = events.NewHub()
+	suite.handler = NewWebSocketHandler(suite.mockHostService, suite.hub, middleware.NewOrigins([]string{"http://dashboard.local"}), 2)
+
+	// Register routes
+	router.GET("/ws", suite.handler.Connect)
+	suite.server = httptest.NewServer(router)
+}
+
+// TearDownTest runs after each test
+func (suite *WebSocketHandlerTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.mockHostService.AssertExpectations(suite.T())
+}
+
+// dial opens a WebSocket connection to the test server
+func (suite *WebSocketHandlerTestSuite) dial(header http.Header) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(suite.server.URL, "http") + "/ws"
+	return websocket.DefaultDialer.Dial(url, header)
+}
+
+// request sends a client message and returns the server's reply
+func (suite *WebSocketHandlerTestSuite) request(conn *websocket.Conn, request models.WebSocketRequest) models.WebSocketMessage {
+	assert.NoError(suite.T(), conn.WriteJSON(request))
+	return suite.read(conn)
+}
+
+// read returns the next server message
+func (suite *WebSocketHandlerTestSuite) read(conn *websocket.Conn) models.WebSocketMessage {
+	var message models.WebSocketMessage
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	assert.NoError(suite.T(), conn.ReadJSON(&message))
+	return message
+}
+
+// TestSubscribeToMetrics tests that only subscribed topics and hosts are delivered
+func (suite *WebSocketHandlerTestSuite) TestSubscribeToMetrics() {
+	conn, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer conn.Close()
+
+	hostID := int64(2)
+	reply := suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "metrics", HostID: &hostID})
+	assert.Equal(suite.T(), models.WebSocketMessage{Type: "subscribed", Topic: "metrics"}, reply)
+
+	suite.hub.PublishHostStatus(entities.HostStatusChange{Status: entities.HostStatusCreated, Host: entities.Host{ID: 2}})
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 10, HostID: 1})
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 11, HostID: 2, CPUUsage: 42.5})
+
+	event := suite.read(conn)
+	assert.Equal(suite.T(), "event", event.Type)
+	assert.Equal(suite.T(), "metrics", event.Topic)
+	assert.Equal(suite.T(), float64(11), event.Data.(map[string]interface{})["id"])
+	assert.Equal(suite.T(), 42.5, event.Data.(map[string]interface{})["cpu_usage"])
+}
+
+// TestSubscribeToHostStatusAndUnsubscribe tests switching topics over one connection
+func (suite *WebSocketHandlerTestSuite) TestSubscribeToHostStatusAndUnsubscribe() {
+	conn, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer conn.Close()
+
+	suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "metrics"})
+	suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "host_status"})
+	reply := suite.request(conn, models.WebSocketRequest{Action: "unsubscribe", Topic: "metrics"})
+	assert.Equal(suite.T(), "unsubscribed", reply.Type)
+
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 12, HostID: 3})
+	suite.hub.PublishHostStatus(entities.HostStatusChange{
+		Status: entities.HostStatusUpdated,
+		Host:   entities.Host{ID: 3, Hostname: "pi-03", Role: "nas"},
+	})
+
+	event := suite.read(conn)
+	assert.Equal(suite.T(), "host_status", event.Topic)
+	assert.Equal(suite.T(), "updated", event.Data.(map[string]interface{})["status"])
+	assert.Equal(suite.T(), "pi-03", event.Data.(map[string]interface{})["host"].(map[string]interface{})["hostname"])
+}
+
+// TestRoleSubscriptionsFollowHostChanges tests that role subscribers see hosts join, leave and
+// be deleted, and that their role caches are updated so later metrics are filtered correctly
+func (suite *WebSocketHandlerTestSuite) TestRoleSubscriptionsFollowHostChanges() {
+	suite.mockHostService.On("GetHosts", mock.Anything, &entities.HostQueryParams{Role: "nas"}).
+		Return([]entities.Host{{ID: 3, Role: "nas"}, {ID: 4, Role: "nas"}}, entities.PageInfo{}, nil).Twice()
+	suite.mockHostService.On("GetHosts", mock.Anything, &entities.HostQueryParams{ID: 4}).
+		Return([]entities.Host{}, entities.PageInfo{}, nil).Once()
+
+	conn, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer conn.Close()
+
+	suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "metrics", Role: "nas"})
+	suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "host_status", Role: "nas"})
+
+	suite.hub.PublishHostStatus(entities.HostStatusChange{Status: entities.HostStatusUpdated, Host: entities.Host{ID: 3, Role: "web"}})
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 20, HostID: 3})
+	suite.hub.PublishHostStatus(entities.HostStatusChange{Status: entities.HostStatusDeleted, Host: entities.Host{ID: 4, Role: "nas"}})
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 21, HostID: 4})
+	suite.hub.PublishHostStatus(entities.HostStatusChange{Status: entities.HostStatusCreated, Host: entities.Host{ID: 6, Role: "web"}})
+	suite.hub.PublishHostStatus(entities.HostStatusChange{Status: entities.HostStatusCreated, Host: entities.Host{ID: 7, Role: "nas"}})
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 22, HostID: 7})
+
+	var received []string
+	for range 4 {
+		event := suite.read(conn)
+		data := event.Data.(map[string]interface{})
+		if event.Topic == "metrics" {
+			received = append(received, fmt.Sprintf("metric %v", data["id"]))
+		} else {
+			received = append(received, fmt.Sprintf("%v %v", data["status"], data["host"].(map[string]interface{})["id"]))
+		}
+	}
+	assert.Equal(suite.T(), []string{"updated 3", "deleted 4", "created 7", "metric 22"}, received)
+}
+
+// TestInvalidRequests tests that bad messages are reported without closing the connection
+func (suite *WebSocketHandlerTestSuite) TestInvalidRequests() {
+	conn, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer conn.Close()
+
+	invalidHostID := int64(0)
+	tests := []struct {
+		name    string
+		request models.WebSocketRequest
+	}{
+		{name: "unknown_topic", request: models.WebSocketRequest{Action: "subscribe", Topic: "alerts"}},
+		{name: "unknown_action", request: models.WebSocketRequest{Action: "publish", Topic: "metrics"}},
+		{name: "invalid_host_id", request: models.WebSocketRequest{Action: "subscribe", Topic: "metrics", HostID: &invalidHostID}},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			reply := suite.request(conn, test.request)
+			assert.Equal(suite.T(), "error", reply.Type)
+			assert.NotEmpty(suite.T(), reply.Error)
+		})
+	}
+
+	assert.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("{not json")))
+	reply := suite.read(conn)
+	assert.Equal(suite.T(), "error", reply.Type)
+	assert.Contains(suite.T(), reply.Error, "invalid message")
+}
+
+// TestSlowClientIsToldAboutDroppedEvents tests the backpressure notification
+func (suite *WebSocketHandlerTestSuite) TestSlowClientIsToldAboutDroppedEvents() {
+	suite.handler.bufferSize = 1
+	conn, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer conn.Close()
+
+	suite.request(conn, models.WebSocketRequest{Action: "subscribe", Topic: "metrics"})
+
+	// Publish far faster than the connection drains its one-event buffer
+	total := 1000
+	for i := 1; i <= total; i++ {
+		suite.hub.PublishMetric(entities.SystemMetric{ID: int64(i), HostID: 1})
+	}
+	time.Sleep(50 * time.Millisecond)
+	suite.hub.PublishMetric(entities.SystemMetric{ID: int64(total + 1), HostID: 1})
+
+	var delivered int
+	var dropped uint64
+	for {
+		message := suite.read(conn)
+		if message.Type == "dropped" {
+			dropped += message.Dropped
+			continue
+		}
+		if message.Type != "event" {
+			break
+		}
+		delivered++
+		if message.Data.(map[string]interface{})["id"] == float64(total+1) {
+			break
+		}
+	}
+
+	assert.Greater(suite.T(), dropped, uint64(0))
+	assert.Equal(suite.T(), total+1, delivered+int(dropped))
+}
+
+// TestConnectionLimit tests that connections beyond the limit are refused
+func (suite *WebSocketHandlerTestSuite) TestConnectionLimit() {
+	first, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	defer first.Close()
+	second, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+
+	_, resp, err := suite.dial(nil)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Closing a connection frees its slot
+	second.Close()
+	assert.Eventually(suite.T(), func() bool { return suite.handler.connections.Load() == 1 }, time.Second, time.Millisecond)
+
+	third, _, err := suite.dial(nil)
+	assert.NoError(suite.T(), err)
+	third.Close()
+}
+
+// TestOriginCheck tests that cross-origin upgrades must match the allowed origins
+func (suite *WebSocketHandlerTestSuite) TestOriginCheck() {
+	tests := []struct {
+		name      string
+		origin    string
+		expectErr bool
+	}{
+		{name: "allowed_origin", origin: "http://dashboard.local"},
+		{name: "same_origin", origin: suite.server.URL},
+		{name: "disallowed_origin", origin: "http://evil.example", expectErr: true},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			conn, resp, err := suite.dial(http.Header{"Origin": []string{test.origin}})
+			if test.expectErr {
+				assert.Error(suite.T(), err)
+				assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			conn.Close()
+			assert.Eventually(suite.T(), func() bool { return suite.handler.connections.Load() == 0 }, time.Second, time.Millisecond)
+		})
+	}
+}
+
+// Run the test suite
+func TestWebSocketHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(WebSocketHandlerTestSuite))
+}
@@ -1,30 +1,71 @@
 package api
 
 import (
-	"database/sql"
+	"io"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"time"
 
 	"github.com/gabrielg2020/monitor-api/internal/api/handlers"
 	"github.com/gabrielg2020/monitor-api/internal/middleware"
-	"github.com/gabrielg2020/monitor-api/internal/repository"
-	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gabrielg2020/monitor-api/internal/tracing"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// Handlers groups the handlers served by the router
+type Handlers struct {
+	Health       handlers.HealthHandlerInterface
+	Host         handlers.HostHandlerInterface
+	Metric       handlers.MetricHandlerInterface
+	ScrapeTarget handlers.ScrapeTargetHandlerInterface
+	Stream       handlers.StreamHandlerInterface
+	WebSocket    handlers.WebSocketHandlerInterface
+	Archive      handlers.ArchiveHandlerInterface
+	Backup       handlers.BackupHandlerInterface
+	Maintenance  handlers.MaintenanceHandlerInterface
+	Telemetry    handlers.TelemetryHandlerInterface
+}
+
+// Timeouts bound how long requests may run before their queries are cancelled and
+// they fail with 504. Zero disables a timeout. Live streams and metric exports are
+// never timed out.
+type Timeouts struct {
+	Request time.Duration // health and API requests
+	Admin   time.Duration // archive, import and backup requests
+}
+
+// untracedRoutes are polled by probes and scrapers and would drown out API traces
+var untracedRoutes = map[string]bool{
+	"/health":           true,
+	"/health/live":      true,
+	"/health/ready":     true,
+	"/internal/metrics": true,
+	"/swagger/*any":     true,
+}
+
 // SetupRouter initialises the router with all routes and middleware
-func SetupRouter(
-	healthHandler handlers.HealthHandlerInterface,
-	hostHandler handlers.HostHandlerInterface,
-	metricHandler handlers.MetricHandlerInterface,
-	allowedOrigins []string,
-) *gin.Engine {
+func SetupRouter(h Handlers, allowedOrigins *middleware.Origins, timeouts Timeouts, logger *slog.Logger, metrics *telemetry.Metrics) *gin.Engine {
 	router := gin.New()
 
-	// Middleware
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	// Middleware; tracing comes first so request logs carry the trace ID
+	router.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return !untracedRoutes[c.FullPath()]
+	})))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Logger(logger))
+	router.Use(middleware.Metrics(metrics))
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logger.ErrorContext(c.Request.Context(), "panic recovered",
+			slog.Any("panic", recovered),
+			slog.String("stack", string(debug.Stack())),
+		)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
 	router.Use(middleware.CORS(allowedOrigins))
 
 	// 405 responses for known routes with unsupported methods
@@ -37,9 +78,16 @@ func SetupRouter(
 		})
 	})
 
+	requestTimeout := middleware.Timeout(timeouts.Request, nil)
+
 	// Health endpoints
-	router.GET("/health", healthHandler.GetHealth)
-	router.GET("/health/detailed", healthHandler.GetDetailedHealth)
+	router.GET("/health", requestTimeout, h.Health.GetHealth)
+	router.GET("/health/live", h.Health.GetLiveness)
+	router.GET("/health/ready", requestTimeout, h.Health.GetReadiness)
+	router.GET("/health/detailed", requestTimeout, h.Health.GetDetailedHealth)
+
+	// The API's own metrics, for Prometheus
+	router.GET("/internal/metrics", h.Telemetry.Metrics)
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -48,43 +96,46 @@ func SetupRouter(
 	v1 := router.Group("/api/v1")
 	{
 		// Host routes
-		hosts := v1.Group("/hosts")
+		hosts := v1.Group("/hosts", requestTimeout)
 		{
-			hosts.POST("", hostHandler.Create)
-			hosts.GET("", hostHandler.Get)
-			hosts.PUT("", hostHandler.Update)
-			hosts.DELETE("", hostHandler.Delete)
+			hosts.POST("", h.Host.Create)
+			hosts.GET("", h.Host.Get)
+			hosts.PUT("", h.Host.Update)
+			hosts.DELETE("", h.Host.Delete)
 		}
 
 		// Metric routes
 		metrics := v1.Group("/metrics")
 		{
-			metrics.POST("", metricHandler.Create)
-			metrics.GET("", metricHandler.Get)
-			metrics.GET("/latest", metricHandler.GetLatest)
+			metrics.POST("", requestTimeout, h.Metric.Create)
+			metrics.GET("", middleware.Timeout(timeouts.Request, handlers.IsMetricExport), h.Metric.Get)
+			metrics.GET("/latest", requestTimeout, h.Metric.GetLatest)
+			metrics.GET("/stream", h.Stream.Metrics)
+		}
+
+		// Scrape target routes
+		scrapeTargets := v1.Group("/scrape-targets", requestTimeout)
+		{
+			scrapeTargets.POST("", h.ScrapeTarget.Create)
+			scrapeTargets.GET("", h.ScrapeTarget.Get)
+			scrapeTargets.PUT("/:id", h.ScrapeTarget.Update)
+			scrapeTargets.DELETE("/:id", h.ScrapeTarget.Delete)
+		}
+
+		// WebSocket subscriptions
+		v1.GET("/ws", h.WebSocket.Connect)
+
+		// Admin routes
+		admin := v1.Group("/admin", middleware.Timeout(timeouts.Admin, nil))
+		{
+			admin.POST("/archive", h.Archive.Archive)
+			admin.POST("/archive/import", h.Archive.Import)
+			admin.POST("/backups", h.Backup.Create)
+			admin.GET("/backups", h.Backup.Get)
+			admin.POST("/maintenance", h.Maintenance.Run)
+			admin.GET("/maintenance", h.Maintenance.Get)
 		}
 	}
 
 	return router
 }
-
-// SetupRouterWithDB is a convenience function for production use
-// that creates handlers from a database connection
-func SetupRouterWithDB(db *sql.DB, allowedOrigins []string) *gin.Engine {
-	// Initialise repositories
-	healthRepo := repository.NewHealthRepository(db)
-	hostRepo := repository.NewHostRepository(db)
-	metricRepo := repository.NewMetricRepository(db)
-
-	// Initialise services
-	healthService := services.NewHealthService(healthRepo)
-	hostService := services.NewHostService(hostRepo)
-	metricService := services.NewMetricService(metricRepo)
-
-	// Initialise handlers
-	healthHandler := handlers.NewHealthHandler(healthService)
-	hostHandler := handlers.NewHostHandler(hostService)
-	metricHandler := handlers.NewMetricHandler(metricService)
-
-	return SetupRouter(healthHandler, hostHandler, metricHandler, allowedOrigins)
-}
@@ -2,10 +2,17 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gabrielg2020/monitor-api/internal/logging"
+	"github.com/gabrielg2020/monitor-api/internal/middleware"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,6 +25,13 @@ type RouterTestSuite struct {
 	mockHealthHandler *mocks.MockHealthHandler
 	mockHostHandler   *mocks.MockHostHandler
 	mockMetricHandler *mocks.MockMetricHandler
+	mockScrapeHandler *mocks.MockScrapeTargetHandler
+	mockStreamHandler *mocks.MockStreamHandler
+	mockWSHandler     *mocks.MockWebSocketHandler
+	mockArchive       *mocks.MockArchiveHandler
+	mockBackup        *mocks.MockBackupHandler
+	mockMaintenance   *mocks.MockMaintenanceHandler
+	mockTelemetry     *mocks.MockTelemetryHandler
 }
 
 // SetupTest runs before each test in the suite
@@ -25,6 +39,34 @@ func (suite *RouterTestSuite) SetupTest() {
 	suite.mockHealthHandler = new(mocks.MockHealthHandler)
 	suite.mockHostHandler = new(mocks.MockHostHandler)
 	suite.mockMetricHandler = new(mocks.MockMetricHandler)
+	suite.mockScrapeHandler = new(mocks.MockScrapeTargetHandler)
+	suite.mockStreamHandler = new(mocks.MockStreamHandler)
+	suite.mockWSHandler = new(mocks.MockWebSocketHandler)
+	suite.mockArchive = new(mocks.MockArchiveHandler)
+	suite.mockBackup = new(mocks.MockBackupHandler)
+	suite.mockMaintenance = new(mocks.MockMaintenanceHandler)
+	suite.mockTelemetry = new(mocks.MockTelemetryHandler)
+}
+
+// handlers returns the mock handlers in the shape SetupRouter expects
+func (suite *RouterTestSuite) handlers() Handlers {
+	return Handlers{
+		Health:       suite.mockHealthHandler,
+		Host:         suite.mockHostHandler,
+		Metric:       suite.mockMetricHandler,
+		ScrapeTarget: suite.mockScrapeHandler,
+		Stream:       suite.mockStreamHandler,
+		WebSocket:    suite.mockWSHandler,
+		Archive:      suite.mockArchive,
+		Backup:       suite.mockBackup,
+		Maintenance:  suite.mockMaintenance,
+		Telemetry:    suite.mockTelemetry,
+	}
+}
+
+// discardLogger returns a logger that drops every record
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
 }
 
 // TearDownTest runs after each test
@@ -32,17 +74,19 @@ func (suite *RouterTestSuite) TearDownTest() {
 	suite.mockHealthHandler.AssertExpectations(suite.T())
 	suite.mockHostHandler.AssertExpectations(suite.T())
 	suite.mockMetricHandler.AssertExpectations(suite.T())
+	suite.mockScrapeHandler.AssertExpectations(suite.T())
+	suite.mockStreamHandler.AssertExpectations(suite.T())
+	suite.mockWSHandler.AssertExpectations(suite.T())
+	suite.mockArchive.AssertExpectations(suite.T())
+	suite.mockBackup.AssertExpectations(suite.T())
+	suite.mockMaintenance.AssertExpectations(suite.T())
+	suite.mockTelemetry.AssertExpectations(suite.T())
 }
 
 // TestSetupRouter tests the router initialisation
 func (suite *RouterTestSuite) TestSetupRouter() {
-	allowedOrigins := []string{"http://localhost:3000"}
-	router := SetupRouter(
-		suite.mockHealthHandler,
-		suite.mockHostHandler,
-		suite.mockMetricHandler,
-		allowedOrigins,
-	)
+	allowedOrigins := middleware.NewOrigins([]string{"http://localhost:3000"})
+	router := SetupRouter(suite.handlers(), allowedOrigins, Timeouts{}, discardLogger(), nil)
 
 	assert.NotNil(suite.T(), router)
 }
@@ -65,6 +109,24 @@ func (suite *RouterTestSuite) TestHealthRoutes() {
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:   "liveness_endpoint_calls_handler",
+			method: http.MethodGet,
+			path:   "/health/live",
+			setupMock: func() {
+				suite.mockHealthHandler.On("GetLiveness", mock.AnythingOfType("*gin.Context")).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "readiness_endpoint_calls_handler",
+			method: http.MethodGet,
+			path:   "/health/ready",
+			setupMock: func() {
+				suite.mockHealthHandler.On("GetReadiness", mock.AnythingOfType("*gin.Context")).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:   "detailed_health_endpoint_calls_handler",
 			method: http.MethodGet,
@@ -74,18 +136,22 @@ func (suite *RouterTestSuite) TestHealthRoutes() {
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:   "self_metrics_endpoint_calls_handler",
+			method: http.MethodGet,
+			path:   "/internal/metrics",
+			setupMock: func() {
+				suite.mockTelemetry.On("Metrics", mock.AnythingOfType("*gin.Context")).Once()
+			},
+			expectedStatus: http.StatusOK,
+		},
 	}
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -102,14 +168,48 @@ func (suite *RouterTestSuite) TestHealthRoutes() {
 	}
 }
 
+// TestRequestLogging tests that requests get an ID, are logged with it, and that panics are recovered
+func (suite *RouterTestSuite) TestRequestLogging() {
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, new(slog.LevelVar), logging.FormatJSON)
+	suite.Require().NoError(err)
+
+	suite.mockHealthHandler.On("GetHealth", mock.AnythingOfType("*gin.Context")).Run(func(mock.Arguments) {
+		panic("boom")
+	}).Once()
+	router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, logger, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/health", nil)
+	assert.NoError(suite.T(), err)
+	req.Header.Set("X-Request-ID", "trace-42")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	assert.Equal(suite.T(), "trace-42", w.Header().Get("X-Request-ID"))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	suite.Require().Len(lines, 2)
+
+	var panicked, request map[string]interface{}
+	suite.Require().NoError(json.Unmarshal([]byte(lines[0]), &panicked))
+	suite.Require().NoError(json.Unmarshal([]byte(lines[1]), &request))
+
+	assert.Equal(suite.T(), "panic recovered", panicked["msg"])
+	assert.Equal(suite.T(), "boom", panicked["panic"])
+	assert.Equal(suite.T(), "trace-42", panicked["request_id"])
+
+	assert.Equal(suite.T(), "request", request["msg"])
+	assert.Equal(suite.T(), "ERROR", request["level"])
+	assert.Equal(suite.T(), "/health", request["route"])
+	assert.Equal(suite.T(), float64(500), request["status"])
+	assert.Equal(suite.T(), "trace-42", request["request_id"])
+}
+
 // TestSwaggerRoute tests that Swagger documentation is accessible
 func (suite *RouterTestSuite) TestSwaggerRoute() {
-	router := SetupRouter(
-		suite.mockHealthHandler,
-		suite.mockHostHandler,
-		suite.mockMetricHandler,
-		[]string{"*"},
-	)
+	router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 	req, err := http.NewRequest(http.MethodGet, "/swagger", nil)
 	assert.NoError(suite.T(), err)
@@ -168,12 +268,7 @@ func (suite *RouterTestSuite) TestAPIv1HostRoutes() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -224,18 +319,21 @@ func (suite *RouterTestSuite) TestAPIv1MetricRoutes() {
 				suite.mockMetricHandler.On("GetLatest", mock.AnythingOfType("*gin.Context")).Once()
 			},
 		},
+		{
+			name:   "get_metrics_stream_calls_stream_metrics",
+			method: http.MethodGet,
+			path:   "/api/v1/metrics/stream",
+			setupMock: func() {
+				suite.mockStreamHandler.On("Metrics", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
 	}
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -254,6 +352,69 @@ func (suite *RouterTestSuite) TestAPIv1MetricRoutes() {
 	}
 }
 
+// TestAPIv1ScrapeTargetRoutes tests that scrape target routes are registered under /api/v1 and call correct handlers
+func (suite *RouterTestSuite) TestAPIv1ScrapeTargetRoutes() {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		setupMock func()
+	}{
+		{
+			name:   "post_scrape_targets_calls_create",
+			method: http.MethodPost,
+			path:   "/api/v1/scrape-targets",
+			setupMock: func() {
+				suite.mockScrapeHandler.On("Create", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "get_scrape_targets_calls_get",
+			method: http.MethodGet,
+			path:   "/api/v1/scrape-targets",
+			setupMock: func() {
+				suite.mockScrapeHandler.On("Get", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "put_scrape_target_calls_update",
+			method: http.MethodPut,
+			path:   "/api/v1/scrape-targets/1",
+			setupMock: func() {
+				suite.mockScrapeHandler.On("Update", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "delete_scrape_target_calls_delete",
+			method: http.MethodDelete,
+			path:   "/api/v1/scrape-targets/1",
+			setupMock: func() {
+				suite.mockScrapeHandler.On("Delete", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
+
+			req, err := http.NewRequest(test.method, test.path, nil)
+			assert.NoError(suite.T(), err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.NotEqual(suite.T(), http.StatusNotFound, w.Code, "Route should be registered")
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
 // TestRouteNotFound tests that 404 is returned for non-existent routes
 func (suite *RouterTestSuite) TestRouteNotFound() {
 	tests := []struct {
@@ -290,12 +451,7 @@ func (suite *RouterTestSuite) TestRouteNotFound() {
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -360,12 +516,7 @@ func (suite *RouterTestSuite) TestMethodNotAllowed() {
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -454,12 +605,7 @@ func (suite *RouterTestSuite) TestRouteGrouping() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(test.method, test.path, nil)
 			assert.NoError(suite.T(), err)
@@ -494,6 +640,20 @@ func (suite *RouterTestSuite) TestAllRoutesRegistered() {
 				suite.mockHealthHandler.On("GetHealth", mock.AnythingOfType("*gin.Context")).Once()
 			},
 		},
+		{
+			method: http.MethodGet,
+			path:   "/health/live",
+			setupMock: func() {
+				suite.mockHealthHandler.On("GetLiveness", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			method: http.MethodGet,
+			path:   "/health/ready",
+			setupMock: func() {
+				suite.mockHealthHandler.On("GetReadiness", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
 		{
 			method: http.MethodGet,
 			path:   "/health/detailed",
@@ -556,12 +716,7 @@ func (suite *RouterTestSuite) TestAllRoutesRegistered() {
 		suite.Run(route.method+"_"+route.path, func() {
 			route.setupMock()
 
-			router := SetupRouter(
-				suite.mockHealthHandler,
-				suite.mockHostHandler,
-				suite.mockMetricHandler,
-				[]string{"*"},
-			)
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
 
 			req, err := http.NewRequest(route.method, route.path, nil)
 			assert.NoError(suite.T(), err)
@@ -580,6 +735,99 @@ func (suite *RouterTestSuite) TestAllRoutesRegistered() {
 	}
 }
 
+// TestAPIv1WebSocketRoute tests that the WebSocket route is registered and calls Connect
+func (suite *RouterTestSuite) TestAPIv1WebSocketRoute() {
+	suite.mockWSHandler.On("Connect", mock.AnythingOfType("*gin.Context")).Once()
+
+	router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/ws", nil)
+	assert.NoError(suite.T(), err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.NotEqual(suite.T(), http.StatusNotFound, w.Code, "Route should be registered")
+}
+
+// TestAPIv1AdminRoutes tests that the admin routes are registered and call correct handlers
+func (suite *RouterTestSuite) TestAPIv1AdminRoutes() {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		setupMock func()
+	}{
+		{
+			name:   "archive_calls_handler",
+			method: http.MethodPost,
+			path:   "/api/v1/admin/archive",
+			setupMock: func() {
+				suite.mockArchive.On("Archive", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "import_calls_handler",
+			method: http.MethodPost,
+			path:   "/api/v1/admin/archive/import",
+			setupMock: func() {
+				suite.mockArchive.On("Import", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "create_backup_calls_handler",
+			method: http.MethodPost,
+			path:   "/api/v1/admin/backups",
+			setupMock: func() {
+				suite.mockBackup.On("Create", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "list_backups_calls_handler",
+			method: http.MethodGet,
+			path:   "/api/v1/admin/backups",
+			setupMock: func() {
+				suite.mockBackup.On("Get", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "run_maintenance_calls_handler",
+			method: http.MethodPost,
+			path:   "/api/v1/admin/maintenance",
+			setupMock: func() {
+				suite.mockMaintenance.On("Run", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+		{
+			name:   "last_maintenance_calls_handler",
+			method: http.MethodGet,
+			path:   "/api/v1/admin/maintenance",
+			setupMock: func() {
+				suite.mockMaintenance.On("Get", mock.AnythingOfType("*gin.Context")).Once()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+			router := SetupRouter(suite.handlers(), middleware.NewOrigins([]string{"*"}), Timeouts{}, discardLogger(), nil)
+
+			req, err := http.NewRequest(test.method, test.path, nil)
+			assert.NoError(suite.T(), err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.NotEqual(suite.T(), http.StatusNotFound, w.Code, "Route should be registered")
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
 // Run the test suite
 func TestRouterTestSuite(t *testing.T) {
 	suite.Run(t, new(RouterTestSuite))
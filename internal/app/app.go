@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gabrielg2020/monitor-api/internal/api"
+	"github.com/gabrielg2020/monitor-api/internal/api/handlers"
+	"github.com/gabrielg2020/monitor-api/internal/config"
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/events"
+	"github.com/gabrielg2020/monitor-api/internal/middleware"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/scraper"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gabrielg2020/monitor-api/pkg/database"
+	"github.com/gabrielg2020/monitor-api/pkg/spool"
+	"github.com/gin-gonic/gin"
+)
+
+// App wires repositories, services, handlers and background workers together
+type App struct {
+	Router      *gin.Engine
+	Ingest      *services.IngestQueue
+	Scheduler   *scraper.Scheduler
+	Backups     *services.BackupScheduler
+	Storage     *services.StorageMonitor
+	Maintenance *services.MaintenanceScheduler
+
+	hub     *events.Hub
+	origins *middleware.Origins
+}
+
+// New builds the application from the database pools and configuration. ingestSpool
+// may be nil; it is replayed by Start and must be closed after Stop.
+func New(db *database.DB, ingestSpool *spool.Log, cfg *config.Config) *App {
+	// The API's own metrics
+	instruments := telemetry.New()
+	instruments.RegisterPool("write", db.Write)
+	if db.Read != db.Write {
+		instruments.RegisterPool("read", db.Read)
+	}
+
+	// Initialise repositories
+	var (
+		healthRepo       repository.HealthRepositoryInterface
+		hostRepo         repository.HostRepositoryInterface
+		metricRepo       repository.MetricRepositoryInterface
+		scrapeTargetRepo repository.ScrapeTargetRepositoryInterface
+	)
+	switch db.Dialect {
+	case database.Postgres:
+		healthRepo = repository.NewPostgresHealthRepository(db.Write)
+		hostRepo = repository.NewPostgresHostRepository(db.Write)
+		metricRepo = repository.NewPostgresMetricRepository(db.Write, cfg.Ingest.DuplicatePolicy)
+		scrapeTargetRepo = repository.NewPostgresScrapeTargetRepository(db.Write)
+	default:
+		healthRepo = repository.NewHealthRepository(db.Write, db.Read, db.Path)
+		hostRepo = repository.NewHostRepository(db.Write, db.Read)
+		metricRepo = repository.NewMetricRepository(db.Write, db.Read, cfg.Ingest.DuplicatePolicy)
+		scrapeTargetRepo = repository.NewScrapeTargetRepository(db.Write)
+	}
+	// VACUUM INTO only reads, so snapshots don't hold up writers
+	var backupRepo repository.BackupRepositoryInterface = repository.NewBackupRepository(db.Read)
+	var maintenanceRepo repository.MaintenanceRepositoryInterface = repository.NewMaintenanceRepository(db.Write, db.Read)
+
+	// Time every database call
+	healthRepo = repository.NewInstrumentedHealthRepository(healthRepo, instruments)
+	hostRepo = repository.NewInstrumentedHostRepository(hostRepo, instruments)
+	metricRepo = repository.NewInstrumentedMetricRepository(metricRepo, instruments)
+	scrapeTargetRepo = repository.NewInstrumentedScrapeTargetRepository(scrapeTargetRepo, instruments)
+	backupRepo = repository.NewInstrumentedBackupRepository(backupRepo, instruments)
+	maintenanceRepo = repository.NewInstrumentedMaintenanceRepository(maintenanceRepo, instruments)
+
+	// Group metric pushes and scrapes into shared transactions; archive imports already
+	// write in bulk, so they keep using the repository directly. With a spool, agent
+	// pushes are acknowledged once they are on disk in it.
+	var ingest *services.IngestQueue
+	var spooler services.MetricSpooler
+	ingestRepo := metricRepo
+	if cfg.Ingest.QueueSize > 0 {
+		ingest = services.NewIngestQueue(metricRepo, cfg.Ingest.QueueSize, cfg.Ingest.BatchSize, cfg.Ingest.FlushInterval, ingestSpool, instruments)
+		ingestRepo = ingest
+		if ingestSpool != nil {
+			spooler = ingest
+		}
+	}
+
+	// Snapshots are SQLite files; back PostgreSQL up with its own tools
+	backupDir := cfg.Backup.Dir
+	if db.Dialect != database.SQLite {
+		backupDir = ""
+	}
+
+	// In-process pub/sub for live streams
+	hub := events.NewHub()
+
+	// Shared by CORS and WebSocket origin checks, and replaced on reload
+	origins := middleware.NewOrigins(cfg.CORS.AllowedOrigins)
+
+	// Keep the concrete health service so subsystems can register checks with it
+	health := services.NewHealthService(healthRepo)
+
+	// Watches free space on the database volume and pauses low-priority writes when it runs out
+	storage := services.NewStorageMonitor(healthRepo, uint64(cfg.Health.MinFreeDiskMiB)<<20, cfg.Health.PauseLowPriorityWrites, instruments)
+
+	// VACUUM, ANALYZE and integrity checks are SQLite's; PostgreSQL runs autovacuum itself
+	maintenance := services.NewMaintenanceService(maintenanceRepo, db.Dialect == database.SQLite)
+
+	// Estimates each host's clock skew from the metrics it pushes, for the host list
+	clocks := services.NewClockSkewTracker()
+	timestampLimits := services.TimestampLimits{MaxAge: cfg.Ingest.MaxTimestampAge, MaxAhead: cfg.Ingest.MaxTimestampAhead}
+
+	// Tells the scraper when its targets change
+	scrapeTargets := services.NewScrapeTargetService(scrapeTargetRepo, hostRepo)
+
+	// Initialise services
+	var (
+		healthService       services.HealthServiceInterface       = health
+		hostService         services.HostServiceInterface         = services.NewHostService(hostRepo, hub, clocks)
+		metricService       services.MetricServiceInterface       = services.NewMetricService(ingestRepo, hub, spooler, timestampLimits, cfg.Ingest.ValidationMode, clocks)
+		scrapeTargetService services.ScrapeTargetServiceInterface = scrapeTargets
+		archiveService      services.ArchiveServiceInterface      = services.NewArchiveService(metricRepo, hostRepo, cfg.Archive.Dir, storage)
+		backupService       services.BackupServiceInterface       = services.NewBackupService(backupRepo, backupDir, cfg.Backup.Keep)
+		maintenanceService  services.MaintenanceServiceInterface  = maintenance
+	)
+
+	// Subsystems report into readiness; warnings degrade it without failing the probe
+	health.Register("migrations", entities.SeverityCritical, services.MigrationCheck(healthRepo, database.LatestSchemaVersion()))
+	health.Register("disk", entities.SeverityWarning, storage.Check)
+	if cfg.Health.MaxIngestLag > 0 {
+		health.Register("ingest_lag", entities.SeverityWarning, services.IngestLagCheck(metricService, cfg.Health.MaxIngestLag))
+	}
+	if db.Dialect == database.SQLite {
+		health.Register("maintenance", entities.SeverityWarning, maintenance.Check)
+	}
+
+	// Record a span for every service call
+	if cfg.Tracing.Enabled {
+		healthService = services.NewTracedHealthService(healthService)
+		hostService = services.NewTracedHostService(hostService)
+		metricService = services.NewTracedMetricService(metricService)
+		scrapeTargetService = services.NewTracedScrapeTargetService(scrapeTargetService)
+		archiveService = services.NewTracedArchiveService(archiveService)
+		backupService = services.NewTracedBackupService(backupService)
+		maintenanceService = services.NewTracedMaintenanceService(maintenanceService)
+	}
+
+	// Initialise handlers
+	router := api.SetupRouter(api.Handlers{
+		Health:       handlers.NewHealthHandler(healthService),
+		Host:         handlers.NewHostHandler(hostService),
+		Metric:       handlers.NewMetricHandler(metricService),
+		ScrapeTarget: handlers.NewScrapeTargetHandler(scrapeTargetService),
+		Stream:       handlers.NewStreamHandler(metricService, hostService, hub),
+		WebSocket:    handlers.NewWebSocketHandler(hostService, hub, origins, cfg.WebSocket.MaxConnections),
+		Archive:      handlers.NewArchiveHandler(archiveService),
+		Backup:       handlers.NewBackupHandler(backupService),
+		Maintenance:  handlers.NewMaintenanceHandler(maintenanceService),
+		Telemetry:    handlers.NewTelemetryHandler(instruments),
+	}, origins, api.Timeouts{
+		Request: cfg.Server.RequestTimeout,
+		Admin:   cfg.Server.AdminRequestTimeout,
+	}, slog.Default(), instruments)
+
+	app := &App{Router: router, Ingest: ingest, Storage: storage, hub: hub, origins: origins}
+
+	if cfg.Scraper.Enabled {
+		app.Scheduler = scraper.NewScheduler(scrapeTargetService, metricService, cfg.Scraper.MaxConcurrent, storage, instruments)
+		scrapeTargets.OnChange(app.Scheduler.TargetsChanged)
+		health.Register("scraper", entities.SeverityWarning, app.Scheduler.Check)
+	}
+
+	if backupDir != "" && cfg.Backup.Interval > 0 {
+		app.Backups = services.NewBackupScheduler(backupService, cfg.Backup.Interval, instruments)
+		health.Register("backups", entities.SeverityWarning, app.Backups.Check)
+	}
+
+	if db.Dialect == database.SQLite && cfg.Maintenance.Interval > 0 {
+		app.Maintenance = services.NewMaintenanceScheduler(maintenanceService, cfg.Maintenance.Interval, instruments)
+	}
+
+	return app
+}
+
+// Start writes the metrics left in the ingest spool, then launches background workers
+func (app *App) Start(ctx context.Context) {
+	if app.Ingest != nil {
+		app.Ingest.Start(ctx)
+	}
+	app.Storage.Start(ctx)
+	if app.Scheduler != nil {
+		app.Scheduler.Start(ctx)
+	}
+	if app.Backups != nil {
+		app.Backups.Start(ctx)
+	}
+	if app.Maintenance != nil {
+		app.Maintenance.Start(ctx)
+	}
+}
+
+// Reload applies the settings that can change while the server is running
+func (app *App) Reload(cfg *config.Config) {
+	app.origins.Set(cfg.CORS.AllowedOrigins)
+}
+
+// CloseStreams ends every SSE and WebSocket subscriber. Live streams never finish on
+// their own, so this lets the server drain them.
+func (app *App) CloseStreams() {
+	app.hub.Close()
+}
+
+// Stop stops background workers and waits for them to finish. Queued metrics are
+// written once the scraper has stopped adding to them. The database must stay open
+// until they have, so when ctx is done first Stop warns and keeps waiting, then
+// returns ctx's error.
+func (app *App) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if app.Scheduler != nil {
+			app.Scheduler.Stop()
+		}
+		if app.Ingest != nil {
+			app.Ingest.Stop()
+		}
+		if app.Backups != nil {
+			app.Backups.Stop()
+		}
+		if app.Maintenance != nil {
+			app.Maintenance.Stop()
+		}
+		app.Storage.Stop()
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+
+	slog.Warn("background workers still running at the shutdown deadline, waiting for queued metrics to be written")
+	<-stopped
+	return ctx.Err()
+}
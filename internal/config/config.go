@@ -1,62 +1,363 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	CORS     CORSConfig
+	Server      ServerConfig
+	Log         LogConfig
+	Database    DatabaseConfig
+	CORS        CORSConfig
+	Scraper     ScraperConfig
+	WebSocket   WebSocketConfig
+	Archive     ArchiveConfig
+	Backup      BackupConfig
+	Tracing     TracingConfig
+	Health      HealthConfig
+	Maintenance MaintenanceConfig
+	Ingest      IngestConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port                string
+	Mode                string
+	RequestTimeout      time.Duration // 0 disables the timeout
+	AdminRequestTimeout time.Duration // 0 disables the timeout
+	ShutdownTimeout     time.Duration // how long to drain requests and workers on SIGTERM
 }
 
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+}
+
+var (
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "text"}
+)
+
 type DatabaseConfig struct {
-	Path string
+	Driver       string // sqlite or postgres
+	Path         string // SQLite database file
+	URL          string // PostgreSQL connection URL
+	JournalMode  string // DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF
+	Synchronous  string // OFF, NORMAL, FULL or EXTRA
+	BusyTimeout  time.Duration
+	CacheSizeKiB int
+	ForeignKeys  bool
+	MaxOpenConns int // read pool; writes always share a single connection
+	MaxIdleConns int
 }
 
+var (
+	drivers      = []string{"sqlite", "postgres"}
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	syncModes    = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
 type CORSConfig struct {
 	AllowedOrigins []string
 }
 
-// Load loads configuration from environment variables
+type ScraperConfig struct {
+	Enabled       bool
+	MaxConcurrent int
+}
+
+type WebSocketConfig struct {
+	MaxConnections int
+}
+
+type ArchiveConfig struct {
+	Dir string // empty disables archiving
+}
+
+type BackupConfig struct {
+	Dir      string        // empty disables backups
+	Interval time.Duration // 0 disables scheduled backups
+	Keep     int           // 0 keeps every backup
+}
+
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string  // OTLP/HTTP collector URL
+	SampleRatio float64 // share of traces kept, from 0 to 1
+}
+
+type HealthConfig struct {
+	MaxIngestLag           time.Duration // newest metric age before readiness is degraded, 0 disables the check
+	MinFreeDiskMiB         int           // free space on the database volume before readiness is degraded, 0 disables the check
+	PauseLowPriorityWrites bool          // pause scrapes and archive imports while below MinFreeDiskMiB
+}
+
+type MaintenanceConfig struct {
+	Interval time.Duration // 0 disables scheduled maintenance
+}
+
+type IngestConfig struct {
+	QueueSize         int           // submissions held before pushes get a 503, 0 writes each metric in its own transaction
+	BatchSize         int           // metrics written per transaction at most
+	FlushInterval     time.Duration // longest a submission waits for its batch to fill
+	SpoolDir          string        // directory for the on-disk spool, empty acknowledges pushes only once they are committed
+	SpoolSegmentMiB   int           // spool segment file size before a new one is started
+	DuplicatePolicy   string        // ignore, replace or reject a metric for a host and timestamp already stored
+	MaxTimestampAge   time.Duration // furthest a pushed timestamp may be behind the server clock, 0 disables the check
+	MaxTimestampAhead time.Duration // furthest a pushed timestamp may be ahead of the server clock, 0 disables the check
+	ValidationMode    string        // reject, warn about or correct a metric whose fields contradict each other
+}
+
+var (
+	duplicatePolicies = []string{"ignore", "replace", "reject"}
+	validationModes   = []string{"reject", "warn", "correct"}
+)
+
+// ConfigFileEnv names the environment variable that points at the configuration file
+const ConfigFileEnv = "CONFIG_FILE"
+
+var ginModes = []string{"debug", "release", "test"}
+
+// Load loads configuration from the YAML or TOML file named by CONFIG_FILE, if set,
+// and environment variables, which take precedence over the file. Every invalid
+// setting is reported in the returned error.
 func Load() (*Config, error) {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8191"
+	src, err := newSource(os.Getenv(ConfigFileEnv))
+	if err != nil {
+		return nil, err
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		return nil, fmt.Errorf("DB_PATH environment variable is required")
-	}
+	driver := strings.ToLower(src.string("DB_DRIVER", "sqlite"))
 
-	mode := os.Getenv("GIN_MODE")
-	if mode == "" {
-		mode = "debug"
+	dbPath := src.string("DB_PATH", "")
+	if dbPath == "" && driver == "sqlite" {
+		return nil, fmt.Errorf("%s is required", src.required("DB_PATH"))
 	}
 
-	allowedOrigins := parseAllowedOrigins(os.Getenv("ALLOWED_ORIGINS"))
+	dbURL := src.string("DB_URL", "")
+	if dbURL == "" && driver == "postgres" {
+		return nil, fmt.Errorf("%s is required when DB_DRIVER is postgres", src.required("DB_URL"))
+	}
 
-	return &Config{
+	cfg := &Config{
 		Server: ServerConfig{
-			Port: port,
-			Mode: mode,
+			Port:                src.string("PORT", "8191"),
+			Mode:                src.string("GIN_MODE", "debug"),
+			RequestTimeout:      src.duration("REQUEST_TIMEOUT", 30*time.Second),
+			AdminRequestTimeout: src.duration("ADMIN_REQUEST_TIMEOUT", 0),
+			ShutdownTimeout:     src.duration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		},
+		Log: LogConfig{
+			Level:  strings.ToLower(src.string("LOG_LEVEL", "info")),
+			Format: strings.ToLower(src.string("LOG_FORMAT", "json")),
 		},
 		Database: DatabaseConfig{
-			Path: dbPath,
+			Driver:       driver,
+			Path:         dbPath,
+			URL:          dbURL,
+			JournalMode:  strings.ToUpper(src.string("DB_JOURNAL_MODE", "WAL")),
+			Synchronous:  strings.ToUpper(src.string("DB_SYNCHRONOUS", "NORMAL")),
+			BusyTimeout:  src.duration("DB_BUSY_TIMEOUT", 5*time.Second),
+			CacheSizeKiB: src.int("DB_CACHE_SIZE_KIB", 20000),
+			ForeignKeys:  src.bool("DB_FOREIGN_KEYS", true),
+			MaxOpenConns: src.int("DB_MAX_OPEN_CONNS", 4),
+			MaxIdleConns: src.int("DB_MAX_IDLE_CONNS", 4),
 		},
 		CORS: CORSConfig{
-			AllowedOrigins: allowedOrigins,
+			AllowedOrigins: parseAllowedOrigins(src.string("ALLOWED_ORIGINS", "")),
+		},
+		Scraper: ScraperConfig{
+			Enabled:       src.bool("SCRAPER_ENABLED", true),
+			MaxConcurrent: src.int("SCRAPER_MAX_CONCURRENT", 4),
+		},
+		WebSocket: WebSocketConfig{
+			MaxConnections: src.int("WS_MAX_CONNECTIONS", 100),
+		},
+		Archive: ArchiveConfig{
+			Dir: src.string("ARCHIVE_DIR", ""),
+		},
+		Backup: BackupConfig{
+			Dir:      src.string("BACKUP_DIR", ""),
+			Interval: src.duration("BACKUP_INTERVAL", 0),
+			Keep:     src.int("BACKUP_KEEP", 7),
+		},
+		Tracing: TracingConfig{
+			Enabled:     src.bool("TRACING_ENABLED", false),
+			Endpoint:    src.string("TRACING_ENDPOINT", "http://localhost:4318"),
+			SampleRatio: src.float("TRACING_SAMPLE_RATIO", 1),
+		},
+		Health: HealthConfig{
+			MaxIngestLag:           src.duration("HEALTH_MAX_INGEST_LAG", 10*time.Minute),
+			MinFreeDiskMiB:         src.int("HEALTH_MIN_FREE_DISK_MIB", 512),
+			PauseLowPriorityWrites: src.bool("HEALTH_PAUSE_LOW_PRIORITY_WRITES", false),
+		},
+		Maintenance: MaintenanceConfig{
+			Interval: src.duration("MAINTENANCE_INTERVAL", 24*time.Hour),
 		},
-	}, nil
+		Ingest: IngestConfig{
+			QueueSize:         src.int("INGEST_QUEUE_SIZE", 1000),
+			BatchSize:         src.int("INGEST_BATCH_SIZE", 100),
+			FlushInterval:     src.duration("INGEST_FLUSH_INTERVAL", 50*time.Millisecond),
+			SpoolDir:          src.string("INGEST_SPOOL_DIR", ""),
+			SpoolSegmentMiB:   src.int("INGEST_SPOOL_SEGMENT_MIB", 16),
+			DuplicatePolicy:   strings.ToLower(src.string("INGEST_DUPLICATE_POLICY", "ignore")),
+			MaxTimestampAge:   src.duration("INGEST_MAX_TIMESTAMP_AGE", 7*24*time.Hour),
+			MaxTimestampAhead: src.duration("INGEST_MAX_TIMESTAMP_AHEAD", 5*time.Minute),
+			ValidationMode:    strings.ToLower(src.string("INGEST_VALIDATION_MODE", "warn")),
+		},
+	}
+
+	cfg.Server.validate(src)
+	cfg.Log.validate(src)
+	cfg.Database.validate(src)
+	cfg.CORS.validate(src)
+	cfg.validateWorkers(src)
+	cfg.Tracing.validate(src)
+	if len(src.errs) > 0 {
+		return nil, errors.Join(src.errs...)
+	}
+
+	return cfg, nil
+}
+
+// validate rejects ports the server can't listen on, unknown Gin modes and negative timeouts
+func (server ServerConfig) validate(src *source) {
+	if port, err := strconv.Atoi(server.Port); err != nil || port < 1 || port > 65535 {
+		src.errorf("PORT", "must be a port number between 1 and 65535")
+	}
+	if !slices.Contains(ginModes, server.Mode) {
+		src.errorf("GIN_MODE", "must be one of %s", strings.Join(ginModes, ", "))
+	}
+	if server.RequestTimeout < 0 {
+		src.errorf("REQUEST_TIMEOUT", "must not be negative")
+	}
+	if server.AdminRequestTimeout < 0 {
+		src.errorf("ADMIN_REQUEST_TIMEOUT", "must not be negative")
+	}
+	if server.ShutdownTimeout < 0 {
+		src.errorf("SHUTDOWN_TIMEOUT", "must not be negative")
+	}
+}
+
+// validate rejects unknown log levels and formats
+func (logConfig LogConfig) validate(src *source) {
+	if !slices.Contains(logLevels, logConfig.Level) {
+		src.errorf("LOG_LEVEL", "must be one of %s", strings.Join(logLevels, ", "))
+	}
+	if !slices.Contains(logFormats, logConfig.Format) {
+		src.errorf("LOG_FORMAT", "must be one of %s", strings.Join(logFormats, ", "))
+	}
+}
+
+// validate rejects settings SQLite would ignore or fail on
+func (database DatabaseConfig) validate(src *source) {
+	if !slices.Contains(drivers, database.Driver) {
+		src.errorf("DB_DRIVER", "must be one of %s", strings.Join(drivers, ", "))
+	}
+	if !slices.Contains(journalModes, database.JournalMode) {
+		src.errorf("DB_JOURNAL_MODE", "must be one of %s", strings.Join(journalModes, ", "))
+	}
+	if !slices.Contains(syncModes, database.Synchronous) {
+		src.errorf("DB_SYNCHRONOUS", "must be one of %s", strings.Join(syncModes, ", "))
+	}
+	if database.BusyTimeout < 0 {
+		src.errorf("DB_BUSY_TIMEOUT", "must not be negative")
+	}
+	if database.CacheSizeKiB < 0 {
+		src.errorf("DB_CACHE_SIZE_KIB", "must not be negative")
+	}
+	if database.MaxOpenConns < 1 {
+		src.errorf("DB_MAX_OPEN_CONNS", "must be at least 1")
+	}
+	if database.MaxIdleConns < 0 {
+		src.errorf("DB_MAX_IDLE_CONNS", "must not be negative")
+	}
+}
+
+// validate rejects origins browsers would never send
+func (cors CORSConfig) validate(src *source) {
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+			src.errorf("ALLOWED_ORIGINS", "must be * or a list of origins such as https://dashboard.example.com, got %q", origin)
+		}
+	}
+}
+
+// validateWorkers rejects limits that would stop the scraper, WebSocket clients,
+// backup rotation, maintenance or the ingest queue from working
+func (cfg *Config) validateWorkers(src *source) {
+	if cfg.Scraper.MaxConcurrent < 1 {
+		src.errorf("SCRAPER_MAX_CONCURRENT", "must be at least 1")
+	}
+	if cfg.WebSocket.MaxConnections < 1 {
+		src.errorf("WS_MAX_CONNECTIONS", "must be at least 1")
+	}
+	if cfg.Backup.Interval < 0 {
+		src.errorf("BACKUP_INTERVAL", "must not be negative")
+	}
+	if cfg.Backup.Keep < 0 {
+		src.errorf("BACKUP_KEEP", "must not be negative")
+	}
+	if cfg.Health.MaxIngestLag < 0 {
+		src.errorf("HEALTH_MAX_INGEST_LAG", "must not be negative")
+	}
+	if cfg.Health.MinFreeDiskMiB < 0 {
+		src.errorf("HEALTH_MIN_FREE_DISK_MIB", "must not be negative")
+	}
+	if cfg.Maintenance.Interval < 0 {
+		src.errorf("MAINTENANCE_INTERVAL", "must not be negative")
+	}
+	if cfg.Ingest.QueueSize < 0 {
+		src.errorf("INGEST_QUEUE_SIZE", "must not be negative")
+	}
+	if cfg.Ingest.BatchSize < 1 {
+		src.errorf("INGEST_BATCH_SIZE", "must be at least 1")
+	}
+	if cfg.Ingest.FlushInterval <= 0 {
+		src.errorf("INGEST_FLUSH_INTERVAL", "must be positive")
+	}
+	if cfg.Ingest.SpoolDir != "" && cfg.Ingest.QueueSize == 0 {
+		src.errorf("INGEST_SPOOL_DIR", "needs the ingest queue, set INGEST_QUEUE_SIZE above 0")
+	}
+	if cfg.Ingest.SpoolSegmentMiB < 1 {
+		src.errorf("INGEST_SPOOL_SEGMENT_MIB", "must be at least 1")
+	}
+	if !slices.Contains(duplicatePolicies, cfg.Ingest.DuplicatePolicy) {
+		src.errorf("INGEST_DUPLICATE_POLICY", "must be one of %s", strings.Join(duplicatePolicies, ", "))
+	}
+	if cfg.Ingest.MaxTimestampAge < 0 {
+		src.errorf("INGEST_MAX_TIMESTAMP_AGE", "must not be negative")
+	}
+	if cfg.Ingest.MaxTimestampAhead < 0 {
+		src.errorf("INGEST_MAX_TIMESTAMP_AHEAD", "must not be negative")
+	}
+	if !slices.Contains(validationModes, cfg.Ingest.ValidationMode) {
+		src.errorf("INGEST_VALIDATION_MODE", "must be one of %s", strings.Join(validationModes, ", "))
+	}
+}
+
+// validate rejects collector URLs the exporter can't use and out of range ratios
+func (tracing TracingConfig) validate(src *source) {
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		src.errorf("TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+	}
+	if !tracing.Enabled {
+		return
+	}
+	endpoint, err := url.Parse(tracing.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		src.errorf("TRACING_ENDPOINT", "must be an http or https URL")
+	}
 }
 
 func parseAllowedOrigins(originsStr string) []string {
@@ -85,21 +386,3 @@ func splitAndTrim(s, sep string) []string {
 	}
 	return result
 }
-
-// GetEnv gets an environment variable with a fallback
-func GetEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return fallback
-}
-
-// GetEnvAsInt gets an environment variable as integer with a fallback
-func GetEnvAsInt(key string, fallback int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
-		}
-	}
-	return fallback
-}
@@ -4,6 +4,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -345,79 +346,400 @@ func (suite *ConfigTestSuite) TestParseAllowedOriginsMultiple() {
 	}
 }
 
-// TestGetEnv tests that GetEnv returns environment variable value when set, or fallback when not set
-func (suite *ConfigTestSuite) TestGetEnv() {
+// TestLoadBackupConfig tests backup settings and their defaults
+func (suite *ConfigTestSuite) TestLoadBackupConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), BackupConfig{Keep: 7}, config.Backup)
+
+	os.Setenv("BACKUP_DIR", "/mnt/nas/backups")
+	os.Setenv("BACKUP_INTERVAL", "6h")
+	os.Setenv("BACKUP_KEEP", "28")
+	defer os.Unsetenv("BACKUP_DIR")
+	defer os.Unsetenv("BACKUP_INTERVAL")
+	defer os.Unsetenv("BACKUP_KEEP")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), BackupConfig{Dir: "/mnt/nas/backups", Interval: 6 * time.Hour, Keep: 28}, config.Backup)
+}
+
+// TestLoadHealthConfig tests the health check thresholds, their defaults and that they can't be negative
+func (suite *ConfigTestSuite) TestLoadHealthConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), HealthConfig{MaxIngestLag: 10 * time.Minute, MinFreeDiskMiB: 512}, config.Health)
+
+	os.Setenv("HEALTH_MAX_INGEST_LAG", "0")
+	os.Setenv("HEALTH_MIN_FREE_DISK_MIB", "2048")
+	os.Setenv("HEALTH_PAUSE_LOW_PRIORITY_WRITES", "true")
+	defer os.Unsetenv("HEALTH_MAX_INGEST_LAG")
+	defer os.Unsetenv("HEALTH_MIN_FREE_DISK_MIB")
+	defer os.Unsetenv("HEALTH_PAUSE_LOW_PRIORITY_WRITES")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), HealthConfig{MinFreeDiskMiB: 2048, PauseLowPriorityWrites: true}, config.Health)
+
+	os.Setenv("HEALTH_MAX_INGEST_LAG", "-1m")
+	os.Setenv("HEALTH_MIN_FREE_DISK_MIB", "-1")
+
+	config, err = Load()
+	assert.Nil(suite.T(), config)
+	assert.EqualError(suite.T(), err, "HEALTH_MAX_INGEST_LAG must not be negative\nHEALTH_MIN_FREE_DISK_MIB must not be negative")
+}
+
+// TestLoadMaintenanceConfig tests the maintenance interval, its default and that it can't be negative
+func (suite *ConfigTestSuite) TestLoadMaintenanceConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), MaintenanceConfig{Interval: 24 * time.Hour}, config.Maintenance)
+
+	os.Setenv("MAINTENANCE_INTERVAL", "0")
+	defer os.Unsetenv("MAINTENANCE_INTERVAL")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), MaintenanceConfig{}, config.Maintenance)
+
+	os.Setenv("MAINTENANCE_INTERVAL", "-1h")
+
+	config, err = Load()
+	assert.Nil(suite.T(), config)
+	assert.EqualError(suite.T(), err, "MAINTENANCE_INTERVAL must not be negative")
+}
+
+// TestLoadIngestConfig tests the ingest queue settings, their defaults and validation
+func (suite *ConfigTestSuite) TestLoadIngestConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), IngestConfig{QueueSize: 1000, BatchSize: 100, FlushInterval: 50 * time.Millisecond, SpoolSegmentMiB: 16, DuplicatePolicy: "ignore", MaxTimestampAge: 7 * 24 * time.Hour, MaxTimestampAhead: 5 * time.Minute, ValidationMode: "warn"}, config.Ingest)
+
+	os.Setenv("INGEST_QUEUE_SIZE", "0")
+	os.Setenv("INGEST_BATCH_SIZE", "500")
+	os.Setenv("INGEST_FLUSH_INTERVAL", "1s")
+	os.Setenv("INGEST_DUPLICATE_POLICY", "Reject")
+	os.Setenv("INGEST_MAX_TIMESTAMP_AGE", "0")
+	os.Setenv("INGEST_MAX_TIMESTAMP_AHEAD", "30s")
+	os.Setenv("INGEST_VALIDATION_MODE", "Correct")
+	defer os.Unsetenv("INGEST_QUEUE_SIZE")
+	defer os.Unsetenv("INGEST_BATCH_SIZE")
+	defer os.Unsetenv("INGEST_FLUSH_INTERVAL")
+	defer os.Unsetenv("INGEST_DUPLICATE_POLICY")
+	defer os.Unsetenv("INGEST_MAX_TIMESTAMP_AGE")
+	defer os.Unsetenv("INGEST_MAX_TIMESTAMP_AHEAD")
+	defer os.Unsetenv("INGEST_VALIDATION_MODE")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), IngestConfig{QueueSize: 0, BatchSize: 500, FlushInterval: time.Second, SpoolSegmentMiB: 16, DuplicatePolicy: "reject", MaxTimestampAhead: 30 * time.Second, ValidationMode: "correct"}, config.Ingest)
+
+	os.Setenv("INGEST_QUEUE_SIZE", "200")
+	os.Setenv("INGEST_SPOOL_DIR", "/var/lib/monitor/spool")
+	os.Setenv("INGEST_SPOOL_SEGMENT_MIB", "4")
+	defer os.Unsetenv("INGEST_SPOOL_DIR")
+	defer os.Unsetenv("INGEST_SPOOL_SEGMENT_MIB")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "/var/lib/monitor/spool", config.Ingest.SpoolDir)
+	assert.Equal(suite.T(), 4, config.Ingest.SpoolSegmentMiB)
+
+	os.Setenv("INGEST_QUEUE_SIZE", "0")
+
+	config, err = Load()
+	assert.Nil(suite.T(), config)
+	assert.EqualError(suite.T(), err, "INGEST_SPOOL_DIR needs the ingest queue, set INGEST_QUEUE_SIZE above 0")
+
+	os.Unsetenv("INGEST_SPOOL_DIR")
+	os.Setenv("INGEST_QUEUE_SIZE", "-1")
+	os.Setenv("INGEST_BATCH_SIZE", "0")
+	os.Setenv("INGEST_FLUSH_INTERVAL", "0")
+	os.Setenv("INGEST_SPOOL_SEGMENT_MIB", "0")
+	os.Setenv("INGEST_DUPLICATE_POLICY", "merge")
+	os.Setenv("INGEST_MAX_TIMESTAMP_AGE", "-1h")
+	os.Setenv("INGEST_MAX_TIMESTAMP_AHEAD", "-1m")
+	os.Setenv("INGEST_VALIDATION_MODE", "ignore")
+
+	config, err = Load()
+	assert.Nil(suite.T(), config)
+	assert.EqualError(suite.T(), err, "INGEST_QUEUE_SIZE must not be negative\nINGEST_BATCH_SIZE must be at least 1\nINGEST_FLUSH_INTERVAL must be positive\nINGEST_SPOOL_SEGMENT_MIB must be at least 1\nINGEST_DUPLICATE_POLICY must be one of ignore, replace, reject\nINGEST_MAX_TIMESTAMP_AGE must not be negative\nINGEST_MAX_TIMESTAMP_AHEAD must not be negative\nINGEST_VALIDATION_MODE must be one of reject, warn, correct")
+}
+
+// TestLoadRequestTimeouts tests the request and shutdown timeouts and their defaults
+func (suite *ConfigTestSuite) TestLoadRequestTimeouts() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 30*time.Second, config.Server.RequestTimeout)
+	assert.Equal(suite.T(), time.Duration(0), config.Server.AdminRequestTimeout)
+	assert.Equal(suite.T(), 10*time.Second, config.Server.ShutdownTimeout)
+
+	os.Setenv("REQUEST_TIMEOUT", "5s")
+	os.Setenv("ADMIN_REQUEST_TIMEOUT", "15m")
+	os.Setenv("SHUTDOWN_TIMEOUT", "25s")
+	defer os.Unsetenv("REQUEST_TIMEOUT")
+	defer os.Unsetenv("ADMIN_REQUEST_TIMEOUT")
+	defer os.Unsetenv("SHUTDOWN_TIMEOUT")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 5*time.Second, config.Server.RequestTimeout)
+	assert.Equal(suite.T(), 15*time.Minute, config.Server.AdminRequestTimeout)
+	assert.Equal(suite.T(), 25*time.Second, config.Server.ShutdownTimeout)
+}
+
+// TestLoadScraperConfig tests that scraper settings are read from the environment
+func (suite *ConfigTestSuite) TestLoadScraperConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+	os.Setenv("SCRAPER_ENABLED", "false")
+	os.Setenv("SCRAPER_MAX_CONCURRENT", "8")
+	defer os.Unsetenv("SCRAPER_ENABLED")
+	defer os.Unsetenv("SCRAPER_MAX_CONCURRENT")
+
+	config, err := Load()
+
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), config.Scraper.Enabled)
+	assert.Equal(suite.T(), 8, config.Scraper.MaxConcurrent)
+}
+
+// TestLoadWebSocketConfig tests the WebSocket connection limit and its default
+func (suite *ConfigTestSuite) TestLoadWebSocketConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 100, config.WebSocket.MaxConnections)
+
+	os.Setenv("WS_MAX_CONNECTIONS", "5")
+	defer os.Unsetenv("WS_MAX_CONNECTIONS")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 5, config.WebSocket.MaxConnections)
+}
+
+// TestLoadArchiveConfig tests that archiving is disabled unless ARCHIVE_DIR is set
+func (suite *ConfigTestSuite) TestLoadArchiveConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "", config.Archive.Dir)
+
+	os.Setenv("ARCHIVE_DIR", "/mnt/nas/monitor-archive")
+	defer os.Unsetenv("ARCHIVE_DIR")
+
+	config, err = Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "/mnt/nas/monitor-archive", config.Archive.Dir)
+}
+
+// TestLoadLogConfig tests the log level and format, their defaults and validation
+func (suite *ConfigTestSuite) TestLoadLogConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+	defer os.Unsetenv("LOG_LEVEL")
+	defer os.Unsetenv("LOG_FORMAT")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), LogConfig{Level: "info", Format: "json"}, config.Log)
+
 	tests := []struct {
-		name     string
-		key      string
-		envValue string
-		fallback string
-		expected string
-		setEnv   bool
+		name          string
+		level         string
+		format        string
+		expected      LogConfig
+		expectedError string
+	}{
+		{name: "debug_text", level: "DEBUG", format: "Text", expected: LogConfig{Level: "debug", Format: "text"}},
+		{name: "invalid_level", level: "trace", format: "json", expectedError: "LOG_LEVEL must be one of debug, info, warn, error"},
+		{name: "invalid_format", level: "info", format: "logfmt", expectedError: "LOG_FORMAT must be one of json, text"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			os.Setenv("LOG_LEVEL", test.level)
+			os.Setenv("LOG_FORMAT", test.format)
+
+			config, err := Load()
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), test.expected, config.Log)
+		})
+	}
+}
+
+// TestLoadTracingConfig tests tracing settings, their defaults and validation
+func (suite *ConfigTestSuite) TestLoadTracingConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+	defer os.Unsetenv("TRACING_ENABLED")
+	defer os.Unsetenv("TRACING_ENDPOINT")
+	defer os.Unsetenv("TRACING_SAMPLE_RATIO")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), TracingConfig{Enabled: false, Endpoint: "http://localhost:4318", SampleRatio: 1}, config.Tracing)
+
+	tests := []struct {
+		name          string
+		enabled       string
+		endpoint      string
+		ratio         string
+		expected      TracingConfig
+		expectedError string
 	}{
 		{
-			name:     "env_var_set_returns_value",
-			key:      "TEST_VAR",
-			envValue: "test_value",
-			fallback: "default_value",
-			expected: "test_value",
-			setEnv:   true,
+			name:     "enabled",
+			enabled:  "true",
+			endpoint: "https://otel-collector:4318",
+			ratio:    "0.1",
+			expected: TracingConfig{Enabled: true, Endpoint: "https://otel-collector:4318", SampleRatio: 0.1},
+		},
+		{name: "bad_endpoint_ignored_when_disabled", enabled: "false", endpoint: "otel-collector:4318", ratio: "1",
+			expected: TracingConfig{Enabled: false, Endpoint: "otel-collector:4318", SampleRatio: 1}},
+		{name: "endpoint_without_scheme", enabled: "true", endpoint: "otel-collector:4318", ratio: "1", expectedError: "TRACING_ENDPOINT must be an http or https URL"},
+		{name: "ratio_out_of_range", enabled: "true", endpoint: "http://localhost:4318", ratio: "1.5", expectedError: "TRACING_SAMPLE_RATIO must be between 0 and 1"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			os.Setenv("TRACING_ENABLED", test.enabled)
+			os.Setenv("TRACING_ENDPOINT", test.endpoint)
+			os.Setenv("TRACING_SAMPLE_RATIO", test.ratio)
+
+			config, err := Load()
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), test.expected, config.Tracing)
+		})
+	}
+}
+
+// TestLoadDatabaseConfig tests SQLite settings, their defaults and validation
+func (suite *ConfigTestSuite) TestLoadDatabaseConfig() {
+	os.Setenv("DB_PATH", "/tmp/test.db")
+
+	config, err := Load()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), DatabaseConfig{
+		Driver:       "sqlite",
+		Path:         "/tmp/test.db",
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		CacheSizeKiB: 20000,
+		ForeignKeys:  true,
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+	}, config.Database)
+
+	tests := []struct {
+		name          string
+		envVars       map[string]string
+		expected      func(*DatabaseConfig)
+		expectedError string
+	}{
+		{
+			name: "custom_settings",
+			envVars: map[string]string{
+				"DB_JOURNAL_MODE":   "delete",
+				"DB_SYNCHRONOUS":    "full",
+				"DB_BUSY_TIMEOUT":   "30s",
+				"DB_CACHE_SIZE_KIB": "65536",
+				"DB_FOREIGN_KEYS":   "false",
+				"DB_MAX_OPEN_CONNS": "8",
+				"DB_MAX_IDLE_CONNS": "2",
+			},
+			expected: func(database *DatabaseConfig) {
+				assert.Equal(suite.T(), DatabaseConfig{
+					Driver:       "sqlite",
+					Path:         "/tmp/test.db",
+					JournalMode:  "DELETE",
+					Synchronous:  "FULL",
+					BusyTimeout:  30 * time.Second,
+					CacheSizeKiB: 65536,
+					ForeignKeys:  false,
+					MaxOpenConns: 8,
+					MaxIdleConns: 2,
+				}, *database)
+			},
+		},
+		{
+			name: "postgres",
+			envVars: map[string]string{
+				"DB_DRIVER": "Postgres",
+				"DB_URL":    "postgres://monitor@nas.local/monitor",
+			},
+			expected: func(database *DatabaseConfig) {
+				assert.Equal(suite.T(), "postgres", database.Driver)
+				assert.Equal(suite.T(), "postgres://monitor@nas.local/monitor", database.URL)
+			},
 		},
 		{
-			name:     "env_var_not_set_returns_fallback",
-			key:      "UNSET_VAR",
-			envValue: "",
-			fallback: "default_value",
-			expected: "default_value",
-			setEnv:   false,
+			name:          "postgres_without_url",
+			envVars:       map[string]string{"DB_DRIVER": "postgres"},
+			expectedError: "DB_URL",
 		},
 		{
-			name:     "env_var_empty_string_returns_fallback",
-			key:      "EMPTY_VAR",
-			envValue: "",
-			fallback: "default_value",
-			expected: "default_value",
-			setEnv:   true,
+			name:          "unknown_driver",
+			envVars:       map[string]string{"DB_DRIVER": "mysql"},
+			expectedError: "DB_DRIVER",
 		},
 		{
-			name:     "env_var_with_spaces",
-			key:      "SPACE_VAR",
-			envValue: "  value with spaces  ",
-			fallback: "default",
-			expected: "  value with spaces  ",
-			setEnv:   true,
+			name:          "invalid_journal_mode",
+			envVars:       map[string]string{"DB_JOURNAL_MODE": "wal2"},
+			expectedError: "DB_JOURNAL_MODE",
 		},
 		{
-			name:     "empty_fallback",
-			key:      "TEST_VAR",
-			envValue: "test_value",
-			fallback: "",
-			expected: "test_value",
-			setEnv:   true,
+			name:          "invalid_synchronous",
+			envVars:       map[string]string{"DB_SYNCHRONOUS": "sometimes"},
+			expectedError: "DB_SYNCHRONOUS",
 		},
 		{
-			name:     "both_empty",
-			key:      "EMPTY_VAR",
-			envValue: "",
-			fallback: "",
-			expected: "",
-			setEnv:   false,
+			name:          "negative_busy_timeout",
+			envVars:       map[string]string{"DB_BUSY_TIMEOUT": "-1s"},
+			expectedError: "DB_BUSY_TIMEOUT",
+		},
+		{
+			name:          "no_read_connections",
+			envVars:       map[string]string{"DB_MAX_OPEN_CONNS": "0"},
+			expectedError: "DB_MAX_OPEN_CONNS",
 		},
 	}
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
-			if test.setEnv {
-				os.Setenv(test.key, test.envValue)
-			} else {
-				os.Unsetenv(test.key)
+			for key, value := range test.envVars {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
 			}
 
-			result := GetEnv(test.key, test.fallback)
-			assert.Equal(suite.T(), test.expected, result)
+			config, err := Load()
 
-			// Cleanup
-			os.Unsetenv(test.key)
+			if test.expectedError != "" {
+				assert.Nil(suite.T(), config)
+				assert.ErrorContains(suite.T(), err, test.expectedError)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			test.expected(&config.Database)
 		})
 	}
 }
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting ties a key in the configuration file to the environment variable that
+// overrides it
+type setting struct {
+	key        string
+	env        string
+	reloadable bool // applied on SIGHUP without a restart
+	secret     bool // masked when printed
+	value      func(cfg *Config) any
+}
+
+// settings lists every key the configuration file accepts
+var settings = []setting{
+	{key: "server.port", env: "PORT", value: func(cfg *Config) any { return cfg.Server.Port }},
+	{key: "server.mode", env: "GIN_MODE", value: func(cfg *Config) any { return cfg.Server.Mode }},
+	{key: "server.request_timeout", env: "REQUEST_TIMEOUT", value: func(cfg *Config) any { return cfg.Server.RequestTimeout.String() }},
+	{key: "server.admin_request_timeout", env: "ADMIN_REQUEST_TIMEOUT", value: func(cfg *Config) any { return cfg.Server.AdminRequestTimeout.String() }},
+	{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", value: func(cfg *Config) any { return cfg.Server.ShutdownTimeout.String() }},
+	{key: "log.level", env: "LOG_LEVEL", reloadable: true, value: func(cfg *Config) any { return cfg.Log.Level }},
+	{key: "log.format", env: "LOG_FORMAT", value: func(cfg *Config) any { return cfg.Log.Format }},
+	{key: "database.driver", env: "DB_DRIVER", value: func(cfg *Config) any { return cfg.Database.Driver }},
+	{key: "database.path", env: "DB_PATH", value: func(cfg *Config) any { return cfg.Database.Path }},
+	{key: "database.url", env: "DB_URL", secret: true, value: func(cfg *Config) any { return cfg.Database.URL }},
+	{key: "database.journal_mode", env: "DB_JOURNAL_MODE", value: func(cfg *Config) any { return cfg.Database.JournalMode }},
+	{key: "database.synchronous", env: "DB_SYNCHRONOUS", value: func(cfg *Config) any { return cfg.Database.Synchronous }},
+	{key: "database.busy_timeout", env: "DB_BUSY_TIMEOUT", value: func(cfg *Config) any { return cfg.Database.BusyTimeout.String() }},
+	{key: "database.cache_size_kib", env: "DB_CACHE_SIZE_KIB", value: func(cfg *Config) any { return cfg.Database.CacheSizeKiB }},
+	{key: "database.foreign_keys", env: "DB_FOREIGN_KEYS", value: func(cfg *Config) any { return cfg.Database.ForeignKeys }},
+	{key: "database.max_open_conns", env: "DB_MAX_OPEN_CONNS", value: func(cfg *Config) any { return cfg.Database.MaxOpenConns }},
+	{key: "database.max_idle_conns", env: "DB_MAX_IDLE_CONNS", value: func(cfg *Config) any { return cfg.Database.MaxIdleConns }},
+	{key: "cors.allowed_origins", env: "ALLOWED_ORIGINS", reloadable: true, value: func(cfg *Config) any { return cfg.CORS.AllowedOrigins }},
+	{key: "scraper.enabled", env: "SCRAPER_ENABLED", value: func(cfg *Config) any { return cfg.Scraper.Enabled }},
+	{key: "scraper.max_concurrent", env: "SCRAPER_MAX_CONCURRENT", value: func(cfg *Config) any { return cfg.Scraper.MaxConcurrent }},
+	{key: "websocket.max_connections", env: "WS_MAX_CONNECTIONS", value: func(cfg *Config) any { return cfg.WebSocket.MaxConnections }},
+	{key: "archive.dir", env: "ARCHIVE_DIR", value: func(cfg *Config) any { return cfg.Archive.Dir }},
+	{key: "backup.dir", env: "BACKUP_DIR", value: func(cfg *Config) any { return cfg.Backup.Dir }},
+	{key: "backup.interval", env: "BACKUP_INTERVAL", value: func(cfg *Config) any { return cfg.Backup.Interval.String() }},
+	{key: "backup.keep", env: "BACKUP_KEEP", value: func(cfg *Config) any { return cfg.Backup.Keep }},
+	{key: "tracing.enabled", env: "TRACING_ENABLED", value: func(cfg *Config) any { return cfg.Tracing.Enabled }},
+	{key: "tracing.endpoint", env: "TRACING_ENDPOINT", value: func(cfg *Config) any { return cfg.Tracing.Endpoint }},
+	{key: "tracing.sample_ratio", env: "TRACING_SAMPLE_RATIO", value: func(cfg *Config) any { return cfg.Tracing.SampleRatio }},
+	{key: "health.max_ingest_lag", env: "HEALTH_MAX_INGEST_LAG", value: func(cfg *Config) any { return cfg.Health.MaxIngestLag.String() }},
+	{key: "health.min_free_disk_mib", env: "HEALTH_MIN_FREE_DISK_MIB", value: func(cfg *Config) any { return cfg.Health.MinFreeDiskMiB }},
+	{key: "health.pause_low_priority_writes", env: "HEALTH_PAUSE_LOW_PRIORITY_WRITES", value: func(cfg *Config) any { return cfg.Health.PauseLowPriorityWrites }},
+	{key: "maintenance.interval", env: "MAINTENANCE_INTERVAL", value: func(cfg *Config) any { return cfg.Maintenance.Interval.String() }},
+	{key: "ingest.queue_size", env: "INGEST_QUEUE_SIZE", value: func(cfg *Config) any { return cfg.Ingest.QueueSize }},
+	{key: "ingest.batch_size", env: "INGEST_BATCH_SIZE", value: func(cfg *Config) any { return cfg.Ingest.BatchSize }},
+	{key: "ingest.flush_interval", env: "INGEST_FLUSH_INTERVAL", value: func(cfg *Config) any { return cfg.Ingest.FlushInterval.String() }},
+	{key: "ingest.spool_dir", env: "INGEST_SPOOL_DIR", value: func(cfg *Config) any { return cfg.Ingest.SpoolDir }},
+	{key: "ingest.spool_segment_mib", env: "INGEST_SPOOL_SEGMENT_MIB", value: func(cfg *Config) any { return cfg.Ingest.SpoolSegmentMiB }},
+	{key: "ingest.duplicate_policy", env: "INGEST_DUPLICATE_POLICY", value: func(cfg *Config) any { return cfg.Ingest.DuplicatePolicy }},
+	{key: "ingest.max_timestamp_age", env: "INGEST_MAX_TIMESTAMP_AGE", value: func(cfg *Config) any { return cfg.Ingest.MaxTimestampAge.String() }},
+	{key: "ingest.max_timestamp_ahead", env: "INGEST_MAX_TIMESTAMP_AHEAD", value: func(cfg *Config) any { return cfg.Ingest.MaxTimestampAhead.String() }},
+	{key: "ingest.validation_mode", env: "INGEST_VALIDATION_MODE", value: func(cfg *Config) any { return cfg.Ingest.ValidationMode }},
+}
+
+// Configuration file formats
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// source looks settings up in the environment first, then in the configuration file,
+// and collects every invalid value so they can be reported together
+type source struct {
+	path string
+	file map[string]string // values from the file, by environment variable
+	keys map[string]string // file keys, by environment variable
+	errs []error
+}
+
+// newSource reads the configuration file at path, if any
+func newSource(path string) (*source, error) {
+	src := &source{path: path, file: map[string]string{}, keys: map[string]string{}}
+	for _, setting := range settings {
+		src.keys[setting.env] = setting.key
+	}
+	if path == "" {
+		return src, nil
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []error
+	for key, value := range values {
+		index := slices.IndexFunc(settings, func(setting setting) bool { return setting.key == key })
+		if index < 0 {
+			unknown = append(unknown, fmt.Errorf("unknown setting %q in %s", key, path))
+			continue
+		}
+		src.file[settings[index].env] = value
+	}
+	if len(unknown) > 0 {
+		slices.SortFunc(unknown, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return nil, errors.Join(unknown...)
+	}
+	return src, nil
+}
+
+// readFile parses a YAML or TOML file, picked by its extension, into dotted keys
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	document := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", document, values); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten turns nested tables into dotted keys; lists become comma-separated values
+// as they would be in the environment
+func flatten(prefix string, document map[string]any, values map[string]string) error {
+	for key, value := range document {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			if err := flatten(key, value, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				switch item.(type) {
+				case map[string]any, []any:
+					return fmt.Errorf("%s must be a list of values", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			// An empty key leaves the default in place
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// lookup returns the value for an environment variable, or the file setting it maps to
+func (src *source) lookup(env string) (string, bool) {
+	if value := os.Getenv(env); value != "" {
+		return value, true
+	}
+	value := src.file[env]
+	return value, value != ""
+}
+
+// name describes where a setting came from, for error messages
+func (src *source) name(env string) string {
+	if os.Getenv(env) == "" {
+		if _, ok := src.file[env]; ok {
+			return fmt.Sprintf("%s in %s", src.keys[env], src.path)
+		}
+	}
+	return env
+}
+
+// required describes a setting that wasn't given at all
+func (src *source) required(env string) string {
+	if src.path == "" {
+		return env + " environment variable"
+	}
+	return fmt.Sprintf("%s environment variable or %s in %s", env, src.keys[env], src.path)
+}
+
+func (src *source) errorf(env, format string, args ...any) {
+	src.errs = append(src.errs, fmt.Errorf("%s "+format, append([]any{src.name(env)}, args...)...))
+}
+
+func (src *source) string(env, fallback string) string {
+	if value, ok := src.lookup(env); ok {
+		return value
+	}
+	return fallback
+}
+
+func (src *source) int(env string, fallback int) int {
+	value, ok := src.lookup(env)
+	if !ok {
+		return fallback
+	}
+	intVal, err := strconv.Atoi(value)
+	if err != nil {
+		src.errorf(env, "must be a whole number, got %q", value)
+		return fallback
+	}
+	return intVal
+}
+
+func (src *source) bool(env string, fallback bool) bool {
+	value, ok := src.lookup(env)
+	if !ok {
+		return fallback
+	}
+	boolVal, err := strconv.ParseBool(value)
+	if err != nil {
+		src.errorf(env, "must be true or false, got %q", value)
+		return fallback
+	}
+	return boolVal
+}
+
+func (src *source) float(env string, fallback float64) float64 {
+	value, ok := src.lookup(env)
+	if !ok {
+		return fallback
+	}
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		src.errorf(env, "must be a number, got %q", value)
+		return fallback
+	}
+	return floatVal
+}
+
+func (src *source) duration(env string, fallback time.Duration) time.Duration {
+	value, ok := src.lookup(env)
+	if !ok {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		src.errorf(env, "must be a duration such as \"30s\" or \"24h\", got %q", value)
+		return fallback
+	}
+	return duration
+}
+
+// Print writes the effective configuration in the configuration file schema, as YAML
+// or TOML. Passwords in the database URL are masked.
+func Print(cfg *Config, format string) ([]byte, error) {
+	document := map[string]any{}
+	for _, setting := range settings {
+		section, key, _ := strings.Cut(setting.key, ".")
+		table, ok := document[section].(map[string]any)
+		if !ok {
+			table = map[string]any{}
+			document[section] = table
+		}
+		value := setting.value(cfg)
+		if setting.secret {
+			value = redactURL(value.(string))
+		}
+		table[key] = value
+	}
+
+	switch format {
+	case FormatYAML:
+		var output bytes.Buffer
+		encoder := yaml.NewEncoder(&output)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+		return output.Bytes(), nil
+	case FormatTOML:
+		return toml.Marshal(document)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatYAML, FormatTOML)
+	}
+}
+
+// Changed lists the settings that differ between two configurations, split into
+// those that can be applied while running and those that need a restart
+func Changed(previous, next *Config) (reloadable, restart []string) {
+	for _, setting := range settings {
+		if fmt.Sprint(setting.value(previous)) == fmt.Sprint(setting.value(next)) {
+			continue
+		}
+		if setting.reloadable {
+			reloadable = append(reloadable, setting.key)
+		} else {
+			restart = append(restart, setting.key)
+		}
+	}
+	return reloadable, restart
+}
+
+// redactURL masks the password in a connection URL
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "xxxxx"
+	}
+	return parsed.Redacted()
+}
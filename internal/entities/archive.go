@@ -0,0 +1,38 @@
+package entities
+
+// ArchiveRequest selects the metrics to write to Parquet archive files
+type ArchiveRequest struct {
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	Delete    bool  `json:"delete"` // remove archived metrics from the database
+}
+
+// ArchiveFile describes one monthly Parquet file written by an archive run
+type ArchiveFile struct {
+	Path           string `json:"path"` // relative to the archive directory
+	Month          string `json:"month"`
+	Rows           int64  `json:"rows"`
+	FirstTimestamp int64  `json:"first_timestamp"`
+	LastTimestamp  int64  `json:"last_timestamp"`
+}
+
+// ArchiveResult summarises an archive run
+type ArchiveResult struct {
+	Files   []ArchiveFile `json:"files"`
+	Rows    int64         `json:"rows"`
+	Deleted int64         `json:"deleted"`
+}
+
+// ImportRequest names an archive file or directory, relative to the archive directory
+type ImportRequest struct {
+	Path string `json:"path"`
+}
+
+// ImportResult summarises an import run
+type ImportResult struct {
+	Files        []string `json:"files"`
+	Rows         int64    `json:"rows"`
+	Inserted     int64    `json:"inserted"`
+	Skipped      int64    `json:"skipped"` // already present for the same host and timestamp
+	HostsCreated int      `json:"hosts_created"`
+}
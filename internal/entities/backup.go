@@ -0,0 +1,8 @@
+package entities
+
+// Backup describes a database snapshot in the backup directory
+type Backup struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
+}
@@ -5,3 +5,58 @@ type HealthResponse struct {
 	Timestamp int64             `json:"timestamp"`
 	Checks    map[string]string `json:"checks"`
 }
+
+// Health statuses, from best to worst
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// Health check severities
+const (
+	SeverityCritical = "critical" // a failure makes the API unready
+	SeverityWarning  = "warning"  // a failure degrades the API, which keeps serving
+)
+
+// HealthCheckResult is the outcome of a single health check
+type HealthCheckResult struct {
+	Name       string                 `json:"name"`
+	Severity   string                 `json:"severity"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs float64                `json:"duration_ms"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthReport combines the outcome of every registered health check. Its status is
+// the worst of the checks'.
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// DetailedHealth is a health report with database statistics
+type DetailedHealth struct {
+	HealthReport
+	DatabaseStats    map[string]interface{} `json:"database_stats,omitempty"`
+	DatabaseSettings map[string]interface{} `json:"database_settings,omitempty"`
+	TableCounts      map[string]int         `json:"table_counts,omitempty"`
+}
+
+// StorageStats describes the database files and the volume holding them
+type StorageStats struct {
+	PageCount     int64        `json:"page_count"`
+	PageSize      int64        `json:"page_size"`
+	FreelistCount int64        `json:"freelist_count"` // unused pages, reclaimed by VACUUM
+	FileBytes     int64        `json:"file_bytes"`
+	WALBytes      int64        `json:"wal_bytes"`
+	Volume        *VolumeStats `json:"volume,omitempty"` // nil when the database isn't on a local volume
+}
+
+// VolumeStats describes the space on a filesystem
+type VolumeStats struct {
+	Path       string `json:"path"`
+	FreeBytes  uint64 `json:"free_bytes"` // available to the API, excluding space reserved for root
+	TotalBytes uint64 `json:"total_bytes"`
+}
@@ -5,10 +5,38 @@ type Host struct {
 	Hostname  string `json:"hostname" db:"hostname"`
 	IPAddress string `json:"ip_address" db:"ip_address"`
 	Role      string `json:"role" db:"role"`
+
+	ClockSkew *ClockSkew `json:"clock_skew,omitempty" db:"-"` // nil until the host has sent a timestamp
+}
+
+// ClockSkew is how far a host's clock is estimated to be from the API's, going by the
+// timestamps of its recent metrics. A positive Seconds means the host's clock is ahead.
+type ClockSkew struct {
+	Seconds    int64 `json:"seconds"`
+	Samples    int   `json:"samples"`
+	ObservedAt int64 `json:"observed_at"` // when the newest sample was received
 }
 
 type HostQueryParams struct {
 	ID        int64  `form:"id"`
 	Hostname  string `form:"hostname"`
 	IPAddress string `form:"ip_address"`
+	Role      string `form:"role"`
+	Limit     int    `form:"limit"` // 0 returns every matching host
+	Cursor    string `form:"cursor"`
+
+	// AfterID is the decoded cursor; only hosts with a greater ID are returned
+	AfterID int64 `form:"-"`
+}
+
+// Host status changes published to live subscribers
+const (
+	HostStatusCreated = "created"
+	HostStatusUpdated = "updated"
+	HostStatusDeleted = "deleted"
+)
+
+type HostStatusChange struct {
+	Status string `json:"status"`
+	Host   Host   `json:"host"`
 }
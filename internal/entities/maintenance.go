@@ -0,0 +1,38 @@
+package entities
+
+// Maintenance tasks, in the order they run
+const (
+	MaintenanceIncrementalVacuum = "incremental_vacuum"
+	MaintenanceAnalyze           = "analyze"
+	MaintenanceOptimize          = "optimize"
+	MaintenanceIntegrityCheck    = "integrity_check"
+)
+
+// Maintenance task statuses
+const (
+	MaintenanceStatusOK     = "ok"
+	MaintenanceStatusFailed = "failed"
+)
+
+// MaintenanceTaskResult is the outcome of a single maintenance task
+type MaintenanceTaskResult struct {
+	Task       string                 `json:"task"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs float64                `json:"duration_ms"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// MaintenanceRun records a run of one or more maintenance tasks
+type MaintenanceRun struct {
+	Trigger    string                  `json:"trigger"` // "scheduled" or "manual"
+	StartedAt  int64                   `json:"started_at"`
+	DurationMs float64                 `json:"duration_ms"`
+	Tasks      []MaintenanceTaskResult `json:"tasks"`
+}
+
+// What started a maintenance run
+const (
+	MaintenanceTriggerScheduled = "scheduled"
+	MaintenanceTriggerManual    = "manual"
+)
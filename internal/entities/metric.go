@@ -4,12 +4,23 @@ type MetricLatestQueryParams struct {
 	HostID *int64 `form:"host_id"`
 }
 
+type MetricStreamQueryParams struct {
+	HostID      *int64 `form:"host_id"`
+	Role        string `form:"role"`
+	LastEventID *int64 `form:"last_event_id"`
+}
+
 type MetricQueryParams struct {
 	HostID    *int64 `form:"host_id"`
 	StartTime *int64 `form:"start_time"`
 	EndTime   *int64 `form:"end_time"`
 	Limit     int    `form:"limit"`
 	Order     string `form:"order"` // "asc" or "desc"
+	Cursor    string `form:"cursor"`
+	Format    string `form:"format"` // "json", "csv" or "ndjson"
+
+	// After is the decoded cursor; only metrics after it in Order are returned
+	After *MetricCursor `form:"-"`
 }
 
 type SystemMetric struct {
@@ -25,4 +36,46 @@ type SystemMetric struct {
 	DiskTotalBytes       int64   `json:"disk_total_bytes" db:"disk_total_bytes"`
 	DiskUsedBytes        int64   `json:"disk_used_bytes" db:"disk_used_bytes"`
 	DiskAvailableBytes   int64   `json:"disk_available_bytes" db:"disk_available_bytes"`
+	ReceivedAt           int64   `json:"received_at" db:"received_at"` // 0 when not known
+}
+
+// Duplicate policies decide what happens to a metric for a host and timestamp that
+// already has one
+const (
+	DuplicateIgnore  = "ignore"  // keep the stored metric
+	DuplicateReplace = "replace" // overwrite the stored metric with the new values
+	DuplicateReject  = "reject"  // turn the new metric away
+)
+
+// Validation modes decide what happens to a metric whose fields contradict each other,
+// such as more memory used than there is in total
+const (
+	ValidationReject  = "reject"  // turn the metric away
+	ValidationWarn    = "warn"    // store the metric as sent and report the violations
+	ValidationCorrect = "correct" // derive the contradicting fields from the byte counts
+)
+
+// FieldViolation is a rule a metric field breaks. Corrected is set when the field was
+// changed so the metric could be stored.
+type FieldViolation struct {
+	Field     string `json:"field"`
+	Message   string `json:"message"`
+	Corrected bool   `json:"corrected,omitempty"`
+}
+
+// MetricWrite is the outcome of storing a metric. When the host already had a metric
+// at that timestamp, Deduplicated is set if the stored one was kept and Replaced if its
+// values were overwritten with the new ones, and ID is the stored metric's.
+// Violations lists the consistency rules the metric was stored despite, or corrected for.
+type MetricWrite struct {
+	ID           int64
+	Deduplicated bool
+	Replaced     bool
+	Violations   []FieldViolation
+}
+
+// MetricExportRow is a metric joined with the host that reported it
+type MetricExportRow struct {
+	Metric SystemMetric
+	Host   Host
 }
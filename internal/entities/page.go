@@ -0,0 +1,14 @@
+package entities
+
+// PageInfo describes where a paginated listing continues
+type PageInfo struct {
+	NextCursor string
+	HasMore    bool
+}
+
+// MetricCursor is the position of the last metric on a page. Metrics are ordered
+// by timestamp, with the ID breaking ties between metrics from the same second.
+type MetricCursor struct {
+	Timestamp int64
+	ID        int64
+}
@@ -0,0 +1,58 @@
+package entities
+
+// Supported scrape target formats
+const (
+	ScrapeFormatPrometheus = "prometheus"
+	ScrapeFormatJSON       = "json"
+)
+
+// Scrape statuses recorded after each attempt
+const (
+	ScrapeStatusOK      = "ok"
+	ScrapeStatusError   = "error"
+	ScrapeStatusPending = "pending"
+)
+
+// ScrapeMappableFields lists the SystemMetric fields a JSON mapping may populate
+var ScrapeMappableFields = []string{
+	"cpu_usage",
+	"memory_usage_percent",
+	"memory_total_bytes",
+	"memory_used_bytes",
+	"memory_available_bytes",
+	"disk_usage_percent",
+	"disk_total_bytes",
+	"disk_used_bytes",
+	"disk_available_bytes",
+}
+
+type ScrapeTarget struct {
+	ID                  int64             `json:"id" db:"id"`
+	Name                string            `json:"name" db:"name"`
+	URL                 string            `json:"url" db:"url"`
+	HostID              int64             `json:"host_id" db:"host_id"`
+	Format              string            `json:"format" db:"format"`
+	IntervalSeconds     int               `json:"interval_seconds" db:"interval_seconds"`
+	TimeoutSeconds      int               `json:"timeout_seconds" db:"timeout_seconds"`
+	JSONMapping         map[string]string `json:"json_mapping,omitempty" db:"json_mapping"`
+	Paused              bool              `json:"paused" db:"paused"`
+	LastScrapeAt        int64             `json:"last_scrape_at" db:"last_scrape_at"`
+	LastStatus          string            `json:"last_status" db:"last_status"`
+	LastError           string            `json:"last_error" db:"last_error"`
+	LastDurationMs      int64             `json:"last_duration_ms" db:"last_duration_ms"`
+	ConsecutiveFailures int               `json:"consecutive_failures" db:"consecutive_failures"`
+}
+
+type ScrapeTargetQueryParams struct {
+	ID         int64 `form:"id"`
+	HostID     int64 `form:"host_id"`
+	ActiveOnly bool  `form:"active_only"`
+}
+
+// ScrapeResult records the outcome of a single scrape attempt
+type ScrapeResult struct {
+	Timestamp  int64
+	Status     string
+	Error      string
+	DurationMs int64
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// Event topics published on the hub
+const (
+	TopicMetrics    = "metrics"
+	TopicHostStatus = "host_status"
+)
+
+// Event is a single message delivered to hub subscribers. Replaced marks a metric
+// whose stored values were overwritten, so its ID is not new.
+type Event struct {
+	Topic    string
+	ID       int64
+	HostID   int64
+	Data     interface{}
+	Replaced bool
+}
+
+// Hub is an in-process publish/subscribe broker. Publishing never blocks: events
+// are dropped for subscribers whose buffer is full and counted on the subscription.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives events published after it was created
+type Subscription struct {
+	hub     *Hub
+	events  chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Subscribe registers a new subscriber with the given buffer size
+func (hub *Hub) Subscribe(bufferSize int) *Subscription {
+	subscription := &Subscription{
+		hub:    hub,
+		events: make(chan Event, bufferSize),
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// Subscribers arriving after Close get a closed channel and end straight away
+	if hub.closed {
+		subscription.once.Do(func() {
+			close(subscription.events)
+		})
+		return subscription
+	}
+
+	hub.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Publish delivers an event to every subscriber that has room for it
+func (hub *Hub) Publish(event Event) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	for subscription := range hub.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
+
+// PublishMetric publishes a newly stored metric on the metrics topic
+func (hub *Hub) PublishMetric(metric entities.SystemMetric) {
+	hub.Publish(Event{
+		Topic:  TopicMetrics,
+		ID:     metric.ID,
+		HostID: metric.HostID,
+		Data:   metric,
+	})
+}
+
+// PublishReplacedMetric publishes the new values of a metric already stored on the
+// metrics topic
+func (hub *Hub) PublishReplacedMetric(metric entities.SystemMetric) {
+	hub.Publish(Event{
+		Topic:    TopicMetrics,
+		ID:       metric.ID,
+		HostID:   metric.HostID,
+		Data:     metric,
+		Replaced: true,
+	})
+}
+
+// PublishHostStatus publishes a host lifecycle change on the host status topic
+func (hub *Hub) PublishHostStatus(change entities.HostStatusChange) {
+	hub.Publish(Event{
+		Topic:  TopicHostStatus,
+		HostID: change.Host.ID,
+		Data:   change,
+	})
+}
+
+// Close closes every subscription, ending live streams so the server can shut down
+func (hub *Hub) Close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.closed = true
+	for subscription := range hub.subscribers {
+		delete(hub.subscribers, subscription)
+		subscription.once.Do(func() {
+			close(subscription.events)
+		})
+	}
+}
+
+// SubscriberCount returns the number of active subscriptions
+func (hub *Hub) SubscriberCount() int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.subscribers)
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+// Dropped returns how many events were discarded because the buffer was full
+func (subscription *Subscription) Dropped() uint64 {
+	return subscription.dropped.Load()
+}
+
+// Close unregisters the subscription and closes its channel
+func (subscription *Subscription) Close() {
+	subscription.once.Do(func() {
+		subscription.hub.mu.Lock()
+		delete(subscription.hub.subscribers, subscription)
+		subscription.hub.mu.Unlock()
+		close(subscription.events)
+	})
+}
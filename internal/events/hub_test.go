@@ -0,0 +1,107 @@
+// nolint
+package events
+
+import (
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// HubTestSuite is the test suite for Hub
+type HubTestSuite struct {
+	suite.Suite
+	hub *Hub
+}
+
+// SetupTest runs before each test in the suite
+func (suite *HubTestSuite) SetupTest() {
+	suite.hub = NewHub()
+}
+
+// TestPublishDeliversToAllSubscribers tests fan-out to every subscriber
+func (suite *HubTestSuite) TestPublishDeliversToAllSubscribers() {
+	first := suite.hub.Subscribe(1)
+	second := suite.hub.Subscribe(1)
+	defer first.Close()
+	defer second.Close()
+
+	suite.hub.PublishMetric(entities.SystemMetric{ID: 5, HostID: 2})
+
+	for _, subscription := range []*Subscription{first, second} {
+		event := <-subscription.Events()
+		assert.Equal(suite.T(), TopicMetrics, event.Topic)
+		assert.Equal(suite.T(), int64(5), event.ID)
+		assert.Equal(suite.T(), int64(2), event.HostID)
+		assert.Equal(suite.T(), entities.SystemMetric{ID: 5, HostID: 2}, event.Data)
+	}
+}
+
+// TestPublishHostStatus tests that host changes are published on the host status topic
+func (suite *HubTestSuite) TestPublishHostStatus() {
+	subscription := suite.hub.Subscribe(1)
+	defer subscription.Close()
+
+	change := entities.HostStatusChange{Status: entities.HostStatusDeleted, Host: entities.Host{ID: 4}}
+	suite.hub.PublishHostStatus(change)
+
+	event := <-subscription.Events()
+	assert.Equal(suite.T(), TopicHostStatus, event.Topic)
+	assert.Equal(suite.T(), int64(4), event.HostID)
+	assert.Equal(suite.T(), change, event.Data)
+}
+
+// TestSlowSubscriberDropsEvents tests that a full buffer drops events instead of blocking
+func (suite *HubTestSuite) TestSlowSubscriberDropsEvents() {
+	subscription := suite.hub.Subscribe(1)
+	defer subscription.Close()
+
+	suite.hub.Publish(Event{ID: 1})
+	suite.hub.Publish(Event{ID: 2})
+	suite.hub.Publish(Event{ID: 3})
+
+	assert.Equal(suite.T(), uint64(2), subscription.Dropped())
+	assert.Equal(suite.T(), int64(1), (<-subscription.Events()).ID)
+}
+
+// TestClose tests that closing unregisters the subscription and closes its channel
+func (suite *HubTestSuite) TestClose() {
+	subscription := suite.hub.Subscribe(1)
+	assert.Equal(suite.T(), 1, suite.hub.SubscriberCount())
+
+	subscription.Close()
+	subscription.Close() // Closing twice is safe
+
+	assert.Equal(suite.T(), 0, suite.hub.SubscriberCount())
+	_, ok := <-subscription.Events()
+	assert.False(suite.T(), ok)
+
+	// Publishing after close must not panic
+	suite.hub.Publish(Event{ID: 1})
+}
+
+// TestHubClose tests that closing the hub ends every subscription, including later ones
+func (suite *HubTestSuite) TestHubClose() {
+	first := suite.hub.Subscribe(1)
+	second := suite.hub.Subscribe(1)
+
+	suite.hub.Close()
+	assert.Equal(suite.T(), 0, suite.hub.SubscriberCount())
+
+	for _, subscription := range []*Subscription{first, second} {
+		_, ok := <-subscription.Events()
+		assert.False(suite.T(), ok)
+		subscription.Close() // Closing after the hub is safe
+	}
+
+	late := suite.hub.Subscribe(1)
+	_, ok := <-late.Events()
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), 0, suite.hub.SubscriberCount())
+}
+
+// Run the test suite
+func TestHubTestSuite(t *testing.T) {
+	suite.Run(t, new(HubTestSuite))
+}
@@ -0,0 +1,133 @@
+// nolint
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LoggingTestSuite is the test suite for the logger
+type LoggingTestSuite struct {
+	suite.Suite
+	buf bytes.Buffer
+}
+
+// SetupTest runs before each test in the suite
+func (suite *LoggingTestSuite) SetupTest() {
+	suite.buf.Reset()
+}
+
+// TestNew tests level and format parsing
+func (suite *LoggingTestSuite) TestNew() {
+	tests := []struct {
+		name        string
+		level       string
+		format      string
+		expectError bool
+	}{
+		{name: "json_info", level: "info", format: "json"},
+		{name: "text_debug", level: "debug", format: "text"},
+		{name: "upper_case", level: "WARN", format: "JSON"},
+		{name: "invalid_level", level: "verbose", format: "json", expectError: true},
+		{name: "invalid_format", level: "info", format: "logfmt", expectError: true},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var logger *slog.Logger
+			level, err := ParseLevel(test.level)
+			if err == nil {
+				logger, err = New(&suite.buf, newLevel(level), test.format)
+			}
+			if test.expectError {
+				assert.Error(suite.T(), err)
+				assert.Nil(suite.T(), logger)
+			} else {
+				assert.NoError(suite.T(), err)
+				assert.NotNil(suite.T(), logger)
+			}
+		})
+	}
+}
+
+// TestLevelFilters tests that records below the level are dropped
+func (suite *LoggingTestSuite) TestLevelFilters() {
+	level := newLevel(slog.LevelWarn)
+	logger, err := New(&suite.buf, level, "json")
+	suite.Require().NoError(err)
+
+	logger.Info("ignored")
+	assert.Zero(suite.T(), suite.buf.Len())
+
+	logger.Warn("kept")
+	assert.Contains(suite.T(), suite.buf.String(), `"msg":"kept"`)
+
+	// Lowering the level takes effect straight away
+	suite.buf.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("now kept")
+	assert.Contains(suite.T(), suite.buf.String(), `"msg":"now kept"`)
+}
+
+// TestRequestID tests that the request ID in the context is added to records
+func (suite *LoggingTestSuite) TestRequestID() {
+	logger, err := New(&suite.buf, newLevel(slog.LevelInfo), "json")
+	suite.Require().NoError(err)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	assert.Equal(suite.T(), "abc123", RequestID(ctx))
+	assert.Equal(suite.T(), "", RequestID(context.Background()))
+
+	logger.With(slog.String("component", "test")).InfoContext(ctx, "handled", "status", 200)
+
+	var record map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(suite.buf.Bytes(), &record))
+	assert.Equal(suite.T(), "handled", record["msg"])
+	assert.Equal(suite.T(), "abc123", record["request_id"])
+	assert.Equal(suite.T(), "test", record["component"])
+	assert.Equal(suite.T(), float64(200), record["status"])
+
+	// Records without a request ID don't get the attribute
+	suite.buf.Reset()
+	logger.Info("background")
+	assert.NotContains(suite.T(), suite.buf.String(), "request_id")
+}
+
+// TestTraceContext tests that records logged within a span carry its IDs
+func (suite *LoggingTestSuite) TestTraceContext() {
+	logger, err := New(&suite.buf, newLevel(slog.LevelInfo), FormatJSON)
+	suite.Require().NoError(err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	logger.InfoContext(ctx, "traced")
+
+	var record map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(suite.buf.Bytes(), &record))
+	assert.Equal(suite.T(), "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(suite.T(), "00f067aa0ba902b7", record["span_id"])
+}
+
+// newLevel returns a level var set to level
+func newLevel(level slog.Level) *slog.LevelVar {
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(level)
+	return levelVar
+}
+
+// Run the test suite
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
@@ -1,33 +1,76 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/logging"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
 	"github.com/gin-gonic/gin"
 )
 
-// CORS returns a middleware that handles CORS
-func CORS(allowedOrigins []string) gin.HandlerFunc {
-	originsMap := make(map[string]bool)
-	hasWildcard := false
+// RequestIDHeader carries the ID that ties a request to its log records
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds incoming request IDs so clients can't bloat the logs
+const maxRequestIDLength = 128
+
+// Origins holds the origins allowed to make cross-origin requests. The list can be
+// replaced while the server is running.
+type Origins struct {
+	current atomic.Pointer[originSet]
+}
+
+type originSet struct {
+	allowed  map[string]bool
+	wildcard bool
+}
 
+func NewOrigins(allowedOrigins []string) *Origins {
+	origins := &Origins{}
+	origins.Set(allowedOrigins)
+	return origins
+}
+
+// Set replaces the allowed origins
+func (origins *Origins) Set(allowedOrigins []string) {
+	set := &originSet{allowed: make(map[string]bool)}
 	for _, origin := range allowedOrigins {
 		if origin == "*" {
-			hasWildcard = true
+			set.wildcard = true
 		}
-		originsMap[origin] = true
+		set.allowed[origin] = true
 	}
+	origins.current.Store(set)
+}
+
+// Allowed reports whether origin is allowed, either by name or by a wildcard
+func (origins *Origins) Allowed(origin string) bool {
+	set := origins.current.Load()
+	return set.wildcard || set.allowed[origin]
+}
 
+// CORS returns a middleware that handles CORS
+func CORS(origins *Origins) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
+		set := origins.current.Load()
 
 		// Check if origin is allowed
-		if hasWildcard {
+		if set.wildcard {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		} else if originsMap[origin] {
+		} else if set.allowed[origin] {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		// Handle preflight requests
@@ -39,3 +82,116 @@ func CORS(allowedOrigins []string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// Timeout returns a middleware that cancels the request context after timeout, so
+// queries stop once the deadline passes. Requests for which exempt returns true, and
+// every request when timeout is not positive, run without a deadline.
+func Timeout(timeout time.Duration, exempt func(c *gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 || (exempt != nil && exempt(c)) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		// Answer for handlers that gave up without writing a response
+		if !c.Writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			c.AbortWithStatusJSON(504, gin.H{
+				"error":   "Gateway Timeout",
+				"details": "The request did not complete within " + timeout.String() + ".",
+			})
+		}
+	}
+}
+
+// RequestID returns a middleware that gives every request an ID, reusing a valid
+// X-Request-ID from the client or proxy. The ID is echoed in the response and
+// stored in the request context, so records logged with it carry request_id.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Writer.Header().Set(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// Logger returns a middleware that logs each request once it completes. Server
+// errors are logged at error level and client errors at warn.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.String(); errs != "" {
+			attrs = append(attrs, slog.String("error", errs))
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Metrics returns a middleware that records the count and latency of requests by
+// method, matched route and status. Unmatched paths share the "unmatched" route.
+func Metrics(metrics *telemetry.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// validRequestID accepts short IDs made of printable ASCII without spaces
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -2,10 +2,17 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gabrielg2020/monitor-api/internal/logging"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -78,7 +85,7 @@ func (suite *MiddlewareTestSuite) TestCORSMiddleware() {
 		suite.Run(test.name, func() {
 			// Create a test router with CORS middleware
 			router := gin.New()
-			router.Use(CORS(test.allowedOrigins))
+			router.Use(CORS(NewOrigins(test.allowedOrigins)))
 
 			// Add a simple test endpoint
 			router.GET("/test", func(c *gin.Context) {
@@ -170,7 +177,7 @@ func (suite *MiddlewareTestSuite) TestCORSPreflightRequest() {
 		suite.Run(test.name, func() {
 			// Create a test router with CORS middleware
 			router := gin.New()
-			router.Use(CORS(test.allowedOrigins))
+			router.Use(CORS(NewOrigins(test.allowedOrigins)))
 
 			// Add a test endpoint (won't be reached for OPTIONS)
 			router.POST("/test", func(c *gin.Context) {
@@ -204,7 +211,7 @@ func (suite *MiddlewareTestSuite) TestCORSPreflightRequest() {
 // TestCORSAllowedMethods tests that correct methods are in Access-Control-Allow-Methods header
 func (suite *MiddlewareTestSuite) TestCORSAllowedMethods() {
 	router := gin.New()
-	router.Use(CORS([]string{"*"}))
+	router.Use(CORS(NewOrigins([]string{"*"})))
 	router.GET("/test", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
@@ -224,10 +231,35 @@ func (suite *MiddlewareTestSuite) TestCORSAllowedMethods() {
 	assert.Contains(suite.T(), allowedMethods, "OPTIONS")
 }
 
+// TestCORSOriginsReplaced tests that replacing the origins applies to the next request
+func (suite *MiddlewareTestSuite) TestCORSOriginsReplaced() {
+	origins := NewOrigins([]string{"http://localhost:3000"})
+	router := gin.New()
+	router.Use(CORS(origins))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Empty(suite.T(), request())
+
+	origins.Set([]string{"https://dashboard.example.com"})
+	assert.Equal(suite.T(), "https://dashboard.example.com", request())
+	assert.True(suite.T(), origins.Allowed("https://dashboard.example.com"))
+	assert.False(suite.T(), origins.Allowed("http://localhost:3000"))
+}
+
 // TestCORSAllowedHeaders tests that correct headers are in Access-Control-Allow-Headers
 func (suite *MiddlewareTestSuite) TestCORSAllowedHeaders() {
 	router := gin.New()
-	router.Use(CORS([]string{"*"}))
+	router.Use(CORS(NewOrigins([]string{"*"})))
 	router.GET("/test", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
@@ -245,6 +277,204 @@ func (suite *MiddlewareTestSuite) TestCORSAllowedHeaders() {
 	assert.Contains(suite.T(), allowedHeaders, "X-CSRF-Token")
 }
 
+// TestTimeout tests that the request context gets a deadline unless the timeout is disabled or the request is exempt
+func (suite *MiddlewareTestSuite) TestTimeout() {
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		exempt         func(c *gin.Context) bool
+		expectDeadline bool
+	}{
+		{
+			name:           "deadline_set",
+			timeout:        time.Minute,
+			expectDeadline: true,
+		},
+		{
+			name:           "disabled",
+			timeout:        0,
+			expectDeadline: false,
+		},
+		{
+			name:    "exempt_request",
+			timeout: time.Minute,
+			exempt: func(c *gin.Context) bool {
+				return c.Query("format") == "csv"
+			},
+			expectDeadline: false,
+		},
+		{
+			name:    "not_exempt_request",
+			timeout: time.Minute,
+			exempt: func(c *gin.Context) bool {
+				return c.Query("format") == "ndjson"
+			},
+			expectDeadline: true,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var hasDeadline bool
+			router := gin.New()
+			router.GET("/test", Timeout(test.timeout, test.exempt), func(c *gin.Context) {
+				_, hasDeadline = c.Request.Context().Deadline()
+				c.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/test?format=csv", nil)
+			assert.NoError(suite.T(), err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(suite.T(), http.StatusOK, w.Code)
+			assert.Equal(suite.T(), test.expectDeadline, hasDeadline)
+		})
+	}
+}
+
+// TestTimeoutExceeded tests that a handler that gives up at the deadline without responding gets a 504
+func (suite *MiddlewareTestSuite) TestTimeoutExceeded() {
+	router := gin.New()
+	router.GET("/slow", Timeout(10*time.Millisecond, nil), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+	router.GET("/handled", Timeout(10*time.Millisecond, nil), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy"})
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/slow", nil)
+	assert.NoError(suite.T(), err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusGatewayTimeout, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "10ms")
+
+	// A response written by the handler is left alone
+	req, err = http.NewRequest(http.MethodGet, "/handled", nil)
+	assert.NoError(suite.T(), err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+}
+
+// TestRequestID tests that valid incoming request IDs are kept and others replaced
+func (suite *MiddlewareTestSuite) TestRequestID() {
+	tests := []struct {
+		name       string
+		header     string
+		expectSame bool
+	}{
+		{name: "incoming_id_kept", header: "7f3c2a9e-proxy", expectSame: true},
+		{name: "missing_id_generated", header: ""},
+		{name: "id_with_spaces_replaced", header: "two words"},
+		{name: "overlong_id_replaced", header: strings.Repeat("a", 129)},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var contextID string
+			router := gin.New()
+			router.Use(RequestID())
+			router.GET("/test", func(c *gin.Context) {
+				contextID = logging.RequestID(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			assert.NoError(suite.T(), err)
+			if test.header != "" {
+				req.Header.Set(RequestIDHeader, test.header)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			responseID := w.Header().Get(RequestIDHeader)
+			assert.Equal(suite.T(), responseID, contextID)
+			if test.expectSame {
+				assert.Equal(suite.T(), test.header, responseID)
+			} else {
+				assert.Len(suite.T(), responseID, 32)
+				assert.NotEqual(suite.T(), test.header, responseID)
+			}
+		})
+	}
+}
+
+// TestLogger tests the level and fields of request log records
+func (suite *MiddlewareTestSuite) TestLogger() {
+	tests := []struct {
+		name          string
+		status        int
+		expectedLevel string
+	}{
+		{name: "success_info", status: http.StatusOK, expectedLevel: "INFO"},
+		{name: "client_error_warn", status: http.StatusNotFound, expectedLevel: "WARN"},
+		{name: "server_error_error", status: http.StatusServiceUnavailable, expectedLevel: "ERROR"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var logs bytes.Buffer
+			logger, err := logging.New(&logs, new(slog.LevelVar), logging.FormatJSON)
+			suite.Require().NoError(err)
+
+			router := gin.New()
+			router.Use(RequestID(), Logger(logger))
+			router.GET("/hosts/:id", func(c *gin.Context) {
+				c.String(test.status, "ok")
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/hosts/7?limit=5", nil)
+			assert.NoError(suite.T(), err)
+			req.Header.Set(RequestIDHeader, "req-1")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var record map[string]interface{}
+			suite.Require().NoError(json.Unmarshal(logs.Bytes(), &record))
+			assert.Equal(suite.T(), test.expectedLevel, record["level"])
+			assert.Equal(suite.T(), "request", record["msg"])
+			assert.Equal(suite.T(), "GET", record["method"])
+			assert.Equal(suite.T(), "/hosts/7", record["path"])
+			assert.Equal(suite.T(), "/hosts/:id", record["route"])
+			assert.Equal(suite.T(), float64(test.status), record["status"])
+			assert.Equal(suite.T(), float64(2), record["bytes"])
+			assert.Equal(suite.T(), "req-1", record["request_id"])
+			assert.Contains(suite.T(), record, "duration_ms")
+		})
+	}
+}
+
+// TestMetrics tests that requests are counted by matched route and status
+func (suite *MiddlewareTestSuite) TestMetrics() {
+	metrics := telemetry.New()
+
+	router := gin.New()
+	router.Use(Metrics(metrics))
+	router.GET("/hosts/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for _, path := range []string{"/hosts/1", "/hosts/2", "/missing"} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(suite.T(), err)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var output bytes.Buffer
+	suite.Require().NoError(metrics.WritePrometheus(&output))
+	assert.Contains(suite.T(), output.String(), `monitor_api_http_requests_total{method="GET",route="/hosts/:id",status="200"} 2`)
+	assert.Contains(suite.T(), output.String(), `monitor_api_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(suite.T(), output.String(), `monitor_api_http_request_duration_seconds_count{method="GET",route="/hosts/:id"} 2`)
+}
+
 // Run the test suite
 func TestMiddlewareTestSuite(t *testing.T) {
 	suite.Run(t, new(MiddlewareTestSuite))
@@ -6,6 +6,15 @@ type Host struct {
 	Hostname  string `json:"hostname" example:"pi-01"`
 	IPAddress string `json:"ip_address" example:"192.168.0.24"`
 	Role      string `json:"role" example:"server"`
+
+	ClockSkew *ClockSkew `json:"clock_skew,omitempty"`
+}
+
+// ClockSkew is how far a host's clock is estimated to be from the API's
+type ClockSkew struct {
+	Seconds    int64 `json:"seconds" example:"-3"`
+	Samples    int   `json:"samples" example:"16"`
+	ObservedAt int64 `json:"observed_at" example:"1729350001"`
 }
 
 // CreateHostRequest for registering a new host
@@ -35,6 +44,7 @@ type SystemMetric struct {
 	DiskTotalBytes       int64   `json:"disk_total_bytes" example:"32212254720"`
 	DiskUsedBytes        int64   `json:"disk_used_bytes" example:"7537723520"`
 	DiskAvailableBytes   int64   `json:"disk_available_bytes" example:"24674531200"`
+	ReceivedAt           int64   `json:"received_at" example:"1729350001"`
 }
 
 // CreateMetricRequest for submitting new metrics
@@ -51,6 +61,14 @@ type CreateMetricRequest struct {
 	DiskAvailableBytes   int64   `json:"disk_available_bytes" binding:"required" example:"24674531200"`
 }
 
+// MetricExportRow is a metric with its host's details, as written by CSV and NDJSON exports
+type MetricExportRow struct {
+	SystemMetric
+	Hostname  string `json:"hostname" example:"raspberrypi"`
+	IPAddress string `json:"ip_address" example:"192.168.1.100"`
+	Role      string `json:"role" example:"monitor"`
+}
+
 // MetricListResponse contains list of metrics
 type MetricListResponse struct {
 	Records []SystemMetric `json:"records"`
@@ -63,10 +81,153 @@ type MetricResponse struct {
 	HostID  int64  `json:"host_id" example:"1"`
 }
 
+// ScrapeTarget represents a pull-mode metrics endpoint scraped by the API
+type ScrapeTarget struct {
+	ID                  int64             `json:"id" example:"1"`
+	Name                string            `json:"name" example:"nas node_exporter"`
+	URL                 string            `json:"url" example:"http://192.168.0.30:9100/metrics"`
+	HostID              int64             `json:"host_id" example:"1"`
+	Format              string            `json:"format" example:"prometheus" enums:"prometheus,json"`
+	IntervalSeconds     int               `json:"interval_seconds" example:"30"`
+	TimeoutSeconds      int               `json:"timeout_seconds" example:"10"`
+	JSONMapping         map[string]string `json:"json_mapping,omitempty"`
+	Paused              bool              `json:"paused" example:"false"`
+	LastScrapeAt        int64             `json:"last_scrape_at" example:"1729350000"`
+	LastStatus          string            `json:"last_status" example:"ok" enums:"ok,error,pending"`
+	LastError           string            `json:"last_error,omitempty"`
+	LastDurationMs      int64             `json:"last_duration_ms" example:"42"`
+	ConsecutiveFailures int               `json:"consecutive_failures" example:"0"`
+}
+
+// CreateScrapeTargetRequest for registering or updating a scrape target
+type CreateScrapeTargetRequest struct {
+	Name            string            `json:"name" example:"nas node_exporter"`
+	URL             string            `json:"url" binding:"required" example:"http://192.168.0.30:9100/metrics"`
+	HostID          int64             `json:"host_id" binding:"required" example:"1"`
+	Format          string            `json:"format" example:"prometheus" enums:"prometheus,json"`
+	IntervalSeconds int               `json:"interval_seconds" example:"30"`
+	TimeoutSeconds  int               `json:"timeout_seconds" example:"10"`
+	JSONMapping     map[string]string `json:"json_mapping,omitempty"`
+	Paused          bool              `json:"paused" example:"false"`
+}
+
+// ScrapeTargetListResponse contains list of scrape targets
+type ScrapeTargetListResponse struct {
+	Targets []ScrapeTarget `json:"targets"`
+	Meta    Meta           `json:"meta"`
+}
+
+// HostStatusChange is published on the host_status WebSocket topic
+type HostStatusChange struct {
+	Status string `json:"status" example:"created" enums:"created,updated,deleted"`
+	Host   Host   `json:"host"`
+}
+
+// StreamTruncated is sent as a "truncated" SSE event when a resume has more missed metrics than can be replayed
+type StreamTruncated struct {
+	LastEventID int64 `json:"last_event_id" example:"10500"`
+}
+
+// WebSocketRequest is a message sent by a client over the WebSocket connection
+type WebSocketRequest struct {
+	Action string `json:"action" example:"subscribe" enums:"subscribe,unsubscribe"`
+	Topic  string `json:"topic" example:"metrics" enums:"metrics,host_status"`
+	HostID *int64 `json:"host_id,omitempty" example:"1"`
+	Role   string `json:"role,omitempty" example:"nas"`
+}
+
+// WebSocketMessage is a message sent by the server over the WebSocket connection
+type WebSocketMessage struct {
+	Type    string      `json:"type" example:"event" enums:"subscribed,unsubscribed,event,dropped,error"`
+	Topic   string      `json:"topic,omitempty" example:"metrics"`
+	Data    interface{} `json:"data,omitempty"`
+	Dropped uint64      `json:"dropped,omitempty" example:"12"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ArchiveRequest selects the metrics to archive to Parquet files
+type ArchiveRequest struct {
+	StartTime int64 `json:"start_time" binding:"required" example:"1735689600"`
+	EndTime   int64 `json:"end_time" binding:"required" example:"1738367999"`
+	Delete    bool  `json:"delete" example:"false"`
+}
+
+// ArchiveFile describes one monthly Parquet file
+type ArchiveFile struct {
+	Path           string `json:"path" example:"year=2025/month=01/metrics-1735689600-1738367940.parquet"`
+	Month          string `json:"month" example:"2025-01"`
+	Rows           int64  `json:"rows" example:"44640"`
+	FirstTimestamp int64  `json:"first_timestamp" example:"1735689600"`
+	LastTimestamp  int64  `json:"last_timestamp" example:"1738367940"`
+}
+
+// ArchiveResponse summarises an archive run
+type ArchiveResponse struct {
+	Files   []ArchiveFile `json:"files"`
+	Rows    int64         `json:"rows" example:"44640"`
+	Deleted int64         `json:"deleted" example:"44640"`
+}
+
+// ImportRequest names an archive file or directory relative to ARCHIVE_DIR
+type ImportRequest struct {
+	Path string `json:"path" binding:"required" example:"year=2025/month=01"`
+}
+
+// ImportResponse summarises an import run
+type ImportResponse struct {
+	Files        []string `json:"files"`
+	Rows         int64    `json:"rows" example:"44640"`
+	Inserted     int64    `json:"inserted" example:"44600"`
+	Skipped      int64    `json:"skipped" example:"40"`
+	HostsCreated int      `json:"hosts_created" example:"1"`
+}
+
+// Backup describes a database snapshot in BACKUP_DIR
+type Backup struct {
+	Name      string `json:"name" example:"monitor-20251018T120000Z.db"`
+	SizeBytes int64  `json:"size_bytes" example:"52428800"`
+	CreatedAt int64  `json:"created_at" example:"1760788800"`
+}
+
+// BackupResponse contains a newly written backup
+type BackupResponse struct {
+	Backup Backup `json:"backup"`
+}
+
+// BackupListResponse contains list of backups
+type BackupListResponse struct {
+	Backups []Backup `json:"backups"`
+	Meta    Meta     `json:"meta"`
+}
+
+// MaintenanceTask is the outcome of a single maintenance task
+type MaintenanceTask struct {
+	Task       string                 `json:"task" example:"incremental_vacuum" enums:"incremental_vacuum,analyze,optimize,integrity_check"`
+	Status     string                 `json:"status" example:"ok" enums:"ok,failed"`
+	Error      string                 `json:"error,omitempty" example:"database is locked"`
+	DurationMs float64                `json:"duration_ms" example:"812.5"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// MaintenanceRun records a run of one or more maintenance tasks
+type MaintenanceRun struct {
+	Trigger    string            `json:"trigger" example:"manual" enums:"scheduled,manual"`
+	StartedAt  int64             `json:"started_at" example:"1760788800"`
+	DurationMs float64           `json:"duration_ms" example:"1520.3"`
+	Tasks      []MaintenanceTask `json:"tasks"`
+}
+
+// MaintenanceResponse contains a maintenance run
+type MaintenanceResponse struct {
+	Run MaintenanceRun `json:"run"`
+}
+
 // Meta contains pagination and count information
 type Meta struct {
-	Count int `json:"count" example:"10"`
-	Limit int `json:"limit" example:"100"`
+	Count      int    `json:"count" example:"10"`
+	Limit      int    `json:"limit" example:"100"`
+	NextCursor string `json:"next_cursor,omitempty" example:"MTcyOTM1MDAwMDo0Mg"`
+	HasMore    bool   `json:"has_more" example:"true"`
 }
 
 // HealthResponse represents API health status
@@ -76,8 +237,47 @@ type HealthResponse struct {
 	Checks    map[string]string `json:"checks"`
 }
 
+// LivenessResponse reports that the process is up
+type LivenessResponse struct {
+	Status    string `json:"status" example:"alive"`
+	Timestamp string `json:"timestamp" example:"2025-10-19T18:30:00Z"`
+}
+
+// HealthCheck is the outcome of a single health check
+type HealthCheck struct {
+	Name       string                 `json:"name" example:"scraper"`
+	Severity   string                 `json:"severity" example:"warning" enums:"critical,warning"`
+	Status     string                 `json:"status" example:"degraded" enums:"healthy,degraded,unhealthy"`
+	Error      string                 `json:"error,omitempty" example:"scraper has not run for 45s"`
+	DurationMs float64                `json:"duration_ms" example:"0.42"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// ReadinessResponse reports the outcome of every health check; the status is the worst of theirs
+type ReadinessResponse struct {
+	Status    string        `json:"status" example:"healthy" enums:"healthy,degraded,unhealthy"`
+	Timestamp string        `json:"timestamp" example:"2025-10-19T18:30:00Z"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+// DetailedHealthResponse adds database statistics to the health checks
+type DetailedHealthResponse struct {
+	ReadinessResponse
+	DatabaseStats    map[string]interface{} `json:"database_stats,omitempty"`
+	DatabaseSettings map[string]interface{} `json:"database_settings,omitempty"`
+	TableCounts      map[string]int         `json:"table_counts,omitempty"`
+}
+
 // ErrorResponse represents an error
 type ErrorResponse struct {
-	Error   string `json:"error" example:"Invalid request"`
-	Details string `json:"details,omitempty"`
+	Error      string           `json:"error" example:"Invalid request"`
+	Details    string           `json:"details,omitempty"`
+	Violations []FieldViolation `json:"violations,omitempty"`
+}
+
+// FieldViolation is a rule a metric field breaks
+type FieldViolation struct {
+	Field     string `json:"field" example:"memory_used_bytes"`
+	Message   string `json:"message" example:"is 5000000000, more than memory_total_bytes (4294967296)"`
+	Corrected bool   `json:"corrected,omitempty"`
 }
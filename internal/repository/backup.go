@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BackupRepository struct {
+	db *sql.DB
+}
+
+func NewBackupRepository(db *sql.DB) *BackupRepository {
+	return &BackupRepository{db: db}
+}
+
+// Snapshot writes a consistent copy of the live database to dest with VACUUM INTO.
+// Writers are not blocked while it runs, and dest must not already exist.
+func (repo *BackupRepository) Snapshot(ctx context.Context, dest string) error {
+	_, err := repo.db.ExecContext(ctx, "VACUUM INTO ?", dest)
+	return err
+}
@@ -0,0 +1,85 @@
+// nolint
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// BackupRepositoryTestSuite is the test suite for BackupRepository
+type BackupRepositoryTestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+	repo *BackupRepository
+}
+
+// SetupTest runs before each test in the suite
+func (suite *BackupRepositoryTestSuite) SetupTest() {
+	var err error
+	suite.db, suite.mock, err = sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp),
+	)
+	suite.Require().NoError(err)
+
+	suite.repo = NewBackupRepository(suite.db)
+}
+
+// TearDownTest runs after each test
+func (suite *BackupRepositoryTestSuite) TearDownTest() {
+	suite.db.Close()
+
+	// Ensure all expectations were met
+	err := suite.mock.ExpectationsWereMet()
+	suite.NoError(err)
+}
+
+// TestSnapshot tests the Snapshot method
+func (suite *BackupRepositoryTestSuite) TestSnapshot() {
+	tests := []struct {
+		name          string
+		setupMock     func()
+		expectedError error
+	}{
+		{
+			name: "successful_snapshot",
+			setupMock: func() {
+				suite.mock.ExpectExec("VACUUM INTO \\?").
+					WithArgs("/backups/monitor.db.tmp").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "destination_exists",
+			setupMock: func() {
+				suite.mock.ExpectExec("VACUUM INTO \\?").
+					WithArgs("/backups/monitor.db.tmp").
+					WillReturnError(errors.New("output file already exists"))
+			},
+			expectedError: errors.New("output file already exists"),
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setupMock()
+
+			err := suite.repo.Snapshot(context.Background(), "/backups/monitor.db.tmp")
+			assert.Equal(suite.T(), test.expectedError, err)
+		})
+	}
+}
+
+// Run the test suite
+func TestBackupRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupRepositoryTestSuite))
+}
@@ -0,0 +1,281 @@
+// nolint
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	fixtureHosts    = 20
+	fixtureStart    = 1760000000
+	fixtureInterval = 15 // seconds between a host's samples
+
+	// benchmarkMetrics is the default fixture size, about a year of one host
+	// reporting every 15 seconds; BENCH_METRICS overrides it
+	benchmarkMetrics = 2000000
+)
+
+// openFixture returns a migrated SQLite file holding fixtureHosts hosts that report in
+// turn, fixtureInterval seconds apart, until there are metrics rows
+func openFixture(tb testing.TB, metrics int) *database.DB {
+	tb.Helper()
+
+	db, err := database.Connect(filepath.Join(tb.TempDir(), "monitor.db"), database.Options{
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		CacheSizeKiB: 20000,
+		ForeignKeys:  true,
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = database.Close(db) })
+	if err := database.Migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+			INSERT INTO hosts (hostname, ip_address, role, created_at, last_seen)
+			SELECT printf('pi-%02d', i), printf('192.168.0.%d', i), 'worker', ?, ? FROM n`,
+			[]interface{}{fixtureHosts, fixtureStart, fixtureStart}},
+		{`WITH RECURSIVE n(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM n WHERE i < ? - 1)
+			INSERT INTO system_metrics (
+				host_id, timestamp, cpu_usage, memory_usage_percent,
+				memory_total_bytes, memory_used_bytes, memory_available_bytes,
+				disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes
+			)
+			SELECT i % ? + 1, ? + i / ? * ?, i % 100, 50,
+				4294967296, 2147483648, 2147483648,
+				40, 32212254720, 12884901888, 19327352832
+			FROM n`,
+			[]interface{}{metrics, fixtureHosts, fixtureStart, fixtureHosts, fixtureInterval}},
+	}
+	for _, statement := range statements {
+		if _, err := db.Write.Exec(statement.query, statement.args...); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return db
+}
+
+// QueryPlanTestSuite checks that metric lookups are answered from an index, in index
+// order, both before and after ANALYZE gives the planner statistics
+type QueryPlanTestSuite struct {
+	suite.Suite
+	db *database.DB
+}
+
+// SetupTest runs before each test in the suite
+func (suite *QueryPlanTestSuite) SetupTest() {
+	suite.db = openFixture(suite.T(), 2000)
+}
+
+// plan returns the EXPLAIN QUERY PLAN output for a query, one step per line
+func (suite *QueryPlanTestSuite) plan(query string, args ...interface{}) string {
+	rows, err := suite.db.Read.Query("EXPLAIN QUERY PLAN "+query, args...)
+	suite.Require().NoError(err)
+	defer rows.Close()
+
+	var steps []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		suite.Require().NoError(rows.Scan(&id, &parent, &unused, &detail))
+		steps = append(steps, detail)
+	}
+	suite.Require().NoError(rows.Err())
+	return strings.Join(steps, "\n")
+}
+
+// TestMetricQueryPlans tests the queries the metric repository issues
+func (suite *QueryPlanTestSuite) TestMetricQueryPlans() {
+	const columns = `SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
+		memory_total_bytes, memory_used_bytes, memory_available_bytes,
+		disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		FROM system_metrics`
+
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		index string // the index and the constraints it is searched with
+	}{
+		{
+			name:  "find_by_host",
+			query: columns + " WHERE 1=1 AND host_id = ? ORDER BY timestamp DESC, id DESC LIMIT ?",
+			args:  []interface{}{7, 100},
+			index: "idx_system_metrics_host_timestamp (host_id=?)",
+		},
+		{
+			name:  "find_by_host_and_range",
+			query: columns + " WHERE 1=1 AND host_id = ? AND timestamp >= ? AND timestamp <= ? ORDER BY timestamp ASC, id ASC LIMIT ?",
+			args:  []interface{}{7, fixtureStart, fixtureStart + 3600, 1000},
+			index: "idx_system_metrics_host_timestamp (host_id=? AND timestamp>? AND timestamp<?)",
+		},
+		{
+			name:  "find_by_host_after_cursor",
+			query: columns + " WHERE 1=1 AND host_id = ? AND (timestamp, id) < (?, ?) ORDER BY timestamp DESC, id DESC LIMIT ?",
+			args:  []interface{}{7, fixtureStart + 600, 500, 100},
+			index: "idx_system_metrics_host_timestamp (host_id=? AND timestamp<?)",
+		},
+		{
+			name:  "find_all_hosts",
+			query: columns + " WHERE 1=1 ORDER BY timestamp DESC, id DESC LIMIT ?",
+			args:  []interface{}{100},
+			index: "idx_system_metrics_timestamp",
+		},
+		{
+			name:  "find_latest_for_host",
+			query: columns + " WHERE host_id = ? ORDER BY timestamp DESC LIMIT 1",
+			args:  []interface{}{7},
+			index: "idx_system_metrics_host_timestamp (host_id=?)",
+		},
+		{
+			name:  "find_latest",
+			query: columns + " ORDER BY timestamp DESC LIMIT 1",
+			index: "idx_system_metrics_timestamp",
+		},
+		{
+			name:  "find_after_id_for_host",
+			query: columns + " WHERE id > ? AND +host_id = ? ORDER BY id ASC LIMIT ?",
+			args:  []interface{}{1500, 7, 500},
+			index: "INTEGER PRIMARY KEY (rowid>?)",
+		},
+		{
+			name:  "delete_range",
+			query: "DELETE FROM system_metrics WHERE timestamp >= ? AND timestamp <= ? AND id <= ?",
+			args:  []interface{}{fixtureStart, fixtureStart + 3600, 2000},
+			index: "idx_system_metrics_timestamp (timestamp>? AND timestamp<?)",
+		},
+	}
+
+	for _, analyzed := range []bool{false, true} {
+		if analyzed {
+			_, err := suite.db.Write.Exec("ANALYZE")
+			suite.Require().NoError(err)
+		}
+
+		for _, test := range tests {
+			suite.Run(fmt.Sprintf("%s/analyzed=%t", test.name, analyzed), func() {
+				plan := suite.plan(test.query, test.args...)
+
+				assert.Contains(suite.T(), plan, test.index)
+				assert.NotContains(suite.T(), plan, "TEMP B-TREE")
+				assert.NotRegexp(suite.T(), `(?m)SCAN system_metrics$`, plan)
+			})
+		}
+	}
+}
+
+// Run the test suite
+func TestQueryPlanTestSuite(t *testing.T) {
+	suite.Run(t, new(QueryPlanTestSuite))
+}
+
+// BenchmarkQueries times the metric lookups and table counts against a fixture of
+// BENCH_METRICS rows, analyzed as scheduled maintenance would leave it
+func BenchmarkQueries(b *testing.B) {
+	metrics := benchmarkMetrics
+	if value := os.Getenv("BENCH_METRICS"); value != "" {
+		var err error
+		if metrics, err = strconv.Atoi(value); err != nil {
+			b.Fatalf("BENCH_METRICS: %v", err)
+		}
+	}
+
+	db := openFixture(b, metrics)
+	if _, err := db.Write.Exec("ANALYZE"); err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	repo := NewMetricRepository(db.Write, db.Read, entities.DuplicateIgnore)
+	health := NewHealthRepository(db.Write, db.Read, db.Path)
+	hostID := int64(7)
+	end := int64(fixtureStart + metrics/fixtureHosts*fixtureInterval)
+	hourAgo := end - 3600
+
+	b.Run("FindByFilters/host", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByFilters(ctx, &entities.MetricQueryParams{HostID: &hostID, Order: "DESC", Limit: 100}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindByFilters/host_last_hour", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByFilters(ctx, &entities.MetricQueryParams{HostID: &hostID, StartTime: &hourAgo, Order: "ASC", Limit: 1000}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindByFilters/host_cursor", func(b *testing.B) {
+		after := &entities.MetricCursor{Timestamp: fixtureStart + (end-fixtureStart)/2, ID: int64(metrics / 2)}
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByFilters(ctx, &entities.MetricQueryParams{HostID: &hostID, Order: "DESC", Limit: 100, After: after}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindByFilters/all_hosts", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByFilters(ctx, &entities.MetricQueryParams{Order: "DESC", Limit: 100}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindLatest/host", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindLatest(ctx, &hostID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindLatest/all_hosts", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindLatest(ctx, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("FindAfterID/host", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindAfterID(ctx, int64(metrics-1000), &hostID, 500); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("GetTableCounts", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := health.GetTableCounts(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
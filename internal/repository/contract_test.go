@@ -0,0 +1,466 @@
+// nolint
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// RepositoryContractTestSuite runs the same behaviour checks against a real database
+// for each storage backend, so every implementation of the repository interfaces agrees
+type RepositoryContractTestSuite struct {
+	suite.Suite
+	open func() *database.DB // returns a migrated, empty database
+
+	db      *database.DB
+	health  HealthRepositoryInterface
+	hosts   HostRepositoryInterface
+	metrics MetricRepositoryInterface
+	targets ScrapeTargetRepositoryInterface
+}
+
+// SetupTest runs before each test in the suite
+func (suite *RepositoryContractTestSuite) SetupTest() {
+	suite.db = suite.open()
+
+	switch suite.db.Dialect {
+	case database.Postgres:
+		suite.health = NewPostgresHealthRepository(suite.db.Write)
+		suite.hosts = NewPostgresHostRepository(suite.db.Write)
+		suite.metrics = suite.metricRepository(entities.DuplicateIgnore)
+		suite.targets = NewPostgresScrapeTargetRepository(suite.db.Write)
+	default:
+		suite.health = NewHealthRepository(suite.db.Write, suite.db.Read, suite.db.Path)
+		suite.hosts = NewHostRepository(suite.db.Write, suite.db.Read)
+		suite.metrics = suite.metricRepository(entities.DuplicateIgnore)
+		suite.targets = NewScrapeTargetRepository(suite.db.Write)
+	}
+}
+
+// metricRepository returns a metric repository for the suite database with a duplicate policy
+func (suite *RepositoryContractTestSuite) metricRepository(duplicatePolicy string) MetricRepositoryInterface {
+	if suite.db.Dialect == database.Postgres {
+		return NewPostgresMetricRepository(suite.db.Write, duplicatePolicy)
+	}
+	return NewMetricRepository(suite.db.Write, suite.db.Read, duplicatePolicy)
+}
+
+// TearDownTest runs after each test
+func (suite *RepositoryContractTestSuite) TearDownTest() {
+	suite.NoError(database.Close(suite.db))
+}
+
+// createHost creates a host and returns its ID
+func (suite *RepositoryContractTestSuite) createHost(hostname, role string) int64 {
+	id, err := suite.hosts.Create(context.Background(), &entities.Host{Hostname: hostname, IPAddress: "192.168.0.10", Role: role})
+	suite.Require().NoError(err)
+	suite.Require().Positive(id)
+	return id
+}
+
+// createMetric creates a metric for a host and returns its ID
+func (suite *RepositoryContractTestSuite) createMetric(hostID, timestamp int64, cpu float64) int64 {
+	write, err := suite.metrics.Create(context.Background(), &entities.SystemMetric{
+		HostID:           hostID,
+		Timestamp:        timestamp,
+		CPUUsage:         cpu,
+		MemoryTotalBytes: 4294967296,
+		DiskTotalBytes:   32212254720,
+		ReceivedAt:       timestamp + 2,
+	})
+	suite.Require().NoError(err)
+	suite.Require().False(write.Deduplicated)
+	suite.Require().Positive(write.ID)
+	return write.ID
+}
+
+// TestHostLifecycle tests creating, filtering, paging, updating and deleting hosts
+func (suite *RepositoryContractTestSuite) TestHostLifecycle() {
+	first := suite.createHost("pi-01", "worker")
+	second := suite.createHost("pi-02", "worker")
+	suite.createHost("nas", "storage")
+
+	// Hostnames are unique
+	_, err := suite.hosts.Create(context.Background(), &entities.Host{Hostname: "pi-01", IPAddress: "192.168.0.11"})
+	assert.Error(suite.T(), err)
+
+	hosts, err := suite.hosts.FindByFilters(context.Background(), &entities.HostQueryParams{Role: "worker"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.Host{
+		{ID: first, Hostname: "pi-01", IPAddress: "192.168.0.10", Role: "worker"},
+		{ID: second, Hostname: "pi-02", IPAddress: "192.168.0.10", Role: "worker"},
+	}, hosts)
+
+	hosts, err = suite.hosts.FindByFilters(context.Background(), &entities.HostQueryParams{Limit: 1, AfterID: first})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), hosts, 1)
+	assert.Equal(suite.T(), second, hosts[0].ID)
+
+	assert.NoError(suite.T(), suite.hosts.Update(context.Background(), first, &entities.Host{Role: "controller"}))
+	hosts, err = suite.hosts.FindByFilters(context.Background(), &entities.HostQueryParams{Hostname: "pi-01"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "controller", hosts[0].Role)
+
+	assert.NoError(suite.T(), suite.hosts.Delete(context.Background(), second))
+	assert.ErrorIs(suite.T(), suite.hosts.Delete(context.Background(), second), sql.ErrNoRows)
+
+	hosts, err = suite.hosts.FindByFilters(context.Background(), &entities.HostQueryParams{ID: second})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), hosts)
+}
+
+// TestMetricQueries tests filtering, ordering, cursors and the latest metric
+func (suite *RepositoryContractTestSuite) TestMetricQueries() {
+	hostID := suite.createHost("pi-01", "worker")
+	otherID := suite.createHost("pi-02", "worker")
+
+	ids := []int64{
+		suite.createMetric(hostID, 1000, 10),
+		suite.createMetric(hostID, 2000, 20),
+		suite.createMetric(otherID, 2000, 25),
+		suite.createMetric(hostID, 3000, 30),
+	}
+	otherMetric := suite.createMetric(otherID, 5000, 50)
+
+	start, end := int64(2000), int64(3000)
+	metrics, err := suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{
+		HostID: &hostID, StartTime: &start, EndTime: &end, Limit: 10, Order: "DESC",
+	})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(metrics, 2)
+	assert.Equal(suite.T(), []int64{ids[3], ids[1]}, []int64{metrics[0].ID, metrics[1].ID})
+	assert.Equal(suite.T(), int64(4294967296), metrics[0].MemoryTotalBytes)
+	assert.Equal(suite.T(), 30.0, metrics[0].CPUUsage)
+	assert.Equal(suite.T(), int64(3002), metrics[0].ReceivedAt)
+
+	// The cursor resumes between metrics from different hosts with the same timestamp
+	metrics, err = suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{
+		Limit: 2, Order: "ASC",
+		After: &entities.MetricCursor{Timestamp: 2000, ID: ids[1]},
+	})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(metrics, 2)
+	assert.Equal(suite.T(), ids[2], metrics[0].ID)
+	assert.Equal(suite.T(), ids[3], metrics[1].ID)
+
+	latest, err := suite.metrics.FindLatest(context.Background(), &hostID)
+	assert.NoError(suite.T(), err)
+	suite.Require().NotNil(latest)
+	assert.Equal(suite.T(), ids[3], latest.ID)
+
+	latest, err = suite.metrics.FindLatest(context.Background(), nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), otherMetric, latest.ID)
+
+	missing := int64(999)
+	latest, err = suite.metrics.FindLatest(context.Background(), &missing)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), latest)
+
+	metrics, err = suite.metrics.FindAfterID(context.Background(), ids[1], nil, 2)
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(metrics, 2)
+	assert.Equal(suite.T(), ids[2], metrics[0].ID)
+	assert.Equal(suite.T(), ids[3], metrics[1].ID)
+}
+
+// TestStreamByFilters tests streaming metrics joined with their host
+func (suite *RepositoryContractTestSuite) TestStreamByFilters() {
+	hostID := suite.createHost("pi-01", "worker")
+	suite.createMetric(hostID, 1000, 10)
+	suite.createMetric(hostID, 2000, 20)
+	suite.createMetric(hostID, 3000, 30)
+
+	var rows []entities.MetricExportRow
+	err := suite.metrics.StreamByFilters(context.Background(), &entities.MetricQueryParams{HostID: &hostID, Limit: 2, Order: "ASC"}, func(row entities.MetricExportRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(rows, 2)
+	assert.Equal(suite.T(), int64(1000), rows[0].Metric.Timestamp)
+	assert.Equal(suite.T(), int64(1002), rows[0].Metric.ReceivedAt)
+	assert.Equal(suite.T(), entities.Host{ID: hostID, Hostname: "pi-01", IPAddress: "192.168.0.10", Role: "worker"}, rows[1].Host)
+}
+
+// TestCreateBatch tests that a batch is stored in order, or not at all
+func (suite *RepositoryContractTestSuite) TestCreateBatch() {
+	hostID := suite.createHost("pi-01", "worker")
+
+	writes, err := suite.metrics.CreateBatch(context.Background(), []entities.SystemMetric{
+		{HostID: hostID, Timestamp: 1000, CPUUsage: 10},
+		{HostID: hostID, Timestamp: 2000, CPUUsage: 20},
+	})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(writes, 2)
+	assert.Less(suite.T(), writes[0].ID, writes[1].ID)
+
+	// A metric for a missing host fails the whole batch
+	writes, err = suite.metrics.CreateBatch(context.Background(), []entities.SystemMetric{
+		{HostID: hostID, Timestamp: 3000, CPUUsage: 30},
+		{HostID: 999, Timestamp: 3000, CPUUsage: 30},
+	})
+	assert.Error(suite.T(), err)
+	assert.Nil(suite.T(), writes)
+
+	metrics, err := suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{Limit: 10, Order: "ASC"})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(metrics, 2)
+	assert.Equal(suite.T(), 20.0, metrics[1].CPUUsage)
+}
+
+// TestDuplicatePolicies tests what each duplicate policy does with a second metric for
+// a host and timestamp, alone and in a batch
+func (suite *RepositoryContractTestSuite) TestDuplicatePolicies() {
+	hostID := suite.createHost("pi-01", "worker")
+	stored := suite.createMetric(hostID, 1000, 10)
+	repeat := &entities.SystemMetric{HostID: hostID, Timestamp: 1000, CPUUsage: 99, ReceivedAt: 1060}
+
+	metricAt := func(timestamp int64) entities.SystemMetric {
+		metrics, err := suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{
+			HostID: &hostID, StartTime: &timestamp, EndTime: &timestamp, Limit: 10, Order: "ASC",
+		})
+		suite.Require().NoError(err)
+		suite.Require().Len(metrics, 1)
+		return metrics[0]
+	}
+
+	write, err := suite.metricRepository(entities.DuplicateIgnore).Create(context.Background(), repeat)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: stored, Deduplicated: true}, write)
+	assert.Equal(suite.T(), 10.0, metricAt(1000).CPUUsage)
+
+	write, err = suite.metricRepository(entities.DuplicateReplace).Create(context.Background(), repeat)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: stored, Replaced: true}, write)
+	assert.Equal(suite.T(), 99.0, metricAt(1000).CPUUsage)
+	assert.Equal(suite.T(), int64(1060), metricAt(1000).ReceivedAt)
+
+	_, err = suite.metricRepository(entities.DuplicateReject).Create(context.Background(), repeat)
+	assert.ErrorIs(suite.T(), err, ErrDuplicateMetric)
+
+	// Duplicates within a batch resolve against the metrics before them
+	writes, err := suite.metricRepository(entities.DuplicateIgnore).CreateBatch(context.Background(), []entities.SystemMetric{
+		{HostID: hostID, Timestamp: 2000, CPUUsage: 20},
+		{HostID: hostID, Timestamp: 1000, CPUUsage: 5},
+		{HostID: hostID, Timestamp: 2000, CPUUsage: 21},
+	})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(writes, 3)
+	assert.False(suite.T(), writes[0].Deduplicated)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: stored, Deduplicated: true}, writes[1])
+	assert.Equal(suite.T(), entities.MetricWrite{ID: writes[0].ID, Deduplicated: true}, writes[2])
+	assert.Equal(suite.T(), 20.0, metricAt(2000).CPUUsage)
+
+	// A rejected duplicate fails the whole batch
+	writes, err = suite.metricRepository(entities.DuplicateReject).CreateBatch(context.Background(), []entities.SystemMetric{
+		{HostID: hostID, Timestamp: 3000, CPUUsage: 30},
+		{HostID: hostID, Timestamp: 2000, CPUUsage: 22},
+	})
+	assert.ErrorIs(suite.T(), err, ErrDuplicateMetric)
+	assert.Nil(suite.T(), writes)
+
+	metrics, err := suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{Limit: 10, Order: "ASC"})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), metrics, 2)
+}
+
+// TestInsertMissingAndDeleteRange tests idempotent bulk inserts and bounded deletes
+func (suite *RepositoryContractTestSuite) TestInsertMissingAndDeleteRange() {
+	hostID := suite.createHost("pi-01", "worker")
+	suite.createMetric(hostID, 1000, 10)
+	deletedHostID := suite.createHost("pi-02", "worker")
+	suite.Require().NoError(suite.hosts.Delete(context.Background(), deletedHostID))
+
+	inserted, err := suite.metrics.InsertMissing(context.Background(), []entities.SystemMetric{
+		{HostID: hostID, Timestamp: 1000, CPUUsage: 99},
+		{HostID: hostID, Timestamp: 2000, CPUUsage: 20},
+		{HostID: deletedHostID, Timestamp: 2000, CPUUsage: 40},
+		{HostID: hostID, Timestamp: 3000, CPUUsage: 30},
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), inserted)
+
+	latest, err := suite.metrics.FindLatest(context.Background(), &hostID)
+	suite.Require().NoError(err)
+
+	// Metrics written after maxID are kept
+	later := suite.createMetric(hostID, 2500, 25)
+
+	deleted, err := suite.metrics.DeleteRange(context.Background(), 1000, 3000, latest.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), deleted)
+
+	metrics, err := suite.metrics.FindByFilters(context.Background(), &entities.MetricQueryParams{Limit: 10, Order: "ASC"})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(metrics, 1)
+	assert.Equal(suite.T(), later, metrics[0].ID)
+}
+
+// TestHostDeleteCascades tests that deleting a host removes its metrics and scrape targets
+func (suite *RepositoryContractTestSuite) TestHostDeleteCascades() {
+	hostID := suite.createHost("pi-01", "worker")
+	suite.createMetric(hostID, 1000, 10)
+	_, err := suite.targets.Create(context.Background(), &entities.ScrapeTarget{
+		Name: "node", URL: "http://pi-01:9100/metrics", HostID: hostID, Format: "prometheus",
+		IntervalSeconds: 30, TimeoutSeconds: 5,
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.hosts.Delete(context.Background(), hostID))
+
+	counts, err := suite.health.GetTableCounts(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{"hosts": 0, "metrics": 0}, counts)
+
+	targets, err := suite.targets.FindByFilters(context.Background(), &entities.ScrapeTargetQueryParams{})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), targets)
+}
+
+// TestScrapeTargets tests scrape target storage and result tracking
+func (suite *RepositoryContractTestSuite) TestScrapeTargets() {
+	hostID := suite.createHost("nas", "storage")
+	target := entities.ScrapeTarget{
+		Name: "nas", URL: "http://nas.local/api/stats", HostID: hostID, Format: "json",
+		IntervalSeconds: 60, TimeoutSeconds: 10, JSONMapping: map[string]string{"cpu_usage": "cpu.percent"},
+	}
+	id, err := suite.targets.Create(context.Background(), &target)
+	suite.Require().NoError(err)
+
+	paused := target
+	paused.Name = "paused"
+	paused.Paused = true
+	_, err = suite.targets.Create(context.Background(), &paused)
+	suite.Require().NoError(err)
+
+	active, err := suite.targets.FindByFilters(context.Background(), &entities.ScrapeTargetQueryParams{ActiveOnly: true})
+	assert.NoError(suite.T(), err)
+	suite.Require().Len(active, 1)
+	assert.Equal(suite.T(), id, active[0].ID)
+	assert.Equal(suite.T(), map[string]string{"cpu_usage": "cpu.percent"}, active[0].JSONMapping)
+
+	for _, result := range []entities.ScrapeResult{
+		{Timestamp: 1000, Status: "error", Error: "timeout", DurationMs: 10000},
+		{Timestamp: 1060, Status: "error", Error: "timeout", DurationMs: 10000},
+	} {
+		suite.Require().NoError(suite.targets.RecordResult(context.Background(), id, &result))
+	}
+	targets, err := suite.targets.FindByFilters(context.Background(), &entities.ScrapeTargetQueryParams{ID: id})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, targets[0].ConsecutiveFailures)
+	assert.Equal(suite.T(), "timeout", targets[0].LastError)
+
+	suite.Require().NoError(suite.targets.RecordResult(context.Background(), id, &entities.ScrapeResult{Timestamp: 1120, Status: "ok", DurationMs: 40}))
+	targets, err = suite.targets.FindByFilters(context.Background(), &entities.ScrapeTargetQueryParams{ID: id})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, targets[0].ConsecutiveFailures)
+	assert.Equal(suite.T(), int64(1120), targets[0].LastScrapeAt)
+
+	target.Paused = true
+	assert.NoError(suite.T(), suite.targets.Update(context.Background(), id, &target))
+	assert.ErrorIs(suite.T(), suite.targets.Update(context.Background(), id+100, &target), sql.ErrNoRows)
+
+	assert.NoError(suite.T(), suite.targets.Delete(context.Background(), id))
+	assert.ErrorIs(suite.T(), suite.targets.Delete(context.Background(), id), sql.ErrNoRows)
+}
+
+// TestHealth tests connectivity checks and reporting
+func (suite *RepositoryContractTestSuite) TestHealth() {
+	suite.createHost("pi-01", "worker")
+
+	assert.NoError(suite.T(), suite.health.CheckDatabaseConnection(context.Background()))
+
+	counts, err := suite.health.GetTableCounts(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{"hosts": 1, "metrics": 0}, counts)
+
+	stats, err := suite.health.GetDatabaseStats()
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), stats)
+
+	settings, err := suite.health.GetDatabaseSettings(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), settings)
+
+	storage, err := suite.health.GetStorageStats(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Positive(suite.T(), storage.FileBytes)
+}
+
+// TestCancelledContext tests that queries stop with the context's error
+func (suite *RepositoryContractTestSuite) TestCancelledContext() {
+	hostID := suite.createHost("pi-01", "worker")
+	suite.createMetric(hostID, 1000, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := suite.hosts.FindByFilters(ctx, &entities.HostQueryParams{})
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+
+	_, err = suite.metrics.Create(ctx, &entities.SystemMetric{HostID: hostID, Timestamp: 2000})
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, err = suite.metrics.FindLatest(ctx, &hostID)
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+}
+
+// Run the contract suite against a SQLite file in a temporary directory
+func TestSQLiteRepositoryContract(t *testing.T) {
+	suite.Run(t, &RepositoryContractTestSuite{open: func() *database.DB {
+		db, err := database.Connect(filepath.Join(t.TempDir(), "monitor.db"), database.Options{
+			JournalMode:  "WAL",
+			Synchronous:  "NORMAL",
+			BusyTimeout:  5 * time.Second,
+			CacheSizeKiB: 2000,
+			ForeignKeys:  true,
+			MaxOpenConns: 4,
+			MaxIdleConns: 4,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}})
+}
+
+// Run the contract suite against the PostgreSQL database at POSTGRES_TEST_URL. Its
+// monitoring tables are emptied before every test.
+func TestPostgresRepositoryContract(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+
+	suite.Run(t, &RepositoryContractTestSuite{open: func() *database.DB {
+		db, err := database.ConnectPostgres(url, database.Options{MaxOpenConns: 4, MaxIdleConns: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Write.Exec("TRUNCATE hosts, system_metrics, scrape_targets RESTART IDENTITY CASCADE"); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}})
+}
@@ -1,53 +1,234 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
 )
 
+// synchronousModes names the values returned by PRAGMA synchronous
+var synchronousModes = map[int]string{0: "OFF", 1: "NORMAL", 2: "FULL", 3: "EXTRA"}
+
 type HealthRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	readDB *sql.DB
+	path   string
 }
 
-func NewHealthRepository(db *sql.DB) *HealthRepository {
-	return &HealthRepository{db: db}
+// NewHealthRepository reports on the SQLite database at path, through its write and read pools
+func NewHealthRepository(db, readDB *sql.DB, path string) *HealthRepository {
+	return &HealthRepository{db: db, readDB: readDB, path: path}
 }
 
-// CheckDatabaseConnection verifies the database is accessible
-func (repo *HealthRepository) CheckDatabaseConnection() error {
-	return repo.db.Ping()
+// CheckDatabaseConnection verifies the database is accessible through both pools
+func (repo *HealthRepository) CheckDatabaseConnection(ctx context.Context) error {
+	if err := repo.db.PingContext(ctx); err != nil {
+		return err
+	}
+	return repo.readDB.PingContext(ctx)
 }
 
-// GetDatabaseStats returns database statistics
+// GetDatabaseStats returns connection pool statistics for the write and read pools
 func (repo *HealthRepository) GetDatabaseStats() (map[string]interface{}, error) {
-	stats := repo.db.Stats()
-
 	return map[string]interface{}{
-		"open_connections": stats.OpenConnections,
-		"in_use":           stats.InUse,
-		"idle":             stats.Idle,
-		"max_open":         stats.MaxOpenConnections,
+		"write": poolStats(repo.db),
+		"read":  poolStats(repo.readDB),
 	}, nil
 }
 
-// GetTableCounts returns record counts for monitoring tables
-func (repo *HealthRepository) GetTableCounts() (map[string]int, error) {
-	counts := make(map[string]int)
+// GetDatabaseSettings returns the SQLite settings in effect on the read pool's
+// connections, which are opened with the same options as the write connection
+func (repo *HealthRepository) GetDatabaseSettings(ctx context.Context) (map[string]interface{}, error) {
+	var journalMode string
+	var synchronous, busyTimeout, cacheSize, pageSize int
+	var foreignKeys bool
+
+	pragmas := []struct {
+		name string
+		dest interface{}
+	}{
+		{"journal_mode", &journalMode},
+		{"synchronous", &synchronous},
+		{"busy_timeout", &busyTimeout},
+		{"cache_size", &cacheSize},
+		{"page_size", &pageSize},
+		{"foreign_keys", &foreignKeys},
+	}
+	for _, pragma := range pragmas {
+		if err := repo.readDB.QueryRowContext(ctx, "PRAGMA "+pragma.name).Scan(pragma.dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma.name, err)
+		}
+	}
+
+	// A negative cache size is in KiB, a positive one in pages
+	cacheSizeKiB := -cacheSize
+	if cacheSize > 0 {
+		cacheSizeKiB = cacheSize * pageSize / 1024
+	}
+
+	return map[string]interface{}{
+		"journal_mode":    journalMode,
+		"synchronous":     synchronousModes[synchronous],
+		"busy_timeout_ms": busyTimeout,
+		"cache_size_kib":  cacheSizeKiB,
+		"foreign_keys":    foreignKeys,
+		"max_open_read":   repo.readDB.Stats().MaxOpenConnections,
+		"max_open_write":  repo.db.Stats().MaxOpenConnections,
+	}, nil
+}
 
-	// Get host count
-	var hostCount int
-	err := repo.db.QueryRow("SELECT COUNT(*) FROM hosts").Scan(&hostCount)
+// GetTableCounts returns record counts for monitoring tables. Tables the statistics
+// from the last ANALYZE put above exactCountLimit rows report that estimate instead.
+func (repo *HealthRepository) GetTableCounts(ctx context.Context) (map[string]int, error) {
+	estimates, err := repo.rowEstimates(ctx)
 	if err != nil {
 		return nil, err
 	}
-	counts["hosts"] = hostCount
+	return countTables(ctx, repo.readDB, estimates)
+}
+
+// rowEstimates reads the row counts ANALYZE recorded in sqlite_stat1, by table. The
+// first number of each index's stat is the table's row count at the time.
+func (repo *HealthRepository) rowEstimates(ctx context.Context) (map[string]int, error) {
+	estimates := make(map[string]int)
+
+	// sqlite_stat1 only exists once ANALYZE has run
+	var analyzed int
+	if err := repo.readDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'").Scan(&analyzed); err != nil {
+		return nil, err
+	}
+	if analyzed == 0 {
+		return estimates, nil
+	}
 
-	// Get metric count
-	var metricCount int
-	err = repo.db.QueryRow("SELECT COUNT(*) FROM system_metrics").Scan(&metricCount)
+	rows, err := repo.readDB.QueryContext(ctx, "SELECT tbl, stat FROM sqlite_stat1")
 	if err != nil {
 		return nil, err
 	}
-	counts["metrics"] = metricCount
+	defer closeRows(rows)
 
+	for rows.Next() {
+		var table string
+		var stat sql.NullString
+		if err := rows.Scan(&table, &stat); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(stat.String)
+		if len(fields) == 0 {
+			continue
+		}
+		if estimate, err := strconv.Atoi(fields[0]); err == nil && estimate > estimates[table] {
+			estimates[table] = estimate
+		}
+	}
+	return estimates, rows.Err()
+}
+
+// GetSchemaVersion returns the highest applied migration, or 0 for an unmigrated database
+func (repo *HealthRepository) GetSchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, repo.readDB)
+}
+
+// GetStorageStats returns the page counts, the sizes of the database file and its WAL,
+// and the space left on the volume holding them
+func (repo *HealthRepository) GetStorageStats(ctx context.Context) (*entities.StorageStats, error) {
+	stats := &entities.StorageStats{}
+
+	pragmas := []struct {
+		name string
+		dest *int64
+	}{
+		{"page_count", &stats.PageCount},
+		{"page_size", &stats.PageSize},
+		{"freelist_count", &stats.FreelistCount},
+	}
+	for _, pragma := range pragmas {
+		if err := repo.readDB.QueryRowContext(ctx, "PRAGMA "+pragma.name).Scan(pragma.dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma.name, err)
+		}
+	}
+
+	if repo.path == "" {
+		return stats, nil
+	}
+
+	file, err := os.Stat(repo.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database file size: %w", err)
+	}
+	stats.FileBytes = file.Size()
+
+	// The WAL only exists while the database is open in WAL mode
+	wal, err := os.Stat(repo.path + "-wal")
+	if err == nil {
+		stats.WALBytes = wal.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read WAL file size: %w", err)
+	}
+
+	stats.Volume, err = volumeStats(filepath.Dir(repo.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read free space: %w", err)
+	}
+
+	return stats, nil
+}
+
+// schemaVersion reads the highest version recorded in schema_migrations
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// exactCountLimit is the estimated row count above which a table isn't counted, as
+// COUNT(*) reads every row
+const exactCountLimit = 100000
+
+// countedTables maps the names GetTableCounts reports to their tables
+var countedTables = []struct{ name, table string }{
+	{"hosts", "hosts"},
+	{"metrics", "system_metrics"},
+}
+
+// countTables returns the estimate for tables known to be large and counts the rest
+func countTables(ctx context.Context, db *sql.DB, estimates map[string]int) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, counted := range countedTables {
+		if estimate := estimates[counted.table]; estimate > exactCountLimit {
+			counts[counted.name] = estimate
+			continue
+		}
+
+		var count int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+counted.table).Scan(&count); err != nil {
+			return nil, err
+		}
+		counts[counted.name] = count
+	}
 	return counts, nil
 }
+
+// poolStats summarises a connection pool
+func poolStats(db *sql.DB) map[string]interface{} {
+	stats := db.Stats()
+
+	return map[string]interface{}{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"max_open":         stats.MaxOpenConnections,
+		"wait_count":       stats.WaitCount,
+		"wait_duration_ms": stats.WaitDuration.Milliseconds(),
+	}
+}
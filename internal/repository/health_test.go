@@ -2,8 +2,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -14,9 +18,11 @@ import (
 // HealthRepositoryTestSuite is the test suite for HealthRepository
 type HealthRepositoryTestSuite struct {
 	suite.Suite
-	db   *sql.DB
-	mock sqlmock.Sqlmock
-	repo *HealthRepository
+	db       *sql.DB
+	mock     sqlmock.Sqlmock
+	readDB   *sql.DB
+	readMock sqlmock.Sqlmock
+	repo     *HealthRepository
 }
 
 // SetupTest runs before each test in the suite
@@ -28,22 +34,30 @@ func (suite *HealthRepositoryTestSuite) SetupTest() {
 	)
 	suite.Require().NoError(err)
 
-	suite.repo = NewHealthRepository(suite.db)
+	suite.readDB, suite.readMock, err = sqlmock.New(
+		sqlmock.MonitorPingsOption(true),
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp),
+	)
+	suite.Require().NoError(err)
+
+	suite.repo = NewHealthRepository(suite.db, suite.readDB, "")
 }
 
 // TearDownTest runs after each test
 func (suite *HealthRepositoryTestSuite) TearDownTest() {
 	suite.db.Close()
+	suite.readDB.Close()
 
 	// Ensure all expectations were met
-	err := suite.mock.ExpectationsWereMet()
-	suite.NoError(err)
+	suite.NoError(suite.mock.ExpectationsWereMet())
+	suite.NoError(suite.readMock.ExpectationsWereMet())
 }
 
 // TestNewHealthRepository tests the constructor
 func (suite *HealthRepositoryTestSuite) TestNewHealthRepository() {
 	assert.NotNil(suite.T(), suite.repo)
 	assert.Equal(suite.T(), suite.db, suite.repo.db)
+	assert.Equal(suite.T(), suite.readDB, suite.repo.readDB)
 }
 
 // TestCheckDatabaseConnection tests the CheckDatabaseConnection method
@@ -57,6 +71,7 @@ func (suite *HealthRepositoryTestSuite) TestCheckDatabaseConnection() {
 			name: "successful_connection",
 			setupMock: func() {
 				suite.mock.ExpectPing()
+				suite.readMock.ExpectPing()
 			},
 			expectedError: nil,
 		},
@@ -74,6 +89,14 @@ func (suite *HealthRepositoryTestSuite) TestCheckDatabaseConnection() {
 			},
 			expectedError: errors.New("i/o timeout"),
 		},
+		{
+			name: "read_pool_failed",
+			setupMock: func() {
+				suite.mock.ExpectPing()
+				suite.readMock.ExpectPing().WillReturnError(errors.New("unable to open database file"))
+			},
+			expectedError: errors.New("unable to open database file"),
+		},
 		{
 			name: "database_not_found",
 			setupMock: func() {
@@ -87,7 +110,7 @@ func (suite *HealthRepositoryTestSuite) TestCheckDatabaseConnection() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.repo.CheckDatabaseConnection()
+			err := suite.repo.CheckDatabaseConnection(context.Background())
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -108,30 +131,104 @@ func (suite *HealthRepositoryTestSuite) TestGetDatabaseStats() {
 	// Note: sql.DB.Stats() doesn't actually query the database,
 	// it returns the internal connection pool statistics.
 	// So no need to set up specific expectations here.
+	suite.db.SetMaxOpenConns(1)
+	suite.readDB.SetMaxOpenConns(4)
 
 	stats, err := suite.repo.GetDatabaseStats()
 
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), stats)
 
-	// Check that all expected keys are present
-	expectedKeys := []string{"open_connections", "in_use", "idle", "max_open"}
-	for _, key := range expectedKeys {
-		_, exists := stats[key]
-		assert.True(suite.T(), exists, "Expected key %s to exist in stats", key)
+	for pool, maxOpen := range map[string]int{"write": 1, "read": 4} {
+		poolStats, ok := stats[pool].(map[string]interface{})
+		suite.Require().True(ok, "Expected stats for the %s pool", pool)
+
+		// Check that all expected keys are present
+		expectedKeys := []string{"open_connections", "in_use", "idle", "max_open", "wait_count", "wait_duration_ms"}
+		for _, key := range expectedKeys {
+			_, exists := poolStats[key]
+			assert.True(suite.T(), exists, "Expected key %s to exist in %s stats", key, pool)
+		}
+
+		// Values should be non-negative
+		assert.GreaterOrEqual(suite.T(), poolStats["open_connections"].(int), 0)
+		assert.GreaterOrEqual(suite.T(), poolStats["in_use"].(int), 0)
+		assert.GreaterOrEqual(suite.T(), poolStats["idle"].(int), 0)
+		assert.Equal(suite.T(), maxOpen, poolStats["max_open"])
+	}
+}
+
+// TestGetDatabaseSettings tests reading the effective SQLite settings
+func (suite *HealthRepositoryTestSuite) TestGetDatabaseSettings() {
+	expectPragmas := func(cacheSize int) {
+		suite.readMock.ExpectQuery("PRAGMA journal_mode").WillReturnRows(sqlmock.NewRows([]string{"journal_mode"}).AddRow("wal"))
+		suite.readMock.ExpectQuery("PRAGMA synchronous").WillReturnRows(sqlmock.NewRows([]string{"synchronous"}).AddRow(1))
+		suite.readMock.ExpectQuery("PRAGMA busy_timeout").WillReturnRows(sqlmock.NewRows([]string{"timeout"}).AddRow(5000))
+		suite.readMock.ExpectQuery("PRAGMA cache_size").WillReturnRows(sqlmock.NewRows([]string{"cache_size"}).AddRow(cacheSize))
+		suite.readMock.ExpectQuery("PRAGMA page_size").WillReturnRows(sqlmock.NewRows([]string{"page_size"}).AddRow(4096))
+		suite.readMock.ExpectQuery("PRAGMA foreign_keys").WillReturnRows(sqlmock.NewRows([]string{"foreign_keys"}).AddRow(1))
+	}
+
+	tests := []struct {
+		name             string
+		setupMock        func()
+		expectedCacheKiB int
+		expectedError    string
+	}{
+		{
+			name:             "cache_size_in_kib",
+			setupMock:        func() { expectPragmas(-20000) },
+			expectedCacheKiB: 20000,
+		},
+		{
+			name:             "cache_size_in_pages",
+			setupMock:        func() { expectPragmas(2000) },
+			expectedCacheKiB: 8000,
+		},
+		{
+			name: "pragma_error",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("PRAGMA journal_mode").WillReturnError(errors.New("database is locked"))
+			},
+			expectedError: "failed to read journal_mode: database is locked",
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+			suite.db.SetMaxOpenConns(1)
+			suite.readDB.SetMaxOpenConns(4)
+
+			test.setupMock()
+
+			settings, err := suite.repo.GetDatabaseSettings(context.Background())
+
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+				assert.Nil(suite.T(), settings)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), map[string]interface{}{
+				"journal_mode":    "wal",
+				"synchronous":     "NORMAL",
+				"busy_timeout_ms": 5000,
+				"cache_size_kib":  test.expectedCacheKiB,
+				"foreign_keys":    true,
+				"max_open_read":   4,
+				"max_open_write":  1,
+			}, settings)
+		})
 	}
+}
 
-	// Verify the values are of correct type (int)
-	assert.IsType(suite.T(), 0, stats["open_connections"])
-	assert.IsType(suite.T(), 0, stats["in_use"])
-	assert.IsType(suite.T(), 0, stats["idle"])
-	assert.IsType(suite.T(), 0, stats["max_open"])
-
-	// Values should be non-negative
-	assert.GreaterOrEqual(suite.T(), stats["open_connections"].(int), 0)
-	assert.GreaterOrEqual(suite.T(), stats["in_use"].(int), 0)
-	assert.GreaterOrEqual(suite.T(), stats["idle"].(int), 0)
-	assert.GreaterOrEqual(suite.T(), stats["max_open"].(int), 0)
+// expectNoStatistics expects the check for sqlite_stat1 and reports that ANALYZE
+// hasn't run, so every table is counted
+func (suite *HealthRepositoryTestSuite) expectNoStatistics() {
+	suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM sqlite_master WHERE name = 'sqlite_stat1'").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 }
 
 // TestGetTableCounts tests the GetTableCounts method
@@ -145,14 +242,16 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "successful_counts",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Mock host count query
 				hostRows := sqlmock.NewRows([]string{"count"}).AddRow(10)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnRows(hostRows)
 
 				// Mock metric count query
 				metricRows := sqlmock.NewRows([]string{"count"}).AddRow(1000)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
 					WillReturnRows(metricRows)
 			},
 			expectedCounts: map[string]int{
@@ -164,14 +263,16 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "zero_counts",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Mock host count query with zero
 				hostRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnRows(hostRows)
 
 				// Mock metric count query with zero
 				metricRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
 					WillReturnRows(metricRows)
 			},
 			expectedCounts: map[string]int{
@@ -183,14 +284,16 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "large_counts",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Mock host count query with large number
 				hostRows := sqlmock.NewRows([]string{"count"}).AddRow(100000)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnRows(hostRows)
 
 				// Mock metric count query with large number
 				metricRows := sqlmock.NewRows([]string{"count"}).AddRow(5000000)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
 					WillReturnRows(metricRows)
 			},
 			expectedCounts: map[string]int{
@@ -199,11 +302,44 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "large_tables_estimated",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM sqlite_master WHERE name = 'sqlite_stat1'").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				suite.readMock.ExpectQuery("SELECT tbl, stat FROM sqlite_stat1").
+					WillReturnRows(sqlmock.NewRows([]string{"tbl", "stat"}).
+						AddRow("hosts", "12 1").
+						AddRow("system_metrics", "4800000 480000 1").
+						AddRow("system_metrics", "4800000 10").
+						AddRow("scrape_targets", nil))
+
+				// Only the small table is counted
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+			},
+			expectedCounts: map[string]int{
+				"hosts":   12,
+				"metrics": 4800000,
+			},
+			expectedError: nil,
+		},
+		{
+			name: "statistics_error",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM sqlite_master").
+					WillReturnError(errors.New("database is locked"))
+			},
+			expectedCounts: nil,
+			expectedError:  errors.New("database is locked"),
+		},
 		{
 			name: "host_table_error",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Mock host count query with error
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnError(errors.New("table 'hosts' doesn't exist"))
 			},
 			expectedCounts: nil,
@@ -212,13 +348,15 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "metric_table_error",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Mock host count query success
 				hostRows := sqlmock.NewRows([]string{"count"}).AddRow(10)
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnRows(hostRows)
 
 				// Mock metric count query with error
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
 					WillReturnError(errors.New("table 'system_metrics' doesn't exist"))
 			},
 			expectedCounts: nil,
@@ -227,7 +365,9 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "database_connection_error_on_hosts",
 			setupMock: func() {
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.expectNoStatistics()
+
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnError(errors.New("database connection lost"))
 			},
 			expectedCounts: nil,
@@ -236,9 +376,11 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		{
 			name: "scan_error_on_hosts",
 			setupMock: func() {
+				suite.expectNoStatistics()
+
 				// Return rows with wrong type/structure
 				hostRows := sqlmock.NewRows([]string{"invalid_column"}).AddRow("not_a_number")
-				suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+				suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 					WillReturnRows(hostRows)
 			},
 			expectedCounts: nil,
@@ -250,7 +392,7 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCounts() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			counts, err := suite.repo.GetTableCounts()
+			counts, err := suite.repo.GetTableCounts(context.Background())
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -278,16 +420,18 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCountsPartialSuccess() {
 	// This tests that if the first query succeeds but the second fails,
 	// it should still return an error and nil counts
 
+	suite.expectNoStatistics()
+
 	// Mock successful host count
 	hostRows := sqlmock.NewRows([]string{"count"}).AddRow(50)
-	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+	suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 		WillReturnRows(hostRows)
 
 	// Mock failed metric count
-	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
+	suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM system_metrics").
 		WillReturnError(errors.New("permission denied"))
 
-	counts, err := suite.repo.GetTableCounts()
+	counts, err := suite.repo.GetTableCounts(context.Background())
 
 	assert.Error(suite.T(), err)
 	assert.Equal(suite.T(), "permission denied", err.Error())
@@ -298,18 +442,135 @@ func (suite *HealthRepositoryTestSuite) TestGetTableCountsPartialSuccess() {
 func (suite *HealthRepositoryTestSuite) TestGetTableCountsWithNullValues() {
 	// Test that NULL values are handled correctly (though COUNT(*) shouldn't return NULL)
 
+	suite.expectNoStatistics()
+
 	// Mock host count query with NULL (edge case)
 	hostRows := sqlmock.NewRows([]string{"count"}).AddRow(nil)
-	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
+	suite.readMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM hosts").
 		WillReturnRows(hostRows)
 
-	counts, err := suite.repo.GetTableCounts()
+	counts, err := suite.repo.GetTableCounts(context.Background())
 
 	// Should error when trying to scan NULL into int
 	assert.Error(suite.T(), err)
 	assert.Nil(suite.T(), counts)
 }
 
+// TestGetSchemaVersion tests reading the applied schema version
+func (suite *HealthRepositoryTestSuite) TestGetSchemaVersion() {
+	tests := []struct {
+		name            string
+		setupMock       func()
+		expectedVersion int
+		expectedError   string
+	}{
+		{
+			name: "migrated",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").
+					WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+			},
+			expectedVersion: 3,
+		},
+		{
+			name: "unmigrated",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").
+					WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+			},
+			expectedVersion: 0,
+		},
+		{
+			name: "missing_table",
+			setupMock: func() {
+				suite.readMock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").
+					WillReturnError(errors.New("no such table: schema_migrations"))
+			},
+			expectedError: "failed to read schema version: no such table: schema_migrations",
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			version, err := suite.repo.GetSchemaVersion(context.Background())
+
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), test.expectedVersion, version)
+		})
+	}
+}
+
+// TestGetStorageStats tests reading page counts and file and volume sizes
+func (suite *HealthRepositoryTestSuite) TestGetStorageStats() {
+	expectPragmas := func() {
+		suite.readMock.ExpectQuery("PRAGMA page_count").WillReturnRows(sqlmock.NewRows([]string{"page_count"}).AddRow(250))
+		suite.readMock.ExpectQuery("PRAGMA page_size").WillReturnRows(sqlmock.NewRows([]string{"page_size"}).AddRow(4096))
+		suite.readMock.ExpectQuery("PRAGMA freelist_count").WillReturnRows(sqlmock.NewRows([]string{"freelist_count"}).AddRow(10))
+	}
+
+	suite.Run("files_and_volume", func() {
+		dir := suite.T().TempDir()
+		path := filepath.Join(dir, "monitor.db")
+		suite.Require().NoError(os.WriteFile(path, make([]byte, 8192), 0o600))
+		suite.Require().NoError(os.WriteFile(path+"-wal", make([]byte, 1024), 0o600))
+		repo := NewHealthRepository(suite.db, suite.readDB, path)
+		expectPragmas()
+
+		stats, err := repo.GetStorageStats(context.Background())
+
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), int64(250), stats.PageCount)
+		assert.Equal(suite.T(), int64(4096), stats.PageSize)
+		assert.Equal(suite.T(), int64(10), stats.FreelistCount)
+		assert.Equal(suite.T(), int64(8192), stats.FileBytes)
+		assert.Equal(suite.T(), int64(1024), stats.WALBytes)
+		if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+			suite.Require().NotNil(stats.Volume)
+			assert.Equal(suite.T(), dir, stats.Volume.Path)
+			assert.Positive(suite.T(), stats.Volume.TotalBytes)
+			assert.LessOrEqual(suite.T(), stats.Volume.FreeBytes, stats.Volume.TotalBytes)
+		}
+	})
+
+	suite.Run("no_wal", func() {
+		path := filepath.Join(suite.T().TempDir(), "monitor.db")
+		suite.Require().NoError(os.WriteFile(path, make([]byte, 4096), 0o600))
+		repo := NewHealthRepository(suite.db, suite.readDB, path)
+		expectPragmas()
+
+		stats, err := repo.GetStorageStats(context.Background())
+
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), int64(4096), stats.FileBytes)
+		assert.Zero(suite.T(), stats.WALBytes)
+	})
+
+	suite.Run("missing_file", func() {
+		repo := NewHealthRepository(suite.db, suite.readDB, filepath.Join(suite.T().TempDir(), "missing.db"))
+		expectPragmas()
+
+		stats, err := repo.GetStorageStats(context.Background())
+
+		assert.Nil(suite.T(), stats)
+		assert.ErrorContains(suite.T(), err, "failed to read database file size")
+	})
+
+	suite.Run("pragma_error", func() {
+		suite.readMock.ExpectQuery("PRAGMA page_count").WillReturnError(errors.New("database is locked"))
+
+		stats, err := suite.repo.GetStorageStats(context.Background())
+
+		assert.Nil(suite.T(), stats)
+		assert.EqualError(suite.T(), err, "failed to read page_count: database is locked")
+	})
+}
+
 // Run the test suite
 func TestHealthRepositoryTestSuite(test *testing.T) {
 	suite.Run(test, new(HealthRepositoryTestSuite))
@@ -1,13 +1,44 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"strconv"
 )
 
+// querier runs statements on a connection pool or inside a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 // closeRows safely closes sql.Rows and logs any errors
 func closeRows(rows *sql.Rows) {
 	if err := rows.Close(); err != nil {
-		log.Printf("failed to close database rows: %v", err)
+		slog.Error("failed to close database rows", "error", err)
+	}
+}
+
+// requireRowsAffected returns sql.ErrNoRows when a statement matched no rows
+func requireRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
 	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// pgArgs collects query arguments and returns PostgreSQL's numbered placeholder for each
+type pgArgs []interface{}
+
+// add appends an argument and returns its placeholder
+func (args *pgArgs) add(value interface{}) string {
+	*args = append(*args, value)
+	return "$" + strconv.Itoa(len(*args))
 }
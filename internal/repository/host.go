@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -8,15 +9,17 @@ import (
 )
 
 type HostRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	readDB *sql.DB
 }
 
-func NewHostRepository(db *sql.DB) *HostRepository {
-	return &HostRepository{db: db}
+// NewHostRepository creates a HostRepository that writes through db and queries through readDB
+func NewHostRepository(db, readDB *sql.DB) *HostRepository {
+	return &HostRepository{db: db, readDB: readDB}
 }
 
 // FindByFilters retrieves hosts based on query parameters
-func (repo *HostRepository) FindByFilters(params *entities.HostQueryParams) ([]entities.Host, error) {
+func (repo *HostRepository) FindByFilters(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, error) {
 	querySQL := `
 		SELECT id, hostname, ip_address, role
 		FROM hosts
@@ -39,23 +42,40 @@ func (repo *HostRepository) FindByFilters(params *entities.HostQueryParams) ([]e
 		args = append(args, params.IPAddress)
 	}
 
-	rows, err := repo.db.Query(querySQL, args...)
+	if params.Role != "" {
+		querySQL += " AND role = ?"
+		args = append(args, params.Role)
+	}
+
+	if params.AfterID != 0 {
+		querySQL += " AND id > ?"
+		args = append(args, params.AfterID)
+	}
+
+	querySQL += " ORDER BY id ASC"
+
+	if params.Limit > 0 {
+		querySQL += " LIMIT ?"
+		args = append(args, params.Limit)
+	}
+
+	rows, err := repo.readDB.QueryContext(ctx, querySQL, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer closeRows(rows)
 
-	return repo.scanHosts(rows)
+	return scanHosts(rows)
 }
 
 // Create inserts a new host
-func (repo *HostRepository) Create(host *entities.Host) (int64, error) {
+func (repo *HostRepository) Create(ctx context.Context, host *entities.Host) (int64, error) {
 	timestamp := time.Now().Unix()
 	insertSQL := `
 		INSERT INTO hosts (hostname, ip_address, role, created_at, last_seen)
 		VALUES (?, ?, ?, ?, ?)`
 
-	result, err := repo.db.Exec(insertSQL,
+	result, err := repo.db.ExecContext(ctx, insertSQL,
 		host.Hostname,
 		host.IPAddress,
 		host.Role,
@@ -71,21 +91,21 @@ func (repo *HostRepository) Create(host *entities.Host) (int64, error) {
 }
 
 // Update updates an existing host
-func (repo *HostRepository) Update(id int64, host *entities.Host) error {
+func (repo *HostRepository) Update(ctx context.Context, id int64, host *entities.Host) error {
 	timestamp := time.Now().Unix()
 	updateSQL := `
 		UPDATE hosts
 		SET role = ?, last_seen = ?
 		WHERE id = ?`
 
-	_, err := repo.db.Exec(updateSQL, host.Role, timestamp, id)
+	_, err := repo.db.ExecContext(ctx, updateSQL, host.Role, timestamp, id)
 	return err
 }
 
 // Delete removes a host from the database
-func (repo *HostRepository) Delete(id int64) error {
+func (repo *HostRepository) Delete(ctx context.Context, id int64) error {
 	deleteSQL := `DELETE FROM hosts WHERE id = ?`
-	result, err := repo.db.Exec(deleteSQL, id)
+	result, err := repo.db.ExecContext(ctx, deleteSQL, id)
 	if err != nil {
 		return err
 	}
@@ -103,7 +123,7 @@ func (repo *HostRepository) Delete(id int64) error {
 }
 
 // scanHosts is a helper to scan multiple rows into Host slice
-func (repo *HostRepository) scanHosts(rows *sql.Rows) ([]entities.Host, error) {
+func scanHosts(rows *sql.Rows) ([]entities.Host, error) {
 	var hosts []entities.Host
 	for rows.Next() {
 		var host entities.Host
@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -29,7 +30,7 @@ func (suite *HostRepositoryTestSuite) SetupTest() {
 	)
 	suite.Require().NoError(err)
 
-	suite.repo = NewHostRepository(suite.db)
+	suite.repo = NewHostRepository(suite.db, suite.db)
 }
 
 // TearDownTest runs after each test
@@ -45,6 +46,7 @@ func (suite *HostRepositoryTestSuite) TearDownTest() {
 func (suite *HostRepositoryTestSuite) TestNewHostRepository() {
 	assert.NotNil(suite.T(), suite.repo)
 	assert.Equal(suite.T(), suite.db, suite.repo.db)
+	assert.Equal(suite.T(), suite.db, suite.repo.readDB)
 }
 
 // TestFindByFilters tests the FindByFilters method
@@ -74,6 +76,43 @@ func (suite *HostRepositoryTestSuite) TestFindByFilters() {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "filter_by_role",
+			params: &entities.HostQueryParams{
+				Role: "nas",
+			},
+			setupMock: func() {
+				rows := sqlmock.NewRows([]string{"id", "hostname", "ip_address", "role"}).
+					AddRow(4, "nas-01", "192.168.1.104", "nas")
+
+				suite.mock.ExpectQuery("SELECT id, hostname, ip_address, role FROM hosts WHERE 1=1 AND role = \\?").
+					WithArgs("nas").
+					WillReturnRows(rows)
+			},
+			expectedHosts: []entities.Host{
+				{ID: 4, Hostname: "nas-01", IPAddress: "192.168.1.104", Role: "nas"},
+			},
+			expectedError: nil,
+		},
+		{
+			name: "paginated",
+			params: &entities.HostQueryParams{
+				Limit:   2,
+				AfterID: 3,
+			},
+			setupMock: func() {
+				rows := sqlmock.NewRows([]string{"id", "hostname", "ip_address", "role"}).
+					AddRow(4, "nas-01", "192.168.1.104", "nas")
+
+				suite.mock.ExpectQuery("SELECT id, hostname, ip_address, role FROM hosts WHERE 1=1 AND id > \\? ORDER BY id ASC LIMIT \\?").
+					WithArgs(int64(3), 2).
+					WillReturnRows(rows)
+			},
+			expectedHosts: []entities.Host{
+				{ID: 4, Hostname: "nas-01", IPAddress: "192.168.1.104", Role: "nas"},
+			},
+			expectedError: nil,
+		},
 		{
 			name: "filter_by_hostname",
 			params: &entities.HostQueryParams{
@@ -194,7 +233,7 @@ func (suite *HostRepositoryTestSuite) TestFindByFilters() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			hosts, err := suite.repo.FindByFilters(test.params)
+			hosts, err := suite.repo.FindByFilters(context.Background(), test.params)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -295,7 +334,7 @@ func (suite *HostRepositoryTestSuite) TestCreate() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			id, err := suite.repo.Create(test.host)
+			id, err := suite.repo.Create(context.Background(), test.host)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -326,7 +365,7 @@ func (suite *HostRepositoryTestSuite) TestCreateWithLastInsertIdError() {
 			sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewErrorResult(errors.New("LastInsertId not supported")))
 
-	id, err := suite.repo.Create(host)
+	id, err := suite.repo.Create(context.Background(), host)
 
 	assert.Error(suite.T(), err)
 	assert.Equal(suite.T(), "LastInsertId not supported", err.Error())
@@ -387,7 +426,7 @@ func (suite *HostRepositoryTestSuite) TestUpdate() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.repo.Update(test.id, test.host)
+			err := suite.repo.Update(context.Background(), test.id, test.host)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -457,7 +496,7 @@ func (suite *HostRepositoryTestSuite) TestDelete() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.repo.Delete(test.id)
+			err := suite.repo.Delete(context.Background(), test.id)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -481,7 +520,7 @@ func (suite *HostRepositoryTestSuite) TestScanHostsErrorHandling() {
 			AddRow(1, "server-01", "192.168.1.100", "web").
 			RowError(0, errors.New("row error")))
 
-	hosts, err := suite.repo.FindByFilters(&entities.HostQueryParams{})
+	hosts, err := suite.repo.FindByFilters(context.Background(), &entities.HostQueryParams{})
 
 	assert.Error(suite.T(), err)
 	assert.Equal(suite.T(), "row error", err.Error())
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+)
+
+// instrumentation records the duration and outcome of repository calls
+type instrumentation struct {
+	metrics    *telemetry.Metrics
+	repository string
+}
+
+// observe is deferred with the call's start time and a pointer to its error. A metric
+// turned away as a duplicate is an answer, not a failed call.
+func (instr instrumentation) observe(method string, start time.Time, err *error) {
+	callErr := *err
+	if errors.Is(callErr, ErrDuplicateMetric) {
+		callErr = nil
+	}
+	instr.metrics.ObserveQuery(instr.repository, method, time.Since(start), callErr)
+}
+
+// InstrumentedBackupRepository times the calls of a BackupRepositoryInterface
+type InstrumentedBackupRepository struct {
+	next BackupRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedBackupRepository(next BackupRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedBackupRepository {
+	return &InstrumentedBackupRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "backup"}}
+}
+
+func (repo *InstrumentedBackupRepository) Snapshot(ctx context.Context, dest string) (err error) {
+	defer repo.observe("Snapshot", time.Now(), &err)
+	return repo.next.Snapshot(ctx, dest)
+}
+
+// InstrumentedHealthRepository times the calls of a HealthRepositoryInterface
+type InstrumentedHealthRepository struct {
+	next HealthRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedHealthRepository(next HealthRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedHealthRepository {
+	return &InstrumentedHealthRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "health"}}
+}
+
+func (repo *InstrumentedHealthRepository) CheckDatabaseConnection(ctx context.Context) (err error) {
+	defer repo.observe("CheckDatabaseConnection", time.Now(), &err)
+	return repo.next.CheckDatabaseConnection(ctx)
+}
+
+func (repo *InstrumentedHealthRepository) GetDatabaseStats() (stats map[string]interface{}, err error) {
+	defer repo.observe("GetDatabaseStats", time.Now(), &err)
+	return repo.next.GetDatabaseStats()
+}
+
+func (repo *InstrumentedHealthRepository) GetDatabaseSettings(ctx context.Context) (settings map[string]interface{}, err error) {
+	defer repo.observe("GetDatabaseSettings", time.Now(), &err)
+	return repo.next.GetDatabaseSettings(ctx)
+}
+
+func (repo *InstrumentedHealthRepository) GetTableCounts(ctx context.Context) (counts map[string]int, err error) {
+	defer repo.observe("GetTableCounts", time.Now(), &err)
+	return repo.next.GetTableCounts(ctx)
+}
+
+func (repo *InstrumentedHealthRepository) GetSchemaVersion(ctx context.Context) (version int, err error) {
+	defer repo.observe("GetSchemaVersion", time.Now(), &err)
+	return repo.next.GetSchemaVersion(ctx)
+}
+
+func (repo *InstrumentedHealthRepository) GetStorageStats(ctx context.Context) (stats *entities.StorageStats, err error) {
+	defer repo.observe("GetStorageStats", time.Now(), &err)
+	return repo.next.GetStorageStats(ctx)
+}
+
+// InstrumentedMaintenanceRepository times the calls of a MaintenanceRepositoryInterface
+type InstrumentedMaintenanceRepository struct {
+	next MaintenanceRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedMaintenanceRepository(next MaintenanceRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedMaintenanceRepository {
+	return &InstrumentedMaintenanceRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "maintenance"}}
+}
+
+func (repo *InstrumentedMaintenanceRepository) IncrementalVacuum(ctx context.Context) (freed int64, err error) {
+	defer repo.observe("IncrementalVacuum", time.Now(), &err)
+	return repo.next.IncrementalVacuum(ctx)
+}
+
+func (repo *InstrumentedMaintenanceRepository) Analyze(ctx context.Context) (err error) {
+	defer repo.observe("Analyze", time.Now(), &err)
+	return repo.next.Analyze(ctx)
+}
+
+func (repo *InstrumentedMaintenanceRepository) Optimize(ctx context.Context) (err error) {
+	defer repo.observe("Optimize", time.Now(), &err)
+	return repo.next.Optimize(ctx)
+}
+
+func (repo *InstrumentedMaintenanceRepository) IntegrityCheck(ctx context.Context) (problems []string, err error) {
+	defer repo.observe("IntegrityCheck", time.Now(), &err)
+	return repo.next.IntegrityCheck(ctx)
+}
+
+// InstrumentedHostRepository times the calls of a HostRepositoryInterface
+type InstrumentedHostRepository struct {
+	next HostRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedHostRepository(next HostRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedHostRepository {
+	return &InstrumentedHostRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "host"}}
+}
+
+func (repo *InstrumentedHostRepository) FindByFilters(ctx context.Context, params *entities.HostQueryParams) (hosts []entities.Host, err error) {
+	defer repo.observe("FindByFilters", time.Now(), &err)
+	return repo.next.FindByFilters(ctx, params)
+}
+
+func (repo *InstrumentedHostRepository) Create(ctx context.Context, host *entities.Host) (id int64, err error) {
+	defer repo.observe("Create", time.Now(), &err)
+	return repo.next.Create(ctx, host)
+}
+
+func (repo *InstrumentedHostRepository) Update(ctx context.Context, id int64, host *entities.Host) (err error) {
+	defer repo.observe("Update", time.Now(), &err)
+	return repo.next.Update(ctx, id, host)
+}
+
+func (repo *InstrumentedHostRepository) Delete(ctx context.Context, id int64) (err error) {
+	defer repo.observe("Delete", time.Now(), &err)
+	return repo.next.Delete(ctx, id)
+}
+
+// InstrumentedMetricRepository times the calls of a MetricRepositoryInterface and
+// counts the metrics it stores
+type InstrumentedMetricRepository struct {
+	next MetricRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedMetricRepository(next MetricRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedMetricRepository {
+	return &InstrumentedMetricRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "metric"}}
+}
+
+func (repo *InstrumentedMetricRepository) FindByFilters(ctx context.Context, params *entities.MetricQueryParams) (metrics []entities.SystemMetric, err error) {
+	defer repo.observe("FindByFilters", time.Now(), &err)
+	return repo.next.FindByFilters(ctx, params)
+}
+
+func (repo *InstrumentedMetricRepository) FindLatest(ctx context.Context, hostID *int64) (metric *entities.SystemMetric, err error) {
+	defer repo.observe("FindLatest", time.Now(), &err)
+	return repo.next.FindLatest(ctx, hostID)
+}
+
+func (repo *InstrumentedMetricRepository) FindAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) (metrics []entities.SystemMetric, err error) {
+	defer repo.observe("FindAfterID", time.Now(), &err)
+	return repo.next.FindAfterID(ctx, afterID, hostID, limit)
+}
+
+func (repo *InstrumentedMetricRepository) StreamByFilters(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) (err error) {
+	defer repo.observe("StreamByFilters", time.Now(), &err)
+	return repo.next.StreamByFilters(ctx, params, fn)
+}
+
+func (repo *InstrumentedMetricRepository) Create(ctx context.Context, metric *entities.SystemMetric) (write entities.MetricWrite, err error) {
+	defer repo.observe("Create", time.Now(), &err)
+	write, err = repo.next.Create(ctx, metric)
+	switch {
+	case err == nil:
+		repo.countWrites(write)
+	case errors.Is(err, ErrDuplicateMetric):
+		repo.metrics.AddIngestDeduplicated(1)
+	}
+	return write, err
+}
+
+func (repo *InstrumentedMetricRepository) CreateBatch(ctx context.Context, metrics []entities.SystemMetric) (writes []entities.MetricWrite, err error) {
+	defer repo.observe("CreateBatch", time.Now(), &err)
+	writes, err = repo.next.CreateBatch(ctx, metrics)
+	repo.countWrites(writes...)
+	// A larger batch holding a rejected duplicate is retried one metric at a time, and
+	// the duplicate is counted then
+	if len(metrics) == 1 && errors.Is(err, ErrDuplicateMetric) {
+		repo.metrics.AddIngestDeduplicated(1)
+	}
+	return writes, err
+}
+
+// countWrites counts stored metrics as ingested, and kept or replaced duplicates as
+// deduplicated
+func (repo *InstrumentedMetricRepository) countWrites(writes ...entities.MetricWrite) {
+	var ingested, deduplicated int64
+	for _, write := range writes {
+		if write.Deduplicated || write.Replaced {
+			deduplicated++
+		} else {
+			ingested++
+		}
+	}
+	repo.metrics.AddIngested(ingested)
+	repo.metrics.AddIngestDeduplicated(deduplicated)
+}
+
+func (repo *InstrumentedMetricRepository) DeleteRange(ctx context.Context, startTime, endTime, maxID int64) (deleted int64, err error) {
+	defer repo.observe("DeleteRange", time.Now(), &err)
+	return repo.next.DeleteRange(ctx, startTime, endTime, maxID)
+}
+
+func (repo *InstrumentedMetricRepository) InsertMissing(ctx context.Context, metrics []entities.SystemMetric) (inserted int64, err error) {
+	defer repo.observe("InsertMissing", time.Now(), &err)
+	inserted, err = repo.next.InsertMissing(ctx, metrics)
+	repo.metrics.AddIngested(inserted)
+	return inserted, err
+}
+
+// InstrumentedScrapeTargetRepository times the calls of a ScrapeTargetRepositoryInterface
+type InstrumentedScrapeTargetRepository struct {
+	next ScrapeTargetRepositoryInterface
+	instrumentation
+}
+
+func NewInstrumentedScrapeTargetRepository(next ScrapeTargetRepositoryInterface, metrics *telemetry.Metrics) *InstrumentedScrapeTargetRepository {
+	return &InstrumentedScrapeTargetRepository{next: next, instrumentation: instrumentation{metrics: metrics, repository: "scrape_target"}}
+}
+
+func (repo *InstrumentedScrapeTargetRepository) FindByFilters(ctx context.Context, params *entities.ScrapeTargetQueryParams) (targets []entities.ScrapeTarget, err error) {
+	defer repo.observe("FindByFilters", time.Now(), &err)
+	return repo.next.FindByFilters(ctx, params)
+}
+
+func (repo *InstrumentedScrapeTargetRepository) Create(ctx context.Context, target *entities.ScrapeTarget) (id int64, err error) {
+	defer repo.observe("Create", time.Now(), &err)
+	return repo.next.Create(ctx, target)
+}
+
+func (repo *InstrumentedScrapeTargetRepository) Update(ctx context.Context, id int64, target *entities.ScrapeTarget) (err error) {
+	defer repo.observe("Update", time.Now(), &err)
+	return repo.next.Update(ctx, id, target)
+}
+
+func (repo *InstrumentedScrapeTargetRepository) Delete(ctx context.Context, id int64) (err error) {
+	defer repo.observe("Delete", time.Now(), &err)
+	return repo.next.Delete(ctx, id)
+}
+
+func (repo *InstrumentedScrapeTargetRepository) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) (err error) {
+	defer repo.observe("RecordResult", time.Now(), &err)
+	return repo.next.RecordResult(ctx, id, result)
+}
@@ -0,0 +1,126 @@
+// nolint
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// InstrumentedRepositoryTestSuite is the test suite for the instrumented repositories
+type InstrumentedRepositoryTestSuite struct {
+	suite.Suite
+	db      *sql.DB
+	mock    sqlmock.Sqlmock
+	metrics *telemetry.Metrics
+}
+
+// SetupTest runs before each test in the suite
+func (suite *InstrumentedRepositoryTestSuite) SetupTest() {
+	var err error
+	suite.db, suite.mock, err = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	suite.Require().NoError(err)
+
+	suite.metrics = telemetry.New()
+}
+
+// TearDownTest runs after each test
+func (suite *InstrumentedRepositoryTestSuite) TearDownTest() {
+	suite.db.Close()
+	suite.NoError(suite.mock.ExpectationsWereMet())
+}
+
+// output renders the recorded metrics
+func (suite *InstrumentedRepositoryTestSuite) output() string {
+	var output bytes.Buffer
+	suite.Require().NoError(suite.metrics.WritePrometheus(&output))
+	return output.String()
+}
+
+// TestHostRepository tests that calls are timed and failures counted
+func (suite *InstrumentedRepositoryTestSuite) TestHostRepository() {
+	repo := NewInstrumentedHostRepository(NewHostRepository(suite.db, suite.db), suite.metrics)
+
+	suite.mock.ExpectExec("UPDATE hosts").WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("DELETE FROM hosts").WillReturnError(errors.New("database is locked"))
+
+	assert.NoError(suite.T(), repo.Update(context.Background(), 1, &entities.Host{Role: "worker"}))
+	assert.EqualError(suite.T(), repo.Delete(context.Background(), 1), "database is locked")
+
+	output := suite.output()
+	assert.Contains(suite.T(), output, `monitor_api_db_query_duration_seconds_count{method="Update",repository="host"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_db_query_duration_seconds_count{method="Delete",repository="host"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_db_query_errors_total{method="Delete",repository="host"} 1`)
+	assert.NotContains(suite.T(), output, `monitor_api_db_query_errors_total{method="Update",repository="host"}`)
+}
+
+// TestMetricRepositoryIngest tests that stored metrics are counted
+func (suite *InstrumentedRepositoryTestSuite) TestMetricRepositoryIngest() {
+	repo := NewInstrumentedMetricRepository(NewMetricRepository(suite.db, suite.db, entities.DuplicateIgnore), suite.metrics)
+
+	suite.mock.ExpectExec("INSERT INTO system_metrics").WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO system_metrics").WillReturnError(errors.New("FOREIGN KEY constraint failed"))
+
+	write, err := repo.Create(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1500})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 1}, write)
+
+	_, err = repo.Create(context.Background(), &entities.SystemMetric{HostID: 999, Timestamp: 1500})
+	assert.Error(suite.T(), err)
+
+	suite.mock.ExpectBegin()
+	prepare := suite.mock.ExpectPrepare("INSERT INTO system_metrics")
+	prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(3, 1))
+	suite.mock.ExpectCommit()
+
+	writes, err := repo.CreateBatch(context.Background(), []entities.SystemMetric{{HostID: 1, Timestamp: 1515}, {HostID: 1, Timestamp: 1530}})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.MetricWrite{{ID: 2}, {ID: 3}}, writes)
+
+	output := suite.output()
+	assert.Contains(suite.T(), output, "monitor_api_metrics_ingested_total 3\n")
+	assert.Contains(suite.T(), output, `monitor_api_db_query_errors_total{method="Create",repository="metric"} 1`)
+}
+
+// TestMetricRepositoryDeduplicated tests that duplicates are counted apart from stored
+// metrics, and a rejected one isn't counted as a failed call
+func (suite *InstrumentedRepositoryTestSuite) TestMetricRepositoryDeduplicated() {
+	ignore := NewInstrumentedMetricRepository(NewMetricRepository(suite.db, suite.db, entities.DuplicateIgnore), suite.metrics)
+	reject := NewInstrumentedMetricRepository(NewMetricRepository(suite.db, suite.db, entities.DuplicateReject), suite.metrics)
+
+	suite.mock.ExpectExec("INSERT INTO system_metrics").WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery("SELECT id FROM system_metrics").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	suite.mock.ExpectExec("INSERT INTO system_metrics").WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectPrepare("INSERT INTO system_metrics").ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+
+	write, err := ignore.Create(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1500})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 1, Deduplicated: true}, write)
+
+	_, err = reject.Create(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1500})
+	assert.ErrorIs(suite.T(), err, ErrDuplicateMetric)
+
+	_, err = reject.CreateBatch(context.Background(), []entities.SystemMetric{{HostID: 1, Timestamp: 1500}})
+	assert.ErrorIs(suite.T(), err, ErrDuplicateMetric)
+
+	output := suite.output()
+	assert.Contains(suite.T(), output, "monitor_api_ingest_deduplicated_total 3\n")
+	assert.Contains(suite.T(), output, "\nmonitor_api_metrics_ingested_total 0\n")
+	assert.NotContains(suite.T(), output, "monitor_api_db_query_errors_total{")
+}
+
+// Run the test suite
+func TestInstrumentedRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(InstrumentedRepositoryTestSuite))
+}
@@ -1,29 +1,78 @@
 package repository
 
-import "github.com/gabrielg2020/monitor-api/internal/entities"
+import (
+	"context"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// BackupRepositoryInterface defines methods for database backups
+type BackupRepositoryInterface interface {
+	Snapshot(ctx context.Context, dest string) error
+}
 
 // HealthRepositoryInterface defines methods for health checks
 type HealthRepositoryInterface interface {
-	CheckDatabaseConnection() error
+	CheckDatabaseConnection(ctx context.Context) error
 	GetDatabaseStats() (map[string]interface{}, error)
-	GetTableCounts() (map[string]int, error)
+	GetDatabaseSettings(ctx context.Context) (map[string]interface{}, error)
+	GetTableCounts(ctx context.Context) (map[string]int, error)
+	GetSchemaVersion(ctx context.Context) (int, error)
+	GetStorageStats(ctx context.Context) (*entities.StorageStats, error)
+}
+
+// MaintenanceRepositoryInterface defines methods for routine database maintenance
+type MaintenanceRepositoryInterface interface {
+	IncrementalVacuum(ctx context.Context) (int64, error)
+	Analyze(ctx context.Context) error
+	Optimize(ctx context.Context) error
+	IntegrityCheck(ctx context.Context) ([]string, error)
 }
 
 // HostRepositoryInterface defines methods for host repository operations
 type HostRepositoryInterface interface {
-	FindByFilters(params *entities.HostQueryParams) ([]entities.Host, error)
-	Create(host *entities.Host) (int64, error)
-	Update(id int64, host *entities.Host) error
-	Delete(id int64) error
+	FindByFilters(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, error)
+	Create(ctx context.Context, host *entities.Host) (int64, error)
+	Update(ctx context.Context, id int64, host *entities.Host) error
+	Delete(ctx context.Context, id int64) error
 }
 
 // MetricRepositoryInterface defines methods for metric repository operations
 type MetricRepositoryInterface interface {
-	FindByFilters(params *entities.MetricQueryParams) ([]entities.SystemMetric, error)
-	FindLatest(hostID *int64) (*entities.SystemMetric, error)
-	Create(metric *entities.SystemMetric) (int64, error)
+	FindByFilters(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, error)
+	FindLatest(ctx context.Context, hostID *int64) (*entities.SystemMetric, error)
+	FindAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error)
+	StreamByFilters(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error
+	Create(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error)
+	CreateBatch(ctx context.Context, metrics []entities.SystemMetric) ([]entities.MetricWrite, error)
+	DeleteRange(ctx context.Context, startTime, endTime, maxID int64) (int64, error)
+	InsertMissing(ctx context.Context, metrics []entities.SystemMetric) (int64, error)
 }
 
+// ScrapeTargetRepositoryInterface defines methods for scrape target repository operations
+type ScrapeTargetRepositoryInterface interface {
+	FindByFilters(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error)
+	Create(ctx context.Context, target *entities.ScrapeTarget) (int64, error)
+	Update(ctx context.Context, id int64, target *entities.ScrapeTarget) error
+	Delete(ctx context.Context, id int64) error
+	RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error
+}
+
+var _ BackupRepositoryInterface = (*BackupRepository)(nil)
 var _ HealthRepositoryInterface = (*HealthRepository)(nil)
 var _ HostRepositoryInterface = (*HostRepository)(nil)
+var _ MaintenanceRepositoryInterface = (*MaintenanceRepository)(nil)
 var _ MetricRepositoryInterface = (*MetricRepository)(nil)
+var _ ScrapeTargetRepositoryInterface = (*ScrapeTargetRepository)(nil)
+
+var _ HealthRepositoryInterface = (*PostgresHealthRepository)(nil)
+var _ HostRepositoryInterface = (*PostgresHostRepository)(nil)
+var _ MetricRepositoryInterface = (*PostgresMetricRepository)(nil)
+var _ ScrapeTargetRepositoryInterface = (*PostgresScrapeTargetRepository)(nil)
+
+var _ BackupRepositoryInterface = (*InstrumentedBackupRepository)(nil)
+var _ HealthRepositoryInterface = (*InstrumentedHealthRepository)(nil)
+var _ HostRepositoryInterface = (*InstrumentedHostRepository)(nil)
+var _ MaintenanceRepositoryInterface = (*InstrumentedMaintenanceRepository)(nil)
+var _ MetricRepositoryInterface = (*InstrumentedMetricRepository)(nil)
+var _ ScrapeTargetRepositoryInterface = (*InstrumentedScrapeTargetRepository)(nil)
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// autoVacuumIncremental is the PRAGMA auto_vacuum value for incremental mode
+const autoVacuumIncremental = 2
+
+// integrityCheckMaxErrors caps the problems PRAGMA integrity_check reports
+const integrityCheckMaxErrors = 100
+
+type MaintenanceRepository struct {
+	db     *sql.DB
+	readDB *sql.DB
+}
+
+func NewMaintenanceRepository(db, readDB *sql.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db, readDB: readDB}
+}
+
+// IncrementalVacuum returns free pages to the filesystem and reports how many were
+// freed. A database created without incremental auto-vacuum is rebuilt once with a
+// full VACUUM to switch it over, which needs as much free disk space as the file.
+func (repo *MaintenanceRepository) IncrementalVacuum(ctx context.Context) (int64, error) {
+	var autoVacuum int
+	if err := repo.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return 0, fmt.Errorf("failed to read auto_vacuum: %w", err)
+	}
+
+	before, err := repo.freelistCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if autoVacuum != autoVacuumIncremental {
+		if _, err := repo.db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return 0, fmt.Errorf("failed to set auto_vacuum: %w", err)
+		}
+		if _, err := repo.db.ExecContext(ctx, "VACUUM"); err != nil {
+			return 0, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	} else {
+		// Each freed page is a step of the statement, so read it to the end
+		rows, err := repo.db.QueryContext(ctx, "PRAGMA incremental_vacuum")
+		if err != nil {
+			return 0, fmt.Errorf("failed to vacuum: %w", err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Close(); err != nil {
+			return 0, fmt.Errorf("failed to vacuum: %w", err)
+		}
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	}
+
+	after, err := repo.freelistCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return before - after, nil
+}
+
+// Analyze refreshes the statistics the query planner uses to pick indexes
+func (repo *MaintenanceRepository) Analyze(ctx context.Context) error {
+	if _, err := repo.db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze: %w", err)
+	}
+	return nil
+}
+
+// Optimize runs PRAGMA optimize, which analyzes tables whose statistics are stale
+func (repo *MaintenanceRepository) Optimize(ctx context.Context) error {
+	if _, err := repo.db.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	return nil
+}
+
+// IntegrityCheck verifies the database file on the read pool and returns the problems
+// found, or none when it is intact
+func (repo *MaintenanceRepository) IntegrityCheck(ctx context.Context) ([]string, error) {
+	rows, err := repo.readDB.QueryContext(ctx, fmt.Sprintf("PRAGMA integrity_check(%d)", integrityCheckMaxErrors))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check integrity: %w", err)
+	}
+	defer rows.Close()
+
+	problems := []string{}
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return nil, fmt.Errorf("failed to check integrity: %w", err)
+		}
+		if message != "ok" {
+			problems = append(problems, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check integrity: %w", err)
+	}
+	return problems, nil
+}
+
+// freelistCount returns the number of unused pages in the database file
+func (repo *MaintenanceRepository) freelistCount(ctx context.Context) (int64, error) {
+	var count int64
+	if err := repo.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to read freelist_count: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,123 @@
+// nolint
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// MaintenanceRepositoryTestSuite runs maintenance against a real SQLite file, since the
+// pragmas only mean something there
+type MaintenanceRepositoryTestSuite struct {
+	suite.Suite
+	db   *database.DB
+	repo *MaintenanceRepository
+}
+
+// SetupTest runs before each test in the suite
+func (suite *MaintenanceRepositoryTestSuite) SetupTest() {
+	var err error
+	suite.db, err = database.Connect(filepath.Join(suite.T().TempDir(), "monitor.db"), database.Options{
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		CacheSizeKiB: 2000,
+		ForeignKeys:  true,
+		MaxOpenConns: 2,
+		MaxIdleConns: 2,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(database.Migrate(suite.db))
+
+	suite.repo = NewMaintenanceRepository(suite.db.Write, suite.db.Read)
+}
+
+// TearDownTest runs after each test
+func (suite *MaintenanceRepositoryTestSuite) TearDownTest() {
+	suite.NoError(database.Close(suite.db))
+}
+
+// fillAndDelete writes enough rows to span many pages, then deletes them to leave free pages
+func (suite *MaintenanceRepositoryTestSuite) fillAndDelete() {
+	_, err := suite.db.Write.Exec("INSERT OR IGNORE INTO hosts (id, hostname, ip_address, created_at, last_seen) VALUES (1, 'pi-01', '192.168.0.10', 1, 1)")
+	suite.Require().NoError(err)
+	_, err = suite.db.Write.Exec(`
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 5000)
+		INSERT INTO system_metrics (host_id, timestamp, cpu_usage) SELECT 1, i, 10 FROM n`)
+	suite.Require().NoError(err)
+	_, err = suite.db.Write.Exec("DELETE FROM system_metrics")
+	suite.Require().NoError(err)
+}
+
+// freelistCount reads the number of unused pages
+func (suite *MaintenanceRepositoryTestSuite) freelistCount() int64 {
+	count, err := suite.repo.freelistCount(context.Background())
+	suite.Require().NoError(err)
+	return count
+}
+
+// TestIncrementalVacuum tests that the first run switches to incremental auto-vacuum
+// and later runs free pages left by deletes
+func (suite *MaintenanceRepositoryTestSuite) TestIncrementalVacuum() {
+	suite.fillAndDelete()
+	suite.Require().Positive(suite.freelistCount())
+
+	freed, err := suite.repo.IncrementalVacuum(context.Background())
+	suite.Require().NoError(err)
+	assert.Positive(suite.T(), freed)
+	assert.Zero(suite.T(), suite.freelistCount())
+
+	var autoVacuum int
+	suite.Require().NoError(suite.db.Write.QueryRow("PRAGMA auto_vacuum").Scan(&autoVacuum))
+	assert.Equal(suite.T(), autoVacuumIncremental, autoVacuum)
+
+	// Incremental auto-vacuum leaves freed pages in place until asked
+	suite.fillAndDelete()
+	suite.Require().Positive(suite.freelistCount())
+
+	freed, err = suite.repo.IncrementalVacuum(context.Background())
+	suite.Require().NoError(err)
+	assert.Positive(suite.T(), freed)
+	assert.Zero(suite.T(), suite.freelistCount())
+}
+
+// TestAnalyzeAndOptimize tests that planner statistics are written
+func (suite *MaintenanceRepositoryTestSuite) TestAnalyzeAndOptimize() {
+	assert.NoError(suite.T(), suite.repo.Analyze(context.Background()))
+	assert.NoError(suite.T(), suite.repo.Optimize(context.Background()))
+
+	var tables int
+	suite.Require().NoError(suite.db.Read.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'").Scan(&tables))
+	assert.Equal(suite.T(), 1, tables)
+}
+
+// TestIntegrityCheck tests that an intact database reports no problems
+func (suite *MaintenanceRepositoryTestSuite) TestIntegrityCheck() {
+	problems, err := suite.repo.IntegrityCheck(context.Background())
+
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), problems)
+}
+
+// TestCancelledContext tests that maintenance stops with the context's error
+func (suite *MaintenanceRepositoryTestSuite) TestCancelledContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := suite.repo.IncrementalVacuum(ctx)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+	assert.ErrorIs(suite.T(), suite.repo.Analyze(ctx), context.Canceled)
+	_, err = suite.repo.IntegrityCheck(ctx)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+}
+
+// Run the test suite
+func TestMaintenanceRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceRepositoryTestSuite))
+}
@@ -1,26 +1,35 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 )
 
+// ErrDuplicateMetric is returned by the reject duplicate policy when the host already
+// has a metric at the timestamp
+var ErrDuplicateMetric = errors.New("metric already exists for this host and timestamp")
+
 type MetricRepository struct {
-	db *sql.DB
+	db              *sql.DB
+	readDB          *sql.DB
+	duplicatePolicy string
 }
 
-func NewMetricRepository(db *sql.DB) *MetricRepository {
-	return &MetricRepository{db: db}
+// NewMetricRepository creates a MetricRepository that writes through db and queries
+// through readDB. duplicatePolicy is one of the entities.Duplicate policies.
+func NewMetricRepository(db, readDB *sql.DB, duplicatePolicy string) *MetricRepository {
+	return &MetricRepository{db: db, readDB: readDB, duplicatePolicy: duplicatePolicy}
 }
 
 // FindByFilters retrieves metrics based on query parameters
-func (repo *MetricRepository) FindByFilters(params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
+func (repo *MetricRepository) FindByFilters(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
 	querySQL := `
 		SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
 			   memory_total_bytes, memory_used_bytes, memory_available_bytes,
-			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes
+			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
 		FROM system_metrics
 		WHERE 1=1`
 
@@ -41,26 +50,37 @@ func (repo *MetricRepository) FindByFilters(params *entities.MetricQueryParams)
 		args = append(args, *params.EndTime)
 	}
 
+	// Resume after the cursor; the ID breaks ties between equal timestamps. Comparing row
+	// values lets the index seek straight to the cursor, where the equivalent OR doesn't.
+	if params.After != nil {
+		comparison := ">"
+		if params.Order == "DESC" {
+			comparison = "<"
+		}
+		querySQL += " AND (timestamp, id) " + comparison + " (?, ?)"
+		args = append(args, params.After.Timestamp, params.After.ID)
+	}
+
 	// Order and limit
-	querySQL += " ORDER BY timestamp " + params.Order
+	querySQL += " ORDER BY timestamp " + params.Order + ", id " + params.Order
 	querySQL += " LIMIT ?"
 	args = append(args, params.Limit)
 
-	rows, err := repo.db.Query(querySQL, args...)
+	rows, err := repo.readDB.QueryContext(ctx, querySQL, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer closeRows(rows)
 
-	return repo.scanMetrics(rows)
+	return scanMetrics(rows)
 }
 
 // FindLatest retrieves the most recent metric for a host
-func (repo *MetricRepository) FindLatest(hostID *int64) (*entities.SystemMetric, error) {
+func (repo *MetricRepository) FindLatest(ctx context.Context, hostID *int64) (*entities.SystemMetric, error) {
 	querySQL := `
         SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
                memory_total_bytes, memory_used_bytes, memory_available_bytes,
-               disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes
+               disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
         FROM system_metrics`
 
 	var args []interface{}
@@ -73,7 +93,7 @@ func (repo *MetricRepository) FindLatest(hostID *int64) (*entities.SystemMetric,
 	querySQL += " ORDER BY timestamp DESC LIMIT 1"
 
 	var metric entities.SystemMetric
-	err := repo.db.QueryRow(querySQL, args...).Scan(
+	err := repo.readDB.QueryRowContext(ctx, querySQL, args...).Scan(
 		&metric.ID,
 		&metric.HostID,
 		&metric.Timestamp,
@@ -86,6 +106,7 @@ func (repo *MetricRepository) FindLatest(hostID *int64) (*entities.SystemMetric,
 		&metric.DiskTotalBytes,
 		&metric.DiskUsedBytes,
 		&metric.DiskAvailableBytes,
+		&metric.ReceivedAt,
 	)
 
 	if err != nil {
@@ -98,16 +119,94 @@ func (repo *MetricRepository) FindLatest(hostID *int64) (*entities.SystemMetric,
 	return &metric, nil
 }
 
-// Create inserts a new metric record
-func (repo *MetricRepository) Create(metric *entities.SystemMetric) (int64, error) {
+// FindAfterID retrieves metrics with an ID greater than afterID in ascending ID order
+func (repo *MetricRepository) FindAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error) {
+	querySQL := `
+		SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
+			   memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		FROM system_metrics
+		WHERE id > ?`
+
+	args := []interface{}{afterID}
+
+	// The unary plus keeps SQLite walking the primary key from afterID, which only reads
+	// the new rows, rather than sorting every row for the host by ID
+	if hostID != nil {
+		querySQL += " AND +host_id = ?"
+		args = append(args, *hostID)
+	}
+
+	querySQL += " ORDER BY id ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := repo.readDB.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanMetrics(rows)
+}
+
+// StreamByFilters calls fn for each metric matching the query parameters, joined
+// with its host, without loading the result set into memory. A Limit of 0 returns
+// every matching metric. Iteration stops at the first error returned by fn.
+func (repo *MetricRepository) StreamByFilters(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error {
+	querySQL := `
+		SELECT m.id, m.host_id, m.timestamp, m.cpu_usage, m.memory_usage_percent,
+			   m.memory_total_bytes, m.memory_used_bytes, m.memory_available_bytes,
+			   m.disk_usage_percent, m.disk_total_bytes, m.disk_used_bytes, m.disk_available_bytes, m.received_at,
+			   h.hostname, h.ip_address, h.role
+		FROM system_metrics m
+		JOIN hosts h ON h.id = m.host_id
+		WHERE 1=1`
+
+	var args []interface{}
+
+	if params.HostID != nil {
+		querySQL += " AND m.host_id = ?"
+		args = append(args, *params.HostID)
+	}
+
+	if params.StartTime != nil {
+		querySQL += " AND m.timestamp >= ?"
+		args = append(args, *params.StartTime)
+	}
+
+	if params.EndTime != nil {
+		querySQL += " AND m.timestamp <= ?"
+		args = append(args, *params.EndTime)
+	}
+
+	querySQL += " ORDER BY m.timestamp " + params.Order + ", m.id " + params.Order
+
+	if params.Limit > 0 {
+		querySQL += " LIMIT ?"
+		args = append(args, params.Limit)
+	}
+
+	rows, err := repo.readDB.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	return streamExportRows(rows, fn)
+}
+
+// Create inserts a new metric record. A metric for a host and timestamp already stored
+// is handled by the duplicate policy.
+func (repo *MetricRepository) Create(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
 	insertSQL := `
 		INSERT INTO system_metrics (
 			host_id, timestamp, cpu_usage, memory_usage_percent,
 			memory_total_bytes, memory_used_bytes, memory_available_bytes,
-			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (host_id, timestamp) DO NOTHING`
 
-	result, err := repo.db.Exec(insertSQL,
+	result, err := repo.db.ExecContext(ctx, insertSQL,
 		metric.HostID,
 		metric.Timestamp,
 		metric.CPUUsage,
@@ -119,17 +218,260 @@ func (repo *MetricRepository) Create(metric *entities.SystemMetric) (int64, erro
 		metric.DiskTotalBytes,
 		metric.DiskUsedBytes,
 		metric.DiskAvailableBytes,
+		metric.ReceivedAt,
 	)
 
 	if err != nil {
-		return -1, err
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	return repo.written(ctx, repo.db, result, metric)
+}
+
+// CreateBatch inserts metrics in a single transaction and returns their outcomes in
+// order. Either every metric is stored or none is.
+func (repo *MetricRepository) CreateBatch(ctx context.Context, metrics []entities.SystemMetric) ([]entities.MetricWrite, error) {
+	insertSQL := `
+		INSERT INTO system_metrics (
+			host_id, timestamp, cpu_usage, memory_usage_percent,
+			memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (host_id, timestamp) DO NOTHING`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	writes := make([]entities.MetricWrite, 0, len(metrics))
+	for _, metric := range metrics {
+		result, err := stmt.ExecContext(ctx,
+			metric.HostID,
+			metric.Timestamp,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		write, err := repo.written(ctx, tx, result, &metric)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, write)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return writes, nil
+}
+
+// written returns the outcome of an insert, which is skipped when the host already has
+// a metric at the timestamp
+func (repo *MetricRepository) written(ctx context.Context, db querier, result sql.Result, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+	if rowsAffected == 0 {
+		return repo.resolveDuplicate(ctx, db, metric)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+	return entities.MetricWrite{ID: id}, nil
+}
+
+// resolveDuplicate applies the duplicate policy to a metric whose insert was skipped
+// because the host already has a metric at the timestamp
+func (repo *MetricRepository) resolveDuplicate(ctx context.Context, db querier, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	if repo.duplicatePolicy == entities.DuplicateReject {
+		return entities.MetricWrite{ID: -1}, ErrDuplicateMetric
+	}
+
+	var write entities.MetricWrite
+	findSQL := `SELECT id FROM system_metrics WHERE host_id = ? AND timestamp = ?`
+	if err := db.QueryRowContext(ctx, findSQL, metric.HostID, metric.Timestamp).Scan(&write.ID); err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	if repo.duplicatePolicy == entities.DuplicateReplace {
+		updateSQL := `
+			UPDATE system_metrics SET
+				cpu_usage = ?, memory_usage_percent = ?,
+				memory_total_bytes = ?, memory_used_bytes = ?, memory_available_bytes = ?,
+				disk_usage_percent = ?, disk_total_bytes = ?, disk_used_bytes = ?, disk_available_bytes = ?,
+				received_at = ?
+			WHERE id = ?`
+		if _, err := db.ExecContext(ctx, updateSQL,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+			write.ID,
+		); err != nil {
+			return entities.MetricWrite{ID: -1}, err
+		}
+		write.Replaced = true
+		return write, nil
+	}
+
+	write.Deduplicated = true
+	return write, nil
+}
+
+// DeleteRange deletes metrics in the inclusive time range whose ID is at most maxID,
+// so rows written after an archive run started are kept
+func (repo *MetricRepository) DeleteRange(ctx context.Context, startTime, endTime, maxID int64) (int64, error) {
+	deleteSQL := `
+		DELETE FROM system_metrics
+		WHERE timestamp >= ? AND timestamp <= ? AND id <= ?`
+
+	result, err := repo.db.ExecContext(ctx, deleteSQL, startTime, endTime, maxID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// InsertMissing inserts metrics in a single transaction, skipping any that already
+// exist for the same host and timestamp and any whose host has been deleted. It
+// returns the number of rows inserted.
+func (repo *MetricRepository) InsertMissing(ctx context.Context, metrics []entities.SystemMetric) (int64, error) {
+	insertSQL := `
+		INSERT INTO system_metrics (
+			host_id, timestamp, cpu_usage, memory_usage_percent,
+			memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM system_metrics WHERE host_id = ? AND timestamp = ?
+		) AND EXISTS (
+			SELECT 1 FROM hosts WHERE id = ?
+		)`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var inserted int64
+	for _, metric := range metrics {
+		result, err := stmt.ExecContext(ctx,
+			metric.HostID,
+			metric.Timestamp,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+			metric.HostID,
+			metric.Timestamp,
+			metric.HostID,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += rowsAffected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// streamExportRows scans metrics joined with their host and passes each to fn
+func streamExportRows(rows *sql.Rows, fn func(row entities.MetricExportRow) error) error {
+	for rows.Next() {
+		var row entities.MetricExportRow
+		if err := rows.Scan(
+			&row.Metric.ID,
+			&row.Metric.HostID,
+			&row.Metric.Timestamp,
+			&row.Metric.CPUUsage,
+			&row.Metric.MemoryUsagePercent,
+			&row.Metric.MemoryTotalBytes,
+			&row.Metric.MemoryUsedBytes,
+			&row.Metric.MemoryAvailableBytes,
+			&row.Metric.DiskUsagePercent,
+			&row.Metric.DiskTotalBytes,
+			&row.Metric.DiskUsedBytes,
+			&row.Metric.DiskAvailableBytes,
+			&row.Metric.ReceivedAt,
+			&row.Host.Hostname,
+			&row.Host.IPAddress,
+			&row.Host.Role,
+		); err != nil {
+			return err
+		}
+		row.Host.ID = row.Metric.HostID
+
+		if err := fn(row); err != nil {
+			return err
+		}
 	}
 
-	return result.LastInsertId()
+	return rows.Err()
 }
 
 // scanMetrics is a helper to scan multiple rows into SystemMetric slice
-func (repo *MetricRepository) scanMetrics(rows *sql.Rows) ([]entities.SystemMetric, error) {
+func scanMetrics(rows *sql.Rows) ([]entities.SystemMetric, error) {
 	var metrics []entities.SystemMetric
 	for rows.Next() {
 		var metric entities.SystemMetric
@@ -146,6 +488,7 @@ func (repo *MetricRepository) scanMetrics(rows *sql.Rows) ([]entities.SystemMetr
 			&metric.DiskTotalBytes,
 			&metric.DiskUsedBytes,
 			&metric.DiskAvailableBytes,
+			&metric.ReceivedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -29,7 +30,7 @@ func (suite *MetricRepositoryTestSuite) SetupTest() {
 	)
 	suite.Require().NoError(err)
 
-	suite.repo = NewMetricRepository(suite.db)
+	suite.repo = NewMetricRepository(suite.db, suite.db, entities.DuplicateIgnore)
 }
 
 // TearDownTest runs after each test
@@ -45,6 +46,7 @@ func (suite *MetricRepositoryTestSuite) TearDownTest() {
 func (suite *MetricRepositoryTestSuite) TestNewMetricRepository() {
 	assert.NotNil(suite.T(), suite.repo)
 	assert.Equal(suite.T(), suite.db, suite.repo.db)
+	assert.Equal(suite.T(), suite.db, suite.repo.readDB)
 }
 
 // TestFindByFilters tests the FindByFilters method
@@ -71,11 +73,11 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(1, 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000)
+					AddRow(1, 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(int64(1), 10).
 					WillReturnRows(rows)
 			},
@@ -109,12 +111,12 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(2, 2, 1200, 30.0, 50.0, 8000000000, 4000000000, 4000000000, 60.0, 250000000000, 150000000000, 100000000000).
-					AddRow(3, 2, 1800, 35.0, 55.0, 8000000000, 4400000000, 3600000000, 65.0, 250000000000, 162500000000, 87500000000)
+					AddRow(2, 2, 1200, 30.0, 50.0, 8000000000, 4000000000, 4000000000, 60.0, 250000000000, 150000000000, 100000000000, 0).
+					AddRow(3, 2, 1800, 35.0, 55.0, 8000000000, 4400000000, 3600000000, 65.0, 250000000000, 162500000000, 87500000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 AND timestamp >= \\? AND timestamp <= \\? ORDER BY timestamp ASC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 AND timestamp >= \\? AND timestamp <= \\? ORDER BY timestamp ASC, id ASC LIMIT \\?").
 					WithArgs(int64(1000), int64(2000), 5).
 					WillReturnRows(rows)
 			},
@@ -163,11 +165,11 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(4, 1, 1500, 40.0, 65.0, 16000000000, 10400000000, 5600000000, 70.0, 500000000000, 350000000000, 150000000000)
+					AddRow(4, 1, 1500, 40.0, 65.0, 16000000000, 10400000000, 5600000000, 70.0, 500000000000, 350000000000, 150000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 AND host_id = \\? AND timestamp >= \\? AND timestamp <= \\? ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 AND host_id = \\? AND timestamp >= \\? AND timestamp <= \\? ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(int64(1), int64(1000), int64(2000), 20).
 					WillReturnRows(rows)
 			},
@@ -199,11 +201,11 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(5, 3, 3000, 50.0, 70.0, 32000000000, 22400000000, 9600000000, 80.0, 1000000000000, 800000000000, 200000000000)
+					AddRow(5, 3, 3000, 50.0, 70.0, 32000000000, 22400000000, 9600000000, 80.0, 1000000000000, 800000000000, 200000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(100).
 					WillReturnRows(rows)
 			},
@@ -236,10 +238,10 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				})
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(int64(1), 10).
 					WillReturnRows(rows)
 			},
@@ -254,7 +256,7 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				Limit:  10,
 			},
 			setupMock: func() {
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 AND host_id = \\? ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(int64(1), 10).
 					WillReturnError(errors.New("connection timeout"))
 			},
@@ -271,11 +273,11 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow("invalid", 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000)
+					AddRow("invalid", 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC LIMIT \\?").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC, id DESC LIMIT \\?").
 					WithArgs(10).
 					WillReturnRows(rows)
 			},
@@ -288,7 +290,7 @@ func (suite *MetricRepositoryTestSuite) TestFindByFilters() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			metrics, err := suite.repo.FindByFilters(test.params)
+			metrics, err := suite.repo.FindByFilters(context.Background(), test.params)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -328,11 +330,11 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(1, 1, 2000, 55.5, 70.0, 16000000000, 11200000000, 4800000000, 85.0, 500000000000, 425000000000, 75000000000)
+					AddRow(1, 1, 2000, 55.5, 70.0, 16000000000, 11200000000, 4800000000, 85.0, 500000000000, 425000000000, 75000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
 					WithArgs(int64(1)).
 					WillReturnRows(rows)
 			},
@@ -359,11 +361,11 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow(2, 3, 3000, 45.0, 65.0, 32000000000, 20800000000, 11200000000, 75.0, 1000000000000, 750000000000, 250000000000)
+					AddRow(2, 3, 3000, 45.0, 65.0, 32000000000, 20800000000, 11200000000, 75.0, 1000000000000, 750000000000, 250000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics ORDER BY timestamp DESC LIMIT 1").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics ORDER BY timestamp DESC LIMIT 1").
 					WillReturnRows(rows)
 			},
 			expectedMetric: &entities.SystemMetric{
@@ -386,7 +388,7 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 			name:   "no_metrics_found",
 			hostID: &hostID,
 			setupMock: func() {
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
 					WithArgs(int64(1)).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -397,7 +399,7 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 			name:   "database_error",
 			hostID: &hostID,
 			setupMock: func() {
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
 					WithArgs(int64(1)).
 					WillReturnError(errors.New("database connection lost"))
 			},
@@ -411,11 +413,11 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 				rows := sqlmock.NewRows([]string{
 					"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 					"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+					"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 				}).
-					AddRow("invalid", 1, 2000, 55.5, 70.0, 16000000000, 11200000000, 4800000000, 85.0, 500000000000, 425000000000, 75000000000)
+					AddRow("invalid", 1, 2000, 55.5, 70.0, 16000000000, 11200000000, 4800000000, 85.0, 500000000000, 425000000000, 75000000000, 0)
 
-				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
+				suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE host_id = \\? ORDER BY timestamp DESC LIMIT 1").
 					WithArgs(int64(1)).
 					WillReturnRows(rows)
 			},
@@ -428,7 +430,7 @@ func (suite *MetricRepositoryTestSuite) TestFindLatest() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			metric, err := suite.repo.FindLatest(test.hostID)
+			metric, err := suite.repo.FindLatest(context.Background(), test.hostID)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -479,8 +481,8 @@ func (suite *MetricRepositoryTestSuite) TestCreate() {
 				DiskAvailableBytes:   125000000000,
 			},
 			setupMock: func() {
-				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\)").
-					WithArgs(int64(1), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000)).
+				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\) ON CONFLICT \\(host_id, timestamp\\) DO NOTHING").
+					WithArgs(int64(1), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000), int64(0)).
 					WillReturnResult(sqlmock.NewResult(10, 1))
 			},
 			expectedID:    10,
@@ -502,8 +504,8 @@ func (suite *MetricRepositoryTestSuite) TestCreate() {
 				DiskAvailableBytes:   125000000000,
 			},
 			setupMock: func() {
-				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\)").
-					WithArgs(int64(999), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000)).
+				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\) ON CONFLICT \\(host_id, timestamp\\) DO NOTHING").
+					WithArgs(int64(999), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000), int64(0)).
 					WillReturnError(errors.New("FOREIGN KEY constraint failed"))
 			},
 			expectedID:    -1,
@@ -525,8 +527,8 @@ func (suite *MetricRepositoryTestSuite) TestCreate() {
 				DiskAvailableBytes:   125000000000,
 			},
 			setupMock: func() {
-				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\)").
-					WithArgs(int64(1), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000)).
+				suite.mock.ExpectExec("INSERT INTO system_metrics \\( host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at \\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\) ON CONFLICT \\(host_id, timestamp\\) DO NOTHING").
+					WithArgs(int64(1), int64(1500), 45.5, 60.0, int64(16000000000), int64(9600000000), int64(6400000000), 75.0, int64(500000000000), int64(375000000000), int64(125000000000), int64(0)).
 					WillReturnError(errors.New("database connection lost"))
 			},
 			expectedID:    -1,
@@ -538,15 +540,15 @@ func (suite *MetricRepositoryTestSuite) TestCreate() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			id, err := suite.repo.Create(test.metric)
+			write, err := suite.repo.Create(context.Background(), test.metric)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
 				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
-				assert.Equal(suite.T(), int64(-1), id)
+				assert.Equal(suite.T(), int64(-1), write.ID)
 			} else {
 				assert.NoError(suite.T(), err)
-				assert.Equal(suite.T(), test.expectedID, id)
+				assert.Equal(suite.T(), entities.MetricWrite{ID: test.expectedID}, write)
 			}
 		})
 
@@ -556,17 +558,331 @@ func (suite *MetricRepositoryTestSuite) TestCreate() {
 	}
 }
 
+// TestCreateDuplicate tests what each duplicate policy does when the insert is skipped
+// because the host already has a metric at the timestamp
+func (suite *MetricRepositoryTestSuite) TestCreateDuplicate() {
+	metric := &entities.SystemMetric{HostID: 1, Timestamp: 1500, CPUUsage: 45.5}
+
+	tests := []struct {
+		name          string
+		policy        string
+		setupMock     func()
+		expectedWrite entities.MetricWrite
+		expectedError error
+	}{
+		{
+			name:   "ignore",
+			policy: entities.DuplicateIgnore,
+			setupMock: func() {
+				suite.mock.ExpectQuery("SELECT id FROM system_metrics WHERE host_id = \\? AND timestamp = \\?").
+					WithArgs(int64(1), int64(1500)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+			},
+			expectedWrite: entities.MetricWrite{ID: 10, Deduplicated: true},
+		},
+		{
+			name:   "replace",
+			policy: entities.DuplicateReplace,
+			setupMock: func() {
+				suite.mock.ExpectQuery("SELECT id FROM system_metrics WHERE host_id = \\? AND timestamp = \\?").
+					WithArgs(int64(1), int64(1500)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+				suite.mock.ExpectExec("UPDATE system_metrics SET .* WHERE id = \\?").
+					WithArgs(45.5, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0), int64(0), int64(10)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedWrite: entities.MetricWrite{ID: 10, Replaced: true},
+		},
+		{
+			name:          "reject",
+			policy:        entities.DuplicateReject,
+			setupMock:     func() {},
+			expectedWrite: entities.MetricWrite{ID: -1},
+			expectedError: ErrDuplicateMetric,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.repo = NewMetricRepository(suite.db, suite.db, test.policy)
+			suite.mock.ExpectExec("INSERT INTO system_metrics .* ON CONFLICT \\(host_id, timestamp\\) DO NOTHING").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			test.setupMock()
+
+			write, err := suite.repo.Create(context.Background(), metric)
+
+			assert.Equal(suite.T(), test.expectedError, err)
+			assert.Equal(suite.T(), test.expectedWrite, write)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestFindByFiltersWithCursor tests keyset pagination on timestamp and ID
+func (suite *MetricRepositoryTestSuite) TestFindByFiltersWithCursor() {
+	tests := []struct {
+		name       string
+		order      string
+		comparison string
+	}{
+		{name: "descending", order: "DESC", comparison: "<"},
+		{name: "ascending", order: "ASC", comparison: ">"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mock.ExpectQuery("FROM system_metrics WHERE 1=1 AND \\(timestamp, id\\) "+test.comparison+" \\(\\?, \\?\\) ORDER BY timestamp "+test.order+", id "+test.order+" LIMIT \\?").
+				WithArgs(int64(200), int64(4), 3).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+			_, err := suite.repo.FindByFilters(context.Background(), &entities.MetricQueryParams{
+				Order: test.order,
+				Limit: 3,
+				After: &entities.MetricCursor{Timestamp: 200, ID: 4},
+			})
+
+			assert.NoError(suite.T(), err)
+			assert.NoError(suite.T(), suite.mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// TestStreamByFilters tests streaming metrics joined with their hosts
+func (suite *MetricRepositoryTestSuite) TestStreamByFilters() {
+	columns := []string{
+		"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
+		"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
+		"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
+		"hostname", "ip_address", "role",
+	}
+	hostID := int64(2)
+
+	// No LIMIT clause when the limit is zero
+	suite.mock.ExpectQuery("FROM system_metrics m JOIN hosts h ON h.id = m.host_id WHERE 1=1 AND m.host_id = \\? ORDER BY m.timestamp ASC, m.id ASC$").
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 2, 1000, 10.0, 20.0, 100, 20, 80, 30.0, 1000, 300, 700, 0, "pi-02", "192.168.1.2", "nas").
+			AddRow(2, 2, 1060, 11.0, 21.0, 100, 21, 79, 30.0, 1000, 300, 700, 0, "pi-02", "192.168.1.2", "nas"))
+
+	var rows []entities.MetricExportRow
+	err := suite.repo.StreamByFilters(context.Background(), &entities.MetricQueryParams{HostID: &hostID, Order: "ASC"}, func(row entities.MetricExportRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), rows, 2)
+	assert.Equal(suite.T(), entities.Host{ID: 2, Hostname: "pi-02", IPAddress: "192.168.1.2", Role: "nas"}, rows[0].Host)
+	assert.Equal(suite.T(), int64(1060), rows[1].Metric.Timestamp)
+
+	// An error from the callback stops iteration
+	suite.mock.ExpectQuery("FROM system_metrics m JOIN hosts h ON h.id = m.host_id WHERE 1=1 ORDER BY m.timestamp DESC, m.id DESC LIMIT \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 2, 1000, 10.0, 20.0, 100, 20, 80, 30.0, 1000, 300, 700, 0, "pi-02", "192.168.1.2", "nas"))
+
+	writeErr := errors.New("client went away")
+	err = suite.repo.StreamByFilters(context.Background(), &entities.MetricQueryParams{Order: "DESC", Limit: 1}, func(row entities.MetricExportRow) error {
+		return writeErr
+	})
+
+	assert.Equal(suite.T(), writeErr, err)
+}
+
+// TestFindAfterID tests the FindAfterID method
+func (suite *MetricRepositoryTestSuite) TestFindAfterID() {
+	hostID := int64(3)
+
+	suite.mock.ExpectQuery("FROM system_metrics WHERE id > \\? AND \\+host_id = \\? ORDER BY id ASC LIMIT \\?").
+		WithArgs(int64(40), int64(3), 500).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
+			"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
+			"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
+		}).
+			AddRow(41, 3, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000, 0))
+
+	metrics, err := suite.repo.FindAfterID(context.Background(), 40, &hostID, 500)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), metrics, 1)
+	assert.Equal(suite.T(), int64(41), metrics[0].ID)
+}
+
+// TestDeleteRange tests the DeleteRange method
+func (suite *MetricRepositoryTestSuite) TestDeleteRange() {
+	suite.mock.ExpectExec("DELETE FROM system_metrics WHERE timestamp >= \\? AND timestamp <= \\? AND id <= \\?").
+		WithArgs(int64(1000), int64(2000), int64(55)).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	deleted, err := suite.repo.DeleteRange(context.Background(), 1000, 2000, 55)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(12), deleted)
+}
+
+// TestCreateBatch tests the CreateBatch method
+func (suite *MetricRepositoryTestSuite) TestCreateBatch() {
+	insertSQL := "INSERT INTO system_metrics .* VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\) ON CONFLICT \\(host_id, timestamp\\) DO NOTHING"
+	metrics := []entities.SystemMetric{
+		{HostID: 1, Timestamp: 1500, CPUUsage: 45.5},
+		{HostID: 2, Timestamp: 1500, CPUUsage: 50.0},
+	}
+
+	tests := []struct {
+		name           string
+		setupMock      func()
+		expectedWrites []entities.MetricWrite
+		expectedError  error
+	}{
+		{
+			name: "commits_all_rows",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().
+					WithArgs(int64(1), int64(1500), 45.5, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0), int64(0)).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+				prepare.ExpectExec().
+					WithArgs(int64(2), int64(1500), 50.0, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0), int64(0)).
+					WillReturnResult(sqlmock.NewResult(8, 1))
+				suite.mock.ExpectCommit()
+			},
+			expectedWrites: []entities.MetricWrite{{ID: 7}, {ID: 8}},
+		},
+		{
+			name: "deduplicates_in_transaction",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(7, 1))
+				prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(7, 0))
+				suite.mock.ExpectQuery("SELECT id FROM system_metrics WHERE host_id = \\? AND timestamp = \\?").
+					WithArgs(int64(2), int64(1500)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				suite.mock.ExpectCommit()
+			},
+			expectedWrites: []entities.MetricWrite{{ID: 7}, {ID: 3, Deduplicated: true}},
+		},
+		{
+			name: "rolls_back_on_error",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(7, 1))
+				prepare.ExpectExec().WillReturnError(errors.New("FOREIGN KEY constraint failed"))
+				suite.mock.ExpectRollback()
+			},
+			expectedError: errors.New("FOREIGN KEY constraint failed"),
+		},
+		{
+			name: "commit_error",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(7, 1))
+				prepare.ExpectExec().WillReturnResult(sqlmock.NewResult(8, 1))
+				suite.mock.ExpectCommit().WillReturnError(errors.New("disk I/O error"))
+			},
+			expectedError: errors.New("disk I/O error"),
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setupMock()
+
+			writes, err := suite.repo.CreateBatch(context.Background(), metrics)
+
+			if test.expectedError != nil {
+				assert.Error(suite.T(), err)
+				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
+			} else {
+				assert.NoError(suite.T(), err)
+			}
+			assert.Equal(suite.T(), test.expectedWrites, writes)
+		})
+	}
+}
+
+// TestInsertMissing tests the InsertMissing method
+func (suite *MetricRepositoryTestSuite) TestInsertMissing() {
+	insertSQL := "INSERT INTO system_metrics .* SELECT .* WHERE NOT EXISTS \\( SELECT 1 FROM system_metrics WHERE host_id = \\? AND timestamp = \\? \\) AND EXISTS \\( SELECT 1 FROM hosts WHERE id = \\? \\)"
+	metrics := []entities.SystemMetric{
+		{HostID: 1, Timestamp: 1500, CPUUsage: 45.5},
+		{HostID: 1, Timestamp: 1560, CPUUsage: 50.0},
+	}
+
+	tests := []struct {
+		name             string
+		setupMock        func()
+		expectedInserted int64
+		expectedError    error
+	}{
+		{
+			name: "skips_existing_rows",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().
+					WithArgs(int64(1), int64(1500), 45.5, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0), int64(0), int64(1), int64(1500), int64(1)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				prepare.ExpectExec().
+					WithArgs(int64(1), int64(1560), 50.0, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0), int64(0), int64(1), int64(1560), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				suite.mock.ExpectCommit()
+			},
+			expectedInserted: 1,
+		},
+		{
+			name: "rolls_back_on_error",
+			setupMock: func() {
+				suite.mock.ExpectBegin()
+				prepare := suite.mock.ExpectPrepare(insertSQL)
+				prepare.ExpectExec().WillReturnError(errors.New("FOREIGN KEY constraint failed"))
+				suite.mock.ExpectRollback()
+			},
+			expectedError: errors.New("FOREIGN KEY constraint failed"),
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setupMock()
+
+			inserted, err := suite.repo.InsertMissing(context.Background(), metrics)
+
+			if test.expectedError != nil {
+				assert.Error(suite.T(), err)
+				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
+			} else {
+				assert.NoError(suite.T(), err)
+			}
+			assert.Equal(suite.T(), test.expectedInserted, inserted)
+		})
+	}
+}
+
 // TestScanMetricsErrorHandling tests error handling in scanMetrics helper
 func (suite *MetricRepositoryTestSuite) TestScanMetricsErrorHandling() {
 	// Test rows.Err() handling
-	suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC LIMIT \\?").
+	suite.mock.ExpectQuery("SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent, memory_total_bytes, memory_used_bytes, memory_available_bytes, disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at FROM system_metrics WHERE 1=1 ORDER BY timestamp DESC, id DESC LIMIT \\?").
 		WithArgs(10).
 		WillReturnRows(sqlmock.NewRows([]string{
 			"id", "host_id", "timestamp", "cpu_usage", "memory_usage_percent",
 			"memory_total_bytes", "memory_used_bytes", "memory_available_bytes",
-			"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes",
+			"disk_usage_percent", "disk_total_bytes", "disk_used_bytes", "disk_available_bytes", "received_at",
 		}).
-			AddRow(1, 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000).
+			AddRow(1, 1, 1500, 45.5, 60.0, 16000000000, 9600000000, 6400000000, 75.0, 500000000000, 375000000000, 125000000000, 0).
 			RowError(0, errors.New("row iteration error")))
 
 	params := &entities.MetricQueryParams{
@@ -574,7 +890,7 @@ func (suite *MetricRepositoryTestSuite) TestScanMetricsErrorHandling() {
 		Limit: 10,
 	}
 
-	metrics, err := suite.repo.FindByFilters(params)
+	metrics, err := suite.repo.FindByFilters(context.Background(), params)
 
 	assert.Error(suite.T(), err)
 	assert.Equal(suite.T(), "row iteration error", err.Error())
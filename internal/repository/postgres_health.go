@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// PostgresHealthRepository reports on a PostgreSQL database
+type PostgresHealthRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresHealthRepository(db *sql.DB) *PostgresHealthRepository {
+	return &PostgresHealthRepository{db: db}
+}
+
+// CheckDatabaseConnection verifies the database is accessible
+func (repo *PostgresHealthRepository) CheckDatabaseConnection(ctx context.Context) error {
+	return repo.db.PingContext(ctx)
+}
+
+// GetDatabaseStats returns connection pool statistics. Reads and writes share one pool.
+func (repo *PostgresHealthRepository) GetDatabaseStats() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"pool": poolStats(repo.db),
+	}, nil
+}
+
+// GetDatabaseSettings returns the server version and connection limits in effect
+func (repo *PostgresHealthRepository) GetDatabaseSettings(ctx context.Context) (map[string]interface{}, error) {
+	var serverVersion, maxConnections string
+
+	for _, setting := range []struct {
+		name string
+		dest *string
+	}{
+		{"server_version", &serverVersion},
+		{"max_connections", &maxConnections},
+	} {
+		if err := repo.db.QueryRowContext(ctx, "SHOW "+setting.name).Scan(setting.dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", setting.name, err)
+		}
+	}
+
+	return map[string]interface{}{
+		"driver":          "postgres",
+		"server_version":  serverVersion,
+		"max_connections": maxConnections,
+		"max_open":        repo.db.Stats().MaxOpenConnections,
+	}, nil
+}
+
+// GetTableCounts returns record counts for monitoring tables. Tables the planner
+// statistics kept by autovacuum put above exactCountLimit rows report that estimate
+// instead.
+func (repo *PostgresHealthRepository) GetTableCounts(ctx context.Context) (map[string]int, error) {
+	estimates, err := repo.rowEstimates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return countTables(ctx, repo.db, estimates)
+}
+
+// rowEstimates reads the planner's row estimates from pg_class, by table. A table that
+// has never been vacuumed or analyzed has no estimate.
+func (repo *PostgresHealthRepository) rowEstimates(ctx context.Context) (map[string]int, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT relname, reltuples FROM pg_class
+		WHERE oid IN ('hosts'::regclass, 'system_metrics'::regclass)`)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	estimates := make(map[string]int)
+	for rows.Next() {
+		var table string
+		var reltuples float64
+		if err := rows.Scan(&table, &reltuples); err != nil {
+			return nil, err
+		}
+		if reltuples >= 0 {
+			estimates[table] = int(reltuples)
+		}
+	}
+	return estimates, rows.Err()
+}
+
+// GetSchemaVersion returns the highest applied migration, or 0 for an unmigrated database
+func (repo *PostgresHealthRepository) GetSchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, repo.db)
+}
+
+// GetStorageStats returns the size of the database. The server's files and volume
+// aren't visible from the API, so only the size is reported.
+func (repo *PostgresHealthRepository) GetStorageStats(ctx context.Context) (*entities.StorageStats, error) {
+	stats := &entities.StorageStats{}
+	if err := repo.db.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&stats.FileBytes); err != nil {
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+	return stats, nil
+}
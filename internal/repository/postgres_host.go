@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// PostgresHostRepository stores hosts in PostgreSQL
+type PostgresHostRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresHostRepository(db *sql.DB) *PostgresHostRepository {
+	return &PostgresHostRepository{db: db}
+}
+
+// FindByFilters retrieves hosts based on query parameters
+func (repo *PostgresHostRepository) FindByFilters(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, error) {
+	querySQL := `
+		SELECT id, hostname, ip_address, role
+		FROM hosts
+		WHERE 1=1`
+
+	var args pgArgs
+
+	if params.ID != 0 {
+		querySQL += " AND id = " + args.add(params.ID)
+	}
+
+	if params.Hostname != "" {
+		querySQL += " AND hostname = " + args.add(params.Hostname)
+	}
+
+	if params.IPAddress != "" {
+		querySQL += " AND ip_address = " + args.add(params.IPAddress)
+	}
+
+	if params.Role != "" {
+		querySQL += " AND role = " + args.add(params.Role)
+	}
+
+	if params.AfterID != 0 {
+		querySQL += " AND id > " + args.add(params.AfterID)
+	}
+
+	querySQL += " ORDER BY id ASC"
+
+	if params.Limit > 0 {
+		querySQL += " LIMIT " + args.add(params.Limit)
+	}
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanHosts(rows)
+}
+
+// Create inserts a new host
+func (repo *PostgresHostRepository) Create(ctx context.Context, host *entities.Host) (int64, error) {
+	timestamp := time.Now().Unix()
+	insertSQL := `
+		INSERT INTO hosts (hostname, ip_address, role, created_at, last_seen)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var id int64
+	err := repo.db.QueryRowContext(ctx, insertSQL,
+		host.Hostname,
+		host.IPAddress,
+		host.Role,
+		timestamp,
+		timestamp,
+	).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Update updates an existing host
+func (repo *PostgresHostRepository) Update(ctx context.Context, id int64, host *entities.Host) error {
+	timestamp := time.Now().Unix()
+	updateSQL := `
+		UPDATE hosts
+		SET role = $1, last_seen = $2
+		WHERE id = $3`
+
+	_, err := repo.db.ExecContext(ctx, updateSQL, host.Role, timestamp, id)
+	return err
+}
+
+// Delete removes a host and, through the foreign keys, its metrics and scrape targets
+func (repo *PostgresHostRepository) Delete(ctx context.Context, id int64) error {
+	deleteSQL := `DELETE FROM hosts WHERE id = $1`
+	result, err := repo.db.ExecContext(ctx, deleteSQL, id)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
@@ -0,0 +1,427 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// lockMetricWritesSQL takes a transaction-scoped lock held by every transaction that
+// inserts metrics. IDs are taken from the sequence when a row is inserted rather than
+// when it commits, so two transactions could otherwise commit their IDs out of order,
+// and a reader resuming after the higher one, as the live stream does, would never see
+// the lower. Serialised this way, IDs become visible in ID order, as they do on SQLite
+// with its single write connection.
+const lockMetricWritesSQL = `SELECT pg_advisory_xact_lock('system_metrics'::regclass::oid::bigint)`
+
+// PostgresMetricRepository stores metrics in PostgreSQL
+type PostgresMetricRepository struct {
+	db              *sql.DB
+	duplicatePolicy string
+}
+
+func NewPostgresMetricRepository(db *sql.DB, duplicatePolicy string) *PostgresMetricRepository {
+	return &PostgresMetricRepository{db: db, duplicatePolicy: duplicatePolicy}
+}
+
+// FindByFilters retrieves metrics based on query parameters
+func (repo *PostgresMetricRepository) FindByFilters(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
+	querySQL := `
+		SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
+			   memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		FROM system_metrics
+		WHERE 1=1`
+
+	var args pgArgs
+
+	if params.HostID != nil {
+		querySQL += " AND host_id = " + args.add(*params.HostID)
+	}
+
+	if params.StartTime != nil {
+		querySQL += " AND timestamp >= " + args.add(*params.StartTime)
+	}
+
+	if params.EndTime != nil {
+		querySQL += " AND timestamp <= " + args.add(*params.EndTime)
+	}
+
+	// Resume after the cursor; the ID breaks ties between equal timestamps
+	if params.After != nil {
+		comparison := ">"
+		if params.Order == "DESC" {
+			comparison = "<"
+		}
+		querySQL += " AND (timestamp, id) " + comparison + " (" + args.add(params.After.Timestamp) + ", " + args.add(params.After.ID) + ")"
+	}
+
+	// Order and limit
+	querySQL += " ORDER BY timestamp " + params.Order + ", id " + params.Order
+	querySQL += " LIMIT " + args.add(params.Limit)
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanMetrics(rows)
+}
+
+// FindLatest retrieves the most recent metric for a host
+func (repo *PostgresMetricRepository) FindLatest(ctx context.Context, hostID *int64) (*entities.SystemMetric, error) {
+	querySQL := `
+		SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
+			   memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		FROM system_metrics`
+
+	var args pgArgs
+
+	if hostID != nil {
+		querySQL += " WHERE host_id = " + args.add(*hostID)
+	}
+
+	querySQL += " ORDER BY timestamp DESC LIMIT 1"
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	metrics, err := scanMetrics(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return nil, nil // Not found
+	}
+
+	return &metrics[0], nil
+}
+
+// FindAfterID retrieves metrics with an ID greater than afterID in ascending ID order
+func (repo *PostgresMetricRepository) FindAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error) {
+	var args pgArgs
+
+	querySQL := `
+		SELECT id, host_id, timestamp, cpu_usage, memory_usage_percent,
+			   memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			   disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		FROM system_metrics
+		WHERE id > ` + args.add(afterID)
+
+	if hostID != nil {
+		querySQL += " AND host_id = " + args.add(*hostID)
+	}
+
+	querySQL += " ORDER BY id ASC LIMIT " + args.add(limit)
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanMetrics(rows)
+}
+
+// StreamByFilters calls fn for each metric matching the query parameters, joined
+// with its host, without loading the result set into memory. A Limit of 0 returns
+// every matching metric. Iteration stops at the first error returned by fn.
+func (repo *PostgresMetricRepository) StreamByFilters(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error {
+	querySQL := `
+		SELECT m.id, m.host_id, m.timestamp, m.cpu_usage, m.memory_usage_percent,
+			   m.memory_total_bytes, m.memory_used_bytes, m.memory_available_bytes,
+			   m.disk_usage_percent, m.disk_total_bytes, m.disk_used_bytes, m.disk_available_bytes, m.received_at,
+			   h.hostname, h.ip_address, h.role
+		FROM system_metrics m
+		JOIN hosts h ON h.id = m.host_id
+		WHERE 1=1`
+
+	var args pgArgs
+
+	if params.HostID != nil {
+		querySQL += " AND m.host_id = " + args.add(*params.HostID)
+	}
+
+	if params.StartTime != nil {
+		querySQL += " AND m.timestamp >= " + args.add(*params.StartTime)
+	}
+
+	if params.EndTime != nil {
+		querySQL += " AND m.timestamp <= " + args.add(*params.EndTime)
+	}
+
+	querySQL += " ORDER BY m.timestamp " + params.Order + ", m.id " + params.Order
+
+	if params.Limit > 0 {
+		querySQL += " LIMIT " + args.add(params.Limit)
+	}
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	return streamExportRows(rows, fn)
+}
+
+// Create inserts a new metric record. A metric for a host and timestamp already stored
+// is handled by the duplicate policy.
+func (repo *PostgresMetricRepository) Create(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	insertSQL := `
+		INSERT INTO system_metrics (
+			host_id, timestamp, cpu_usage, memory_usage_percent,
+			memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (host_id, timestamp) DO NOTHING
+		RETURNING id`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, lockMetricWritesSQL); err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, insertSQL,
+		metric.HostID,
+		metric.Timestamp,
+		metric.CPUUsage,
+		metric.MemoryUsagePercent,
+		metric.MemoryTotalBytes,
+		metric.MemoryUsedBytes,
+		metric.MemoryAvailableBytes,
+		metric.DiskUsagePercent,
+		metric.DiskTotalBytes,
+		metric.DiskUsedBytes,
+		metric.DiskAvailableBytes,
+		metric.ReceivedAt,
+	).Scan(&id)
+
+	write := entities.MetricWrite{ID: id}
+	if errors.Is(err, sql.ErrNoRows) {
+		write, err = repo.resolveDuplicate(ctx, tx, metric)
+	}
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	return write, nil
+}
+
+// CreateBatch inserts metrics in a single transaction and returns their outcomes in
+// order. Either every metric is stored or none is.
+func (repo *PostgresMetricRepository) CreateBatch(ctx context.Context, metrics []entities.SystemMetric) ([]entities.MetricWrite, error) {
+	insertSQL := `
+		INSERT INTO system_metrics (
+			host_id, timestamp, cpu_usage, memory_usage_percent,
+			memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (host_id, timestamp) DO NOTHING
+		RETURNING id`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, lockMetricWritesSQL); err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	writes := make([]entities.MetricWrite, 0, len(metrics))
+	for _, metric := range metrics {
+		var id int64
+		err := stmt.QueryRowContext(ctx,
+			metric.HostID,
+			metric.Timestamp,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+		).Scan(&id)
+		write := entities.MetricWrite{ID: id}
+		if errors.Is(err, sql.ErrNoRows) {
+			write, err = repo.resolveDuplicate(ctx, tx, &metric)
+		}
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, write)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return writes, nil
+}
+
+// resolveDuplicate applies the duplicate policy to a metric whose insert was skipped
+// because the host already has a metric at the timestamp
+func (repo *PostgresMetricRepository) resolveDuplicate(ctx context.Context, db querier, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	if repo.duplicatePolicy == entities.DuplicateReject {
+		return entities.MetricWrite{ID: -1}, ErrDuplicateMetric
+	}
+
+	var write entities.MetricWrite
+	findSQL := `SELECT id FROM system_metrics WHERE host_id = $1 AND timestamp = $2`
+	if err := db.QueryRowContext(ctx, findSQL, metric.HostID, metric.Timestamp).Scan(&write.ID); err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+
+	if repo.duplicatePolicy == entities.DuplicateReplace {
+		updateSQL := `
+			UPDATE system_metrics SET
+				cpu_usage = $1, memory_usage_percent = $2,
+				memory_total_bytes = $3, memory_used_bytes = $4, memory_available_bytes = $5,
+				disk_usage_percent = $6, disk_total_bytes = $7, disk_used_bytes = $8, disk_available_bytes = $9,
+				received_at = $10
+			WHERE id = $11`
+		if _, err := db.ExecContext(ctx, updateSQL,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+			write.ID,
+		); err != nil {
+			return entities.MetricWrite{ID: -1}, err
+		}
+		write.Replaced = true
+		return write, nil
+	}
+
+	write.Deduplicated = true
+	return write, nil
+}
+
+// DeleteRange deletes metrics in the inclusive time range whose ID is at most maxID,
+// so rows written after an archive run started are kept
+func (repo *PostgresMetricRepository) DeleteRange(ctx context.Context, startTime, endTime, maxID int64) (int64, error) {
+	deleteSQL := `
+		DELETE FROM system_metrics
+		WHERE timestamp >= $1 AND timestamp <= $2 AND id <= $3`
+
+	result, err := repo.db.ExecContext(ctx, deleteSQL, startTime, endTime, maxID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// InsertMissing inserts metrics in a single transaction, skipping any that already
+// exist for the same host and timestamp and any whose host has been deleted. It
+// returns the number of rows inserted.
+func (repo *PostgresMetricRepository) InsertMissing(ctx context.Context, metrics []entities.SystemMetric) (int64, error) {
+	// Parameters in a SELECT list have no column to take their type from, so cast them
+	insertSQL := `
+		INSERT INTO system_metrics (
+			host_id, timestamp, cpu_usage, memory_usage_percent,
+			memory_total_bytes, memory_used_bytes, memory_available_bytes,
+			disk_usage_percent, disk_total_bytes, disk_used_bytes, disk_available_bytes, received_at
+		)
+		SELECT $1::BIGINT, $2::BIGINT, $3::DOUBLE PRECISION, $4::DOUBLE PRECISION,
+			   $5::BIGINT, $6::BIGINT, $7::BIGINT,
+			   $8::DOUBLE PRECISION, $9::BIGINT, $10::BIGINT, $11::BIGINT, $12::BIGINT
+		WHERE NOT EXISTS (
+			SELECT 1 FROM system_metrics WHERE host_id = $1 AND timestamp = $2
+		) AND EXISTS (
+			SELECT 1 FROM hosts WHERE id = $1
+		)`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, lockMetricWritesSQL); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var inserted int64
+	for _, metric := range metrics {
+		result, err := stmt.ExecContext(ctx,
+			metric.HostID,
+			metric.Timestamp,
+			metric.CPUUsage,
+			metric.MemoryUsagePercent,
+			metric.MemoryTotalBytes,
+			metric.MemoryUsedBytes,
+			metric.MemoryAvailableBytes,
+			metric.DiskUsagePercent,
+			metric.DiskTotalBytes,
+			metric.DiskUsedBytes,
+			metric.DiskAvailableBytes,
+			metric.ReceivedAt,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += rowsAffected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
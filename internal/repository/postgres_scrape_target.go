@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// PostgresScrapeTargetRepository stores scrape targets in PostgreSQL
+type PostgresScrapeTargetRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresScrapeTargetRepository(db *sql.DB) *PostgresScrapeTargetRepository {
+	return &PostgresScrapeTargetRepository{db: db}
+}
+
+// FindByFilters retrieves scrape targets based on query parameters
+func (repo *PostgresScrapeTargetRepository) FindByFilters(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error) {
+	querySQL := `
+		SELECT id, name, url, host_id, format, interval_seconds, timeout_seconds,
+			   json_mapping, paused, last_scrape_at, last_status, last_error,
+			   last_duration_ms, consecutive_failures
+		FROM scrape_targets
+		WHERE 1=1`
+
+	var args pgArgs
+
+	if params.ID != 0 {
+		querySQL += " AND id = " + args.add(params.ID)
+	}
+
+	if params.HostID != 0 {
+		querySQL += " AND host_id = " + args.add(params.HostID)
+	}
+
+	if params.ActiveOnly {
+		querySQL += " AND NOT paused"
+	}
+
+	querySQL += " ORDER BY id ASC"
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanTargets(rows)
+}
+
+// Create inserts a new scrape target
+func (repo *PostgresScrapeTargetRepository) Create(ctx context.Context, target *entities.ScrapeTarget) (int64, error) {
+	mapping, err := marshalMapping(target.JSONMapping)
+	if err != nil {
+		return -1, err
+	}
+
+	insertSQL := `
+		INSERT INTO scrape_targets (
+			name, url, host_id, format, interval_seconds, timeout_seconds, json_mapping, paused
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	var id int64
+	err = repo.db.QueryRowContext(ctx, insertSQL,
+		target.Name,
+		target.URL,
+		target.HostID,
+		target.Format,
+		target.IntervalSeconds,
+		target.TimeoutSeconds,
+		mapping,
+		target.Paused,
+	).Scan(&id)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Update replaces the configuration of an existing scrape target
+func (repo *PostgresScrapeTargetRepository) Update(ctx context.Context, id int64, target *entities.ScrapeTarget) error {
+	mapping, err := marshalMapping(target.JSONMapping)
+	if err != nil {
+		return err
+	}
+
+	updateSQL := `
+		UPDATE scrape_targets
+		SET name = $1, url = $2, host_id = $3, format = $4, interval_seconds = $5,
+			timeout_seconds = $6, json_mapping = $7, paused = $8
+		WHERE id = $9`
+
+	result, err := repo.db.ExecContext(ctx, updateSQL,
+		target.Name,
+		target.URL,
+		target.HostID,
+		target.Format,
+		target.IntervalSeconds,
+		target.TimeoutSeconds,
+		mapping,
+		target.Paused,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// Delete removes a scrape target
+func (repo *PostgresScrapeTargetRepository) Delete(ctx context.Context, id int64) error {
+	deleteSQL := `DELETE FROM scrape_targets WHERE id = $1`
+	result, err := repo.db.ExecContext(ctx, deleteSQL, id)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// RecordResult stores the outcome of the latest scrape of a target
+func (repo *PostgresScrapeTargetRepository) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error {
+	updateSQL := `
+		UPDATE scrape_targets
+		SET last_scrape_at = $1, last_status = $2, last_error = $3, last_duration_ms = $4,
+			consecutive_failures = CASE WHEN $2 = 'error' THEN consecutive_failures + 1 ELSE 0 END
+		WHERE id = $5`
+
+	_, err := repo.db.ExecContext(ctx, updateSQL,
+		result.Timestamp,
+		result.Status,
+		result.Error,
+		result.DurationMs,
+		id,
+	)
+	return err
+}
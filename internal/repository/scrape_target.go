@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+type ScrapeTargetRepository struct {
+	db *sql.DB
+}
+
+func NewScrapeTargetRepository(db *sql.DB) *ScrapeTargetRepository {
+	return &ScrapeTargetRepository{db: db}
+}
+
+// FindByFilters retrieves scrape targets based on query parameters
+func (repo *ScrapeTargetRepository) FindByFilters(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error) {
+	querySQL := `
+		SELECT id, name, url, host_id, format, interval_seconds, timeout_seconds,
+			   json_mapping, paused, last_scrape_at, last_status, last_error,
+			   last_duration_ms, consecutive_failures
+		FROM scrape_targets
+		WHERE 1=1`
+
+	var args []interface{}
+
+	if params.ID != 0 {
+		querySQL += " AND id = ?"
+		args = append(args, params.ID)
+	}
+
+	if params.HostID != 0 {
+		querySQL += " AND host_id = ?"
+		args = append(args, params.HostID)
+	}
+
+	if params.ActiveOnly {
+		querySQL += " AND paused = 0"
+	}
+
+	querySQL += " ORDER BY id ASC"
+
+	rows, err := repo.db.QueryContext(ctx, querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanTargets(rows)
+}
+
+// Create inserts a new scrape target
+func (repo *ScrapeTargetRepository) Create(ctx context.Context, target *entities.ScrapeTarget) (int64, error) {
+	mapping, err := marshalMapping(target.JSONMapping)
+	if err != nil {
+		return -1, err
+	}
+
+	insertSQL := `
+		INSERT INTO scrape_targets (
+			name, url, host_id, format, interval_seconds, timeout_seconds, json_mapping, paused
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := repo.db.ExecContext(ctx, insertSQL,
+		target.Name,
+		target.URL,
+		target.HostID,
+		target.Format,
+		target.IntervalSeconds,
+		target.TimeoutSeconds,
+		mapping,
+		target.Paused,
+	)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return result.LastInsertId()
+}
+
+// Update replaces the configuration of an existing scrape target
+func (repo *ScrapeTargetRepository) Update(ctx context.Context, id int64, target *entities.ScrapeTarget) error {
+	mapping, err := marshalMapping(target.JSONMapping)
+	if err != nil {
+		return err
+	}
+
+	updateSQL := `
+		UPDATE scrape_targets
+		SET name = ?, url = ?, host_id = ?, format = ?, interval_seconds = ?,
+			timeout_seconds = ?, json_mapping = ?, paused = ?
+		WHERE id = ?`
+
+	result, err := repo.db.ExecContext(ctx, updateSQL,
+		target.Name,
+		target.URL,
+		target.HostID,
+		target.Format,
+		target.IntervalSeconds,
+		target.TimeoutSeconds,
+		mapping,
+		target.Paused,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// Delete removes a scrape target
+func (repo *ScrapeTargetRepository) Delete(ctx context.Context, id int64) error {
+	deleteSQL := `DELETE FROM scrape_targets WHERE id = ?`
+	result, err := repo.db.ExecContext(ctx, deleteSQL, id)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// RecordResult stores the outcome of the latest scrape of a target
+func (repo *ScrapeTargetRepository) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error {
+	updateSQL := `
+		UPDATE scrape_targets
+		SET last_scrape_at = ?, last_status = ?, last_error = ?, last_duration_ms = ?,
+			consecutive_failures = CASE WHEN ? = 'error' THEN consecutive_failures + 1 ELSE 0 END
+		WHERE id = ?`
+
+	_, err := repo.db.ExecContext(ctx, updateSQL,
+		result.Timestamp,
+		result.Status,
+		result.Error,
+		result.DurationMs,
+		result.Status,
+		id,
+	)
+	return err
+}
+
+// scanTargets is a helper to scan multiple rows into ScrapeTarget slice
+func scanTargets(rows *sql.Rows) ([]entities.ScrapeTarget, error) {
+	var targets []entities.ScrapeTarget
+	for rows.Next() {
+		var target entities.ScrapeTarget
+		var mapping string
+		if err := rows.Scan(
+			&target.ID,
+			&target.Name,
+			&target.URL,
+			&target.HostID,
+			&target.Format,
+			&target.IntervalSeconds,
+			&target.TimeoutSeconds,
+			&mapping,
+			&target.Paused,
+			&target.LastScrapeAt,
+			&target.LastStatus,
+			&target.LastError,
+			&target.LastDurationMs,
+			&target.ConsecutiveFailures,
+		); err != nil {
+			return nil, err
+		}
+
+		if mapping != "" {
+			if err := json.Unmarshal([]byte(mapping), &target.JSONMapping); err != nil {
+				return nil, err
+			}
+		}
+
+		targets = append(targets, target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// marshalMapping encodes a JSON mapping for storage, using an empty object for nil
+func marshalMapping(mapping map[string]string) (string, error) {
+	if mapping == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(mapping)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,195 @@
+// nolint
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// ScrapeTargetRepositoryTestSuite is the test suite for ScrapeTargetRepository
+type ScrapeTargetRepositoryTestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+	repo *ScrapeTargetRepository
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ScrapeTargetRepositoryTestSuite) SetupTest() {
+	var err error
+	suite.db, suite.mock, err = sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp),
+	)
+	suite.Require().NoError(err)
+
+	suite.repo = NewScrapeTargetRepository(suite.db)
+}
+
+// TearDownTest runs after each test
+func (suite *ScrapeTargetRepositoryTestSuite) TearDownTest() {
+	suite.db.Close()
+
+	// Ensure all expectations were met
+	err := suite.mock.ExpectationsWereMet()
+	suite.NoError(err)
+}
+
+// scrapeTargetColumns lists the columns returned by FindByFilters
+var scrapeTargetColumns = []string{
+	"id", "name", "url", "host_id", "format", "interval_seconds", "timeout_seconds",
+	"json_mapping", "paused", "last_scrape_at", "last_status", "last_error",
+	"last_duration_ms", "consecutive_failures",
+}
+
+// TestFindByFilters tests the FindByFilters method
+func (suite *ScrapeTargetRepositoryTestSuite) TestFindByFilters() {
+	tests := []struct {
+		name            string
+		params          *entities.ScrapeTargetQueryParams
+		setupMock       func()
+		expectedTargets []entities.ScrapeTarget
+		expectedError   error
+	}{
+		{
+			name:   "active_targets_for_host",
+			params: &entities.ScrapeTargetQueryParams{HostID: 2, ActiveOnly: true},
+			setupMock: func() {
+				rows := sqlmock.NewRows(scrapeTargetColumns).
+					AddRow(1, "nas", "http://nas:9100/metrics", 2, "prometheus", 30, 10, "{}", false, 1500, "ok", "", 12, 0).
+					AddRow(2, "ups", "http://ups/status", 2, "json", 60, 5, `{"cpu_usage":"cpu.load"}`, false, 0, "", "", 0, 0)
+
+				suite.mock.ExpectQuery("FROM scrape_targets WHERE 1=1 AND host_id = \\? AND paused = 0 ORDER BY id ASC").
+					WithArgs(int64(2)).
+					WillReturnRows(rows)
+			},
+			expectedTargets: []entities.ScrapeTarget{
+				{
+					ID: 1, Name: "nas", URL: "http://nas:9100/metrics", HostID: 2, Format: "prometheus",
+					IntervalSeconds: 30, TimeoutSeconds: 10, JSONMapping: map[string]string{}, LastScrapeAt: 1500, LastStatus: "ok", LastDurationMs: 12,
+				},
+				{
+					ID: 2, Name: "ups", URL: "http://ups/status", HostID: 2, Format: "json",
+					IntervalSeconds: 60, TimeoutSeconds: 5, JSONMapping: map[string]string{"cpu_usage": "cpu.load"},
+				},
+			},
+		},
+		{
+			name:   "database_error",
+			params: &entities.ScrapeTargetQueryParams{},
+			setupMock: func() {
+				suite.mock.ExpectQuery("FROM scrape_targets WHERE 1=1 ORDER BY id ASC").
+					WillReturnError(errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			targets, err := suite.repo.FindByFilters(context.Background(), test.params)
+
+			if test.expectedError != nil {
+				assert.EqualError(suite.T(), err, test.expectedError.Error())
+				assert.Nil(suite.T(), targets)
+			} else {
+				assert.NoError(suite.T(), err)
+				assert.Equal(suite.T(), test.expectedTargets, targets)
+			}
+		})
+	}
+}
+
+// TestCreate tests the Create method
+func (suite *ScrapeTargetRepositoryTestSuite) TestCreate() {
+	target := &entities.ScrapeTarget{
+		Name:            "ups",
+		URL:             "http://ups/status",
+		HostID:          1,
+		Format:          "json",
+		IntervalSeconds: 60,
+		TimeoutSeconds:  5,
+		JSONMapping:     map[string]string{"cpu_usage": "cpu.load"},
+	}
+
+	suite.mock.ExpectExec("INSERT INTO scrape_targets").
+		WithArgs("ups", "http://ups/status", int64(1), "json", 60, 5, `{"cpu_usage":"cpu.load"}`, false).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	id, err := suite.repo.Create(context.Background(), target)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(7), id)
+}
+
+// TestUpdate tests the Update method
+func (suite *ScrapeTargetRepositoryTestSuite) TestUpdate() {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		expectedError error
+	}{
+		{name: "successful_update", rowsAffected: 1},
+		{name: "target_not_found", rowsAffected: 0, expectedError: sql.ErrNoRows},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mock.ExpectExec("UPDATE scrape_targets SET name = \\?").
+				WithArgs("nas", "http://nas:9100/metrics", int64(1), "prometheus", 30, 10, "{}", true, int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, test.rowsAffected))
+
+			err := suite.repo.Update(context.Background(), 3, &entities.ScrapeTarget{
+				Name:            "nas",
+				URL:             "http://nas:9100/metrics",
+				HostID:          1,
+				Format:          "prometheus",
+				IntervalSeconds: 30,
+				TimeoutSeconds:  10,
+				Paused:          true,
+			})
+
+			assert.Equal(suite.T(), test.expectedError, err)
+		})
+	}
+}
+
+// TestDelete tests the Delete method
+func (suite *ScrapeTargetRepositoryTestSuite) TestDelete() {
+	suite.mock.ExpectExec("DELETE FROM scrape_targets WHERE id = \\?").
+		WithArgs(int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.repo.Delete(context.Background(), 4)
+
+	assert.ErrorIs(suite.T(), err, sql.ErrNoRows)
+}
+
+// TestRecordResult tests the RecordResult method
+func (suite *ScrapeTargetRepositoryTestSuite) TestRecordResult() {
+	suite.mock.ExpectExec("UPDATE scrape_targets SET last_scrape_at = \\?").
+		WithArgs(int64(1700), "error", "timeout", int64(10000), "error", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := suite.repo.RecordResult(context.Background(), 2, &entities.ScrapeResult{
+		Timestamp:  1700,
+		Status:     "error",
+		Error:      "timeout",
+		DurationMs: 10000,
+	})
+
+	assert.NoError(suite.T(), err)
+}
+
+// Run the test suite
+func TestScrapeTargetRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(ScrapeTargetRepositoryTestSuite))
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package repository
+
+import "github.com/gabrielg2020/monitor-api/internal/entities"
+
+// volumeStats isn't available on this platform, so free space goes unreported
+func volumeStats(dir string) (*entities.VolumeStats, error) {
+	return nil, nil
+}
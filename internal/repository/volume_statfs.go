@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+
+package repository
+
+import (
+	"syscall"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// volumeStats reports the free and total space on the filesystem holding dir
+func volumeStats(dir string) (*entities.VolumeStats, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return nil, err
+	}
+
+	blockSize := uint64(fs.Bsize)
+	return &entities.VolumeStats{
+		Path:       dir,
+		FreeBytes:  uint64(fs.Bavail) * blockSize,
+		TotalBytes: uint64(fs.Blocks) * blockSize,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// jsonMetric converts a JSON document into a SystemMetric using a field -> path mapping.
+// Paths are dot separated object keys or array indexes, e.g. "memory.total" or "disks.0.used".
+func jsonMetric(body []byte, mapping map[string]string) (*entities.SystemMetric, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	metric := &entities.SystemMetric{}
+	for field, path := range mapping {
+		raw, err := lookupPath(document, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+
+		value, err := toFloat(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+
+		if err := setMetricField(metric, field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Derive percentages the target did not report directly
+	if _, ok := mapping["memory_usage_percent"]; !ok {
+		metric.MemoryUsagePercent = percentOf(metric.MemoryUsedBytes, metric.MemoryTotalBytes)
+	}
+	if _, ok := mapping["disk_usage_percent"]; !ok {
+		metric.DiskUsagePercent = percentOf(metric.DiskUsedBytes, metric.DiskTotalBytes)
+	}
+
+	return metric, nil
+}
+
+// lookupPath walks a decoded JSON document following a dot separated path
+func lookupPath(document interface{}, path string) (interface{}, error) {
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("path %q: key %q not found", path, segment)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("path %q: invalid array index %q", path, segment)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %q: cannot descend into %q", path, segment)
+		}
+	}
+	return current, nil
+}
+
+// toFloat converts a decoded JSON number or numeric string to float64
+func toFloat(value interface{}) (float64, error) {
+	switch typed := value.(type) {
+	case json.Number:
+		return typed.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(typed), 64)
+	default:
+		return 0, fmt.Errorf("value %v is not numeric", value)
+	}
+}
+
+// setMetricField assigns a mapped value to the matching SystemMetric field
+func setMetricField(metric *entities.SystemMetric, field string, value float64) error {
+	switch field {
+	case "cpu_usage":
+		metric.CPUUsage = value
+	case "memory_usage_percent":
+		metric.MemoryUsagePercent = value
+	case "memory_total_bytes":
+		metric.MemoryTotalBytes = int64(value)
+	case "memory_used_bytes":
+		metric.MemoryUsedBytes = int64(value)
+	case "memory_available_bytes":
+		metric.MemoryAvailableBytes = int64(value)
+	case "disk_usage_percent":
+		metric.DiskUsagePercent = value
+	case "disk_total_bytes":
+		metric.DiskTotalBytes = int64(value)
+	case "disk_used_bytes":
+		metric.DiskUsedBytes = int64(value)
+	case "disk_available_bytes":
+		metric.DiskAvailableBytes = int64(value)
+	default:
+		return fmt.Errorf("unknown metric field %q", field)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// nolint
+package scraper
+
+import (
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// JSONTestSuite is the test suite for the JSON path converter
+type JSONTestSuite struct {
+	suite.Suite
+}
+
+// TestJSONMetric tests converting JSON documents into a SystemMetric
+func (suite *JSONTestSuite) TestJSONMetric() {
+	body := []byte(`{
+		"cpu": {"percent": 12.5},
+		"memory": {"total": 1000, "used": "250"},
+		"disks": [{"total": 400, "used": 100}]
+	}`)
+
+	tests := []struct {
+		name           string
+		mapping        map[string]string
+		expectedMetric *entities.SystemMetric
+		expectError    bool
+	}{
+		{
+			name: "percentages_derived_from_bytes",
+			mapping: map[string]string{
+				"cpu_usage":          "cpu.percent",
+				"memory_total_bytes": "memory.total",
+				"memory_used_bytes":  "memory.used",
+				"disk_total_bytes":   "disks.0.total",
+				"disk_used_bytes":    "disks.0.used",
+			},
+			expectedMetric: &entities.SystemMetric{
+				CPUUsage:           12.5,
+				MemoryTotalBytes:   1000,
+				MemoryUsedBytes:    250,
+				MemoryUsagePercent: 25,
+				DiskTotalBytes:     400,
+				DiskUsedBytes:      100,
+				DiskUsagePercent:   25,
+			},
+		},
+		{
+			name:        "missing_key",
+			mapping:     map[string]string{"cpu_usage": "cpu.load"},
+			expectError: true,
+		},
+		{
+			name:        "array_index_out_of_range",
+			mapping:     map[string]string{"disk_used_bytes": "disks.3.used"},
+			expectError: true,
+		},
+		{
+			name:        "non_numeric_value",
+			mapping:     map[string]string{"cpu_usage": "cpu"},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			metric, err := jsonMetric(body, test.mapping)
+
+			if test.expectError {
+				assert.Error(suite.T(), err)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), test.expectedMetric, metric)
+		})
+	}
+}
+
+// TestJSONMetricInvalidDocument tests that malformed responses are rejected
+func (suite *JSONTestSuite) TestJSONMetricInvalidDocument() {
+	_, err := jsonMetric([]byte("not json"), map[string]string{"cpu_usage": "cpu"})
+	assert.Error(suite.T(), err)
+}
+
+// Run the test suite
+func TestJSONTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONTestSuite))
+}
@@ -0,0 +1,220 @@
+package scraper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// errNeedsBaseline is returned when CPU usage cannot be derived until a second scrape
+var errNeedsBaseline = errors.New("waiting for a second sample to derive CPU usage")
+
+// sample is a single series value from the Prometheus text exposition format
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// cpuTimes holds cumulative node_cpu_seconds_total values summed across CPUs
+type cpuTimes struct {
+	idle  float64
+	total float64
+}
+
+// parsePrometheusText parses the Prometheus text exposition format
+func parsePrometheusText(reader io.Reader) ([]sample, error) {
+	var samples []sample
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parsed, err := parseSampleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		samples = append(samples, parsed)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// parseSampleLine parses `name{label="value",...} value [timestamp]`
+func parseSampleLine(line string) (sample, error) {
+	result := sample{labels: map[string]string{}}
+
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return result, errors.New("missing sample value")
+	}
+	result.name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if rest[0] == '{' {
+		closing, err := parseLabels(rest[1:], result.labels)
+		if err != nil {
+			return result, err
+		}
+		rest = rest[1+closing+1:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return result, errors.New("missing sample value")
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return result, fmt.Errorf("invalid sample value %q", fields[0])
+	}
+	result.value = value
+
+	return result, nil
+}
+
+// parseLabels reads a label set up to the closing brace, returning the brace's index
+func parseLabels(input string, labels map[string]string) (int, error) {
+	i := 0
+	for {
+		for i < len(input) && (input[i] == ' ' || input[i] == ',') {
+			i++
+		}
+		if i >= len(input) {
+			return 0, errors.New("unterminated label set")
+		}
+		if input[i] == '}' {
+			return i, nil
+		}
+
+		equals := strings.IndexByte(input[i:], '=')
+		if equals <= 0 {
+			return 0, errors.New("malformed label")
+		}
+		key := strings.TrimSpace(input[i : i+equals])
+		i += equals + 1
+
+		if i >= len(input) || input[i] != '"' {
+			return 0, fmt.Errorf("label %q value must be quoted", key)
+		}
+		i++
+
+		var value strings.Builder
+		for {
+			if i >= len(input) {
+				return 0, fmt.Errorf("unterminated value for label %q", key)
+			}
+			char := input[i]
+			if char == '"' {
+				i++
+				break
+			}
+			if char == '\\' && i+1 < len(input) {
+				i++
+				switch input[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(input[i])
+				}
+				i++
+				continue
+			}
+			value.WriteByte(char)
+			i++
+		}
+		labels[key] = value.String()
+	}
+}
+
+// nodeExporterMetric converts node_exporter samples into a SystemMetric. CPU usage
+// is derived from the change in CPU counters since the previous scrape, so the first
+// scrape of a target returns errNeedsBaseline alongside the counters to remember.
+func nodeExporterMetric(samples []sample, previous *cpuTimes) (*entities.SystemMetric, cpuTimes, error) {
+	var current cpuTimes
+	var memTotal, memAvailable, diskSize, diskAvailable float64
+	var haveMemTotal, haveMemAvailable, haveDiskSize, haveDiskAvailable bool
+
+	for _, s := range samples {
+		switch s.name {
+		case "node_cpu_seconds_total":
+			current.total += s.value
+			if mode := s.labels["mode"]; mode == "idle" || mode == "iowait" {
+				current.idle += s.value
+			}
+		case "node_memory_MemTotal_bytes":
+			memTotal, haveMemTotal = s.value, true
+		case "node_memory_MemAvailable_bytes":
+			memAvailable, haveMemAvailable = s.value, true
+		case "node_filesystem_size_bytes":
+			if s.labels["mountpoint"] == "/" {
+				diskSize, haveDiskSize = s.value, true
+			}
+		case "node_filesystem_avail_bytes":
+			if s.labels["mountpoint"] == "/" {
+				diskAvailable, haveDiskAvailable = s.value, true
+			}
+		}
+	}
+
+	if current.total == 0 {
+		return nil, current, errors.New("node_cpu_seconds_total not found")
+	}
+	if !haveMemTotal || !haveMemAvailable {
+		return nil, current, errors.New("node_memory_MemTotal_bytes or node_memory_MemAvailable_bytes not found")
+	}
+	if !haveDiskSize || !haveDiskAvailable {
+		return nil, current, errors.New("node_filesystem_size_bytes or node_filesystem_avail_bytes for mountpoint \"/\" not found")
+	}
+
+	if previous == nil || current.total <= previous.total {
+		return nil, current, errNeedsBaseline
+	}
+
+	deltaTotal := current.total - previous.total
+	deltaIdle := current.idle - previous.idle
+	cpuUsage := clampPercent((1 - deltaIdle/deltaTotal) * 100)
+
+	metric := &entities.SystemMetric{
+		CPUUsage:             cpuUsage,
+		MemoryTotalBytes:     int64(memTotal),
+		MemoryAvailableBytes: int64(memAvailable),
+		MemoryUsedBytes:      int64(memTotal - memAvailable),
+		DiskTotalBytes:       int64(diskSize),
+		DiskAvailableBytes:   int64(diskAvailable),
+		DiskUsedBytes:        int64(diskSize - diskAvailable),
+	}
+	metric.MemoryUsagePercent = percentOf(metric.MemoryUsedBytes, metric.MemoryTotalBytes)
+	metric.DiskUsagePercent = percentOf(metric.DiskUsedBytes, metric.DiskTotalBytes)
+
+	return metric, current, nil
+}
+
+// percentOf returns used as a percentage of total, or 0 when total is unknown
+func percentOf(used, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return clampPercent(float64(used) / float64(total) * 100)
+}
+
+// clampPercent keeps rounding noise from pushing a percentage outside 0-100
+func clampPercent(value float64) float64 {
+	return max(0, min(100, value))
+}
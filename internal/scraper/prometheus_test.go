@@ -0,0 +1,143 @@
+// nolint
+package scraper
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// PrometheusTestSuite is the test suite for the Prometheus text parser and converter
+type PrometheusTestSuite struct {
+	suite.Suite
+}
+
+// nodeExporterFixture builds a trimmed node_exporter response with the given CPU counters
+func nodeExporterFixture(idle, user float64) string {
+	return `# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} ` + strconv.FormatFloat(idle, 'f', -1, 64) + `
+node_cpu_seconds_total{cpu="0",mode="user"} ` + strconv.FormatFloat(user, 'f', -1, 64) + `
+node_memory_MemTotal_bytes 4.294967296e+09
+node_memory_MemAvailable_bytes 1.073741824e+09
+node_filesystem_size_bytes{device="/dev/root",fstype="ext4",mountpoint="/"} 3.2e+10
+node_filesystem_avail_bytes{device="/dev/root",fstype="ext4",mountpoint="/"} 2.4e+10
+node_filesystem_size_bytes{device="/dev/sda1",fstype="vfat",mountpoint="/boot"} 2.5e+08
+`
+}
+
+// TestParseSampleLine tests parsing of individual exposition lines
+func (suite *PrometheusTestSuite) TestParseSampleLine() {
+	tests := []struct {
+		name           string
+		line           string
+		expectedName   string
+		expectedLabels map[string]string
+		expectedValue  float64
+		expectError    bool
+	}{
+		{
+			name:           "no_labels",
+			line:           "node_load1 0.42",
+			expectedName:   "node_load1",
+			expectedLabels: map[string]string{},
+			expectedValue:  0.42,
+		},
+		{
+			name:           "labels_and_timestamp",
+			line:           `node_cpu_seconds_total{cpu="1",mode="idle"} 1234.5 1700000000000`,
+			expectedName:   "node_cpu_seconds_total",
+			expectedLabels: map[string]string{"cpu": "1", "mode": "idle"},
+			expectedValue:  1234.5,
+		},
+		{
+			name:           "escaped_label_value",
+			line:           `node_uname_info{version="#1 SMP \"test\""} 1`,
+			expectedName:   "node_uname_info",
+			expectedLabels: map[string]string{"version": `#1 SMP "test"`},
+			expectedValue:  1,
+		},
+		{
+			name:        "missing_value",
+			line:        "node_load1",
+			expectError: true,
+		},
+		{
+			name:        "unterminated_labels",
+			line:        `node_load1{cpu="0" 1`,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			parsed, err := parseSampleLine(test.line)
+
+			if test.expectError {
+				assert.Error(suite.T(), err)
+				return
+			}
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), test.expectedName, parsed.name)
+			assert.Equal(suite.T(), test.expectedLabels, parsed.labels)
+			assert.Equal(suite.T(), test.expectedValue, parsed.value)
+		})
+	}
+}
+
+// TestNodeExporterMetric tests conversion of node_exporter samples into a SystemMetric
+func (suite *PrometheusTestSuite) TestNodeExporterMetric() {
+	first, err := parsePrometheusText(strings.NewReader(nodeExporterFixture(100, 100)))
+	suite.Require().NoError(err)
+
+	// First scrape only establishes the CPU baseline
+	metric, baseline, err := nodeExporterMetric(first, nil)
+	assert.ErrorIs(suite.T(), err, errNeedsBaseline)
+	assert.Nil(suite.T(), metric)
+	assert.Equal(suite.T(), cpuTimes{idle: 100, total: 200}, baseline)
+
+	// Second scrape: 25s idle out of 100s total -> 75% busy
+	second, err := parsePrometheusText(strings.NewReader(nodeExporterFixture(125, 175)))
+	suite.Require().NoError(err)
+
+	metric, _, err = nodeExporterMetric(second, &baseline)
+	assert.NoError(suite.T(), err)
+	assert.InDelta(suite.T(), 75.0, metric.CPUUsage, 0.001)
+	assert.Equal(suite.T(), int64(4294967296), metric.MemoryTotalBytes)
+	assert.Equal(suite.T(), int64(3221225472), metric.MemoryUsedBytes)
+	assert.InDelta(suite.T(), 75.0, metric.MemoryUsagePercent, 0.001)
+	assert.Equal(suite.T(), int64(32000000000), metric.DiskTotalBytes)
+	assert.Equal(suite.T(), int64(8000000000), metric.DiskUsedBytes)
+	assert.InDelta(suite.T(), 25.0, metric.DiskUsagePercent, 0.001)
+}
+
+// TestNodeExporterMetricMissingSeries tests that incomplete responses are rejected
+func (suite *PrometheusTestSuite) TestNodeExporterMetricMissingSeries() {
+	samples, err := parsePrometheusText(strings.NewReader("node_load1 0.5\n"))
+	suite.Require().NoError(err)
+
+	_, _, err = nodeExporterMetric(samples, nil)
+	assert.Error(suite.T(), err)
+	assert.NotErrorIs(suite.T(), err, errNeedsBaseline)
+
+	// Without the free space the root filesystem would look full
+	var lines []string
+	for _, line := range strings.Split(nodeExporterFixture(125, 175), "\n") {
+		if !strings.HasPrefix(line, "node_filesystem_avail_bytes") {
+			lines = append(lines, line)
+		}
+	}
+	samples, err = parsePrometheusText(strings.NewReader(strings.Join(lines, "\n")))
+	suite.Require().NoError(err)
+
+	_, _, err = nodeExporterMetric(samples, &cpuTimes{idle: 100, total: 200})
+	assert.EqualError(suite.T(), err, `node_filesystem_size_bytes or node_filesystem_avail_bytes for mountpoint "/" not found`)
+}
+
+// Run the test suite
+func TestPrometheusTestSuite(t *testing.T) {
+	suite.Run(t, new(PrometheusTestSuite))
+}
@@ -0,0 +1,319 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+)
+
+// maxResponseBytes caps how much of a scrape response is read
+const maxResponseBytes = 4 << 20
+
+// staleTicks is how many ticks the loop may miss before its health check fails
+const staleTicks = 30
+
+// targetRefresh is how long the scheduler uses its copy of the targets before loading
+// them again, when it hasn't been told they changed
+const targetRefresh = 5 * time.Minute
+
+// Scheduler periodically scrapes registered targets and stores the results as metrics
+type Scheduler struct {
+	targets       services.ScrapeTargetServiceInterface
+	metrics       services.MetricServiceInterface
+	instruments   *telemetry.Metrics
+	storage       *services.StorageMonitor
+	client        *http.Client
+	tick          time.Duration
+	maxConcurrent int
+
+	mu        sync.Mutex
+	nextRun   map[int64]time.Time
+	inFlight  map[int64]bool
+	baselines map[int64]cpuTimes
+
+	lastTick atomic.Int64 // unix nanoseconds of the last scheduling pass
+
+	// Active targets as last loaded, only touched by the scheduling loop
+	targetList     []entities.ScrapeTarget
+	targetsLoaded  time.Time
+	targetsChanged atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(
+	targets services.ScrapeTargetServiceInterface,
+	metrics services.MetricServiceInterface,
+	maxConcurrent int,
+	storage *services.StorageMonitor,
+	instruments *telemetry.Metrics,
+) *Scheduler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	scheduler := &Scheduler{
+		targets:       targets,
+		metrics:       metrics,
+		instruments:   instruments,
+		storage:       storage,
+		client:        &http.Client{},
+		tick:          time.Second,
+		maxConcurrent: maxConcurrent,
+		nextRun:       make(map[int64]time.Time),
+		inFlight:      make(map[int64]bool),
+		baselines:     make(map[int64]cpuTimes),
+	}
+	scheduler.targetsChanged.Store(true)
+	return scheduler
+}
+
+// TargetsChanged makes the next scheduling pass load the targets again
+func (scheduler *Scheduler) TargetsChanged() {
+	scheduler.targetsChanged.Store(true)
+}
+
+// Start launches the scheduling loop in the background
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	ctx, scheduler.cancel = context.WithCancel(ctx)
+
+	scheduler.wg.Add(1)
+	go func() {
+		defer scheduler.wg.Done()
+		scheduler.loop(ctx)
+	}()
+}
+
+// Stop cancels the scheduling loop and waits for in-flight scrapes to finish
+func (scheduler *Scheduler) Stop() {
+	if scheduler.cancel != nil {
+		scheduler.cancel()
+	}
+	scheduler.wg.Wait()
+}
+
+// loop checks for due targets on every tick until the context is cancelled
+func (scheduler *Scheduler) loop(ctx context.Context) {
+	semaphore := make(chan struct{}, scheduler.maxConcurrent)
+	ticker := time.NewTicker(scheduler.tick)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		scheduler.lastTick.Store(now.UnixNano())
+		scheduler.runDue(ctx, now, semaphore)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reports whether the scheduling loop is still running, for the health checks
+func (scheduler *Scheduler) Check(ctx context.Context) (map[string]interface{}, error) {
+	lastTick := scheduler.lastTick.Load()
+	if lastTick == 0 {
+		return nil, errors.New("scraper is not running")
+	}
+
+	scheduler.mu.Lock()
+	inFlight := len(scheduler.inFlight)
+	scheduler.mu.Unlock()
+
+	last := time.Unix(0, lastTick)
+	details := map[string]interface{}{
+		"last_pass": last.UTC().Format(time.RFC3339),
+		"in_flight": inFlight,
+	}
+	if since := time.Since(last); since > staleTicks*scheduler.tick {
+		return details, fmt.Errorf("scraper has not run for %s", since.Round(time.Second))
+	}
+	return details, nil
+}
+
+// runDue starts a scrape for every active target whose interval has elapsed
+func (scheduler *Scheduler) runDue(ctx context.Context, now time.Time, semaphore chan struct{}) {
+	// Scrapes are low priority; leave what disk space is left to agent pushes
+	if scheduler.storage.LowPriorityPaused() {
+		return
+	}
+
+	targets, err := scheduler.activeTargets(ctx, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "scraper failed to load scrape targets", "error", err)
+		return
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	active := make(map[int64]bool, len(targets))
+	for _, target := range targets {
+		active[target.ID] = true
+
+		if scheduler.inFlight[target.ID] {
+			continue
+		}
+		if next, ok := scheduler.nextRun[target.ID]; ok && now.Before(next) {
+			continue
+		}
+
+		scheduler.nextRun[target.ID] = now.Add(time.Duration(target.IntervalSeconds) * time.Second)
+		scheduler.inFlight[target.ID] = true
+
+		scheduler.wg.Add(1)
+		go func(target entities.ScrapeTarget) {
+			defer scheduler.wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				scheduler.finish(target.ID)
+				return
+			}
+			defer func() { <-semaphore }()
+
+			scheduler.scrape(ctx, target)
+			scheduler.finish(target.ID)
+		}(target)
+	}
+
+	// Forget state for targets that were removed or paused
+	for id := range scheduler.nextRun {
+		if !active[id] && !scheduler.inFlight[id] {
+			delete(scheduler.nextRun, id)
+			delete(scheduler.baselines, id)
+		}
+	}
+}
+
+// activeTargets returns the active targets, loading them again once they have changed
+// or targetRefresh has passed
+func (scheduler *Scheduler) activeTargets(ctx context.Context, now time.Time) ([]entities.ScrapeTarget, error) {
+	if !scheduler.targetsChanged.Swap(false) && now.Sub(scheduler.targetsLoaded) < targetRefresh {
+		return scheduler.targetList, nil
+	}
+
+	targets, err := scheduler.targets.GetTargets(ctx, &entities.ScrapeTargetQueryParams{ActiveOnly: true})
+	if err != nil {
+		scheduler.targetsChanged.Store(true)
+		return nil, err
+	}
+	scheduler.targetList, scheduler.targetsLoaded = targets, now
+	return targets, nil
+}
+
+// finish marks a target as no longer being scraped
+func (scheduler *Scheduler) finish(id int64) {
+	scheduler.mu.Lock()
+	delete(scheduler.inFlight, id)
+	scheduler.mu.Unlock()
+}
+
+// scrape fetches a single target, stores the resulting metric and records scrape health
+func (scheduler *Scheduler) scrape(ctx context.Context, target entities.ScrapeTarget) {
+	start := time.Now()
+
+	metric, err := scheduler.fetch(ctx, target)
+	if err == nil {
+		metric.HostID = target.HostID
+		metric.Timestamp = start.Unix()
+		_, err = scheduler.metrics.CreateMetric(ctx, metric)
+	}
+
+	result := &entities.ScrapeResult{
+		Timestamp:  start.Unix(),
+		Status:     entities.ScrapeStatusOK,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	switch {
+	case errors.Is(err, errNeedsBaseline):
+		result.Status = entities.ScrapeStatusPending
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = entities.ScrapeStatusError
+		result.Error = err.Error()
+	}
+
+	scheduler.instruments.ObserveJob("scrape", result.Status, time.Since(start))
+
+	if err := scheduler.targets.RecordResult(ctx, target.ID, result); err != nil {
+		slog.ErrorContext(ctx, "scraper failed to record result", "target_id", target.ID, "error", err)
+	}
+}
+
+// fetch retrieves a target and converts its response into a SystemMetric
+func (scheduler *Scheduler) fetch(ctx context.Context, target entities.ScrapeTarget) (*entities.SystemMetric, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(target.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if target.Format == entities.ScrapeFormatJSON {
+		request.Header.Set("Accept", "application/json")
+	} else {
+		request.Header.Set("Accept", "text/plain;version=0.0.4")
+	}
+
+	response, err := scheduler.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := response.Body.Close(); err != nil {
+			slog.Warn("scraper failed to close response body", "error", err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Format == entities.ScrapeFormatJSON {
+		return jsonMetric(body, target.JSONMapping)
+	}
+
+	samples, err := parsePrometheusText(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler.mu.Lock()
+	previous, hasPrevious := scheduler.baselines[target.ID]
+	scheduler.mu.Unlock()
+
+	var baseline *cpuTimes
+	if hasPrevious {
+		baseline = &previous
+	}
+
+	metric, current, err := nodeExporterMetric(samples, baseline)
+	if current.total > 0 {
+		scheduler.mu.Lock()
+		scheduler.baselines[target.ID] = current
+		scheduler.mu.Unlock()
+	}
+
+	return metric, err
+}
@@ -0,0 +1,207 @@
+// nolint
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/services"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// SchedulerTestSuite is the test suite for Scheduler
+type SchedulerTestSuite struct {
+	suite.Suite
+	mockTargets *mocks.MockScrapeTargetService
+	mockMetrics *mocks.MockMetricService
+	scheduler   *Scheduler
+	server      *httptest.Server
+}
+
+// SetupTest runs before each test in the suite
+func (suite *SchedulerTestSuite) SetupTest() {
+	suite.mockTargets = new(mocks.MockScrapeTargetService)
+	suite.mockMetrics = new(mocks.MockMetricService)
+	suite.scheduler = NewScheduler(suite.mockTargets, suite.mockMetrics, 2, nil, nil)
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cpu": 40, "mem": {"total": 200, "used": 50}}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+}
+
+// TearDownTest runs after each test
+func (suite *SchedulerTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.mockTargets.AssertExpectations(suite.T())
+	suite.mockMetrics.AssertExpectations(suite.T())
+}
+
+// runOnce runs a single scheduling pass and waits for the scrapes it started
+func (suite *SchedulerTestSuite) runOnce(now time.Time) {
+	suite.scheduler.runDue(context.Background(), now, make(chan struct{}, 2))
+	suite.scheduler.wg.Wait()
+}
+
+// TestSuccessfulScrape tests that a scraped target is stored as a metric and recorded as healthy
+func (suite *SchedulerTestSuite) TestSuccessfulScrape() {
+	target := entities.ScrapeTarget{
+		ID:              1,
+		URL:             suite.server.URL + "/status",
+		HostID:          7,
+		Format:          entities.ScrapeFormatJSON,
+		IntervalSeconds: 30,
+		TimeoutSeconds:  5,
+		JSONMapping: map[string]string{
+			"cpu_usage":          "cpu",
+			"memory_total_bytes": "mem.total",
+			"memory_used_bytes":  "mem.used",
+		},
+	}
+
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{target}, nil).Once()
+	suite.mockMetrics.On("CreateMetric", mock.Anything, mock.MatchedBy(func(metric *entities.SystemMetric) bool {
+		return metric.HostID == 7 && metric.CPUUsage == 40 && metric.MemoryUsagePercent == 25 && metric.Timestamp > 0
+	})).Return(entities.MetricWrite{ID: 11}, nil).Once()
+	suite.mockTargets.On("RecordResult", mock.Anything, int64(1), mock.MatchedBy(func(result *entities.ScrapeResult) bool {
+		return result.Status == entities.ScrapeStatusOK && result.Error == ""
+	})).Return(nil).Once()
+
+	suite.runOnce(time.Now())
+}
+
+// TestFailedScrape tests that HTTP failures are recorded against the target
+func (suite *SchedulerTestSuite) TestFailedScrape() {
+	target := entities.ScrapeTarget{
+		ID:              2,
+		URL:             suite.server.URL + "/metrics",
+		HostID:          7,
+		Format:          entities.ScrapeFormatPrometheus,
+		IntervalSeconds: 30,
+		TimeoutSeconds:  5,
+	}
+
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{target}, nil).Once()
+	suite.mockTargets.On("RecordResult", mock.Anything, int64(2), mock.MatchedBy(func(result *entities.ScrapeResult) bool {
+		return result.Status == entities.ScrapeStatusError && result.Error == "unexpected status 503"
+	})).Return(nil).Once()
+
+	suite.runOnce(time.Now())
+}
+
+// TestIntervalRespected tests that a target is not scraped again before its interval elapses
+func (suite *SchedulerTestSuite) TestIntervalRespected() {
+	target := entities.ScrapeTarget{
+		ID:              3,
+		URL:             suite.server.URL + "/metrics",
+		HostID:          7,
+		Format:          entities.ScrapeFormatPrometheus,
+		IntervalSeconds: 30,
+		TimeoutSeconds:  5,
+	}
+
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{target}, nil).Once()
+	suite.mockTargets.On("RecordResult", mock.Anything, int64(3), mock.Anything).Return(nil).Twice()
+
+	start := time.Now()
+	suite.runOnce(start)
+	suite.runOnce(start.Add(10 * time.Second))
+	suite.runOnce(start.Add(31 * time.Second))
+}
+
+// TestTargetLoadError tests that a failure to load targets skips the pass, and they are
+// loaded again on the next one
+func (suite *SchedulerTestSuite) TestTargetLoadError() {
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return(nil, errors.New("database is locked")).Once()
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{}, nil).Once()
+
+	start := time.Now()
+	suite.runOnce(start)
+	assert.Empty(suite.T(), suite.scheduler.nextRun)
+
+	suite.runOnce(start.Add(time.Second))
+}
+
+// TestTargetsCached tests that targets are only loaded again once they change or the
+// refresh interval passes
+func (suite *SchedulerTestSuite) TestTargetsCached() {
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{}, nil).Times(3)
+
+	start := time.Now()
+	suite.runOnce(start)
+	suite.runOnce(start.Add(time.Second))
+	suite.mockTargets.AssertNumberOfCalls(suite.T(), "GetTargets", 1)
+
+	suite.scheduler.TargetsChanged()
+	suite.runOnce(start.Add(2 * time.Second))
+	suite.runOnce(start.Add(3 * time.Second))
+	suite.mockTargets.AssertNumberOfCalls(suite.T(), "GetTargets", 2)
+
+	suite.runOnce(start.Add(2*time.Second + targetRefresh))
+	suite.mockTargets.AssertNumberOfCalls(suite.T(), "GetTargets", 3)
+}
+
+// TestStartStop tests that Stop waits for the scheduling loop to exit
+func (suite *SchedulerTestSuite) TestStartStop() {
+	suite.mockTargets.On("GetTargets", mock.Anything, &entities.ScrapeTargetQueryParams{ActiveOnly: true}).
+		Return([]entities.ScrapeTarget{}, nil)
+
+	suite.scheduler.Start(context.Background())
+	suite.scheduler.Stop()
+}
+
+// TestCheck tests that the health check fails until the loop runs and once it stalls
+func (suite *SchedulerTestSuite) TestCheck() {
+	_, err := suite.scheduler.Check(context.Background())
+	assert.EqualError(suite.T(), err, "scraper is not running")
+
+	suite.scheduler.lastTick.Store(time.Now().UnixNano())
+	details, err := suite.scheduler.Check(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, details["in_flight"])
+
+	suite.scheduler.lastTick.Store(time.Now().Add(-time.Minute).UnixNano())
+	_, err = suite.scheduler.Check(context.Background())
+	assert.EqualError(suite.T(), err, "scraper has not run for 1m0s")
+}
+
+// TestPausedForLowDiskSpace tests that no targets are scraped while the volume is nearly full
+func (suite *SchedulerTestSuite) TestPausedForLowDiskSpace() {
+	healthRepo := new(mocks.MockHealthRepository)
+	healthRepo.On("GetStorageStats", mock.Anything).Return(&entities.StorageStats{
+		Volume: &entities.VolumeStats{Path: "/data", FreeBytes: 1 << 20, TotalBytes: 1 << 30},
+	}, nil)
+	storage := services.NewStorageMonitor(healthRepo, 512<<20, true, nil)
+	_, err := storage.Check(context.Background())
+	suite.Require().Error(err)
+	suite.scheduler = NewScheduler(suite.mockTargets, suite.mockMetrics, 2, storage, nil)
+
+	suite.runOnce(time.Now())
+
+	suite.mockTargets.AssertNotCalled(suite.T(), "GetTargets", mock.Anything, mock.Anything)
+	assert.Empty(suite.T(), suite.scheduler.nextRun)
+}
+
+// Run the test suite
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
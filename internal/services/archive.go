@@ -0,0 +1,513 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/pkg/parquet"
+)
+
+// importBatchSize is how many metrics are inserted per transaction during an import
+const importBatchSize = 1000
+
+// archiveColumns is the Parquet schema of archive files: every metric field plus the
+// host it was reported by, so files can be imported into a database with other host IDs
+var archiveColumns = []parquet.Column{
+	{Name: "id", Type: parquet.Int64},
+	{Name: "host_id", Type: parquet.Int64},
+	{Name: "hostname", Type: parquet.String},
+	{Name: "ip_address", Type: parquet.String},
+	{Name: "role", Type: parquet.String},
+	{Name: "timestamp", Type: parquet.Int64},
+	{Name: "cpu_usage", Type: parquet.Double},
+	{Name: "memory_usage_percent", Type: parquet.Double},
+	{Name: "memory_total_bytes", Type: parquet.Int64},
+	{Name: "memory_used_bytes", Type: parquet.Int64},
+	{Name: "memory_available_bytes", Type: parquet.Int64},
+	{Name: "disk_usage_percent", Type: parquet.Double},
+	{Name: "disk_total_bytes", Type: parquet.Int64},
+	{Name: "disk_used_bytes", Type: parquet.Int64},
+	{Name: "disk_available_bytes", Type: parquet.Int64},
+	{Name: "received_at", Type: parquet.Int64},
+}
+
+// optionalArchiveColumns were added after the first archives were written, so files
+// without them can still be imported
+var optionalArchiveColumns = []string{"received_at"}
+
+type ArchiveService struct {
+	metricRepo repository.MetricRepositoryInterface
+	hostRepo   repository.HostRepositoryInterface
+	dir        string
+	storage    *StorageMonitor
+	running    sync.Mutex
+}
+
+// NewArchiveService creates an ArchiveService that reads and writes files under dir.
+// An empty dir disables archiving. Imports are refused while storage pauses low-priority
+// writes; storage may be nil.
+func NewArchiveService(
+	metricRepo repository.MetricRepositoryInterface,
+	hostRepo repository.HostRepositoryInterface,
+	dir string,
+	storage *StorageMonitor,
+) *ArchiveService {
+	return &ArchiveService{metricRepo: metricRepo, hostRepo: hostRepo, dir: dir, storage: storage}
+}
+
+// Archive writes metrics in the requested time range to one Parquet file per UTC
+// month. Metrics are only deleted once every file has been written successfully.
+func (service *ArchiveService) Archive(ctx context.Context, req *entities.ArchiveRequest) (*entities.ArchiveResult, error) {
+	if service.dir == "" {
+		return nil, ErrArchiveDisabled
+	}
+	if req == nil {
+		return nil, ErrNilQueryParams
+	}
+	if req.StartTime <= 0 || req.EndTime <= 0 || req.StartTime > req.EndTime {
+		return nil, ErrInvalidTimeRange
+	}
+
+	if !service.running.TryLock() {
+		return nil, ErrArchiveInProgress
+	}
+	defer service.running.Unlock()
+
+	result := &entities.ArchiveResult{Files: []entities.ArchiveFile{}}
+	var current *archivePartition
+	var written []string
+	var maxID int64
+
+	// Remove everything this run wrote if it fails part way through
+	failed := true
+	defer func() {
+		if !failed {
+			return
+		}
+		if current != nil {
+			current.abort()
+		}
+		for _, path := range written {
+			_ = os.Remove(path)
+		}
+	}()
+
+	finish := func() error {
+		file, err := current.finish()
+		if err != nil {
+			return err
+		}
+		written = append(written, filepath.Join(service.dir, file.Path))
+		result.Files = append(result.Files, *file)
+		current = nil
+		return nil
+	}
+
+	params := &entities.MetricQueryParams{StartTime: &req.StartTime, EndTime: &req.EndTime, Order: "ASC"}
+	err := service.metricRepo.StreamByFilters(ctx, params, func(row entities.MetricExportRow) error {
+		month := time.Unix(row.Metric.Timestamp, 0).UTC().Format("2006-01")
+		if current != nil && current.month != month {
+			if err := finish(); err != nil {
+				return err
+			}
+		}
+
+		if current == nil {
+			partition, err := newArchivePartition(service.dir, month)
+			if err != nil {
+				return err
+			}
+			current = partition
+		}
+
+		if err := current.write(row); err != nil {
+			return err
+		}
+		result.Rows++
+		maxID = max(maxID, row.Metric.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		if err := finish(); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Delete && result.Rows > 0 {
+		deleted, err := service.metricRepo.DeleteRange(ctx, req.StartTime, req.EndTime, maxID)
+		if err != nil {
+			// The files are complete, so keep them and leave the metrics in place
+			failed = false
+			return nil, fmt.Errorf("archive files were written but deleting metrics failed: %w", err)
+		}
+		result.Deleted = deleted
+	}
+
+	failed = false
+	return result, nil
+}
+
+// Import loads an archive file, or every .parquet file below a directory, back into
+// the database. Hosts are matched by hostname and created when missing; metrics
+// already stored for the same host and timestamp are skipped.
+func (service *ArchiveService) Import(ctx context.Context, req *entities.ImportRequest) (*entities.ImportResult, error) {
+	if service.dir == "" {
+		return nil, ErrArchiveDisabled
+	}
+	if req == nil {
+		return nil, ErrNilQueryParams
+	}
+	if service.storage.LowPriorityPaused() {
+		return nil, ErrLowDiskSpace
+	}
+
+	files, err := service.importFiles(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !service.running.TryLock() {
+		return nil, ErrArchiveInProgress
+	}
+	defer service.running.Unlock()
+
+	result := &entities.ImportResult{Files: []string{}}
+	hostIDs := make(map[string]int64)
+	for _, path := range files {
+		if err := service.importFile(ctx, path, hostIDs, result); err != nil {
+			return nil, err
+		}
+
+		relative, _ := filepath.Rel(service.dir, path)
+		result.Files = append(result.Files, filepath.ToSlash(relative))
+	}
+
+	return result, nil
+}
+
+// importFiles resolves a path relative to the archive directory to the files to import
+func (service *ArchiveService) importFiles(path string) ([]string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(path))
+	if path == "" || !filepath.IsLocal(cleaned) && cleaned != "." {
+		return nil, ErrInvalidArchivePath
+	}
+
+	root := filepath.Join(service.dir, cleaned)
+	info, err := os.Stat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrArchiveNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".parquet") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, ErrArchiveNotFound
+	}
+	return files, nil
+}
+
+// importFile inserts the metrics of one archive file in batches
+func (service *ArchiveService) importFile(ctx context.Context, path string, hostIDs map[string]int64, result *entities.ImportResult) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader, err := parquet.NewReader(file, info.Size())
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchiveFile, filepath.Base(path), err)
+	}
+
+	index, err := archiveColumnIndex(reader.Columns())
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchiveFile, filepath.Base(path), err)
+	}
+
+	batch := make([]entities.SystemMetric, 0, importBatchSize)
+	flush := func() error {
+		inserted, err := service.metricRepo.InsertMissing(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Inserted += inserted
+		result.Skipped += int64(len(batch)) - inserted
+		batch = batch[:0]
+		return nil
+	}
+
+	err = reader.Read(func(row parquet.Row) error {
+		metric, host := archiveRowToMetric(row, index)
+
+		hostID, err := service.resolveHost(ctx, host, hostIDs, result)
+		if err != nil {
+			return err
+		}
+		metric.HostID = hostID
+
+		if err := ValidateSystemMetric(&metric); err != nil {
+			return fmt.Errorf("%w: %s: metric at %d: %v", ErrInvalidArchiveFile, filepath.Base(path), metric.Timestamp, err)
+		}
+
+		batch = append(batch, metric)
+		result.Rows++
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if errors.Is(err, parquet.ErrUnsupported) {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchiveFile, filepath.Base(path), err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// resolveHost finds the local ID of an archived host, creating the host if needed
+func (service *ArchiveService) resolveHost(ctx context.Context, host entities.Host, hostIDs map[string]int64, result *entities.ImportResult) (int64, error) {
+	if id, ok := hostIDs[host.Hostname]; ok {
+		return id, nil
+	}
+
+	hosts, err := service.hostRepo.FindByFilters(ctx, &entities.HostQueryParams{Hostname: host.Hostname})
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	if len(hosts) > 0 {
+		id = hosts[0].ID
+	} else {
+		if host.Hostname == "" {
+			return 0, fmt.Errorf("%w: metric has no hostname", ErrInvalidArchiveFile)
+		}
+		if id, err = service.hostRepo.Create(ctx, &host); err != nil {
+			return 0, err
+		}
+		result.HostsCreated++
+	}
+
+	hostIDs[host.Hostname] = id
+	return id, nil
+}
+
+// archiveColumnIndex maps archive column names to their position in a file, checking
+// that every expected column is present with the expected type
+func archiveColumnIndex(columns []parquet.Column) (map[string]int, error) {
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column.Name] = i
+	}
+
+	for _, expected := range archiveColumns {
+		i, ok := index[expected.Name]
+		if !ok && slices.Contains(optionalArchiveColumns, expected.Name) {
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing column %q", expected.Name)
+		}
+		if columns[i].Type != expected.Type {
+			return nil, fmt.Errorf("column %q has type %v, expected %v", expected.Name, columns[i].Type, expected.Type)
+		}
+	}
+	return index, nil
+}
+
+// archiveRowToMetric converts a file row to a metric and its host. The archived
+// metric and host IDs are not reused.
+func archiveRowToMetric(row parquet.Row, index map[string]int) (entities.SystemMetric, entities.Host) {
+	int64Value := func(name string) int64 { return row[index[name]].(int64) }
+	floatValue := func(name string) float64 { return row[index[name]].(float64) }
+	stringValue := func(name string) string { return row[index[name]].(string) }
+
+	metric := entities.SystemMetric{
+		Timestamp:            int64Value("timestamp"),
+		CPUUsage:             floatValue("cpu_usage"),
+		MemoryUsagePercent:   floatValue("memory_usage_percent"),
+		MemoryTotalBytes:     int64Value("memory_total_bytes"),
+		MemoryUsedBytes:      int64Value("memory_used_bytes"),
+		MemoryAvailableBytes: int64Value("memory_available_bytes"),
+		DiskUsagePercent:     floatValue("disk_usage_percent"),
+		DiskTotalBytes:       int64Value("disk_total_bytes"),
+		DiskUsedBytes:        int64Value("disk_used_bytes"),
+		DiskAvailableBytes:   int64Value("disk_available_bytes"),
+	}
+	if _, ok := index["received_at"]; ok {
+		metric.ReceivedAt = int64Value("received_at")
+	}
+	host := entities.Host{
+		Hostname:  stringValue("hostname"),
+		IPAddress: stringValue("ip_address"),
+		Role:      stringValue("role"),
+	}
+	return metric, host
+}
+
+// archivePartition is a monthly archive file being written. Rows go to a temporary
+// file that is renamed into place once complete.
+type archivePartition struct {
+	dir      string
+	month    string
+	relative string
+	file     *os.File
+	buffer   *bufio.Writer
+	writer   *parquet.Writer
+	rows     int64
+	first    int64
+	last     int64
+}
+
+func newArchivePartition(root, month string) (*archivePartition, error) {
+	year, monthNumber, _ := strings.Cut(month, "-")
+	relative := filepath.Join("year="+year, "month="+monthNumber)
+	dir := filepath.Join(root, relative)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(dir, ".metrics-*.parquet.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bufio.NewWriter(file)
+	writer, err := parquet.NewWriter(buffer, archiveColumns)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &archivePartition{
+		dir:      dir,
+		month:    month,
+		relative: relative,
+		file:     file,
+		buffer:   buffer,
+		writer:   writer,
+	}, nil
+}
+
+func (partition *archivePartition) write(row entities.MetricExportRow) error {
+	metric := row.Metric
+	if partition.rows == 0 {
+		partition.first = metric.Timestamp
+	}
+	partition.last = metric.Timestamp
+	partition.rows++
+
+	return partition.writer.Write(parquet.Row{
+		metric.ID,
+		metric.HostID,
+		row.Host.Hostname,
+		row.Host.IPAddress,
+		row.Host.Role,
+		metric.Timestamp,
+		metric.CPUUsage,
+		metric.MemoryUsagePercent,
+		metric.MemoryTotalBytes,
+		metric.MemoryUsedBytes,
+		metric.MemoryAvailableBytes,
+		metric.DiskUsagePercent,
+		metric.DiskTotalBytes,
+		metric.DiskUsedBytes,
+		metric.DiskAvailableBytes,
+		metric.ReceivedAt,
+	})
+}
+
+// finish completes the file and moves it to its final name. Existing archives are
+// never overwritten.
+func (partition *archivePartition) finish() (*entities.ArchiveFile, error) {
+	name := fmt.Sprintf("metrics-%d-%d.parquet", partition.first, partition.last)
+	target := filepath.Join(partition.dir, name)
+
+	if err := partition.writer.Close(); err != nil {
+		partition.abort()
+		return nil, err
+	}
+	if err := partition.buffer.Flush(); err != nil {
+		partition.abort()
+		return nil, err
+	}
+	if err := partition.file.Sync(); err != nil {
+		partition.abort()
+		return nil, err
+	}
+	if err := partition.file.Close(); err != nil {
+		_ = os.Remove(partition.file.Name())
+		return nil, err
+	}
+
+	// Link fails if the target exists, unlike Rename
+	err := os.Link(partition.file.Name(), target)
+	_ = os.Remove(partition.file.Name())
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveExists, filepath.ToSlash(filepath.Join(partition.relative, name)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.ArchiveFile{
+		Path:           filepath.ToSlash(filepath.Join(partition.relative, name)),
+		Month:          partition.month,
+		Rows:           partition.rows,
+		FirstTimestamp: partition.first,
+		LastTimestamp:  partition.last,
+	}, nil
+}
+
+// abort discards a partially written file
+func (partition *archivePartition) abort() {
+	_ = partition.file.Close()
+	_ = os.Remove(partition.file.Name())
+}
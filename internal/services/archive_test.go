@@ -0,0 +1,311 @@
+// nolint
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/pkg/parquet"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// ArchiveServiceTestSuite is the test suite for ArchiveService
+type ArchiveServiceTestSuite struct {
+	suite.Suite
+	mockMetricRepo *mocks.MockMetricRepository
+	mockHostRepo   *mocks.MockHostRepository
+	dir            string
+	service        *ArchiveService
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ArchiveServiceTestSuite) SetupTest() {
+	suite.mockMetricRepo = new(mocks.MockMetricRepository)
+	suite.mockHostRepo = new(mocks.MockHostRepository)
+	suite.dir = suite.T().TempDir()
+	suite.service = NewArchiveService(suite.mockMetricRepo, suite.mockHostRepo, suite.dir, nil)
+}
+
+// TearDownTest runs after each test
+func (suite *ArchiveServiceTestSuite) TearDownTest() {
+	suite.mockMetricRepo.AssertExpectations(suite.T())
+	suite.mockHostRepo.AssertExpectations(suite.T())
+}
+
+// archiveRows returns metrics from two hosts either side of the January/February 2025 boundary
+func archiveRows() []entities.MetricExportRow {
+	web := entities.Host{ID: 1, Hostname: "pi-01", IPAddress: "192.168.0.10", Role: "web"}
+	nas := entities.Host{ID: 2, Hostname: "nas", IPAddress: "192.168.0.30", Role: "nas"}
+	return []entities.MetricExportRow{
+		{Metric: entities.SystemMetric{ID: 7, HostID: 1, Timestamp: 1738364400, CPUUsage: 12.5, MemoryTotalBytes: 8000, ReceivedAt: 1738364401}, Host: web},
+		{Metric: entities.SystemMetric{ID: 9, HostID: 2, Timestamp: 1738364460, DiskUsagePercent: 80}, Host: nas},
+		{Metric: entities.SystemMetric{ID: 8, HostID: 1, Timestamp: 1738368000, CPUUsage: 99.9}, Host: web},
+	}
+}
+
+// archiveParams matches the stream query for the test time range
+func archiveParams() interface{} {
+	return mock.MatchedBy(func(params *entities.MetricQueryParams) bool {
+		return *params.StartTime == 1738000000 && *params.EndTime == 1739000000 && params.Order == "ASC"
+	})
+}
+
+// listFiles returns every file below the archive directory, relative to it
+func (suite *ArchiveServiceTestSuite) listFiles() []string {
+	var files []string
+	_ = filepath.WalkDir(suite.dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			relative, _ := filepath.Rel(suite.dir, path)
+			files = append(files, filepath.ToSlash(relative))
+		}
+		return nil
+	})
+	return files
+}
+
+// TestArchiveValidation tests that invalid requests are rejected before any work
+func (suite *ArchiveServiceTestSuite) TestArchiveValidation() {
+	disabled := NewArchiveService(suite.mockMetricRepo, suite.mockHostRepo, "", nil)
+
+	tests := []struct {
+		name          string
+		service       *ArchiveService
+		req           *entities.ArchiveRequest
+		expectedError error
+	}{
+		{name: "disabled", service: disabled, req: &entities.ArchiveRequest{StartTime: 1, EndTime: 2}, expectedError: ErrArchiveDisabled},
+		{name: "nil_request", service: suite.service, expectedError: ErrNilQueryParams},
+		{name: "missing_start", service: suite.service, req: &entities.ArchiveRequest{EndTime: 2}, expectedError: ErrInvalidTimeRange},
+		{name: "reversed_range", service: suite.service, req: &entities.ArchiveRequest{StartTime: 3, EndTime: 2}, expectedError: ErrInvalidTimeRange},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			result, err := test.service.Archive(context.Background(), test.req)
+			assert.Nil(suite.T(), result)
+			assert.Equal(suite.T(), test.expectedError, err)
+		})
+	}
+}
+
+// TestArchiveWritesMonthlyFiles tests month partitioning and deletion after a successful run
+func (suite *ArchiveServiceTestSuite) TestArchiveWritesMonthlyFiles() {
+	suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows(), nil).Once()
+	suite.mockMetricRepo.On("DeleteRange", mock.Anything, int64(1738000000), int64(1739000000), int64(9)).Return(int64(3), nil).Once()
+
+	result, err := suite.service.Archive(context.Background(), &entities.ArchiveRequest{StartTime: 1738000000, EndTime: 1739000000, Delete: true})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), result.Rows)
+	assert.Equal(suite.T(), int64(3), result.Deleted)
+	assert.Equal(suite.T(), []entities.ArchiveFile{
+		{Path: "year=2025/month=01/metrics-1738364400-1738364460.parquet", Month: "2025-01", Rows: 2, FirstTimestamp: 1738364400, LastTimestamp: 1738364460},
+		{Path: "year=2025/month=02/metrics-1738368000-1738368000.parquet", Month: "2025-02", Rows: 1, FirstTimestamp: 1738368000, LastTimestamp: 1738368000},
+	}, result.Files)
+
+	// No temporary files are left behind
+	assert.Equal(suite.T(), []string{result.Files[0].Path, result.Files[1].Path}, suite.listFiles())
+}
+
+// TestArchiveWithoutDelete tests that metrics are kept unless deletion is requested
+func (suite *ArchiveServiceTestSuite) TestArchiveWithoutDelete() {
+	suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows()[:1], nil).Once()
+
+	result, err := suite.service.Archive(context.Background(), &entities.ArchiveRequest{StartTime: 1738000000, EndTime: 1739000000})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result.Files, 1)
+	assert.Equal(suite.T(), int64(0), result.Deleted)
+	suite.mockMetricRepo.AssertNotCalled(suite.T(), "DeleteRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestArchiveFailures tests that failed runs remove their files and keep the metrics
+func (suite *ArchiveServiceTestSuite) TestArchiveFailures() {
+	req := &entities.ArchiveRequest{StartTime: 1738000000, EndTime: 1739000000, Delete: true}
+
+	tests := []struct {
+		name          string
+		setup         func()
+		expectedError error
+		expectedFiles int
+	}{
+		{
+			name: "stream_error",
+			setup: func() {
+				suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows(), errors.New("database is locked")).Once()
+			},
+			expectedError: errors.New("database is locked"),
+		},
+		{
+			name: "existing_file",
+			setup: func() {
+				existing := filepath.Join(suite.dir, "year=2025", "month=02")
+				suite.Require().NoError(os.MkdirAll(existing, 0o755))
+				suite.Require().NoError(os.WriteFile(filepath.Join(existing, "metrics-1738368000-1738368000.parquet"), []byte("keep"), 0o644))
+				suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows(), nil).Once()
+			},
+			expectedError: ErrArchiveExists,
+			expectedFiles: 1,
+		},
+		{
+			name: "delete_error",
+			setup: func() {
+				suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows(), nil).Once()
+				suite.mockMetricRepo.On("DeleteRange", mock.Anything, int64(1738000000), int64(1739000000), int64(9)).Return(int64(0), errors.New("disk I/O error")).Once()
+			},
+			expectedError: errors.New("disk I/O error"),
+			expectedFiles: 2,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setup()
+
+			result, err := suite.service.Archive(context.Background(), req)
+
+			assert.Nil(suite.T(), result)
+			assert.Error(suite.T(), err)
+			if errors.Is(test.expectedError, ErrArchiveExists) {
+				assert.ErrorIs(suite.T(), err, ErrArchiveExists)
+			} else {
+				assert.Contains(suite.T(), err.Error(), test.expectedError.Error())
+			}
+			assert.Len(suite.T(), suite.listFiles(), test.expectedFiles)
+		})
+	}
+}
+
+// TestImportRoundTrip tests that archived files are imported with hosts matched by hostname
+func (suite *ArchiveServiceTestSuite) TestImportRoundTrip() {
+	suite.mockMetricRepo.On("StreamByFilters", mock.Anything, archiveParams()).Return(archiveRows(), nil).Once()
+	_, err := suite.service.Archive(context.Background(), &entities.ArchiveRequest{StartTime: 1738000000, EndTime: 1739000000})
+	suite.Require().NoError(err)
+
+	// pi-01 already exists with another ID; nas is created
+	suite.mockHostRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Hostname: "pi-01"}).Return([]entities.Host{{ID: 40, Hostname: "pi-01"}}, nil).Once()
+	suite.mockHostRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Hostname: "nas"}).Return([]entities.Host{}, nil).Once()
+	suite.mockHostRepo.On("Create", mock.Anything, &entities.Host{Hostname: "nas", IPAddress: "192.168.0.30", Role: "nas"}).Return(int64(41), nil).Once()
+
+	suite.mockMetricRepo.On("InsertMissing", mock.Anything, []entities.SystemMetric{
+		{HostID: 40, Timestamp: 1738364400, CPUUsage: 12.5, MemoryTotalBytes: 8000, ReceivedAt: 1738364401},
+		{HostID: 41, Timestamp: 1738364460, DiskUsagePercent: 80},
+	}).Return(int64(1), nil).Once()
+	suite.mockMetricRepo.On("InsertMissing", mock.Anything, []entities.SystemMetric{
+		{HostID: 40, Timestamp: 1738368000, CPUUsage: 99.9},
+	}).Return(int64(1), nil).Once()
+
+	result, err := suite.service.Import(context.Background(), &entities.ImportRequest{Path: "year=2025"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &entities.ImportResult{
+		Files: []string{
+			"year=2025/month=01/metrics-1738364400-1738364460.parquet",
+			"year=2025/month=02/metrics-1738368000-1738368000.parquet",
+		},
+		Rows:         3,
+		Inserted:     2,
+		Skipped:      1,
+		HostsCreated: 1,
+	}, result)
+}
+
+// TestImportWithoutReceivedAt tests that files archived before receive times were
+// recorded can still be imported
+func (suite *ArchiveServiceTestSuite) TestImportWithoutReceivedAt() {
+	file, err := os.Create(filepath.Join(suite.dir, "old.parquet"))
+	suite.Require().NoError(err)
+	writer, err := parquet.NewWriter(file, archiveColumns[:len(archiveColumns)-1])
+	suite.Require().NoError(err)
+	suite.Require().NoError(writer.Write(parquet.Row{
+		int64(7), int64(1), "pi-01", "192.168.0.10", "web", int64(1738364400),
+		12.5, 0.0, int64(0), int64(0), int64(0), 0.0, int64(0), int64(0), int64(0),
+	}))
+	suite.Require().NoError(writer.Close())
+	suite.Require().NoError(file.Close())
+
+	suite.mockHostRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Hostname: "pi-01"}).Return([]entities.Host{{ID: 40, Hostname: "pi-01"}}, nil).Once()
+	suite.mockMetricRepo.On("InsertMissing", mock.Anything, []entities.SystemMetric{
+		{HostID: 40, Timestamp: 1738364400, CPUUsage: 12.5},
+	}).Return(int64(1), nil).Once()
+
+	result, err := suite.service.Import(context.Background(), &entities.ImportRequest{Path: "old.parquet"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), result.Inserted)
+}
+
+// TestImportNullValue tests that a file with a null value, written by DuckDB, is
+// rejected as invalid
+func (suite *ArchiveServiceTestSuite) TestImportNullValue() {
+	data, err := os.ReadFile("../../pkg/parquet/testdata/duckdb_metrics_null.parquet")
+	suite.Require().NoError(err)
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "duckdb.parquet"), data, 0o644))
+
+	// The rows before the null are read first
+	suite.mockHostRepo.On("FindByFilters", mock.Anything, mock.Anything).Return([]entities.Host{{ID: 40}}, nil).Times(3)
+
+	result, err := suite.service.Import(context.Background(), &entities.ImportRequest{Path: "duckdb.parquet"})
+
+	assert.Nil(suite.T(), result)
+	assert.ErrorIs(suite.T(), err, ErrInvalidArchiveFile)
+	assert.ErrorContains(suite.T(), err, `"cpu_usage"`)
+}
+
+// TestImportErrors tests path validation and unreadable files
+func (suite *ArchiveServiceTestSuite) TestImportErrors() {
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "broken.parquet"), []byte("not parquet"), 0o644))
+	suite.Require().NoError(os.Mkdir(filepath.Join(suite.dir, "empty"), 0o755))
+
+	tests := []struct {
+		name          string
+		service       *ArchiveService
+		path          string
+		expectedError error
+	}{
+		{name: "disabled", service: NewArchiveService(suite.mockMetricRepo, suite.mockHostRepo, "", nil), path: "x", expectedError: ErrArchiveDisabled},
+		{name: "empty_path", service: suite.service, path: "", expectedError: ErrInvalidArchivePath},
+		{name: "parent_directory", service: suite.service, path: "../secrets", expectedError: ErrInvalidArchivePath},
+		{name: "absolute_path", service: suite.service, path: "/etc/passwd", expectedError: ErrInvalidArchivePath},
+		{name: "missing_file", service: suite.service, path: "year=1999", expectedError: ErrArchiveNotFound},
+		{name: "empty_directory", service: suite.service, path: "empty", expectedError: ErrArchiveNotFound},
+		{name: "corrupt_file", service: suite.service, path: "broken.parquet", expectedError: ErrInvalidArchiveFile},
+		{name: "low_disk_space", service: suite.lowDiskService(), path: "broken.parquet", expectedError: ErrLowDiskSpace},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			result, err := test.service.Import(context.Background(), &entities.ImportRequest{Path: test.path})
+			assert.Nil(suite.T(), result)
+			assert.ErrorIs(suite.T(), err, test.expectedError)
+		})
+	}
+}
+
+// lowDiskService returns an archive service whose volume is below the free space threshold
+func (suite *ArchiveServiceTestSuite) lowDiskService() *ArchiveService {
+	healthRepo := new(mocks.MockHealthRepository)
+	healthRepo.On("GetStorageStats", mock.Anything).Return(&entities.StorageStats{
+		Volume: &entities.VolumeStats{Path: suite.dir, FreeBytes: 1 << 20, TotalBytes: 1 << 30},
+	}, nil)
+	storage := NewStorageMonitor(healthRepo, 512<<20, true, nil)
+	_, err := storage.Check(context.Background())
+	suite.Require().Error(err)
+
+	return NewArchiveService(suite.mockMetricRepo, suite.mockHostRepo, suite.dir, storage)
+}
+
+// Run the test suite
+func TestArchiveServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveServiceTestSuite))
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+)
+
+// Backup files are named by their UTC creation time so they sort chronologically
+const (
+	backupPrefix     = "monitor-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+type BackupService struct {
+	repo    repository.BackupRepositoryInterface
+	dir     string
+	keep    int
+	running sync.Mutex
+	now     func() time.Time
+}
+
+// NewBackupService creates a BackupService that writes snapshots to dir and keeps the
+// newest keep of them. An empty dir disables backups; keep <= 0 keeps every backup.
+func NewBackupService(repo repository.BackupRepositoryInterface, dir string, keep int) *BackupService {
+	return &BackupService{repo: repo, dir: dir, keep: keep, now: time.Now}
+}
+
+// CreateBackup takes a consistent snapshot of the live database, then removes the
+// oldest backups beyond the retention limit
+func (service *BackupService) CreateBackup(ctx context.Context) (*entities.Backup, error) {
+	if service.dir == "" {
+		return nil, ErrBackupDisabled
+	}
+
+	if !service.running.TryLock() {
+		return nil, ErrBackupInProgress
+	}
+	defer service.running.Unlock()
+
+	if err := os.MkdirAll(service.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	createdAt := service.now().UTC()
+	name := backupPrefix + createdAt.Format(backupTimeFormat) + backupSuffix
+	target := filepath.Join(service.dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBackupExists, name)
+	}
+
+	// Snapshot under a temporary name so a partial file is never mistaken for a backup
+	staged := target + ".tmp"
+	_ = os.Remove(staged)
+	if err := service.repo.Snapshot(ctx, staged); err != nil {
+		_ = os.Remove(staged)
+		return nil, err
+	}
+	if err := os.Rename(staged, target); err != nil {
+		_ = os.Remove(staged)
+		return nil, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.rotate(); err != nil {
+		slog.WarnContext(ctx, "failed to remove old backups", "error", err)
+	}
+
+	return &entities.Backup{Name: name, SizeBytes: info.Size(), CreatedAt: createdAt.Unix()}, nil
+}
+
+// ListBackups returns the backups in the backup directory, newest first
+func (service *BackupService) ListBackups() ([]entities.Backup, error) {
+	if service.dir == "" {
+		return nil, ErrBackupDisabled
+	}
+
+	entries, err := os.ReadDir(service.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []entities.Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []entities.Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+
+		createdAt, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		backups = append(backups, entities.Backup{Name: name, SizeBytes: info.Size(), CreatedAt: createdAt.Unix()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt > backups[j].CreatedAt
+	})
+
+	return backups, nil
+}
+
+// rotate removes the oldest backups beyond the retention limit
+func (service *BackupService) rotate() error {
+	if service.keep <= 0 {
+		return nil
+	}
+
+	backups, err := service.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range backups[min(service.keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(service.dir, backup.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackupScheduler takes backups on a fixed interval
+type BackupScheduler struct {
+	service     BackupServiceInterface
+	interval    time.Duration
+	instruments *telemetry.Metrics
+
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewBackupScheduler(service BackupServiceInterface, interval time.Duration, instruments *telemetry.Metrics) *BackupScheduler {
+	return &BackupScheduler{service: service, interval: interval, instruments: instruments}
+}
+
+// Start launches the backup loop in the background
+func (scheduler *BackupScheduler) Start(ctx context.Context) {
+	ctx, scheduler.cancel = context.WithCancel(ctx)
+
+	scheduler.wg.Add(1)
+	go func() {
+		defer scheduler.wg.Done()
+
+		ticker := time.NewTicker(scheduler.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Stop waits for a backup under way rather than cancelling it
+				start := time.Now()
+				backup, err := scheduler.service.CreateBackup(context.WithoutCancel(ctx))
+				scheduler.record(start, err)
+				if err != nil {
+					scheduler.instruments.ObserveJob("backup", telemetry.OutcomeError, time.Since(start))
+					slog.ErrorContext(ctx, "scheduled backup failed", "error", err)
+					continue
+				}
+				scheduler.instruments.ObserveJob("backup", telemetry.OutcomeOK, time.Since(start))
+				slog.InfoContext(ctx, "scheduled backup written", "name", backup.Name, "size_bytes", backup.SizeBytes)
+			}
+		}
+	}()
+}
+
+// record keeps the outcome of the latest backup for the health check
+func (scheduler *BackupScheduler) record(start time.Time, err error) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.lastRun = start
+	scheduler.lastErr = err
+}
+
+// Check reports whether the latest scheduled backup failed, for the health checks
+func (scheduler *BackupScheduler) Check(ctx context.Context) (map[string]interface{}, error) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	details := map[string]interface{}{"interval": scheduler.interval.String()}
+	if scheduler.lastRun.IsZero() {
+		return details, nil
+	}
+	details["last_run"] = scheduler.lastRun.UTC().Format(time.RFC3339)
+	if scheduler.lastErr != nil {
+		return details, fmt.Errorf("last scheduled backup failed: %w", scheduler.lastErr)
+	}
+	return details, nil
+}
+
+// Stop cancels the backup loop and waits for a running backup to finish
+func (scheduler *BackupScheduler) Stop() {
+	if scheduler.cancel != nil {
+		scheduler.cancel()
+	}
+	scheduler.wg.Wait()
+}
@@ -0,0 +1,181 @@
+// nolint
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// BackupServiceTestSuite is the test suite for BackupService
+type BackupServiceTestSuite struct {
+	suite.Suite
+	mockRepo *mocks.MockBackupRepository
+	dir      string
+	clock    time.Time
+	service  *BackupService
+}
+
+// SetupTest runs before each test in the suite
+func (suite *BackupServiceTestSuite) SetupTest() {
+	suite.mockRepo = new(mocks.MockBackupRepository)
+	suite.dir = filepath.Join(suite.T().TempDir(), "backups")
+	suite.clock = time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+	suite.service = NewBackupService(suite.mockRepo, suite.dir, 2)
+	suite.service.now = func() time.Time { return suite.clock }
+}
+
+// TearDownTest runs after each test
+func (suite *BackupServiceTestSuite) TearDownTest() {
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+// expectSnapshot makes the mock write a small file where the snapshot is requested
+func (suite *BackupServiceTestSuite) expectSnapshot(name string) {
+	suite.mockRepo.On("Snapshot", mock.Anything, filepath.Join(suite.dir, name+".tmp")).Run(func(args mock.Arguments) {
+		suite.Require().NoError(os.WriteFile(args.String(1), []byte("SQLite format 3"), 0o644))
+	}).Return(nil).Once()
+}
+
+// TestCreateBackup tests snapshot naming and the returned metadata
+func (suite *BackupServiceTestSuite) TestCreateBackup() {
+	suite.expectSnapshot("monitor-20251018T120000Z.db")
+
+	backup, err := suite.service.CreateBackup(context.Background())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &entities.Backup{
+		Name:      "monitor-20251018T120000Z.db",
+		SizeBytes: 15,
+		CreatedAt: suite.clock.Unix(),
+	}, backup)
+	assert.FileExists(suite.T(), filepath.Join(suite.dir, backup.Name))
+	assert.NoFileExists(suite.T(), filepath.Join(suite.dir, backup.Name+".tmp"))
+}
+
+// TestCreateBackupRotates tests that only the newest backups are kept
+func (suite *BackupServiceTestSuite) TestCreateBackupRotates() {
+	for _, hour := range []int{12, 13, 14} {
+		suite.clock = time.Date(2025, 10, 18, hour, 0, 0, 0, time.UTC)
+		suite.expectSnapshot(backupPrefix + suite.clock.Format(backupTimeFormat) + backupSuffix)
+		_, err := suite.service.CreateBackup(context.Background())
+		assert.NoError(suite.T(), err)
+	}
+
+	// Files that are not backups are left alone
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "notes.txt"), []byte("keep"), 0o644))
+
+	backups, err := suite.service.ListBackups()
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), backups, 2)
+	assert.Equal(suite.T(), "monitor-20251018T140000Z.db", backups[0].Name)
+	assert.Equal(suite.T(), "monitor-20251018T130000Z.db", backups[1].Name)
+	assert.FileExists(suite.T(), filepath.Join(suite.dir, "notes.txt"))
+}
+
+// TestCreateBackupErrors tests disabled backups, snapshot failures and name clashes
+func (suite *BackupServiceTestSuite) TestCreateBackupErrors() {
+	tests := []struct {
+		name          string
+		setup         func()
+		expectedError error
+	}{
+		{
+			name: "disabled",
+			setup: func() {
+				suite.service = NewBackupService(suite.mockRepo, "", 2)
+			},
+			expectedError: ErrBackupDisabled,
+		},
+		{
+			name: "snapshot_error",
+			setup: func() {
+				suite.mockRepo.On("Snapshot", mock.Anything, mock.Anything).Return(errors.New("disk I/O error")).Once()
+			},
+			expectedError: errors.New("disk I/O error"),
+		},
+		{
+			name: "backup_exists",
+			setup: func() {
+				suite.Require().NoError(os.MkdirAll(suite.dir, 0o755))
+				suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "monitor-20251018T120000Z.db"), nil, 0o644))
+			},
+			expectedError: ErrBackupExists,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setup()
+
+			backup, err := suite.service.CreateBackup(context.Background())
+
+			assert.Nil(suite.T(), backup)
+			assert.Error(suite.T(), err)
+			assert.Contains(suite.T(), err.Error(), test.expectedError.Error())
+		})
+	}
+}
+
+// TestListBackupsWithoutDirectory tests listing before the first backup is taken
+func (suite *BackupServiceTestSuite) TestListBackupsWithoutDirectory() {
+	backups, err := suite.service.ListBackups()
+
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), backups)
+}
+
+// TestBackupScheduler tests that scheduled backups run until the scheduler stops
+func (suite *BackupServiceTestSuite) TestBackupScheduler() {
+	mockService := new(mocks.MockBackupService)
+	called := make(chan struct{}, 10)
+	mockService.On("CreateBackup", mock.Anything).Run(func(mock.Arguments) {
+		called <- struct{}{}
+	}).Return(&entities.Backup{Name: "monitor-20251018T120000Z.db"}, nil)
+
+	scheduler := NewBackupScheduler(mockService, 5*time.Millisecond, nil)
+	scheduler.Start(context.Background())
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		suite.Fail("scheduled backup did not run")
+	}
+	scheduler.Stop()
+}
+
+// TestBackupSchedulerCheck tests that a failed scheduled backup fails the health check
+func (suite *BackupServiceTestSuite) TestBackupSchedulerCheck() {
+	scheduler := NewBackupScheduler(new(mocks.MockBackupService), 6*time.Hour, nil)
+
+	details, err := scheduler.Check(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]interface{}{"interval": "6h0m0s"}, details)
+
+	scheduler.record(time.Now(), errors.New("disk full"))
+	details, err = scheduler.Check(context.Background())
+	assert.EqualError(suite.T(), err, "last scheduled backup failed: disk full")
+	assert.Contains(suite.T(), details, "last_run")
+
+	scheduler.record(time.Now(), nil)
+	_, err = scheduler.Check(context.Background())
+	assert.NoError(suite.T(), err)
+}
+
+// Run the test suite
+func TestBackupServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupServiceTestSuite))
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+const (
+	// clockSkewSamples is how many of a host's most recent metrics its skew is estimated from
+	clockSkewSamples = 16
+
+	// maxClockSkewHosts bounds how many hosts are tracked. Timestamps are observed before
+	// the host is known to exist, so made-up host IDs must not grow the tracker forever.
+	maxClockSkewHosts = 1024
+)
+
+// ClockSkewTracker estimates how far each host's clock is from the API's. A metric
+// reaches the API some time after it was taken, so its timestamp less its receive time
+// is the skew less however long delivery took. The largest of these among a host's
+// recent metrics was delayed least, and is taken as its skew. Estimates are kept in
+// memory and start again when the API restarts. Once limit hosts are tracked, the one
+// observed least recently makes way for a new one.
+type ClockSkewTracker struct {
+	mu       sync.Mutex
+	hosts    map[int64]*clockSamples
+	limit    int
+	sequence uint64 // counts observations, ordering hosts by when they were last seen
+}
+
+// clockSamples is a ring of a host's most recent offsets, timestamp minus receive time
+type clockSamples struct {
+	offsets    [clockSkewSamples]int64
+	count      int
+	next       int
+	observedAt int64
+	sequence   uint64
+}
+
+// NewClockSkewTracker creates an empty ClockSkewTracker
+func NewClockSkewTracker() *ClockSkewTracker {
+	return &ClockSkewTracker{hosts: make(map[int64]*clockSamples), limit: maxClockSkewHosts}
+}
+
+// Observe records a metric a host timestamped by its own clock and the API received
+// at receivedAt by its clock, both in Unix seconds
+func (tracker *ClockSkewTracker) Observe(hostID, timestamp, receivedAt int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	samples, ok := tracker.hosts[hostID]
+	if !ok {
+		if len(tracker.hosts) >= tracker.limit {
+			tracker.evictOldest()
+		}
+		samples = &clockSamples{}
+		tracker.hosts[hostID] = samples
+	}
+	tracker.sequence++
+	samples.sequence = tracker.sequence
+	samples.offsets[samples.next] = timestamp - receivedAt
+	samples.next = (samples.next + 1) % clockSkewSamples
+	samples.count = min(samples.count+1, clockSkewSamples)
+	samples.observedAt = receivedAt
+}
+
+// Skew returns the host's estimated clock skew, or nil before it has sent a timestamp
+func (tracker *ClockSkewTracker) Skew(hostID int64) *entities.ClockSkew {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	samples, ok := tracker.hosts[hostID]
+	if !ok {
+		return nil
+	}
+
+	skew := samples.offsets[0]
+	for _, offset := range samples.offsets[1:samples.count] {
+		skew = max(skew, offset)
+	}
+	return &entities.ClockSkew{Seconds: skew, Samples: samples.count, ObservedAt: samples.observedAt}
+}
+
+// evictOldest drops the host observed least recently
+func (tracker *ClockSkewTracker) evictOldest() {
+	oldest, oldestSequence := int64(0), uint64(0)
+	for hostID, samples := range tracker.hosts {
+		if oldestSequence == 0 || samples.sequence < oldestSequence {
+			oldest, oldestSequence = hostID, samples.sequence
+		}
+	}
+	delete(tracker.hosts, oldest)
+}
+
+// Forget drops the samples of a deleted host
+func (tracker *ClockSkewTracker) Forget(hostID int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	delete(tracker.hosts, hostID)
+}
@@ -0,0 +1,92 @@
+// nolint
+package services
+
+import (
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// ClockSkewTrackerTestSuite is the test suite for ClockSkewTracker
+type ClockSkewTrackerTestSuite struct {
+	suite.Suite
+	tracker *ClockSkewTracker
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ClockSkewTrackerTestSuite) SetupTest() {
+	suite.tracker = NewClockSkewTracker()
+}
+
+// TestSkew tests that the least delayed of a host's recent metrics sets its skew
+func (suite *ClockSkewTrackerTestSuite) TestSkew() {
+	assert.Nil(suite.T(), suite.tracker.Skew(1))
+
+	// A host 30s ahead whose metrics took between 0 and 4 seconds to arrive
+	suite.tracker.Observe(1, 1030, 1004)
+	suite.tracker.Observe(1, 1040, 1010)
+	suite.tracker.Observe(1, 1050, 1021)
+	suite.tracker.Observe(2, 990, 1021)
+
+	assert.Equal(suite.T(), &entities.ClockSkew{Seconds: 30, Samples: 3, ObservedAt: 1021}, suite.tracker.Skew(1))
+	assert.Equal(suite.T(), &entities.ClockSkew{Seconds: -31, Samples: 1, ObservedAt: 1021}, suite.tracker.Skew(2))
+}
+
+// TestRecentSamples tests that only the most recent samples count, so a corrected clock
+// shows up once the old samples have been replaced
+func (suite *ClockSkewTrackerTestSuite) TestRecentSamples() {
+	// Booted without a clock, then synced
+	suite.tracker.Observe(1, 1000, 1000)
+	for i := int64(1); i < clockSkewSamples; i++ {
+		suite.tracker.Observe(1, 1000+i, 1000+i)
+	}
+	suite.tracker.Observe(1, 0, 1100)
+
+	skew := suite.tracker.Skew(1)
+	assert.Equal(suite.T(), int64(0), skew.Seconds)
+	assert.Equal(suite.T(), clockSkewSamples, skew.Samples)
+
+	// Every sample is now from the unsynced clock
+	for i := int64(0); i < clockSkewSamples; i++ {
+		suite.tracker.Observe(1, 60+i, 1200+i)
+	}
+	assert.Equal(suite.T(), int64(-1140), suite.tracker.Skew(1).Seconds)
+}
+
+// TestForget tests that a deleted host's samples are dropped
+func (suite *ClockSkewTrackerTestSuite) TestForget() {
+	suite.tracker.Observe(1, 1000, 1002)
+	suite.tracker.Forget(1)
+
+	assert.Nil(suite.T(), suite.tracker.Skew(1))
+}
+
+// TestLimit tests that made-up host IDs can't grow the tracker past its limit, and that
+// the host observed least recently is the one dropped
+func (suite *ClockSkewTrackerTestSuite) TestLimit() {
+	suite.tracker.limit = 3
+
+	suite.tracker.Observe(1, 1000, 1000)
+	suite.tracker.Observe(2, 1000, 1000)
+	suite.tracker.Observe(3, 1000, 1000)
+	suite.tracker.Observe(1, 1010, 1010)
+	for hostID := int64(100); hostID < 200; hostID++ {
+		suite.tracker.Observe(hostID, 1020, 1020)
+		suite.tracker.Observe(1, 1020, 1020)
+	}
+
+	assert.Len(suite.T(), suite.tracker.hosts, 3)
+	assert.NotNil(suite.T(), suite.tracker.Skew(1))
+	assert.Nil(suite.T(), suite.tracker.Skew(2))
+	assert.Nil(suite.T(), suite.tracker.Skew(3))
+	assert.Nil(suite.T(), suite.tracker.Skew(197))
+	assert.NotNil(suite.T(), suite.tracker.Skew(199))
+	assert.NotNil(suite.T(), suite.tracker.Skew(198))
+}
+
+// Run the test suite
+func TestClockSkewTrackerTestSuite(t *testing.T) {
+	suite.Run(t, new(ClockSkewTrackerTestSuite))
+}
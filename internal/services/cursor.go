@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// Cursors are opaque to clients: the base64url encoding of the sort key of the
+// last item on the previous page.
+
+func encodeMetricCursor(metric entities.SystemMetric) string {
+	key := fmt.Sprintf("%d:%d", metric.Timestamp, metric.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeMetricCursor(cursor string) (*entities.MetricCursor, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	timestamp, id, found := strings.Cut(string(key), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	var position entities.MetricCursor
+	if position.Timestamp, err = strconv.ParseInt(timestamp, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if position.ID, err = strconv.ParseInt(id, 10, 64); err != nil || position.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &position, nil
+}
+
+func encodeHostCursor(host entities.Host) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(host.ID, 10)))
+}
+
+func decodeHostCursor(cursor string) (int64, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(key), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
@@ -10,11 +10,55 @@ var (
 	ErrDuplicateHost   = errors.New("host already exists")
 
 	// Metric service errors
+	ErrInvalidMetric      = errors.New("invalid metric")
 	ErrInvalidHostID      = errors.New("invalid host ID")
 	ErrInvalidCPUUsage    = errors.New("CPU usage must be between 0 and 100")
 	ErrInvalidMemoryUsage = errors.New("memory usage must be between 0 and 100")
 	ErrInvalidDiskUsage   = errors.New("disk usage must be between 0 and 100")
+	ErrNegativeByteCount  = errors.New("byte counts must not be negative")
+	ErrInconsistentMemory = errors.New("memory usage does not match the memory byte counts")
+	ErrInconsistentDisk   = errors.New("disk usage does not match the disk byte counts")
 	ErrNilQueryParams     = errors.New("query parameters cannot be nil")
 	ErrMetricNotFound     = errors.New("metric not found")
 	ErrInvalidTimeRange   = errors.New("invalid time range")
+	ErrDuplicateMetric    = errors.New("a metric for this host and timestamp is already stored")
+	ErrTimestampTooOld    = errors.New("timestamp is too far in the past")
+	ErrTimestampTooNew    = errors.New("timestamp is too far in the future")
+
+	// Pagination errors
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+	// Archive service errors
+	ErrArchiveDisabled    = errors.New("archiving is disabled, set ARCHIVE_DIR to enable it")
+	ErrArchiveInProgress  = errors.New("an archive or import is already running")
+	ErrArchiveExists      = errors.New("archive file already exists")
+	ErrArchiveNotFound    = errors.New("archive file not found")
+	ErrInvalidArchivePath = errors.New("archive path must be relative to the archive directory")
+	ErrInvalidArchiveFile = errors.New("invalid archive file")
+
+	// Maintenance service errors
+	ErrMaintenanceDisabled    = errors.New("maintenance only runs with SQLite; PostgreSQL runs autovacuum itself")
+	ErrMaintenanceInProgress  = errors.New("maintenance is already running")
+	ErrInvalidMaintenanceTask = errors.New("maintenance task must be incremental_vacuum, analyze, optimize or integrity_check")
+	ErrNoMaintenanceRun       = errors.New("maintenance has not run yet")
+
+	// Ingest queue errors
+	ErrIngestQueueFull = errors.New("ingest queue is full, retry shortly")
+
+	// Storage errors
+	ErrLowDiskSpace = errors.New("paused until there is more free disk space")
+
+	// Backup service errors
+	ErrBackupDisabled   = errors.New("backups are disabled, set BACKUP_DIR to enable them")
+	ErrBackupInProgress = errors.New("a backup is already running")
+	ErrBackupExists     = errors.New("backup file already exists")
+
+	// Scrape target service errors
+	ErrScrapeTargetNotFound   = errors.New("scrape target not found")
+	ErrInvalidScrapeURL       = errors.New("scrape URL must be an absolute http or https URL")
+	ErrInvalidScrapeFormat    = errors.New("scrape format must be 'prometheus' or 'json'")
+	ErrInvalidScrapeInterval  = errors.New("scrape interval must be between 5 and 86400 seconds")
+	ErrInvalidScrapeTimeout   = errors.New("scrape timeout must be positive and no longer than the interval")
+	ErrInvalidScrapeJSONField = errors.New("JSON mapping contains an unknown metric field")
+	ErrMissingScrapeJSONPath  = errors.New("JSON format requires a mapping with at least one field")
 )
@@ -1,51 +1,171 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/repository"
 )
 
+// healthCheckTimeout bounds each check, so one hung subsystem can't stall a probe
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheckFunc reports on a subsystem. It returns an error when the subsystem is
+// failing, and optionally details to include in the detailed health response.
+type HealthCheckFunc func(ctx context.Context) (map[string]interface{}, error)
+
+type healthCheck struct {
+	name     string
+	severity string
+	check    HealthCheckFunc
+}
+
+// statusRank orders health statuses from best to worst
+var statusRank = map[string]int{
+	entities.HealthStatusHealthy:   0,
+	entities.HealthStatusDegraded:  1,
+	entities.HealthStatusUnhealthy: 2,
+}
+
 type HealthService struct {
-	repo repository.HealthRepositoryInterface
+	repo    repository.HealthRepositoryInterface
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []healthCheck
 }
 
+// NewHealthService builds a health service with the database check registered
 func NewHealthService(repo repository.HealthRepositoryInterface) *HealthService {
-	return &HealthService{repo: repo}
+	service := &HealthService{repo: repo, timeout: healthCheckTimeout}
+	service.Register("database", entities.SeverityCritical, func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, repo.CheckDatabaseConnection(ctx)
+	})
+	return service
+}
+
+// Register adds a health check. A failing critical check makes the API unready; a
+// failing warning check reports it as degraded.
+func (service *HealthService) Register(name, severity string, check HealthCheckFunc) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	service.checks = append(service.checks, healthCheck{name: name, severity: severity, check: check})
 }
 
 // CheckHealth performs basic health checks
-func (service *HealthService) CheckHealth() error {
+func (service *HealthService) CheckHealth(ctx context.Context) error {
 	// Check database connectivity
-	return service.repo.CheckDatabaseConnection()
+	return service.repo.CheckDatabaseConnection(ctx)
 }
 
-// GetDetailedHealth returns detailed health information
-func (service *HealthService) GetDetailedHealth() (map[string]interface{}, error) {
-	health := make(map[string]interface{})
+// CheckReadiness runs every registered check concurrently
+func (service *HealthService) CheckReadiness(ctx context.Context) *entities.HealthReport {
+	service.mu.RLock()
+	checks := append([]healthCheck(nil), service.checks...)
+	service.mu.RUnlock()
+
+	results := make([]entities.HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, check, service.timeout)
+		}()
+	}
+	wg.Wait()
 
-	// Check database
-	if err := service.repo.CheckDatabaseConnection(); err != nil {
-		health["database"] = map[string]interface{}{
-			"status": "unhealthy",
-			"error":  err.Error(),
+	report := &entities.HealthReport{Status: entities.HealthStatusHealthy, Checks: results}
+	for _, result := range results {
+		if statusRank[result.Status] > statusRank[report.Status] {
+			report.Status = result.Status
 		}
-		return health, err
 	}
+	return report
+}
 
-	health["database"] = map[string]interface{}{
-		"status": "healthy",
+// GetDetailedHealth returns every check's result with database statistics
+func (service *HealthService) GetDetailedHealth(ctx context.Context) *entities.DetailedHealth {
+	health := &entities.DetailedHealth{HealthReport: *service.CheckReadiness(ctx)}
+
+	// Statistics are best effort; a failing database already shows in the checks
+	if stats, err := service.repo.GetDatabaseStats(); err == nil {
+		health.DatabaseStats = stats
+	}
+	if settings, err := service.repo.GetDatabaseSettings(ctx); err == nil {
+		health.DatabaseSettings = settings
+	}
+	if counts, err := service.repo.GetTableCounts(ctx); err == nil {
+		health.TableCounts = counts
 	}
 
-	// Get database stats
-	stats, err := service.repo.GetDatabaseStats()
-	if err == nil {
-		health["database_stats"] = stats
+	return health
+}
+
+// runHealthCheck runs a check with a timeout and records how it went
+func runHealthCheck(ctx context.Context, check healthCheck, timeout time.Duration) entities.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.check(ctx)
+
+	result := entities.HealthCheckResult{
+		Name:       check.name,
+		Severity:   check.severity,
+		Status:     entities.HealthStatusHealthy,
+		DurationMs: durationMs(time.Since(start)),
+		Details:    details,
+	}
+	if err != nil {
+		result.Status = entities.HealthStatusUnhealthy
+		if check.severity == entities.SeverityWarning {
+			result.Status = entities.HealthStatusDegraded
+		}
+		result.Error = err.Error()
 	}
+	return result
+}
+
+// MigrationCheck fails unless the schema is at the version this build migrates to
+func MigrationCheck(repo repository.HealthRepositoryInterface, latest int) HealthCheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		version, err := repo.GetSchemaVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	// Get table counts
-	counts, err := service.repo.GetTableCounts()
-	if err == nil {
-		health["table_counts"] = counts
+		details := map[string]interface{}{"version": version, "expected": latest}
+		if version != latest {
+			return details, fmt.Errorf("schema is at version %d, expected %d", version, latest)
+		}
+		return details, nil
 	}
+}
+
+// IngestLagCheck fails when the newest metric is older than maxLag, which usually means
+// agents have stopped reporting. A database without metrics passes.
+func IngestLagCheck(metrics MetricServiceInterface, maxLag time.Duration) HealthCheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		latest, err := metrics.GetLatestMetric(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return map[string]interface{}{"latest_timestamp": nil}, nil
+		}
 
-	return health, nil
+		lag := time.Since(time.Unix(latest.Timestamp, 0)).Truncate(time.Second)
+		details := map[string]interface{}{
+			"latest_timestamp": latest.Timestamp,
+			"lag_seconds":      int64(lag.Seconds()),
+		}
+		if lag > maxLag {
+			return details, fmt.Errorf("no metrics received for %s", lag)
+		}
+		return details, nil
+	}
 }
@@ -2,11 +2,15 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -45,7 +49,7 @@ func (suite *HealthServiceTestSuite) TestCheckHealth() {
 		{
 			name: "successful_health_check",
 			setupMock: func() {
-				suite.mockRepo.On("CheckDatabaseConnection").Return(nil).Once()
+				suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(nil).Once()
 			},
 			expectedError: nil,
 			description:   "Should return nil when database connection is healthy",
@@ -53,7 +57,7 @@ func (suite *HealthServiceTestSuite) TestCheckHealth() {
 		{
 			name: "failed_health_check",
 			setupMock: func() {
-				suite.mockRepo.On("CheckDatabaseConnection").Return(errors.New("connection refused")).Once()
+				suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(errors.New("connection refused")).Once()
 			},
 			expectedError: errors.New("connection refused"),
 			description:   "Should return error when database connection fails",
@@ -64,7 +68,7 @@ func (suite *HealthServiceTestSuite) TestCheckHealth() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.service.CheckHealth()
+			err := suite.service.CheckHealth(context.Background())
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -80,146 +84,69 @@ func (suite *HealthServiceTestSuite) TestCheckHealth() {
 	}
 }
 
-// TestGetDetailedHealth tests the GetDetailedHealth method
-func (suite *HealthServiceTestSuite) TestGetDetailedHealth() {
+// TestCheckReadiness tests that the report takes the worst status of its checks
+func (suite *HealthServiceTestSuite) TestCheckReadiness() {
+	failing := func(context.Context) (map[string]interface{}, error) { return nil, errors.New("stalled") }
+	passing := func(context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"in_flight": 2}, nil
+	}
+
 	tests := []struct {
 		name           string
-		setupMock      func()
-		expectedResult map[string]interface{}
-		expectedError  error
-		validateResult func(result map[string]interface{})
-		description    string
+		databaseErr    error
+		register       func(service *HealthService)
+		expectedStatus string
+		validateChecks func(checks []entities.HealthCheckResult)
 	}{
 		{
-			name: "all_checks_successful",
-			setupMock: func() {
-				// Database connection check succeeds
-				suite.mockRepo.On("CheckDatabaseConnection").Return(nil).Once()
-
-				// Database stats
-				stats := map[string]interface{}{
-					"open_connections": 5,
-					"in_use":           2,
-					"idle":             3,
-					"max_open":         10,
-				}
-				suite.mockRepo.On("GetDatabaseStats").Return(stats, nil).Once()
-
-				// Table counts
-				counts := map[string]int{
-					"hosts":   10,
-					"metrics": 1000,
-				}
-				suite.mockRepo.On("GetTableCounts").Return(counts, nil).Once()
-			},
-			expectedError: nil,
-			validateResult: func(result map[string]interface{}) {
-				// Check database status
-				dbStatus, ok := result["database"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), "healthy", dbStatus["status"])
-
-				// Check database stats
-				dbStats, ok := result["database_stats"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), 5, dbStats["open_connections"])
-				assert.Equal(suite.T(), 2, dbStats["in_use"])
-
-				// Check table counts
-				tableCounts, ok := result["table_counts"].(map[string]int)
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), 10, tableCounts["hosts"])
-				assert.Equal(suite.T(), 1000, tableCounts["metrics"])
-			},
-			description: "All checks successful",
-		},
-		{
-			name: "database_connection_failed",
-			setupMock: func() {
-				suite.mockRepo.On("CheckDatabaseConnection").Return(errors.New("connection timeout")).Once()
+			name: "all_healthy",
+			register: func(service *HealthService) {
+				service.Register("scraper", entities.SeverityWarning, passing)
 			},
-			expectedError: errors.New("connection timeout"),
-			validateResult: func(result map[string]interface{}) {
-				dbStatus, ok := result["database"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), "unhealthy", dbStatus["status"])
-				assert.Equal(suite.T(), "connection timeout", dbStatus["error"])
+			expectedStatus: entities.HealthStatusHealthy,
+			validateChecks: func(checks []entities.HealthCheckResult) {
+				assert.Len(suite.T(), checks, 2)
+				assert.Equal(suite.T(), "database", checks[0].Name)
+				assert.Equal(suite.T(), entities.SeverityCritical, checks[0].Severity)
+				assert.Equal(suite.T(), "scraper", checks[1].Name)
+				assert.Equal(suite.T(), map[string]interface{}{"in_flight": 2}, checks[1].Details)
 			},
-			description: "Database connection fails",
 		},
 		{
-			name: "database_stats_failed",
-			setupMock: func() {
-				suite.mockRepo.On("CheckDatabaseConnection").Return(nil).Once()
-				suite.mockRepo.On("GetDatabaseStats").Return(nil, errors.New("stats error")).Once()
-
-				counts := map[string]int{"hosts": 5, "metrics": 500}
-				suite.mockRepo.On("GetTableCounts").Return(counts, nil).Once()
+			name: "failing_warning_degrades",
+			register: func(service *HealthService) {
+				service.Register("scraper", entities.SeverityWarning, failing)
 			},
-			expectedError: nil,
-			validateResult: func(result map[string]interface{}) {
-				// Database should be healthy
-				dbStatus, ok := result["database"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), "healthy", dbStatus["status"])
-
-				// Stats should not be present
-				_, ok = result["database_stats"]
-				assert.False(suite.T(), ok)
-
-				// Table counts should still be present
-				tableCounts, ok := result["table_counts"].(map[string]int)
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), 5, tableCounts["hosts"])
+			expectedStatus: entities.HealthStatusDegraded,
+			validateChecks: func(checks []entities.HealthCheckResult) {
+				assert.Equal(suite.T(), entities.HealthStatusHealthy, checks[0].Status)
+				assert.Equal(suite.T(), entities.HealthStatusDegraded, checks[1].Status)
+				assert.Equal(suite.T(), "stalled", checks[1].Error)
 			},
-			description: "Database stats retrieval fails",
 		},
 		{
-			name: "table_counts_failed",
-			setupMock: func() {
-				suite.mockRepo.On("CheckDatabaseConnection").Return(nil).Once()
-
-				stats := map[string]interface{}{"open_connections": 3}
-				suite.mockRepo.On("GetDatabaseStats").Return(stats, nil).Once()
-
-				suite.mockRepo.On("GetTableCounts").Return(nil, errors.New("count error")).Once()
+			name:        "failing_critical_is_unhealthy",
+			databaseErr: errors.New("connection refused"),
+			register: func(service *HealthService) {
+				service.Register("scraper", entities.SeverityWarning, failing)
 			},
-			expectedError: nil,
-			validateResult: func(result map[string]interface{}) {
-				// Database should be healthy
-				dbStatus, ok := result["database"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), "healthy", dbStatus["status"])
-
-				// Stats should be present
-				dbStats, ok := result["database_stats"].(map[string]interface{})
-				assert.True(suite.T(), ok)
-				assert.Equal(suite.T(), 3, dbStats["open_connections"])
-
-				// Table counts should not be present
-				_, ok = result["table_counts"]
-				assert.False(suite.T(), ok)
+			expectedStatus: entities.HealthStatusUnhealthy,
+			validateChecks: func(checks []entities.HealthCheckResult) {
+				assert.Equal(suite.T(), entities.HealthStatusUnhealthy, checks[0].Status)
+				assert.Equal(suite.T(), "connection refused", checks[0].Error)
 			},
-			description: "Table counts retrieval fails",
 		},
 	}
 
 	for _, test := range tests {
 		suite.Run(test.name, func() {
-			test.setupMock()
+			suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(test.databaseErr).Once()
+			test.register(suite.service)
 
-			result, err := suite.service.GetDetailedHealth()
-
-			if test.expectedError != nil {
-				assert.Error(suite.T(), err)
-				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
-			} else {
-				assert.NoError(suite.T(), err)
-			}
+			report := suite.service.CheckReadiness(context.Background())
 
-			if test.validateResult != nil {
-				test.validateResult(result)
-			}
+			assert.Equal(suite.T(), test.expectedStatus, report.Status)
+			test.validateChecks(report.Checks)
 		})
 
 		// Reset mock for next test
@@ -228,28 +155,112 @@ func (suite *HealthServiceTestSuite) TestGetDetailedHealth() {
 	}
 }
 
-// TestGetDetailedHealth_PartialFailures tests partial failures in detailed health
+// TestCheckReadinessTimeout tests that a hung check fails once its timeout passes
+func (suite *HealthServiceTestSuite) TestCheckReadinessTimeout() {
+	suite.service.timeout = 10 * time.Millisecond
+	suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(nil).Once()
+	suite.service.Register("backups", entities.SeverityWarning, func(ctx context.Context) (map[string]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	report := suite.service.CheckReadiness(context.Background())
+
+	assert.Equal(suite.T(), entities.HealthStatusDegraded, report.Status)
+	assert.Equal(suite.T(), "context deadline exceeded", report.Checks[1].Error)
+}
+
+// TestGetDetailedHealth tests that database statistics are added to the report
+func (suite *HealthServiceTestSuite) TestGetDetailedHealth() {
+	suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("GetDatabaseStats").Return(map[string]interface{}{"open_connections": 5}, nil).Once()
+	suite.mockRepo.On("GetDatabaseSettings", mock.Anything).Return(map[string]interface{}{"journal_mode": "wal"}, nil).Once()
+	suite.mockRepo.On("GetTableCounts", mock.Anything).Return(map[string]int{"hosts": 10, "metrics": 1000}, nil).Once()
+
+	health := suite.service.GetDetailedHealth(context.Background())
+
+	assert.Equal(suite.T(), entities.HealthStatusHealthy, health.Status)
+	assert.Len(suite.T(), health.Checks, 1)
+	assert.Equal(suite.T(), map[string]interface{}{"open_connections": 5}, health.DatabaseStats)
+	assert.Equal(suite.T(), map[string]interface{}{"journal_mode": "wal"}, health.DatabaseSettings)
+	assert.Equal(suite.T(), map[string]int{"hosts": 10, "metrics": 1000}, health.TableCounts)
+}
+
+// TestGetDetailedHealth_PartialFailures tests that statistics that can't be read are left out
 func (suite *HealthServiceTestSuite) TestGetDetailedHealth_PartialFailures() {
-	// This is a focused test for edge cases
-	suite.mockRepo.On("CheckDatabaseConnection").Return(nil).Once()
+	suite.mockRepo.On("CheckDatabaseConnection", mock.Anything).Return(errors.New("connection timeout")).Once()
 	suite.mockRepo.On("GetDatabaseStats").Return(nil, errors.New("stats unavailable")).Once()
-	suite.mockRepo.On("GetTableCounts").Return(nil, errors.New("counts unavailable")).Once()
+	suite.mockRepo.On("GetDatabaseSettings", mock.Anything).Return(nil, errors.New("settings unavailable")).Once()
+	suite.mockRepo.On("GetTableCounts", mock.Anything).Return(nil, errors.New("counts unavailable")).Once()
+
+	health := suite.service.GetDetailedHealth(context.Background())
+
+	assert.Equal(suite.T(), entities.HealthStatusUnhealthy, health.Status)
+	assert.Equal(suite.T(), "connection timeout", health.Checks[0].Error)
+	assert.Nil(suite.T(), health.DatabaseStats)
+	assert.Nil(suite.T(), health.DatabaseSettings)
+	assert.Nil(suite.T(), health.TableCounts)
+}
+
+// TestMigrationCheck tests that the check compares the schema with the expected version
+func (suite *HealthServiceTestSuite) TestMigrationCheck() {
+	tests := []struct {
+		name          string
+		version       int
+		versionErr    error
+		expectedError string
+	}{
+		{name: "current", version: 3},
+		{name: "behind", version: 2, expectedError: "schema is at version 2, expected 3"},
+		{name: "unreadable", versionErr: errors.New("no such table: schema_migrations"), expectedError: "no such table: schema_migrations"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mockRepo.On("GetSchemaVersion", mock.Anything).Return(test.version, test.versionErr).Once()
 
-	result, err := suite.service.GetDetailedHealth()
+			_, err := MigrationCheck(suite.mockRepo, 3)(context.Background())
+
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+			} else {
+				assert.NoError(suite.T(), err)
+			}
+		})
+	}
+}
 
-	// Should not return error for partial failures
-	assert.NoError(suite.T(), err)
+// TestIngestLagCheck tests that the check fails when metrics stop arriving
+func (suite *HealthServiceTestSuite) TestIngestLagCheck() {
+	tests := []struct {
+		name          string
+		latest        *entities.SystemMetric
+		expectedError string
+	}{
+		{name: "recent", latest: &entities.SystemMetric{Timestamp: time.Now().Add(-30 * time.Second).Unix()}},
+		{name: "no_metrics_yet", latest: nil},
+		{name: "stale", latest: &entities.SystemMetric{Timestamp: time.Now().Add(-time.Hour).Unix()}, expectedError: "no metrics received for 1h0m0s"},
+	}
 
-	// Database should still be marked as healthy
-	dbStatus := result["database"].(map[string]interface{})
-	assert.Equal(suite.T(), "healthy", dbStatus["status"])
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			metrics := new(mocks.MockMetricService)
+			if test.latest == nil {
+				metrics.On("GetLatestMetric", mock.Anything, (*int64)(nil)).Return(nil, nil).Once()
+			} else {
+				metrics.On("GetLatestMetric", mock.Anything, (*int64)(nil)).Return(test.latest, nil).Once()
+			}
 
-	// Optional fields should not be present
-	_, hasStats := result["database_stats"]
-	assert.False(suite.T(), hasStats)
+			_, err := IngestLagCheck(metrics, 10*time.Minute)(context.Background())
 
-	_, hasCounts := result["table_counts"]
-	assert.False(suite.T(), hasCounts)
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+			} else {
+				assert.NoError(suite.T(), err)
+			}
+			metrics.AssertExpectations(suite.T())
+		})
+	}
 }
 
 // Run the test suite
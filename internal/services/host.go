@@ -1,34 +1,131 @@
 package services
 
 import (
+	"context"
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/repository"
 )
 
+// HostPublisher is notified when a host is created, updated or deleted
+type HostPublisher interface {
+	PublishHostStatus(change entities.HostStatusChange)
+}
+
 type HostService struct {
-	repo repository.HostRepositoryInterface
+	repo      repository.HostRepositoryInterface
+	publisher HostPublisher
+	clocks    *ClockSkewTracker
 }
 
-func NewHostService(repo repository.HostRepositoryInterface) *HostService {
-	return &HostService{repo: repo}
+// NewHostService creates a HostService. Hosts are listed with their clock skew as
+// estimated by clocks. publisher and clocks may be nil.
+func NewHostService(repo repository.HostRepositoryInterface, publisher HostPublisher, clocks *ClockSkewTracker) *HostService {
+	return &HostService{repo: repo, publisher: publisher, clocks: clocks}
 }
 
 // CreateHost creates a new host
-func (service *HostService) CreateHost(host *entities.Host) (int64, error) {
-	return service.repo.Create(host)
+func (service *HostService) CreateHost(ctx context.Context, host *entities.Host) (int64, error) {
+	id, err := service.repo.Create(ctx, host)
+	if err != nil {
+		return id, err
+	}
+
+	published := *host
+	published.ID = id
+	service.publish(entities.HostStatusCreated, published)
+
+	return id, nil
+}
+
+// GetHosts retrieves hosts based on query parameters, one page at a time when a limit is set
+func (service *HostService) GetHosts(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, entities.PageInfo, error) {
+	var page entities.PageInfo
+
+	if params.Cursor != "" {
+		afterID, err := decodeHostCursor(params.Cursor)
+		if err != nil {
+			return nil, page, err
+		}
+		params.AfterID = afterID
+	}
+
+	if params.Limit <= 0 {
+		hosts, err := service.repo.FindByFilters(ctx, params)
+		service.addClockSkew(hosts)
+		return hosts, page, err
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	query := *params
+	query.Limit++
+
+	hosts, err := service.repo.FindByFilters(ctx, &query)
+	if err != nil {
+		return nil, page, err
+	}
+
+	if len(hosts) > params.Limit {
+		hosts = hosts[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeHostCursor(hosts[len(hosts)-1])
+	}
+
+	service.addClockSkew(hosts)
+	return hosts, page, nil
 }
 
-// GetHosts retrieves hosts based on query parameters
-func (service *HostService) GetHosts(params *entities.HostQueryParams) ([]entities.Host, error) {
-	return service.repo.FindByFilters(params)
+// addClockSkew fills in each host's estimated clock skew
+func (service *HostService) addClockSkew(hosts []entities.Host) {
+	if service.clocks == nil {
+		return
+	}
+	for i := range hosts {
+		hosts[i].ClockSkew = service.clocks.Skew(hosts[i].ID)
+	}
 }
 
 // UpdateHost updates an existing host
-func (service *HostService) UpdateHost(id int64, host *entities.Host) error {
-	return service.repo.Update(id, host)
+func (service *HostService) UpdateHost(ctx context.Context, id int64, host *entities.Host) error {
+	if err := service.repo.Update(ctx, id, host); err != nil {
+		return err
+	}
+
+	if service.publisher != nil {
+		// Only the role is updated, so publish the stored host rather than the request
+		updated := entities.Host{ID: id, Role: host.Role}
+		if hosts, err := service.repo.FindByFilters(ctx, &entities.HostQueryParams{ID: id}); err == nil && len(hosts) == 1 {
+			updated = hosts[0]
+		}
+		service.publish(entities.HostStatusUpdated, updated)
+	}
+
+	return nil
 }
 
 // DeleteHost deletes a host by ID
-func (service *HostService) DeleteHost(id int64) error {
-	return service.repo.Delete(id)
+func (service *HostService) DeleteHost(ctx context.Context, id int64) error {
+	// Load the host first so subscribers filtering by role still see it go
+	deleted := entities.Host{ID: id}
+	if service.publisher != nil {
+		if hosts, err := service.repo.FindByFilters(ctx, &entities.HostQueryParams{ID: id}); err == nil && len(hosts) == 1 {
+			deleted = hosts[0]
+		}
+	}
+
+	if err := service.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if service.clocks != nil {
+		service.clocks.Forget(id)
+	}
+
+	service.publish(entities.HostStatusDeleted, deleted)
+	return nil
+}
+
+// publish notifies the publisher, if any, of a host change
+func (service *HostService) publish(status string, host entities.Host) {
+	if service.publisher != nil {
+		service.publisher.PublishHostStatus(entities.HostStatusChange{Status: status, Host: host})
+	}
 }
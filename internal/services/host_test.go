@@ -2,6 +2,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -22,7 +24,7 @@ type HostServiceTestSuite struct {
 // SetupTest runs before each test in the suite
 func (suite *HostServiceTestSuite) SetupTest() {
 	suite.mockRepo = new(mocks.MockHostRepository)
-	suite.service = NewHostService(suite.mockRepo)
+	suite.service = NewHostService(suite.mockRepo, nil, nil)
 }
 
 // TearDownTest runs after each test
@@ -54,7 +56,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 				Role:      "web-server",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.Host{
+				suite.mockRepo.On("Create", mock.Anything, &entities.Host{
 					Hostname:  "server-01.example.com",
 					IPAddress: "192.168.1.100",
 					Role:      "web-server",
@@ -72,7 +74,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 				Role:      "database",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.Host{
+				suite.mockRepo.On("Create", mock.Anything, &entities.Host{
 					Hostname:  "existing-server.example.com",
 					IPAddress: "192.168.1.101",
 					Role:      "database",
@@ -90,7 +92,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 				Role:      "database",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.Host{
+				suite.mockRepo.On("Create", mock.Anything, &entities.Host{
 					Hostname:  "server-02.example.com",
 					IPAddress: "duplicate-ip",
 					Role:      "database",
@@ -108,7 +110,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 				Role:      "cache",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.Host{
+				suite.mockRepo.On("Create", mock.Anything, &entities.Host{
 					Hostname:  "server-03.example.com",
 					IPAddress: "invalid-ip",
 					Role:      "cache",
@@ -126,7 +128,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 				Role:      "monitoring",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.Host{
+				suite.mockRepo.On("Create", mock.Anything, &entities.Host{
 					Hostname:  "server-04.example.com",
 					IPAddress: "192.168.1.103",
 					Role:      "monitoring",
@@ -142,7 +144,7 @@ func (suite *HostServiceTestSuite) TestCreateHost() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			id, err := suite.service.CreateHost(test.host)
+			id, err := suite.service.CreateHost(context.Background(), test.host)
 
 			assert.Equal(suite.T(), test.expectedID, id)
 			if test.expectedError != nil {
@@ -183,7 +185,7 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 						Role:      "web-server",
 					},
 				}
-				suite.mockRepo.On("FindByFilters", &entities.HostQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{
 					Hostname: "server-01.example.com",
 				}).Return(hosts, nil).Once()
 			},
@@ -212,7 +214,7 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 						Role:      "web-server",
 					},
 				}
-				suite.mockRepo.On("FindByFilters", &entities.HostQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{
 					IPAddress: "192.168.1.100",
 				}).Return(hosts, nil).Once()
 			},
@@ -241,7 +243,7 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 						Role:      "web-server",
 					},
 				}
-				suite.mockRepo.On("FindByFilters", &entities.HostQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{
 					ID: 1,
 				}).Return(hosts, nil).Once()
 			},
@@ -262,7 +264,7 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 				Hostname: "non-existent-server.example.com",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("FindByFilters", &entities.HostQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{
 					Hostname: "non-existent-server.example.com",
 				}).Return([]entities.Host{}, nil).Once()
 			},
@@ -276,7 +278,7 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 				ID: 999,
 			},
 			setupMock: func() {
-				suite.mockRepo.On("FindByFilters", &entities.HostQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{
 					ID: 999,
 				}).Return(nil, errors.New("database query failed")).Once()
 			},
@@ -290,9 +292,10 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			hosts, err := suite.service.GetHosts(test.params)
+			hosts, page, err := suite.service.GetHosts(context.Background(), test.params)
 
 			assert.Equal(suite.T(), test.expectedHosts, hosts)
+			assert.Equal(suite.T(), entities.PageInfo{}, page)
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
 				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
@@ -307,6 +310,30 @@ func (suite *HostServiceTestSuite) TestGetHosts() {
 	}
 }
 
+// TestGetHostsPagination tests that a limit pages through hosts in ID order
+func (suite *HostServiceTestSuite) TestGetHostsPagination() {
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Limit: 3}).
+		Return([]entities.Host{{ID: 1}, {ID: 2}, {ID: 5}}, nil).Once()
+
+	hosts, page, err := suite.service.GetHosts(context.Background(), &entities.HostQueryParams{Limit: 2})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.Host{{ID: 1}, {ID: 2}}, hosts)
+	assert.True(suite.T(), page.HasMore)
+
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Limit: 3, Cursor: page.NextCursor, AfterID: 2}).
+		Return([]entities.Host{{ID: 5}}, nil).Once()
+
+	hosts, page, err = suite.service.GetHosts(context.Background(), &entities.HostQueryParams{Limit: 2, Cursor: page.NextCursor})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.Host{{ID: 5}}, hosts)
+	assert.Equal(suite.T(), entities.PageInfo{}, page)
+
+	_, _, err = suite.service.GetHosts(context.Background(), &entities.HostQueryParams{Limit: 2, Cursor: "%%%"})
+	assert.Equal(suite.T(), ErrInvalidCursor, err)
+}
+
 // TestUpdateHost tests the UpdateHost method
 func (suite *HostServiceTestSuite) TestUpdateHost() {
 	tests := []struct {
@@ -324,7 +351,7 @@ func (suite *HostServiceTestSuite) TestUpdateHost() {
 				Role: "database",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Update", int64(1), &entities.Host{
+				suite.mockRepo.On("Update", mock.Anything, int64(1), &entities.Host{
 					Role: "database",
 				}).Return(nil).Once()
 			},
@@ -338,7 +365,7 @@ func (suite *HostServiceTestSuite) TestUpdateHost() {
 				Role: "web-server",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Update", int64(999), &entities.Host{
+				suite.mockRepo.On("Update", mock.Anything, int64(999), &entities.Host{
 					Role: "web-server",
 				}).Return(sql.ErrNoRows).Once()
 			},
@@ -352,7 +379,7 @@ func (suite *HostServiceTestSuite) TestUpdateHost() {
 				Role: "cache",
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Update", int64(2), &entities.Host{
+				suite.mockRepo.On("Update", mock.Anything, int64(2), &entities.Host{
 					Role: "cache",
 				}).Return(errors.New("database locked")).Once()
 			},
@@ -365,7 +392,7 @@ func (suite *HostServiceTestSuite) TestUpdateHost() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.service.UpdateHost(test.id, test.host)
+			err := suite.service.UpdateHost(context.Background(), test.id, test.host)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -394,7 +421,7 @@ func (suite *HostServiceTestSuite) TestDeleteHost() {
 			name: "successful_deletion",
 			id:   1,
 			setupMock: func() {
-				suite.mockRepo.On("Delete", int64(1)).Return(nil).Once()
+				suite.mockRepo.On("Delete", mock.Anything, int64(1)).Return(nil).Once()
 			},
 			expectedError: nil,
 			description:   "Should delete the host successfully",
@@ -403,7 +430,7 @@ func (suite *HostServiceTestSuite) TestDeleteHost() {
 			name: "deletion_of_non_existent_host",
 			id:   999,
 			setupMock: func() {
-				suite.mockRepo.On("Delete", int64(999)).Return(sql.ErrNoRows).Once()
+				suite.mockRepo.On("Delete", mock.Anything, int64(999)).Return(sql.ErrNoRows).Once()
 			},
 			expectedError: sql.ErrNoRows,
 			description:   "Should return sql.ErrNoRows when trying to delete a non-existent host",
@@ -412,7 +439,7 @@ func (suite *HostServiceTestSuite) TestDeleteHost() {
 			name: "database_error_during_deletion",
 			id:   2,
 			setupMock: func() {
-				suite.mockRepo.On("Delete", int64(2)).Return(errors.New("foreign key constraint failed")).Once()
+				suite.mockRepo.On("Delete", mock.Anything, int64(2)).Return(errors.New("foreign key constraint failed")).Once()
 			},
 			expectedError: errors.New("foreign key constraint failed"),
 			description:   "Should return an error when database error occurs",
@@ -423,7 +450,7 @@ func (suite *HostServiceTestSuite) TestDeleteHost() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			err := suite.service.DeleteHost(test.id)
+			err := suite.service.DeleteHost(context.Background(), test.id)
 
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
@@ -439,6 +466,74 @@ func (suite *HostServiceTestSuite) TestDeleteHost() {
 	}
 }
 
+// recordingHostPublisher captures host changes published by the service
+type recordingHostPublisher struct {
+	changes []entities.HostStatusChange
+}
+
+func (publisher *recordingHostPublisher) PublishHostStatus(change entities.HostStatusChange) {
+	publisher.changes = append(publisher.changes, change)
+}
+
+// TestHostChangesArePublished tests that successful changes are published and failures are not
+func (suite *HostServiceTestSuite) TestHostChangesArePublished() {
+	publisher := &recordingHostPublisher{}
+	suite.service = NewHostService(suite.mockRepo, publisher, nil)
+
+	host := &entities.Host{Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "web"}
+	suite.mockRepo.On("Create", mock.Anything, host).Return(int64(7), nil).Once()
+	suite.mockRepo.On("Update", mock.Anything, int64(7), &entities.Host{Role: "nas"}).Return(nil).Once()
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{ID: 7}).
+		Return([]entities.Host{{ID: 7, Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "nas"}}, nil).Once()
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{ID: 7}).
+		Return([]entities.Host{{ID: 7, Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "nas"}}, nil).Once()
+	suite.mockRepo.On("Delete", mock.Anything, int64(7)).Return(nil).Once()
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{ID: 8}).
+		Return([]entities.Host{}, nil).Once()
+	suite.mockRepo.On("Delete", mock.Anything, int64(8)).Return(sql.ErrNoRows).Once()
+
+	_, err := suite.service.CreateHost(context.Background(), host)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.service.UpdateHost(context.Background(), 7, &entities.Host{Role: "nas"}))
+	assert.NoError(suite.T(), suite.service.DeleteHost(context.Background(), 7))
+	assert.Error(suite.T(), suite.service.DeleteHost(context.Background(), 8))
+
+	assert.Equal(suite.T(), []entities.HostStatusChange{
+		{Status: entities.HostStatusCreated, Host: entities.Host{ID: 7, Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "web"}},
+		{Status: entities.HostStatusUpdated, Host: entities.Host{ID: 7, Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "nas"}},
+		{Status: entities.HostStatusDeleted, Host: entities.Host{ID: 7, Hostname: "pi-01", IPAddress: "192.168.1.10", Role: "nas"}},
+	}, publisher.changes)
+}
+
+// TestHostClockSkew tests that listed hosts carry their estimated clock skew, on every
+// page, and that a deleted host's estimate is dropped
+func (suite *HostServiceTestSuite) TestHostClockSkew() {
+	clocks := NewClockSkewTracker()
+	clocks.Observe(1, 1030, 1000)
+	clocks.Observe(2, 990, 1000)
+	suite.service = NewHostService(suite.mockRepo, nil, clocks)
+
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{}).
+		Return([]entities.Host{{ID: 1}, {ID: 3}}, nil).Once()
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{Limit: 2}).
+		Return([]entities.Host{{ID: 2}}, nil).Once()
+	suite.mockRepo.On("Delete", mock.Anything, int64(2)).Return(nil).Once()
+
+	hosts, _, err := suite.service.GetHosts(context.Background(), &entities.HostQueryParams{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.Host{
+		{ID: 1, ClockSkew: &entities.ClockSkew{Seconds: 30, Samples: 1, ObservedAt: 1000}},
+		{ID: 3},
+	}, hosts)
+
+	hosts, _, err = suite.service.GetHosts(context.Background(), &entities.HostQueryParams{Limit: 1})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.Host{{ID: 2, ClockSkew: &entities.ClockSkew{Seconds: -10, Samples: 1, ObservedAt: 1000}}}, hosts)
+
+	assert.NoError(suite.T(), suite.service.DeleteHost(context.Background(), 2))
+	assert.Nil(suite.T(), clocks.Skew(2))
+}
+
 // Run the test suite
 func TestHostServiceTestSuite(test *testing.T) {
 	suite.Run(test, new(HostServiceTestSuite))
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gabrielg2020/monitor-api/pkg/spool"
+)
+
+const (
+	// spoolRetries is how many more times a spooled metric whose write failed is tried
+	// before it is moved to the dead-letter file
+	spoolRetries = 5
+
+	// defaultSpoolRetryBackoff is the wait before a spooled metric's first retry,
+	// doubling for each one after
+	defaultSpoolRetryBackoff = time.Second
+
+	// DeadLetterFile, in the spool directory, holds spooled metrics that could not be
+	// written, one JSON object per line
+	DeadLetterFile = "dead-letter.ndjson"
+)
+
+// IngestQueue groups metric inserts into shared transactions. An SD card or USB stick
+// pays for a sync on every commit, so one commit per agent push caps ingest far below
+// what the database can write. Create waits for the transaction holding its metric to
+// commit and returns its outcome, so callers are still only told a metric is stored once
+// it is. The other repository methods go straight to the wrapped repository.
+//
+// With a spool, Spool acknowledges a metric as soon as it is on disk in the spool, and
+// metrics left in the spool by a crash are written when the queue starts again. A
+// spooled metric whose write fails is retried with a backoff, then moved to the
+// dead-letter file, so the spool drains while the process runs.
+type IngestQueue struct {
+	repository.MetricRepositoryInterface
+
+	requests      chan *ingestRequest
+	batchSize     int
+	flushInterval time.Duration
+	spool         *spool.Log // nil without a spool
+	instruments   *telemetry.Metrics
+	retryBackoff  time.Duration
+
+	mu      sync.RWMutex
+	stopped bool
+	done    chan struct{} // closed by Stop, abandoning pending retries
+
+	wg sync.WaitGroup
+}
+
+// ingestRequest is a metric waiting in the queue and where to send its outcome. A
+// spooled metric has nobody waiting; it has its place in the spool instead.
+type ingestRequest struct {
+	ctx    context.Context
+	metric entities.SystemMetric
+	result chan ingestResult
+
+	position *spool.Position
+	stored   func(write entities.MetricWrite)
+	attempts int // retries of a spooled metric so far
+}
+
+type ingestResult struct {
+	write entities.MetricWrite
+	err   error
+}
+
+// NewIngestQueue creates an IngestQueue holding up to capacity metrics. A batch is
+// written once it has batchSize metrics or its first metric has waited flushInterval.
+// spoolLog may be nil.
+func NewIngestQueue(
+	repo repository.MetricRepositoryInterface,
+	capacity int,
+	batchSize int,
+	flushInterval time.Duration,
+	spoolLog *spool.Log,
+	instruments *telemetry.Metrics,
+) *IngestQueue {
+	queue := &IngestQueue{
+		MetricRepositoryInterface: repo,
+		requests:                  make(chan *ingestRequest, capacity),
+		batchSize:                 batchSize,
+		flushInterval:             flushInterval,
+		spool:                     spoolLog,
+		instruments:               instruments,
+		retryBackoff:              defaultSpoolRetryBackoff,
+		done:                      make(chan struct{}),
+	}
+	instruments.RegisterIngestQueue(func() (int, int) {
+		return len(queue.requests), cap(queue.requests)
+	})
+	return queue
+}
+
+// Start writes the metrics left in the spool, then launches the loop that writes
+// queued metrics
+func (queue *IngestQueue) Start(ctx context.Context) {
+	if queue.spool != nil {
+		queue.replay(ctx)
+	}
+
+	queue.wg.Add(1)
+	go func() {
+		defer queue.wg.Done()
+		queue.run(context.WithoutCancel(ctx))
+	}()
+}
+
+// Stop turns new metrics away from the queue, writes the ones already in it and waits
+// for the loop to finish. Metrics created after Stop are written one at a time. Spooled
+// metrics waiting to be retried stay in the spool for the next start.
+func (queue *IngestQueue) Stop() {
+	queue.mu.Lock()
+	if !queue.stopped {
+		queue.stopped = true
+		close(queue.requests)
+		close(queue.done)
+	}
+	queue.mu.Unlock()
+
+	queue.wg.Wait()
+}
+
+// Create queues a metric and waits for the transaction holding it to commit. It fails
+// with ErrIngestQueueFull straight away when the queue is at capacity. If ctx ends
+// first the metric may still be written.
+func (queue *IngestQueue) Create(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	request := &ingestRequest{ctx: ctx, metric: *metric, result: make(chan ingestResult, 1)}
+
+	queue.mu.RLock()
+	if queue.stopped {
+		queue.mu.RUnlock()
+		return queue.MetricRepositoryInterface.Create(ctx, metric)
+	}
+	select {
+	case queue.requests <- request:
+		queue.mu.RUnlock()
+	default:
+		queue.mu.RUnlock()
+		queue.instruments.AddIngestRejected()
+		return entities.MetricWrite{ID: -1}, ErrIngestQueueFull
+	}
+
+	select {
+	case result := <-request.result:
+		return result.write, result.err
+	case <-ctx.Done():
+		return entities.MetricWrite{ID: -1}, ctx.Err()
+	}
+}
+
+// Spool records a metric in the spool and queues it, returning once it is on disk
+// rather than once it is in the database. stored is called with the outcome after the
+// metric is written. It fails with ErrIngestQueueFull when the queue is at capacity.
+// Without a spool the metric is written before Spool returns.
+func (queue *IngestQueue) Spool(ctx context.Context, metric *entities.SystemMetric, stored func(write entities.MetricWrite)) error {
+	if queue.spool == nil {
+		write, err := queue.Create(ctx, metric)
+		if err == nil {
+			stored(write)
+		}
+		return err
+	}
+
+	payload, err := json.Marshal(metric)
+	if err != nil {
+		return err
+	}
+
+	queue.mu.RLock()
+	defer queue.mu.RUnlock()
+	if queue.stopped {
+		write, err := queue.MetricRepositoryInterface.Create(ctx, metric)
+		if err == nil {
+			stored(write)
+		}
+		return err
+	}
+	if len(queue.requests) >= cap(queue.requests) {
+		queue.instruments.AddIngestRejected()
+		return ErrIngestQueueFull
+	}
+
+	position, err := queue.spool.Append(payload)
+	if err != nil {
+		return err
+	}
+
+	// The caller is answered before the metric is written, so its context must not
+	// cancel the write
+	request := &ingestRequest{ctx: context.WithoutCancel(ctx), metric: *metric, position: &position, stored: stored}
+	select {
+	case queue.requests <- request:
+		return nil
+	case <-ctx.Done():
+		queue.spool.Done(position)
+		return ctx.Err()
+	}
+}
+
+// run collects queued metrics into batches until the queue is closed and drained
+func (queue *IngestQueue) run(ctx context.Context) {
+	timer := time.NewTimer(queue.flushInterval)
+	timer.Stop()
+	defer timer.Stop()
+
+	batch := make([]*ingestRequest, 0, queue.batchSize)
+	for {
+		select {
+		case request, ok := <-queue.requests:
+			if !ok {
+				queue.flush(ctx, batch)
+				return
+			}
+			batch = append(batch, request)
+			if len(batch) == 1 {
+				timer.Reset(queue.flushInterval)
+			}
+			if len(batch) < queue.batchSize {
+				continue
+			}
+		case <-timer.C:
+		}
+
+		timer.Stop()
+		queue.flush(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// flush writes a batch in one transaction and answers every request in it
+func (queue *IngestQueue) flush(ctx context.Context, batch []*ingestRequest) {
+	// Callers that gave up were never told their metric was stored, so leave it out
+	pending := make([]*ingestRequest, 0, len(batch))
+	metrics := make([]entities.SystemMetric, 0, len(batch))
+	for _, request := range batch {
+		if err := request.ctx.Err(); err != nil {
+			queue.finish(ctx, request, entities.MetricWrite{ID: -1}, err)
+			continue
+		}
+		pending = append(pending, request)
+		metrics = append(metrics, request.metric)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	start := time.Now()
+	writes, err := queue.MetricRepositoryInterface.CreateBatch(ctx, metrics)
+	if err == nil {
+		queue.instruments.ObserveJob("ingest_flush", telemetry.OutcomeOK, time.Since(start))
+		queue.instruments.ObserveIngestBatch(len(pending))
+		for i, request := range pending {
+			queue.finish(ctx, request, writes[i], nil)
+		}
+		return
+	}
+	queue.instruments.ObserveJob("ingest_flush", telemetry.OutcomeError, time.Since(start))
+
+	if len(pending) == 1 {
+		queue.finish(ctx, pending[0], entities.MetricWrite{ID: -1}, err)
+		return
+	}
+
+	// One bad metric, such as one for a host deleted since it was validated or a rejected
+	// duplicate, rolls back the whole transaction, so write them one at a time and only
+	// fail that one
+	slog.WarnContext(ctx, "ingest batch failed, writing its metrics one at a time", "metrics", len(pending), "error", err)
+	for _, request := range pending {
+		write, err := queue.MetricRepositoryInterface.Create(request.ctx, &request.metric)
+		queue.finish(ctx, request, write, err)
+	}
+}
+
+// finish sends the outcome to the caller waiting for it. A spooled metric is released
+// from the spool once stored or rejected as a duplicate; one that failed is retried.
+func (queue *IngestQueue) finish(ctx context.Context, request *ingestRequest, write entities.MetricWrite, err error) {
+	if request.position == nil {
+		request.result <- ingestResult{write: write, err: err}
+		return
+	}
+
+	if errors.Is(err, repository.ErrDuplicateMetric) {
+		slog.InfoContext(ctx, "dropping spooled metric, the host already has one at its timestamp",
+			"host_id", request.metric.HostID, "timestamp", request.metric.Timestamp)
+		queue.spool.Done(*request.position)
+		return
+	}
+	if err != nil {
+		queue.retrySpooled(ctx, request, err)
+		return
+	}
+	queue.spool.Done(*request.position)
+	request.stored(write)
+}
+
+// retrySpooled writes a spooled metric again after a backoff, so a database that was
+// briefly locked or full doesn't leave the metric pinning its segment until the next
+// start. Once the retries run out, the metric is moved to the dead-letter file. If the
+// queue stops first, the metric stays in the spool and is replayed on the next start.
+func (queue *IngestQueue) retrySpooled(ctx context.Context, request *ingestRequest, err error) {
+	if request.attempts >= spoolRetries {
+		queue.deadLetter(ctx, request, err)
+		return
+	}
+	request.attempts++
+	backoff := queue.retryBackoff << (request.attempts - 1)
+	slog.WarnContext(ctx, "failed to write spooled metric, retrying",
+		"host_id", request.metric.HostID, "timestamp", request.metric.Timestamp,
+		"attempt", request.attempts, "backoff", backoff, "error", err)
+
+	queue.wg.Add(1)
+	go func() {
+		defer queue.wg.Done()
+
+		timer := time.NewTimer(backoff)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-queue.done:
+			return
+		}
+
+		write, err := queue.MetricRepositoryInterface.Create(request.ctx, &request.metric)
+		queue.finish(ctx, request, write, err)
+	}()
+}
+
+// deadLetter appends a spooled metric that could not be written to the dead-letter file,
+// with the last error, and releases it from the spool. If the file can't be written
+// either, the metric stays in the spool for the next start.
+func (queue *IngestQueue) deadLetter(ctx context.Context, request *ingestRequest, writeErr error) {
+	path := filepath.Join(queue.spool.Dir(), DeadLetterFile)
+	line, err := json.Marshal(deadLetterEntry{Metric: request.metric, Error: writeErr.Error(), FailedAt: time.Now().Unix()})
+	if err == nil {
+		err = appendLine(path, line)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to move spooled metric to the dead-letter file, it will be retried on the next start",
+			"host_id", request.metric.HostID, "timestamp", request.metric.Timestamp, "error", errors.Join(writeErr, err))
+		return
+	}
+
+	slog.ErrorContext(ctx, "moved spooled metric that can't be written to the dead-letter file",
+		"host_id", request.metric.HostID, "timestamp", request.metric.Timestamp, "file", path, "error", writeErr)
+	queue.spool.Done(*request.position)
+}
+
+// deadLetterEntry is a line of the dead-letter file
+type deadLetterEntry struct {
+	Metric   entities.SystemMetric `json:"metric"`
+	Error    string                `json:"error"`
+	FailedAt int64                 `json:"failed_at"`
+}
+
+// appendLine appends a line to a file, creating it if needed, and syncs it to disk
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// replay writes the metrics a previous run left in the spool. Metrics that reached the
+// database before the crash, or whose host has been deleted since, are skipped. This
+// ignores the duplicate policy, as a stored metric at the same host and timestamp is
+// usually the spooled one written just before the crash. If a batch can't be written,
+// replay stops and the rest of the spool is kept for the next start rather than
+// dropping metrics that were already acknowledged.
+func (queue *IngestQueue) replay(ctx context.Context) {
+	start := time.Now()
+	stats, err := queue.spool.Replay(queue.batchSize, func(payloads [][]byte) error {
+		metrics := make([]entities.SystemMetric, 0, len(payloads))
+		for _, payload := range payloads {
+			var metric entities.SystemMetric
+			if err := json.Unmarshal(payload, &metric); err != nil {
+				slog.ErrorContext(ctx, "dropping unreadable spooled metric", "payload", string(payload), "error", err)
+				continue
+			}
+			metrics = append(metrics, metric)
+		}
+
+		_, err := queue.MetricRepositoryInterface.InsertMissing(ctx, metrics)
+		return err
+	})
+
+	outcome := telemetry.OutcomeOK
+	if err != nil || len(stats.Corrupt) > 0 {
+		outcome = telemetry.OutcomeError
+	}
+	queue.instruments.ObserveJob("ingest_replay", outcome, time.Since(start))
+
+	if len(stats.Corrupt) > 0 {
+		slog.ErrorContext(ctx, "set aside corrupt ingest spool segments, metrics after the damage were not replayed",
+			"segments", stats.Corrupt)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to replay ingest spool, the rest is kept for the next start", "error", err)
+		return
+	}
+	if stats.Segments > 0 {
+		slog.InfoContext(ctx, "replayed ingest spool", "segments", stats.Segments, "metrics", stats.Records, "torn_write", stats.Torn)
+	}
+}
@@ -0,0 +1,402 @@
+// nolint
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+	"github.com/gabrielg2020/monitor-api/pkg/spool"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// IngestQueueTestSuite is the test suite for IngestQueue
+type IngestQueueTestSuite struct {
+	suite.Suite
+	mockRepo    *mocks.MockMetricRepository
+	instruments *telemetry.Metrics
+}
+
+// SetupTest runs before each test in the suite
+func (suite *IngestQueueTestSuite) SetupTest() {
+	suite.mockRepo = new(mocks.MockMetricRepository)
+	suite.instruments = telemetry.New()
+}
+
+// TearDownTest runs after each test
+func (suite *IngestQueueTestSuite) TearDownTest() {
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+// enqueue creates a metric in the background and waits until it is in the queue, so
+// metrics queued before Start are batched in order
+func (suite *IngestQueueTestSuite) enqueue(queue *IngestQueue, ctx context.Context, metric entities.SystemMetric) chan ingestResult {
+	depth := len(queue.requests)
+	result := make(chan ingestResult, 1)
+	go func() {
+		write, err := queue.Create(ctx, &metric)
+		result <- ingestResult{write: write, err: err}
+	}()
+	suite.Require().Eventually(func() bool { return len(queue.requests) > depth }, time.Second, time.Millisecond)
+	return result
+}
+
+// wait returns the outcome of a metric queued with enqueue
+func (suite *IngestQueueTestSuite) wait(result chan ingestResult) ingestResult {
+	select {
+	case outcome := <-result:
+		return outcome
+	case <-time.After(time.Second):
+		suite.FailNow("metric was not written")
+		return ingestResult{}
+	}
+}
+
+// TestBatchSize tests that a full batch is written in one transaction and every
+// caller gets its own ID
+func (suite *IngestQueueTestSuite) TestBatchSize() {
+	queue := NewIngestQueue(suite.mockRepo, 10, 3, time.Hour, nil, suite.instruments)
+	metrics := []entities.SystemMetric{
+		{HostID: 1, Timestamp: 1000},
+		{HostID: 2, Timestamp: 1000},
+		{HostID: 3, Timestamp: 1000},
+	}
+	suite.mockRepo.On("CreateBatch", mock.Anything, metrics).Return([]entities.MetricWrite{{ID: 11}, {ID: 12}, {ID: 13}}, nil).Once()
+
+	var results []chan ingestResult
+	for _, metric := range metrics {
+		results = append(results, suite.enqueue(queue, context.Background(), metric))
+	}
+
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	for i, result := range results {
+		outcome := suite.wait(result)
+		assert.NoError(suite.T(), outcome.err)
+		assert.Equal(suite.T(), int64(11+i), outcome.write.ID)
+	}
+}
+
+// TestFlushInterval tests that a batch that doesn't fill is written after the interval
+func (suite *IngestQueueTestSuite) TestFlushInterval() {
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, 10*time.Millisecond, nil, suite.instruments)
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{{HostID: 1, Timestamp: 1000}}).Return([]entities.MetricWrite{{ID: 5}}, nil).Once()
+
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	write, err := queue.Create(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1000})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 5}, write)
+}
+
+// TestQueueFull tests that metrics are turned away at capacity and the queued ones are
+// written when the queue stops
+func (suite *IngestQueueTestSuite) TestQueueFull() {
+	queue := NewIngestQueue(suite.mockRepo, 1, 100, time.Hour, nil, suite.instruments)
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{{HostID: 1, Timestamp: 1000}}).Return([]entities.MetricWrite{{ID: 5}}, nil).Once()
+
+	result := suite.enqueue(queue, context.Background(), entities.SystemMetric{HostID: 1, Timestamp: 1000})
+
+	write, err := queue.Create(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1015})
+	assert.ErrorIs(suite.T(), err, ErrIngestQueueFull)
+	assert.Equal(suite.T(), int64(-1), write.ID)
+
+	queue.Start(context.Background())
+	queue.Stop()
+
+	outcome := suite.wait(result)
+	assert.NoError(suite.T(), outcome.err)
+	assert.Equal(suite.T(), int64(5), outcome.write.ID)
+
+	var output bytes.Buffer
+	suite.Require().NoError(suite.instruments.WritePrometheus(&output))
+	assert.Contains(suite.T(), output.String(), "monitor_api_ingest_rejected_total 1\n")
+	assert.Contains(suite.T(), output.String(), "monitor_api_ingest_queue_capacity 1\n")
+	assert.Contains(suite.T(), output.String(), `monitor_api_job_runs_total{job="ingest_flush",outcome="ok"} 1`)
+}
+
+// TestBatchError tests that a failed batch is retried one metric at a time, so only
+// the bad metric fails
+func (suite *IngestQueueTestSuite) TestBatchError() {
+	queue := NewIngestQueue(suite.mockRepo, 10, 2, time.Hour, nil, suite.instruments)
+	good := entities.SystemMetric{HostID: 1, Timestamp: 1000}
+	bad := entities.SystemMetric{HostID: 999, Timestamp: 1000}
+	foreignKey := errors.New("FOREIGN KEY constraint failed")
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{good, bad}).Return(nil, foreignKey).Once()
+	suite.mockRepo.On("Create", mock.Anything, &good).Return(entities.MetricWrite{ID: 7}, nil).Once()
+	suite.mockRepo.On("Create", mock.Anything, &bad).Return(entities.MetricWrite{ID: -1}, foreignKey).Once()
+
+	goodResult := suite.enqueue(queue, context.Background(), good)
+	badResult := suite.enqueue(queue, context.Background(), bad)
+
+	queue.Start(context.Background())
+	defer queue.Stop()
+
+	outcome := suite.wait(goodResult)
+	assert.NoError(suite.T(), outcome.err)
+	assert.Equal(suite.T(), int64(7), outcome.write.ID)
+
+	outcome = suite.wait(badResult)
+	assert.Equal(suite.T(), foreignKey, outcome.err)
+}
+
+// TestCancelled tests that metrics whose caller gave up before the flush are not written
+func (suite *IngestQueueTestSuite) TestCancelled() {
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, nil, suite.instruments)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := suite.enqueue(queue, ctx, entities.SystemMetric{HostID: 1, Timestamp: 1000})
+	cancel()
+
+	outcome := suite.wait(result)
+	assert.ErrorIs(suite.T(), outcome.err, context.Canceled)
+
+	// CreateBatch has no expectation, so the mock fails the test if it is called
+	queue.Start(context.Background())
+	queue.Stop()
+}
+
+// TestCreateAfterStop tests that metrics created after Stop are written directly
+func (suite *IngestQueueTestSuite) TestCreateAfterStop() {
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, nil, suite.instruments)
+	metric := &entities.SystemMetric{HostID: 1, Timestamp: 1000}
+	suite.mockRepo.On("Create", mock.Anything, metric).Return(entities.MetricWrite{ID: 9}, nil).Once()
+
+	queue.Start(context.Background())
+	queue.Stop()
+	queue.Stop()
+
+	write, err := queue.Create(context.Background(), metric)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 9}, write)
+}
+
+// openSpool opens a spool in a temporary directory
+func (suite *IngestQueueTestSuite) openSpool(dir string) *spool.Log {
+	log, err := spool.Open(dir, 0)
+	suite.Require().NoError(err)
+	return log
+}
+
+// TestSpool tests that a spooled metric is acknowledged before it is written, then
+// written, reported as stored and released from the spool
+func (suite *IngestQueueTestSuite) TestSpool() {
+	dir := suite.T().TempDir()
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, log, suite.instruments)
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 1000, CPUUsage: 12.5}
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{metric}).Return([]entities.MetricWrite{{ID: 21}}, nil).Once()
+
+	stored := make(chan entities.MetricWrite, 1)
+	err := queue.Spool(context.Background(), &metric, func(write entities.MetricWrite) { stored <- write })
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), stored)
+
+	queue.Start(context.Background())
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 21}, <-stored)
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
+// TestSpoolDuplicate tests that a spooled metric rejected as a duplicate is released from
+// the spool without being reported as stored
+func (suite *IngestQueueTestSuite) TestSpoolDuplicate() {
+	dir := suite.T().TempDir()
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, log, suite.instruments)
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 1000}
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{metric}).Return(nil, repository.ErrDuplicateMetric).Once()
+
+	suite.Require().NoError(queue.Spool(context.Background(), &metric, func(entities.MetricWrite) {
+		suite.Fail("a rejected duplicate was reported as stored")
+	}))
+
+	queue.Start(context.Background())
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
+// TestSpoolQueueFull tests that nothing is spooled once the queue is at capacity
+func (suite *IngestQueueTestSuite) TestSpoolQueueFull() {
+	log := suite.openSpool(suite.T().TempDir())
+	defer log.Close()
+	queue := NewIngestQueue(suite.mockRepo, 1, 100, time.Hour, log, suite.instruments)
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 1000}
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{metric}).Return([]entities.MetricWrite{{ID: 21}}, nil).Once()
+
+	suite.Require().NoError(queue.Spool(context.Background(), &metric, func(entities.MetricWrite) {}))
+	err := queue.Spool(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: 1015}, func(entities.MetricWrite) {})
+	assert.ErrorIs(suite.T(), err, ErrIngestQueueFull)
+
+	queue.Start(context.Background())
+	queue.Stop()
+}
+
+// TestSpoolRetry tests that a spooled metric whose write failed is retried while the
+// queue runs, then reported as stored and released from the spool
+func (suite *IngestQueueTestSuite) TestSpoolRetry() {
+	dir := suite.T().TempDir()
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, 10*time.Millisecond, log, suite.instruments)
+	queue.retryBackoff = time.Millisecond
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 1000}
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{metric}).Return(nil, errors.New("database is locked")).Once()
+	suite.mockRepo.On("Create", mock.Anything, &metric).Return(entities.MetricWrite{ID: -1}, errors.New("database is locked")).Once()
+	suite.mockRepo.On("Create", mock.Anything, &metric).Return(entities.MetricWrite{ID: 21}, nil).Once()
+
+	stored := make(chan entities.MetricWrite, 1)
+	suite.Require().NoError(queue.Spool(context.Background(), &metric, func(write entities.MetricWrite) { stored <- write }))
+	queue.Start(context.Background())
+
+	select {
+	case write := <-stored:
+		assert.Equal(suite.T(), entities.MetricWrite{ID: 21}, write)
+	case <-time.After(time.Second):
+		suite.FailNow("spooled metric was not retried")
+	}
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+}
+
+// TestSpoolDeadLetter tests that a spooled metric that keeps failing is moved to the
+// dead-letter file and released from the spool
+func (suite *IngestQueueTestSuite) TestSpoolDeadLetter() {
+	dir := suite.T().TempDir()
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, 10*time.Millisecond, log, suite.instruments)
+	queue.retryBackoff = time.Millisecond
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 1000, CPUUsage: 12.5}
+	suite.mockRepo.On("CreateBatch", mock.Anything, []entities.SystemMetric{metric}).Return(nil, errors.New("disk full")).Once()
+	suite.mockRepo.On("Create", mock.Anything, &metric).Return(entities.MetricWrite{ID: -1}, errors.New("disk full")).Times(spoolRetries)
+
+	suite.Require().NoError(queue.Spool(context.Background(), &metric, func(entities.MetricWrite) {
+		suite.Fail("a failed metric was reported as stored")
+	}))
+	queue.Start(context.Background())
+
+	path := filepath.Join(dir, DeadLetterFile)
+	suite.Require().Eventually(func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 2*time.Second, time.Millisecond)
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 1)
+	assert.Equal(suite.T(), DeadLetterFile, entries[0].Name())
+
+	content, err := os.ReadFile(path)
+	suite.Require().NoError(err)
+	var entry deadLetterEntry
+	suite.Require().NoError(json.Unmarshal(bytes.TrimSuffix(content, []byte("\n")), &entry))
+	assert.Equal(suite.T(), metric, entry.Metric)
+	assert.Equal(suite.T(), "disk full", entry.Error)
+	assert.NotZero(suite.T(), entry.FailedAt)
+}
+
+// writeSpool leaves metrics in a spool in dir, as a crashed process would
+func (suite *IngestQueueTestSuite) writeSpool(dir string, metrics []entities.SystemMetric) {
+	log := suite.openSpool(dir)
+	for _, metric := range metrics {
+		payload, err := json.Marshal(metric)
+		suite.Require().NoError(err)
+		_, err = log.Append(payload)
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(log.Close())
+}
+
+// TestReplay tests that metrics left in the spool are written on start and the spool
+// emptied; metrics already stored or for deleted hosts are skipped by the repository
+func (suite *IngestQueueTestSuite) TestReplay() {
+	dir := suite.T().TempDir()
+	metrics := []entities.SystemMetric{
+		{HostID: 1, Timestamp: 1000, CPUUsage: 10},
+		{HostID: 999, Timestamp: 1000, CPUUsage: 20},
+		{HostID: 1, Timestamp: 1015, CPUUsage: 30},
+	}
+	suite.writeSpool(dir, metrics)
+	suite.mockRepo.On("InsertMissing", mock.Anything, metrics).Return(int64(2), nil).Once()
+
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, log, suite.instruments)
+	queue.Start(context.Background())
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+
+	var output bytes.Buffer
+	suite.Require().NoError(suite.instruments.WritePrometheus(&output))
+	assert.Contains(suite.T(), output.String(), `monitor_api_job_runs_total{job="ingest_replay",outcome="ok"} 1`)
+}
+
+// TestReplayError tests that spooled metrics are kept for the next start when the
+// database can't take them, rather than dropped
+func (suite *IngestQueueTestSuite) TestReplayError() {
+	dir := suite.T().TempDir()
+	metrics := []entities.SystemMetric{
+		{HostID: 1, Timestamp: 1000, CPUUsage: 10},
+		{HostID: 1, Timestamp: 1015, CPUUsage: 30},
+	}
+	suite.writeSpool(dir, metrics)
+	suite.mockRepo.On("InsertMissing", mock.Anything, metrics).Return(int64(0), errors.New("database is locked")).Once()
+
+	log := suite.openSpool(dir)
+	queue := NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, log, suite.instruments)
+	queue.Start(context.Background())
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	// The next start replays the same metrics
+	suite.mockRepo.On("InsertMissing", mock.Anything, metrics).Return(int64(2), nil).Once()
+	log = suite.openSpool(dir)
+	queue = NewIngestQueue(suite.mockRepo, 10, 100, time.Hour, log, suite.instruments)
+	queue.Start(context.Background())
+	queue.Stop()
+	suite.Require().NoError(log.Close())
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), entries)
+
+	var output bytes.Buffer
+	suite.Require().NoError(suite.instruments.WritePrometheus(&output))
+	assert.Contains(suite.T(), output.String(), `monitor_api_job_runs_total{job="ingest_replay",outcome="error"} 1`)
+}
+
+// Run the test suite
+func TestIngestQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(IngestQueueTestSuite))
+}
@@ -1,28 +1,76 @@
 package services
 
-import "github.com/gabrielg2020/monitor-api/internal/entities"
+import (
+	"context"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// ArchiveServiceInterface defines methods for archiving metrics to Parquet files
+type ArchiveServiceInterface interface {
+	Archive(ctx context.Context, req *entities.ArchiveRequest) (*entities.ArchiveResult, error)
+	Import(ctx context.Context, req *entities.ImportRequest) (*entities.ImportResult, error)
+}
+
+// BackupServiceInterface defines methods for database backups
+type BackupServiceInterface interface {
+	CreateBackup(ctx context.Context) (*entities.Backup, error)
+	ListBackups() ([]entities.Backup, error)
+}
 
 // HealthServiceInterface defines methods for health checks
 type HealthServiceInterface interface {
-	CheckHealth() error
-	GetDetailedHealth() (map[string]interface{}, error)
+	CheckHealth(ctx context.Context) error
+	CheckReadiness(ctx context.Context) *entities.HealthReport
+	GetDetailedHealth(ctx context.Context) *entities.DetailedHealth
 }
 
 // HostServiceInterface defines methods for host service operations
 type HostServiceInterface interface {
-	CreateHost(host *entities.Host) (int64, error)
-	GetHosts(params *entities.HostQueryParams) ([]entities.Host, error)
-	UpdateHost(id int64, host *entities.Host) error
-	DeleteHost(id int64) error
+	CreateHost(ctx context.Context, host *entities.Host) (int64, error)
+	GetHosts(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, entities.PageInfo, error)
+	UpdateHost(ctx context.Context, id int64, host *entities.Host) error
+	DeleteHost(ctx context.Context, id int64) error
+}
+
+// MaintenanceServiceInterface defines methods for routine database maintenance
+type MaintenanceServiceInterface interface {
+	RunMaintenance(ctx context.Context, trigger string, tasks []string) (*entities.MaintenanceRun, error)
+	LastRun() (*entities.MaintenanceRun, error)
 }
 
 // MetricServiceInterface defines methods for metric service operations
 type MetricServiceInterface interface {
-	CreateMetric(metric *entities.SystemMetric) (int64, error)
-	GetMetrics(params *entities.MetricQueryParams) ([]entities.SystemMetric, error)
-	GetLatestMetric(hostID *int64) (*entities.SystemMetric, error)
+	CreateMetric(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error)
+	AcceptMetric(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, bool, error)
+	GetMetrics(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, entities.PageInfo, error)
+	ExportMetrics(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error
+	GetLatestMetric(ctx context.Context, hostID *int64) (*entities.SystemMetric, error)
+	GetMetricsAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error)
 }
 
+// ScrapeTargetServiceInterface defines methods for scrape target service operations
+type ScrapeTargetServiceInterface interface {
+	CreateTarget(ctx context.Context, target *entities.ScrapeTarget) (int64, error)
+	GetTargets(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error)
+	UpdateTarget(ctx context.Context, id int64, target *entities.ScrapeTarget) error
+	DeleteTarget(ctx context.Context, id int64) error
+	RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error
+}
+
+var _ ArchiveServiceInterface = (*ArchiveService)(nil)
+var _ BackupServiceInterface = (*BackupService)(nil)
 var _ HealthServiceInterface = (*HealthService)(nil)
 var _ HostServiceInterface = (*HostService)(nil)
+var _ MaintenanceServiceInterface = (*MaintenanceService)(nil)
 var _ MetricServiceInterface = (*MetricService)(nil)
+var _ ScrapeTargetServiceInterface = (*ScrapeTargetService)(nil)
+var _ MetricSpooler = (*IngestQueue)(nil)
+
+var _ ArchiveServiceInterface = (*TracedArchiveService)(nil)
+var _ BackupServiceInterface = (*TracedBackupService)(nil)
+var _ HealthServiceInterface = (*TracedHealthService)(nil)
+var _ HostServiceInterface = (*TracedHostService)(nil)
+var _ MaintenanceServiceInterface = (*TracedMaintenanceService)(nil)
+var _ MetricServiceInterface = (*TracedMetricService)(nil)
+var _ ScrapeTargetServiceInterface = (*TracedScrapeTargetService)(nil)
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+)
+
+// maintenanceTasks lists every task in the order they run. Vacuuming first lets
+// ANALYZE see the compacted tables, and the integrity check covers the result.
+var maintenanceTasks = []string{
+	entities.MaintenanceIncrementalVacuum,
+	entities.MaintenanceAnalyze,
+	entities.MaintenanceOptimize,
+	entities.MaintenanceIntegrityCheck,
+}
+
+type MaintenanceService struct {
+	repo    repository.MaintenanceRepositoryInterface
+	enabled bool
+	running sync.Mutex
+	now     func() time.Time
+
+	mu   sync.Mutex
+	last *entities.MaintenanceRun
+}
+
+// NewMaintenanceService creates a MaintenanceService. Maintenance is SQLite specific, so
+// it is disabled for other databases.
+func NewMaintenanceService(repo repository.MaintenanceRepositoryInterface, enabled bool) *MaintenanceService {
+	return &MaintenanceService{repo: repo, enabled: enabled, now: time.Now}
+}
+
+// RunMaintenance runs the requested tasks, or all of them when none are given, in their
+// usual order. A failed task is recorded and doesn't stop the ones after it.
+func (service *MaintenanceService) RunMaintenance(ctx context.Context, trigger string, tasks []string) (*entities.MaintenanceRun, error) {
+	if !service.enabled {
+		return nil, ErrMaintenanceDisabled
+	}
+	for _, task := range tasks {
+		if !slices.Contains(maintenanceTasks, task) {
+			return nil, fmt.Errorf("%w, got %q", ErrInvalidMaintenanceTask, task)
+		}
+	}
+
+	if !service.running.TryLock() {
+		return nil, ErrMaintenanceInProgress
+	}
+	defer service.running.Unlock()
+
+	start := service.now()
+	run := &entities.MaintenanceRun{
+		Trigger:   trigger,
+		StartedAt: start.Unix(),
+		Tasks:     []entities.MaintenanceTaskResult{},
+	}
+	for _, task := range maintenanceTasks {
+		if len(tasks) > 0 && !slices.Contains(tasks, task) {
+			continue
+		}
+		run.Tasks = append(run.Tasks, service.runTask(ctx, task))
+	}
+	run.DurationMs = durationMs(service.now().Sub(start))
+
+	service.mu.Lock()
+	service.last = run
+	service.mu.Unlock()
+
+	return run, nil
+}
+
+// LastRun returns the most recent maintenance run
+func (service *MaintenanceService) LastRun() (*entities.MaintenanceRun, error) {
+	if !service.enabled {
+		return nil, ErrMaintenanceDisabled
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if service.last == nil {
+		return nil, ErrNoMaintenanceRun
+	}
+	return service.last, nil
+}
+
+// Check reports the most recent run and fails if any of its tasks failed, for the
+// health checks
+func (service *MaintenanceService) Check(ctx context.Context) (map[string]interface{}, error) {
+	service.mu.Lock()
+	run := service.last
+	service.mu.Unlock()
+
+	if run == nil {
+		return nil, nil
+	}
+
+	details := map[string]interface{}{
+		"last_run":    time.Unix(run.StartedAt, 0).UTC().Format(time.RFC3339),
+		"trigger":     run.Trigger,
+		"duration_ms": run.DurationMs,
+		"tasks":       run.Tasks,
+	}
+	for _, task := range run.Tasks {
+		if task.Status != entities.MaintenanceStatusOK {
+			return details, fmt.Errorf("%s failed: %s", task.Task, task.Error)
+		}
+	}
+	return details, nil
+}
+
+// runTask runs one task and records how it went
+func (service *MaintenanceService) runTask(ctx context.Context, task string) entities.MaintenanceTaskResult {
+	start := service.now()
+	var details map[string]interface{}
+	var err error
+
+	switch task {
+	case entities.MaintenanceIncrementalVacuum:
+		var freed int64
+		freed, err = service.repo.IncrementalVacuum(ctx)
+		details = map[string]interface{}{"pages_freed": freed}
+	case entities.MaintenanceAnalyze:
+		err = service.repo.Analyze(ctx)
+	case entities.MaintenanceOptimize:
+		err = service.repo.Optimize(ctx)
+	case entities.MaintenanceIntegrityCheck:
+		var problems []string
+		problems, err = service.repo.IntegrityCheck(ctx)
+		if err == nil && len(problems) > 0 {
+			details = map[string]interface{}{"problems": problems}
+			err = fmt.Errorf("found %d problems", len(problems))
+		}
+	}
+
+	result := entities.MaintenanceTaskResult{
+		Task:       task,
+		Status:     entities.MaintenanceStatusOK,
+		DurationMs: durationMs(service.now().Sub(start)),
+		Details:    details,
+	}
+	if err != nil {
+		result.Status = entities.MaintenanceStatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+// MaintenanceScheduler runs every maintenance task on a fixed interval
+type MaintenanceScheduler struct {
+	service     MaintenanceServiceInterface
+	interval    time.Duration
+	instruments *telemetry.Metrics
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewMaintenanceScheduler(service MaintenanceServiceInterface, interval time.Duration, instruments *telemetry.Metrics) *MaintenanceScheduler {
+	return &MaintenanceScheduler{service: service, interval: interval, instruments: instruments}
+}
+
+// Start launches the maintenance loop in the background
+func (scheduler *MaintenanceScheduler) Start(ctx context.Context) {
+	ctx, scheduler.cancel = context.WithCancel(ctx)
+
+	scheduler.wg.Add(1)
+	go func() {
+		defer scheduler.wg.Done()
+
+		ticker := time.NewTicker(scheduler.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				scheduler.run(ctx)
+			}
+		}
+	}()
+}
+
+// run runs every task once, logging and recording each one's outcome
+func (scheduler *MaintenanceScheduler) run(ctx context.Context) {
+	// Stop waits for a run under way rather than cancelling it part way through a VACUUM
+	run, err := scheduler.service.RunMaintenance(context.WithoutCancel(ctx), entities.MaintenanceTriggerScheduled, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "scheduled maintenance failed", "error", err)
+		return
+	}
+
+	for _, task := range run.Tasks {
+		duration := time.Duration(task.DurationMs * float64(time.Millisecond))
+		if task.Status != entities.MaintenanceStatusOK {
+			scheduler.instruments.ObserveJob("maintenance_"+task.Task, telemetry.OutcomeError, duration)
+			slog.ErrorContext(ctx, "maintenance task failed", "task", task.Task, "error", task.Error)
+			continue
+		}
+		scheduler.instruments.ObserveJob("maintenance_"+task.Task, telemetry.OutcomeOK, duration)
+	}
+	slog.InfoContext(ctx, "scheduled maintenance finished", "duration_ms", run.DurationMs)
+}
+
+// Stop cancels the maintenance loop and waits for a running maintenance to finish
+func (scheduler *MaintenanceScheduler) Stop() {
+	if scheduler.cancel != nil {
+		scheduler.cancel()
+	}
+	scheduler.wg.Wait()
+}
@@ -0,0 +1,202 @@
+// nolint
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// MaintenanceServiceTestSuite is the test suite for MaintenanceService
+type MaintenanceServiceTestSuite struct {
+	suite.Suite
+	mockRepo *mocks.MockMaintenanceRepository
+	clock    time.Time
+	service  *MaintenanceService
+}
+
+// SetupTest runs before each test in the suite
+func (suite *MaintenanceServiceTestSuite) SetupTest() {
+	suite.mockRepo = new(mocks.MockMaintenanceRepository)
+	suite.clock = time.Date(2025, 10, 18, 3, 0, 0, 0, time.UTC)
+	suite.service = NewMaintenanceService(suite.mockRepo, true)
+	suite.service.now = func() time.Time { return suite.clock }
+}
+
+// TearDownTest runs after each test
+func (suite *MaintenanceServiceTestSuite) TearDownTest() {
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+// taskNames returns the names of the tasks in a run, in order
+func taskNames(run *entities.MaintenanceRun) []string {
+	names := make([]string, len(run.Tasks))
+	for i, task := range run.Tasks {
+		names[i] = task.Task
+	}
+	return names
+}
+
+// TestRunMaintenance tests that every task runs, in order, when none are requested
+func (suite *MaintenanceServiceTestSuite) TestRunMaintenance() {
+	suite.mockRepo.On("IncrementalVacuum", mock.Anything).Return(int64(42), nil).Once()
+	suite.mockRepo.On("Analyze", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("Optimize", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("IntegrityCheck", mock.Anything).Return([]string{}, nil).Once()
+
+	run, err := suite.service.RunMaintenance(context.Background(), entities.MaintenanceTriggerManual, nil)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), entities.MaintenanceTriggerManual, run.Trigger)
+	assert.Equal(suite.T(), suite.clock.Unix(), run.StartedAt)
+	assert.Equal(suite.T(), maintenanceTasks, taskNames(run))
+	for _, task := range run.Tasks {
+		assert.Equal(suite.T(), entities.MaintenanceStatusOK, task.Status)
+	}
+	assert.Equal(suite.T(), map[string]interface{}{"pages_freed": int64(42)}, run.Tasks[0].Details)
+
+	last, err := suite.service.LastRun()
+	assert.NoError(suite.T(), err)
+	assert.Same(suite.T(), run, last)
+}
+
+// TestRunMaintenanceSubset tests that only the requested tasks run, in their usual order
+func (suite *MaintenanceServiceTestSuite) TestRunMaintenanceSubset() {
+	suite.mockRepo.On("Analyze", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("IntegrityCheck", mock.Anything).Return([]string{}, nil).Once()
+
+	run, err := suite.service.RunMaintenance(context.Background(), entities.MaintenanceTriggerManual,
+		[]string{entities.MaintenanceIntegrityCheck, entities.MaintenanceAnalyze})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{entities.MaintenanceAnalyze, entities.MaintenanceIntegrityCheck}, taskNames(run))
+}
+
+// TestRunMaintenanceFailedTask tests that a failed task is recorded and the rest still run
+func (suite *MaintenanceServiceTestSuite) TestRunMaintenanceFailedTask() {
+	suite.mockRepo.On("IncrementalVacuum", mock.Anything).Return(int64(0), errors.New("database is locked")).Once()
+	suite.mockRepo.On("Analyze", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("Optimize", mock.Anything).Return(nil).Once()
+	suite.mockRepo.On("IntegrityCheck", mock.Anything).Return([]string{"row 3 missing from index"}, nil).Once()
+
+	run, err := suite.service.RunMaintenance(context.Background(), entities.MaintenanceTriggerScheduled, nil)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), run.Tasks, 4)
+	assert.Equal(suite.T(), entities.MaintenanceStatusFailed, run.Tasks[0].Status)
+	assert.Equal(suite.T(), "database is locked", run.Tasks[0].Error)
+	assert.Equal(suite.T(), entities.MaintenanceStatusOK, run.Tasks[1].Status)
+	assert.Equal(suite.T(), entities.MaintenanceStatusFailed, run.Tasks[3].Status)
+	assert.Equal(suite.T(), "found 1 problems", run.Tasks[3].Error)
+	assert.Equal(suite.T(), map[string]interface{}{"problems": []string{"row 3 missing from index"}}, run.Tasks[3].Details)
+
+	_, err = suite.service.Check(context.Background())
+	assert.EqualError(suite.T(), err, "incremental_vacuum failed: database is locked")
+}
+
+// TestRunMaintenanceErrors tests the errors returned before any task runs
+func (suite *MaintenanceServiceTestSuite) TestRunMaintenanceErrors() {
+	tests := []struct {
+		name          string
+		setup         func()
+		tasks         []string
+		expectedError error
+	}{
+		{
+			name: "disabled",
+			setup: func() {
+				suite.service = NewMaintenanceService(suite.mockRepo, false)
+			},
+			expectedError: ErrMaintenanceDisabled,
+		},
+		{
+			name:          "invalid_task",
+			setup:         func() {},
+			tasks:         []string{entities.MaintenanceAnalyze, "reindex"},
+			expectedError: ErrInvalidMaintenanceTask,
+		},
+		{
+			name: "in_progress",
+			setup: func() {
+				suite.service.running.Lock()
+			},
+			expectedError: ErrMaintenanceInProgress,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.TearDownTest()
+			suite.SetupTest()
+
+			test.setup()
+
+			run, err := suite.service.RunMaintenance(context.Background(), entities.MaintenanceTriggerManual, test.tasks)
+
+			assert.Nil(suite.T(), run)
+			assert.ErrorIs(suite.T(), err, test.expectedError)
+		})
+	}
+}
+
+// TestLastRunErrors tests LastRun before maintenance has run and when it is disabled
+func (suite *MaintenanceServiceTestSuite) TestLastRunErrors() {
+	_, err := suite.service.LastRun()
+	assert.ErrorIs(suite.T(), err, ErrNoMaintenanceRun)
+
+	_, err = NewMaintenanceService(suite.mockRepo, false).LastRun()
+	assert.ErrorIs(suite.T(), err, ErrMaintenanceDisabled)
+}
+
+// TestCheck tests the health check before and after a run
+func (suite *MaintenanceServiceTestSuite) TestCheck() {
+	details, err := suite.service.Check(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), details)
+
+	suite.mockRepo.On("Optimize", mock.Anything).Return(nil).Once()
+	_, err = suite.service.RunMaintenance(context.Background(), entities.MaintenanceTriggerManual, []string{entities.MaintenanceOptimize})
+	assert.NoError(suite.T(), err)
+
+	details, err = suite.service.Check(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "2025-10-18T03:00:00Z", details["last_run"])
+	assert.Equal(suite.T(), entities.MaintenanceTriggerManual, details["trigger"])
+}
+
+// TestMaintenanceScheduler tests that scheduled maintenance runs until the scheduler stops
+func (suite *MaintenanceServiceTestSuite) TestMaintenanceScheduler() {
+	mockService := new(mocks.MockMaintenanceService)
+	called := make(chan struct{}, 10)
+	mockService.On("RunMaintenance", mock.Anything, entities.MaintenanceTriggerScheduled, []string(nil)).Run(func(mock.Arguments) {
+		called <- struct{}{}
+	}).Return(&entities.MaintenanceRun{
+		Trigger: entities.MaintenanceTriggerScheduled,
+		Tasks: []entities.MaintenanceTaskResult{
+			{Task: entities.MaintenanceAnalyze, Status: entities.MaintenanceStatusOK},
+			{Task: entities.MaintenanceOptimize, Status: entities.MaintenanceStatusFailed, Error: "database is locked"},
+		},
+	}, nil)
+
+	scheduler := NewMaintenanceScheduler(mockService, 5*time.Millisecond, nil)
+	scheduler.Start(context.Background())
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		suite.Fail("scheduled maintenance did not run")
+	}
+	scheduler.Stop()
+}
+
+// Run the test suite
+func TestMaintenanceServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceServiceTestSuite))
+}
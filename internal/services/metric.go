@@ -1,40 +1,240 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/gabrielg2020/monitor-api/internal/repository"
 )
 
+// MetricPublisher is notified of every metric once it has been stored, and of the new
+// values of a stored metric replaced by a duplicate
+type MetricPublisher interface {
+	PublishMetric(metric entities.SystemMetric)
+	PublishReplacedMetric(metric entities.SystemMetric)
+}
+
+// MetricSpooler takes metrics to write later. A metric is safe on disk once Spool
+// returns, and stored is called with the outcome when it reaches the database.
+type MetricSpooler interface {
+	Spool(ctx context.Context, metric *entities.SystemMetric, stored func(write entities.MetricWrite)) error
+}
+
+// TimestampLimits bound how far a metric's timestamp may be from the time the API
+// receives it. A zero limit is not checked.
+type TimestampLimits struct {
+	MaxAge   time.Duration // furthest behind the API's clock
+	MaxAhead time.Duration // furthest ahead of the API's clock
+}
+
 type MetricService struct {
-	repo repository.MetricRepositoryInterface
+	repo       repository.MetricRepositoryInterface
+	publisher  MetricPublisher
+	spooler    MetricSpooler
+	limits     TimestampLimits
+	validation string
+	clocks     *ClockSkewTracker
+	now        func() time.Time
+}
+
+// NewMetricService creates a MetricService. validation is one of the entities.Validation
+// modes, and publisher, spooler and clocks may be nil.
+func NewMetricService(
+	repo repository.MetricRepositoryInterface,
+	publisher MetricPublisher,
+	spooler MetricSpooler,
+	limits TimestampLimits,
+	validation string,
+	clocks *ClockSkewTracker,
+) *MetricService {
+	return &MetricService{
+		repo:       repo,
+		publisher:  publisher,
+		spooler:    spooler,
+		limits:     limits,
+		validation: validation,
+		clocks:     clocks,
+		now:        time.Now,
+	}
+}
+
+// CreateMetric stores a new metric record. An invalid metric fails with a
+// *ValidationError. When the host already has a metric at the timestamp the
+// repository's duplicate policy decides the outcome, and the reject policy fails with
+// ErrDuplicateMetric.
+func (service *MetricService) CreateMetric(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	violations, err := service.receive(ctx, metric)
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, err
+	}
+	write, err := service.repo.Create(ctx, metric)
+	if errors.Is(err, repository.ErrDuplicateMetric) {
+		return write, ErrDuplicateMetric
+	}
+	if err != nil {
+		return write, err
+	}
+
+	service.publish(*metric, write)
+	write.Violations = violations
+	return write, nil
+}
+
+// AcceptMetric stores a metric pushed by an agent. With a spooler the metric is
+// acknowledged once it is on disk and written to the database shortly after, so there
+// is no outcome yet and spooled is true. Without one it is the same as CreateMetric.
+func (service *MetricService) AcceptMetric(ctx context.Context, metric *entities.SystemMetric) (write entities.MetricWrite, spooled bool, err error) {
+	if service.spooler == nil {
+		write, err := service.CreateMetric(ctx, metric)
+		return write, false, err
+	}
+
+	violations, err := service.receive(ctx, metric)
+	if err != nil {
+		return entities.MetricWrite{ID: -1}, false, err
+	}
+	accepted := *metric
+	if err := service.spooler.Spool(ctx, metric, func(write entities.MetricWrite) { service.publish(accepted, write) }); err != nil {
+		return entities.MetricWrite{ID: -1}, false, err
+	}
+	return entities.MetricWrite{ID: -1, Violations: violations}, true, nil
+}
+
+// receive validates a metric and stamps it with the time it was received. A metric
+// without a timestamp is given that time; any other timestamp feeds the host's clock
+// skew estimate and must be within the limits. Once every field is in range, the
+// validation mode decides what happens to fields that contradict each other, and the
+// contradictions are returned.
+func (service *MetricService) receive(ctx context.Context, metric *entities.SystemMetric) ([]entities.FieldViolation, error) {
+	invalid := &ValidationError{}
+	validateRanges(metric, invalid)
+
+	metric.ReceivedAt = service.now().Unix()
+	switch {
+	case metric.Timestamp == 0:
+		metric.Timestamp = metric.ReceivedAt
+	case metric.Timestamp < 0:
+		invalid.add("timestamp", ErrTimestampTooOld, "is before 1970")
+	default:
+		service.checkTimestamp(metric, invalid)
+	}
+	if err := invalid.orNil(); err != nil {
+		return nil, err
+	}
+
+	violations, err := CheckMetricConsistency(metric, service.validation)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		slog.WarnContext(ctx, "storing metric with inconsistent fields",
+			"host_id", metric.HostID, "timestamp", metric.Timestamp, "validation", service.validation, "violations", violations)
+	}
+	return violations, nil
 }
 
-func NewMetricService(repo repository.MetricRepositoryInterface) *MetricService {
-	return &MetricService{repo: repo}
+// checkTimestamp observes the host's clock skew from the metric's timestamp and adds a
+// violation if it is outside the limits
+func (service *MetricService) checkTimestamp(metric *entities.SystemMetric, invalid *ValidationError) {
+	if service.clocks != nil && metric.HostID > 0 {
+		service.clocks.Observe(metric.HostID, metric.Timestamp, metric.ReceivedAt)
+	}
+
+	behind := metric.ReceivedAt - metric.Timestamp
+	if maxAge := int64(service.limits.MaxAge / time.Second); maxAge > 0 && behind > maxAge {
+		invalid.add("timestamp", ErrTimestampTooOld, fmt.Sprintf("is %ds behind the server clock, more than the %ds allowed", behind, maxAge))
+	}
+	if maxAhead := int64(service.limits.MaxAhead / time.Second); maxAhead > 0 && -behind > maxAhead {
+		invalid.add("timestamp", ErrTimestampTooNew, fmt.Sprintf("is %ds ahead of the server clock, more than the %ds allowed", -behind, maxAhead))
+	}
 }
 
-// CreateMetric stores a new metric record
-func (service *MetricService) CreateMetric(metric *entities.SystemMetric) (int64, error) {
-	if err := ValidateSystemMetric(metric); err != nil {
-		return -1, err
+// publish notifies the publisher of a stored metric. A deduplicated metric left the
+// stored one as it was, so subscribers aren't told about it again; a replaced one
+// changed it, so they are given the new values.
+func (service *MetricService) publish(metric entities.SystemMetric, write entities.MetricWrite) {
+	if service.publisher == nil || write.Deduplicated {
+		return
+	}
+	metric.ID = write.ID
+	if write.Replaced {
+		service.publisher.PublishReplacedMetric(metric)
+		return
 	}
-	return service.repo.Create(metric)
+	service.publisher.PublishMetric(metric)
 }
 
-// GetMetrics retrieves metrics based on query parameters
-func (service *MetricService) GetMetrics(params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
+// GetMetrics retrieves a page of metrics based on query parameters
+func (service *MetricService) GetMetrics(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, entities.PageInfo, error) {
+	var page entities.PageInfo
 	if params == nil {
-		return nil, ErrNilQueryParams
+		return nil, page, ErrNilQueryParams
 	}
 
-	if params != nil && *params.HostID <= 0 {
-		return nil, ErrInvalidHostID
+	if params.HostID != nil && *params.HostID <= 0 {
+		return nil, page, ErrInvalidHostID
+	}
+
+	if params.Cursor != "" {
+		after, err := decodeMetricCursor(params.Cursor)
+		if err != nil {
+			return nil, page, err
+		}
+		params.After = after
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	query := *params
+	if query.Limit > 0 {
+		query.Limit++
+	}
+
+	metrics, err := service.repo.FindByFilters(ctx, &query)
+	if err != nil {
+		return nil, page, err
+	}
+
+	if params.Limit > 0 && len(metrics) > params.Limit {
+		metrics = metrics[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeMetricCursor(metrics[len(metrics)-1])
 	}
 
-	return service.repo.FindByFilters(params)
+	return metrics, page, nil
+}
+
+// ExportMetrics streams every metric matching the query parameters to fn, joined
+// with its host. Unlike GetMetrics there is no page size limit.
+func (service *MetricService) ExportMetrics(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error {
+	if params == nil {
+		return ErrNilQueryParams
+	}
+
+	if params.HostID != nil && *params.HostID <= 0 {
+		return ErrInvalidHostID
+	}
+
+	if params.StartTime != nil && params.EndTime != nil && *params.StartTime > *params.EndTime {
+		return ErrInvalidTimeRange
+	}
+
+	return service.repo.StreamByFilters(ctx, params, fn)
 }
 
 // GetLatestMetric retrieves the most recent metric for a specific host or all hosts
-func (service *MetricService) GetLatestMetric(hostID *int64) (*entities.SystemMetric, error) {
-	return service.repo.FindLatest(hostID)
+func (service *MetricService) GetLatestMetric(ctx context.Context, hostID *int64) (*entities.SystemMetric, error) {
+	return service.repo.FindLatest(ctx, hostID)
+}
+
+// GetMetricsAfterID retrieves metrics stored after the given ID in insertion order
+func (service *MetricService) GetMetricsAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error) {
+	if hostID != nil && *hostID <= 0 {
+		return nil, ErrInvalidHostID
+	}
+
+	return service.repo.FindAfterID(ctx, afterID, hostID, limit)
 }
@@ -2,14 +2,18 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
 	"github.com/gabrielg2020/monitor-api/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -18,12 +22,15 @@ type MetricServiceTestSuite struct {
 	suite.Suite
 	mockRepo *mocks.MockMetricRepository
 	service  *MetricService
+	clock    time.Time
 }
 
 // SetupTest runs before each test in the suite
 func (suite *MetricServiceTestSuite) SetupTest() {
 	suite.mockRepo = new(mocks.MockMetricRepository)
-	suite.service = NewMetricService(suite.mockRepo)
+	suite.service = NewMetricService(suite.mockRepo, nil, nil, TimestampLimits{}, entities.ValidationReject, nil)
+	suite.clock = time.Unix(1760000000, 0)
+	suite.service.now = func() time.Time { return suite.clock }
 }
 
 // TearDownTest runs after each test
@@ -39,7 +46,8 @@ func (suite *MetricServiceTestSuite) TestNewMetricService() {
 
 // TestCreateMetric tests the CreateMetric method
 func (suite *MetricServiceTestSuite) TestCreateMetric() {
-	timestamp := time.Now().Unix()
+	timestamp := suite.clock.Unix() - 5
+	connectionErr := errors.New("database connection lost")
 
 	tests := []struct {
 		name          string
@@ -65,7 +73,7 @@ func (suite *MetricServiceTestSuite) TestCreateMetric() {
 				DiskAvailableBytes:   109000000000,
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.SystemMetric{
+				suite.mockRepo.On("Create", mock.Anything, &entities.SystemMetric{
 					HostID:               1,
 					Timestamp:            timestamp,
 					CPUUsage:             45.5,
@@ -77,7 +85,8 @@ func (suite *MetricServiceTestSuite) TestCreateMetric() {
 					DiskTotalBytes:       500000000000,
 					DiskUsedBytes:        391000000000,
 					DiskAvailableBytes:   109000000000,
-				}).Return(int64(1), nil).Once()
+					ReceivedAt:           timestamp + 5,
+				}).Return(entities.MetricWrite{ID: 1}, nil).Once()
 			},
 			expectedID:    1,
 			expectedError: nil,
@@ -177,16 +186,17 @@ func (suite *MetricServiceTestSuite) TestCreateMetric() {
 				DiskUsagePercent:   78.2,
 			},
 			setupMock: func() {
-				suite.mockRepo.On("Create", &entities.SystemMetric{
+				suite.mockRepo.On("Create", mock.Anything, &entities.SystemMetric{
 					HostID:             1,
 					Timestamp:          timestamp,
 					CPUUsage:           45.5,
 					MemoryUsagePercent: 67.8,
 					DiskUsagePercent:   78.2,
-				}).Return(int64(-1), errors.New("database connection lost")).Once()
+					ReceivedAt:         timestamp + 5,
+				}).Return(entities.MetricWrite{ID: -1}, connectionErr).Once()
 			},
 			expectedID:    -1,
-			expectedError: errors.New("database connection lost"),
+			expectedError: connectionErr,
 			description:   "Should return an error when there is a database connection issue",
 		},
 	}
@@ -195,12 +205,11 @@ func (suite *MetricServiceTestSuite) TestCreateMetric() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			id, err := suite.service.CreateMetric(test.metric)
+			write, err := suite.service.CreateMetric(context.Background(), test.metric)
 
-			assert.Equal(suite.T(), test.expectedID, id)
+			assert.Equal(suite.T(), test.expectedID, write.ID)
 			if test.expectedError != nil {
-				assert.Error(suite.T(), err)
-				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
+				assert.ErrorIs(suite.T(), err, test.expectedError)
 			} else {
 				assert.NoError(suite.T(), err)
 			}
@@ -255,12 +264,12 @@ func (suite *MetricServiceTestSuite) TestGetMetrics() {
 						DiskUsagePercent:   78.5,
 					},
 				}
-				suite.mockRepo.On("FindByFilters", &entities.MetricQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.MetricQueryParams{
 					HostID:    &hostID,
 					StartTime: &startTime,
 					EndTime:   &endTime,
 					Order:     "ASC",
-					Limit:     50,
+					Limit:     51,
 				}).Return(metrics, nil).Once()
 			},
 			expectedMetrics: []entities.SystemMetric{
@@ -312,10 +321,10 @@ func (suite *MetricServiceTestSuite) TestGetMetrics() {
 				Limit:  10,
 			},
 			setupMock: func() {
-				suite.mockRepo.On("FindByFilters", &entities.MetricQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.MetricQueryParams{
 					HostID: &hostID,
 					Order:  "DESC",
-					Limit:  10,
+					Limit:  11,
 				}).Return([]entities.SystemMetric{}, nil).Once()
 			},
 			expectedMetrics: []entities.SystemMetric{},
@@ -330,10 +339,10 @@ func (suite *MetricServiceTestSuite) TestGetMetrics() {
 				Limit:  100,
 			},
 			setupMock: func() {
-				suite.mockRepo.On("FindByFilters", &entities.MetricQueryParams{
+				suite.mockRepo.On("FindByFilters", mock.Anything, &entities.MetricQueryParams{
 					HostID: &hostID,
 					Order:  "DESC",
-					Limit:  100,
+					Limit:  101,
 				}).Return(nil, errors.New("query timeout")).Once()
 			},
 			expectedMetrics: nil,
@@ -346,9 +355,10 @@ func (suite *MetricServiceTestSuite) TestGetMetrics() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			metrics, err := suite.service.GetMetrics(test.params)
+			metrics, page, err := suite.service.GetMetrics(context.Background(), test.params)
 
 			assert.Equal(suite.T(), test.expectedMetrics, metrics)
+			assert.Equal(suite.T(), entities.PageInfo{}, page)
 			if test.expectedError != nil {
 				assert.Error(suite.T(), err)
 				assert.Equal(suite.T(), test.expectedError.Error(), err.Error())
@@ -363,6 +373,70 @@ func (suite *MetricServiceTestSuite) TestGetMetrics() {
 	}
 }
 
+// TestGetMetricsPagination tests that cursors walk through results a page at a time
+func (suite *MetricServiceTestSuite) TestGetMetricsPagination() {
+	firstPage := []entities.SystemMetric{
+		{ID: 5, Timestamp: 300},
+		{ID: 4, Timestamp: 200},
+		{ID: 3, Timestamp: 200},
+	}
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.MetricQueryParams{Order: "DESC", Limit: 3}).Return(firstPage, nil).Once()
+
+	metrics, page, err := suite.service.GetMetrics(context.Background(), &entities.MetricQueryParams{Order: "DESC", Limit: 2})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), firstPage[:2], metrics)
+	assert.True(suite.T(), page.HasMore)
+	assert.NotEmpty(suite.T(), page.NextCursor)
+
+	// The cursor resumes after the last metric returned
+	suite.mockRepo.On("FindByFilters", mock.Anything, &entities.MetricQueryParams{
+		Order:  "DESC",
+		Limit:  3,
+		Cursor: page.NextCursor,
+		After:  &entities.MetricCursor{Timestamp: 200, ID: 4},
+	}).Return([]entities.SystemMetric{{ID: 3, Timestamp: 200}}, nil).Once()
+
+	metrics, page, err = suite.service.GetMetrics(context.Background(), &entities.MetricQueryParams{Order: "DESC", Limit: 2, Cursor: page.NextCursor})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.SystemMetric{{ID: 3, Timestamp: 200}}, metrics)
+	assert.Equal(suite.T(), entities.PageInfo{}, page)
+}
+
+// TestGetMetricsInvalidCursor tests that malformed cursors are rejected
+func (suite *MetricServiceTestSuite) TestGetMetricsInvalidCursor() {
+	for _, cursor := range []string{"not base64!", "MTIz", "YTpi"} {
+		_, _, err := suite.service.GetMetrics(context.Background(), &entities.MetricQueryParams{Order: "DESC", Limit: 10, Cursor: cursor})
+		assert.Equal(suite.T(), ErrInvalidCursor, err, cursor)
+	}
+}
+
+// TestExportMetrics tests the ExportMetrics method
+func (suite *MetricServiceTestSuite) TestExportMetrics() {
+	hostID := int64(1)
+	invalidHostID := int64(0)
+	startTime := int64(200)
+	endTime := int64(100)
+
+	params := &entities.MetricQueryParams{HostID: &hostID, Order: "ASC"}
+	rows := []entities.MetricExportRow{{Metric: entities.SystemMetric{ID: 1}}, {Metric: entities.SystemMetric{ID: 2}}}
+	suite.mockRepo.On("StreamByFilters", mock.Anything, params).Return(rows, nil).Once()
+
+	var exported []int64
+	err := suite.service.ExportMetrics(context.Background(), params, func(row entities.MetricExportRow) error {
+		exported = append(exported, row.Metric.ID)
+		return nil
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []int64{1, 2}, exported)
+
+	noop := func(row entities.MetricExportRow) error { return nil }
+	assert.Equal(suite.T(), ErrNilQueryParams, suite.service.ExportMetrics(context.Background(), nil, noop))
+	assert.Equal(suite.T(), ErrInvalidHostID, suite.service.ExportMetrics(context.Background(), &entities.MetricQueryParams{HostID: &invalidHostID}, noop))
+	assert.Equal(suite.T(), ErrInvalidTimeRange, suite.service.ExportMetrics(context.Background(), &entities.MetricQueryParams{StartTime: &startTime, EndTime: &endTime}, noop))
+}
+
 // TestGetLatestMetric tests the GetMetrics method
 func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 	hostID := int64(1)
@@ -388,7 +462,7 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 			name:   "get_latest_metric",
 			hostID: nil,
 			setupMock: func() {
-				suite.mockRepo.On("FindLatest", (*int64)(nil)).Return(&latestMetric, nil).Once()
+				suite.mockRepo.On("FindLatest", mock.Anything, (*int64)(nil)).Return(&latestMetric, nil).Once()
 			},
 			expectedMetric: &latestMetric,
 			expectedError:  nil,
@@ -398,7 +472,7 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 			name:   "get_latest_metric_with_host_id",
 			hostID: &hostID,
 			setupMock: func() {
-				suite.mockRepo.On("FindLatest", &hostID).Return(&latestMetric, nil).Once()
+				suite.mockRepo.On("FindLatest", mock.Anything, &hostID).Return(&latestMetric, nil).Once()
 			},
 			expectedMetric: &latestMetric,
 			expectedError:  nil,
@@ -408,7 +482,7 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 			name:   "get_latest_metrics_empty_result",
 			hostID: &hostID,
 			setupMock: func() {
-				suite.mockRepo.On("FindLatest", &hostID).Return(nil, sql.ErrNoRows).Once()
+				suite.mockRepo.On("FindLatest", mock.Anything, &hostID).Return(nil, sql.ErrNoRows).Once()
 			},
 			expectedMetric: nil,
 			expectedError:  sql.ErrNoRows,
@@ -418,7 +492,7 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 			name:   "database_error",
 			hostID: &hostID,
 			setupMock: func() {
-				suite.mockRepo.On("FindLatest", &hostID).Return(nil, errors.New("query timeout")).Once()
+				suite.mockRepo.On("FindLatest", mock.Anything, &hostID).Return(nil, errors.New("query timeout")).Once()
 			},
 			expectedMetric: nil,
 			expectedError:  errors.New("query timeout"),
@@ -430,7 +504,7 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 		suite.Run(test.name, func() {
 			test.setupMock()
 
-			metric, err := suite.service.GetLatestMetric(test.hostID)
+			metric, err := suite.service.GetLatestMetric(context.Background(), test.hostID)
 
 			assert.Equal(suite.T(), test.expectedMetric, metric)
 			if test.expectedError != nil {
@@ -447,6 +521,461 @@ func (suite *MetricServiceTestSuite) TestGetLatestMetric() {
 	}
 }
 
+// recordingPublisher captures metrics published by the service
+type recordingPublisher struct {
+	metrics  []entities.SystemMetric
+	replaced []entities.SystemMetric
+}
+
+func (publisher *recordingPublisher) PublishMetric(metric entities.SystemMetric) {
+	publisher.metrics = append(publisher.metrics, metric)
+}
+
+func (publisher *recordingPublisher) PublishReplacedMetric(metric entities.SystemMetric) {
+	publisher.replaced = append(publisher.replaced, metric)
+}
+
+// TestCreateMetricPublishes tests that newly stored metrics are published with their ID
+func (suite *MetricServiceTestSuite) TestCreateMetricPublishes() {
+	publisher := &recordingPublisher{}
+	suite.service = NewMetricService(suite.mockRepo, publisher, nil, TimestampLimits{}, entities.ValidationReject, nil)
+	suite.service.now = func() time.Time { return suite.clock }
+
+	metric := &entities.SystemMetric{HostID: 1, Timestamp: 100, CPUUsage: 10}
+	suite.mockRepo.On("Create", mock.Anything, metric).Return(entities.MetricWrite{ID: 9}, nil).Once()
+	suite.mockRepo.On("Create", mock.Anything, &entities.SystemMetric{HostID: 2, Timestamp: 1760000000, ReceivedAt: 1760000000}).Return(entities.MetricWrite{ID: -1}, errors.New("FOREIGN KEY constraint failed")).Once()
+	suite.mockRepo.On("Create", mock.Anything, &entities.SystemMetric{HostID: 3, Timestamp: 1760000000, ReceivedAt: 1760000000}).Return(entities.MetricWrite{ID: 5, Deduplicated: true}, nil).Once()
+
+	_, err := suite.service.CreateMetric(context.Background(), metric)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.CreateMetric(context.Background(), &entities.SystemMetric{HostID: 2})
+	assert.Error(suite.T(), err)
+
+	// A duplicate was already published when it was first stored
+	write, err := suite.service.CreateMetric(context.Background(), &entities.SystemMetric{HostID: 3})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), write.Deduplicated)
+
+	assert.Equal(suite.T(), []entities.SystemMetric{{ID: 9, HostID: 1, Timestamp: 100, CPUUsage: 10, ReceivedAt: 1760000000}}, publisher.metrics)
+}
+
+// recordingSpooler captures spooled metrics, and stores them when store is called
+type recordingSpooler struct {
+	metrics []entities.SystemMetric
+	stored  []func(write entities.MetricWrite)
+	err     error
+}
+
+func (spooler *recordingSpooler) Spool(ctx context.Context, metric *entities.SystemMetric, stored func(write entities.MetricWrite)) error {
+	if spooler.err != nil {
+		return spooler.err
+	}
+	spooler.metrics = append(spooler.metrics, *metric)
+	spooler.stored = append(spooler.stored, stored)
+	return nil
+}
+
+// TestAcceptMetric tests that pushed metrics are spooled when there is a spooler, and
+// published once they are stored
+func (suite *MetricServiceTestSuite) TestAcceptMetric() {
+	// Without a spooler the metric is stored before returning
+	metric := &entities.SystemMetric{HostID: 1, Timestamp: 100, CPUUsage: 10}
+	suite.mockRepo.On("Create", mock.Anything, metric).Return(entities.MetricWrite{ID: 9}, nil).Once()
+
+	write, spooled, err := suite.service.AcceptMetric(context.Background(), metric)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), spooled)
+	assert.Equal(suite.T(), entities.MetricWrite{ID: 9}, write)
+
+	publisher := &recordingPublisher{}
+	spooler := &recordingSpooler{}
+	suite.service = NewMetricService(suite.mockRepo, publisher, spooler, TimestampLimits{}, entities.ValidationReject, nil)
+	suite.service.now = func() time.Time { return suite.clock }
+
+	// Invalid metrics never reach the spool
+	_, spooled, err = suite.service.AcceptMetric(context.Background(), &entities.SystemMetric{HostID: 1, CPUUsage: 150})
+	assert.ErrorIs(suite.T(), err, ErrInvalidCPUUsage)
+	assert.False(suite.T(), spooled)
+
+	write, spooled, err = suite.service.AcceptMetric(context.Background(), metric)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), spooled)
+	assert.Equal(suite.T(), int64(-1), write.ID)
+	assert.Equal(suite.T(), []entities.SystemMetric{*metric}, spooler.metrics)
+	assert.Empty(suite.T(), publisher.metrics)
+
+	// Published with its ID once written, unless it turns out to be a duplicate
+	spooler.stored[0](entities.MetricWrite{ID: 12})
+	assert.Equal(suite.T(), []entities.SystemMetric{{ID: 12, HostID: 1, Timestamp: 100, CPUUsage: 10, ReceivedAt: 1760000000}}, publisher.metrics)
+
+	_, _, err = suite.service.AcceptMetric(context.Background(), metric)
+	assert.NoError(suite.T(), err)
+	spooler.stored[1](entities.MetricWrite{ID: 12, Deduplicated: true})
+	assert.Len(suite.T(), publisher.metrics, 1)
+
+	// A spooled duplicate that replaced the stored values is published with them
+	_, _, err = suite.service.AcceptMetric(context.Background(), metric)
+	assert.NoError(suite.T(), err)
+	spooler.stored[2](entities.MetricWrite{ID: 12, Replaced: true})
+	assert.Len(suite.T(), publisher.metrics, 1)
+	assert.Equal(suite.T(), []entities.SystemMetric{{ID: 12, HostID: 1, Timestamp: 100, CPUUsage: 10, ReceivedAt: 1760000000}}, publisher.replaced)
+
+	spooler.err = ErrIngestQueueFull
+	_, spooled, err = suite.service.AcceptMetric(context.Background(), metric)
+	assert.Equal(suite.T(), ErrIngestQueueFull, err)
+	assert.False(suite.T(), spooled)
+}
+
+// TestCreateMetricDuplicate tests the outcome of a duplicate under each duplicate policy,
+// and that only a replaced one is published, with its new values
+func (suite *MetricServiceTestSuite) TestCreateMetricDuplicate() {
+	tests := []struct {
+		name             string
+		repoWrite        entities.MetricWrite
+		repoErr          error
+		expectedWrite    entities.MetricWrite
+		expectedError    error
+		expectedReplaced []entities.SystemMetric
+	}{
+		{
+			name:          "ignore",
+			repoWrite:     entities.MetricWrite{ID: 5, Deduplicated: true},
+			expectedWrite: entities.MetricWrite{ID: 5, Deduplicated: true},
+		},
+		{
+			name:             "replace",
+			repoWrite:        entities.MetricWrite{ID: 5, Replaced: true},
+			expectedWrite:    entities.MetricWrite{ID: 5, Replaced: true},
+			expectedReplaced: []entities.SystemMetric{{ID: 5, HostID: 1, Timestamp: 100, CPUUsage: 20, ReceivedAt: 1760000000}},
+		},
+		{
+			name:          "reject",
+			repoWrite:     entities.MetricWrite{ID: -1},
+			repoErr:       repository.ErrDuplicateMetric,
+			expectedWrite: entities.MetricWrite{ID: -1},
+			expectedError: ErrDuplicateMetric,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			publisher := &recordingPublisher{}
+			suite.service = NewMetricService(suite.mockRepo, publisher, nil, TimestampLimits{}, entities.ValidationReject, nil)
+			suite.service.now = func() time.Time { return suite.clock }
+
+			metric := &entities.SystemMetric{HostID: 1, Timestamp: 100, CPUUsage: 20}
+			suite.mockRepo.On("Create", mock.Anything, metric).Return(test.repoWrite, test.repoErr).Once()
+
+			write, err := suite.service.CreateMetric(context.Background(), metric)
+
+			assert.Equal(suite.T(), test.expectedError, err)
+			assert.Equal(suite.T(), test.expectedWrite, write)
+			assert.Empty(suite.T(), publisher.metrics)
+			assert.Equal(suite.T(), test.expectedReplaced, publisher.replaced)
+		})
+
+		// Reset mock for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestCreateMetricTimestamps tests that missing timestamps are given the receive time,
+// timestamps outside the limits are rejected, and every timestamp sent feeds the host's
+// clock skew estimate
+func (suite *MetricServiceTestSuite) TestCreateMetricTimestamps() {
+	now := suite.clock.Unix()
+	limits := TimestampLimits{MaxAge: time.Hour, MaxAhead: time.Minute}
+
+	tests := []struct {
+		name              string
+		limits            TimestampLimits
+		timestamp         int64
+		expectedTimestamp int64
+		expectedError     error
+		expectedSkew      *entities.ClockSkew
+	}{
+		{
+			name:              "missing_timestamp_defaults_to_receive_time",
+			limits:            limits,
+			timestamp:         0,
+			expectedTimestamp: now,
+		},
+		{
+			name:              "within_limits",
+			limits:            limits,
+			timestamp:         now - 3,
+			expectedTimestamp: now - 3,
+			expectedSkew:      &entities.ClockSkew{Seconds: -3, Samples: 1, ObservedAt: now},
+		},
+		{
+			name:          "too_far_behind",
+			limits:        limits,
+			timestamp:     now - 3601,
+			expectedError: ErrTimestampTooOld,
+			expectedSkew:  &entities.ClockSkew{Seconds: -3601, Samples: 1, ObservedAt: now},
+		},
+		{
+			name:          "clock_reset_to_1970",
+			limits:        limits,
+			timestamp:     86400,
+			expectedError: ErrTimestampTooOld,
+			expectedSkew:  &entities.ClockSkew{Seconds: 86400 - now, Samples: 1, ObservedAt: now},
+		},
+		{
+			name:          "too_far_ahead",
+			limits:        limits,
+			timestamp:     now + 61,
+			expectedError: ErrTimestampTooNew,
+			expectedSkew:  &entities.ClockSkew{Seconds: 61, Samples: 1, ObservedAt: now},
+		},
+		{
+			name:          "negative_timestamp",
+			timestamp:     -1,
+			expectedError: ErrTimestampTooOld,
+		},
+		{
+			name:              "limits_disabled",
+			timestamp:         86400,
+			expectedTimestamp: 86400,
+			expectedSkew:      &entities.ClockSkew{Seconds: 86400 - now, Samples: 1, ObservedAt: now},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			clocks := NewClockSkewTracker()
+			suite.service = NewMetricService(suite.mockRepo, nil, nil, test.limits, entities.ValidationReject, clocks)
+			suite.service.now = func() time.Time { return suite.clock }
+
+			if test.expectedError == nil {
+				suite.mockRepo.On("Create", mock.Anything, &entities.SystemMetric{
+					HostID: 1, Timestamp: test.expectedTimestamp, ReceivedAt: now,
+				}).Return(entities.MetricWrite{ID: 1}, nil).Once()
+			}
+
+			_, err := suite.service.CreateMetric(context.Background(), &entities.SystemMetric{HostID: 1, Timestamp: test.timestamp})
+
+			assert.ErrorIs(suite.T(), err, test.expectedError)
+			assert.Equal(suite.T(), test.expectedSkew, clocks.Skew(1))
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestCreateMetricViolations tests that every field out of range is reported at once
+func (suite *MetricServiceTestSuite) TestCreateMetricViolations() {
+	suite.service = NewMetricService(suite.mockRepo, nil, nil, TimestampLimits{MaxAge: time.Hour}, entities.ValidationWarn, nil)
+	suite.service.now = func() time.Time { return suite.clock }
+
+	_, err := suite.service.CreateMetric(context.Background(), &entities.SystemMetric{
+		HostID:           1,
+		Timestamp:        86400,
+		CPUUsage:         150,
+		MemoryTotalBytes: -1,
+	})
+
+	var invalid *ValidationError
+	suite.Require().ErrorAs(err, &invalid)
+	assert.ErrorIs(suite.T(), err, ErrInvalidMetric)
+	assert.ErrorIs(suite.T(), err, ErrInvalidCPUUsage)
+	assert.ErrorIs(suite.T(), err, ErrNegativeByteCount)
+	assert.ErrorIs(suite.T(), err, ErrTimestampTooOld)
+	assert.Equal(suite.T(), []entities.FieldViolation{
+		{Field: "cpu_usage", Message: "must be between 0 and 100, got 150"},
+		{Field: "memory_total_bytes", Message: "must not be negative, got -1"},
+		{Field: "timestamp", Message: fmt.Sprintf("is %ds behind the server clock, more than the 3600s allowed", suite.clock.Unix()-86400)},
+	}, invalid.Violations)
+}
+
+// TestCheckMetricConsistency tests each validation mode against memory and disk fields
+func (suite *MetricServiceTestSuite) TestCheckMetricConsistency() {
+	consistent := entities.SystemMetric{
+		MemoryUsagePercent:   75,
+		MemoryTotalBytes:     8000,
+		MemoryUsedBytes:      6000,
+		MemoryAvailableBytes: 2000,
+		// Like df, with blocks reserved for root
+		DiskUsagePercent:   50,
+		DiskTotalBytes:     10000,
+		DiskUsedBytes:      4000,
+		DiskAvailableBytes: 4000,
+	}
+	with := func(change func(metric *entities.SystemMetric)) entities.SystemMetric {
+		metric := consistent
+		change(&metric)
+		return metric
+	}
+	overcommitted := with(func(metric *entities.SystemMetric) {
+		metric.MemoryUsagePercent = 100
+		metric.MemoryUsedBytes = 9000
+		metric.MemoryAvailableBytes = 500
+	})
+	overcommittedViolations := []entities.FieldViolation{
+		{Field: "memory_used_bytes", Message: "is 9000, more than memory_total_bytes (8000)"},
+		{Field: "memory_available_bytes", Message: "plus memory_used_bytes is 9500, more than memory_total_bytes (8000)"},
+		{Field: "memory_usage_percent", Message: "is 100, but the byte counts give 112.5"},
+	}
+
+	tests := []struct {
+		name               string
+		mode               string
+		metric             entities.SystemMetric
+		expectedMetric     entities.SystemMetric
+		expectedViolations []entities.FieldViolation
+		expectedError      error
+	}{
+		{
+			name:           "consistent",
+			mode:           entities.ValidationReject,
+			metric:         consistent,
+			expectedMetric: consistent,
+		},
+		{
+			name:           "within_tolerance",
+			mode:           entities.ValidationReject,
+			metric:         with(func(metric *entities.SystemMetric) { metric.MemoryUsagePercent = 78 }),
+			expectedMetric: with(func(metric *entities.SystemMetric) { metric.MemoryUsagePercent = 78 }),
+		},
+		{
+			name:           "total_unknown",
+			mode:           entities.ValidationReject,
+			metric:         with(func(metric *entities.SystemMetric) { metric.DiskTotalBytes = 0 }),
+			expectedMetric: with(func(metric *entities.SystemMetric) { metric.DiskTotalBytes = 0 }),
+		},
+		{
+			name:               "reject",
+			mode:               entities.ValidationReject,
+			metric:             overcommitted,
+			expectedMetric:     overcommitted,
+			expectedViolations: overcommittedViolations,
+			expectedError:      ErrInconsistentMemory,
+		},
+		{
+			name:               "warn",
+			mode:               entities.ValidationWarn,
+			metric:             overcommitted,
+			expectedMetric:     overcommitted,
+			expectedViolations: overcommittedViolations,
+		},
+		{
+			// Capping the used bytes makes the percentage right again
+			name:   "correct_byte_counts",
+			mode:   entities.ValidationCorrect,
+			metric: overcommitted,
+			expectedMetric: with(func(metric *entities.SystemMetric) {
+				metric.MemoryUsagePercent = 100
+				metric.MemoryUsedBytes = 8000
+				metric.MemoryAvailableBytes = 0
+			}),
+			expectedViolations: []entities.FieldViolation{
+				{Field: "memory_used_bytes", Message: "is 9000, more than memory_total_bytes (8000)", Corrected: true},
+				{Field: "memory_available_bytes", Message: "plus memory_used_bytes is 8500, more than memory_total_bytes (8000)", Corrected: true},
+			},
+		},
+		{
+			name:           "correct_percent",
+			mode:           entities.ValidationCorrect,
+			metric:         with(func(metric *entities.SystemMetric) { metric.DiskUsagePercent = 5 }),
+			expectedMetric: with(func(metric *entities.SystemMetric) { metric.DiskUsagePercent = 40 }),
+			expectedViolations: []entities.FieldViolation{
+				{Field: "disk_usage_percent", Message: "is 5, but the byte counts give 40", Corrected: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			metric := test.metric
+
+			violations, err := CheckMetricConsistency(&metric, test.mode)
+
+			assert.Equal(suite.T(), test.expectedViolations, violations)
+			assert.Equal(suite.T(), test.expectedMetric, metric)
+			if test.expectedError == nil {
+				assert.NoError(suite.T(), err)
+			} else {
+				assert.ErrorIs(suite.T(), err, test.expectedError)
+				assert.ErrorIs(suite.T(), err, ErrInvalidMetric)
+			}
+		})
+	}
+}
+
+// TestCreateMetricInconsistent tests that the validation mode decides what is stored
+func (suite *MetricServiceTestSuite) TestCreateMetricInconsistent() {
+	metric := entities.SystemMetric{HostID: 1, Timestamp: 100, MemoryUsagePercent: 20, MemoryTotalBytes: 8000, MemoryUsedBytes: 6000}
+	violation := entities.FieldViolation{Field: "memory_usage_percent", Message: "is 20, but the byte counts give 75"}
+
+	tests := []struct {
+		name               string
+		mode               string
+		storedPercent      float64
+		expectedViolations []entities.FieldViolation
+		expectedError      error
+	}{
+		{
+			name:          "reject",
+			mode:          entities.ValidationReject,
+			expectedError: ErrInconsistentMemory,
+		},
+		{
+			name:               "warn",
+			mode:               entities.ValidationWarn,
+			storedPercent:      20,
+			expectedViolations: []entities.FieldViolation{violation},
+		},
+		{
+			name:               "correct",
+			mode:               entities.ValidationCorrect,
+			storedPercent:      75,
+			expectedViolations: []entities.FieldViolation{{Field: violation.Field, Message: violation.Message, Corrected: true}},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.service = NewMetricService(suite.mockRepo, nil, nil, TimestampLimits{}, test.mode, nil)
+			suite.service.now = func() time.Time { return suite.clock }
+
+			if test.expectedError == nil {
+				stored := metric
+				stored.MemoryUsagePercent = test.storedPercent
+				stored.ReceivedAt = suite.clock.Unix()
+				suite.mockRepo.On("Create", mock.Anything, &stored).Return(entities.MetricWrite{ID: 3}, nil).Once()
+			}
+
+			received := metric
+			write, err := suite.service.CreateMetric(context.Background(), &received)
+
+			assert.ErrorIs(suite.T(), err, test.expectedError)
+			assert.Equal(suite.T(), test.expectedViolations, write.Violations)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGetMetricsAfterID tests the GetMetricsAfterID method
+func (suite *MetricServiceTestSuite) TestGetMetricsAfterID() {
+	hostID := int64(2)
+	invalidHostID := int64(0)
+	suite.mockRepo.On("FindAfterID", mock.Anything, int64(10), &hostID, 50).Return([]entities.SystemMetric{{ID: 11}}, nil).Once()
+
+	metrics, err := suite.service.GetMetricsAfterID(context.Background(), 10, &hostID, 50)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []entities.SystemMetric{{ID: 11}}, metrics)
+
+	_, err = suite.service.GetMetricsAfterID(context.Background(), 10, &invalidHostID, 50)
+	assert.Equal(suite.T(), ErrInvalidHostID, err)
+}
+
 // Run the test suite
 func TestMetricServiceTestSuite(test *testing.T) {
 	suite.Run(test, new(MetricServiceTestSuite))
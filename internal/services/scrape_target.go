@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+)
+
+// Defaults applied to scrape targets that omit interval or timeout
+const (
+	defaultScrapeIntervalSeconds = 30
+	defaultScrapeTimeoutSeconds  = 10
+)
+
+type ScrapeTargetService struct {
+	repo     repository.ScrapeTargetRepositoryInterface
+	hostRepo repository.HostRepositoryInterface
+	onChange func()
+}
+
+func NewScrapeTargetService(repo repository.ScrapeTargetRepositoryInterface, hostRepo repository.HostRepositoryInterface) *ScrapeTargetService {
+	return &ScrapeTargetService{repo: repo, hostRepo: hostRepo}
+}
+
+// OnChange sets a function called whenever a target is created, updated or deleted.
+// It must be set before the service is used.
+func (service *ScrapeTargetService) OnChange(fn func()) {
+	service.onChange = fn
+}
+
+// CreateTarget registers a new scrape target
+func (service *ScrapeTargetService) CreateTarget(ctx context.Context, target *entities.ScrapeTarget) (int64, error) {
+	if err := service.validate(ctx, target); err != nil {
+		return -1, err
+	}
+
+	id, err := service.repo.Create(ctx, target)
+	if err != nil {
+		return id, err
+	}
+	service.changed()
+	return id, nil
+}
+
+// GetTargets retrieves scrape targets based on query parameters
+func (service *ScrapeTargetService) GetTargets(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error) {
+	if params == nil {
+		return nil, ErrNilQueryParams
+	}
+
+	return service.repo.FindByFilters(ctx, params)
+}
+
+// UpdateTarget replaces the configuration of an existing scrape target
+func (service *ScrapeTargetService) UpdateTarget(ctx context.Context, id int64, target *entities.ScrapeTarget) error {
+	if err := service.validate(ctx, target); err != nil {
+		return err
+	}
+
+	err := service.repo.Update(ctx, id, target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrScrapeTargetNotFound
+	}
+	if err != nil {
+		return err
+	}
+	service.changed()
+	return nil
+}
+
+// DeleteTarget deletes a scrape target by ID
+func (service *ScrapeTargetService) DeleteTarget(ctx context.Context, id int64) error {
+	err := service.repo.Delete(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrScrapeTargetNotFound
+	}
+	if err != nil {
+		return err
+	}
+	service.changed()
+	return nil
+}
+
+// RecordResult stores the health of the latest scrape of a target
+func (service *ScrapeTargetService) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error {
+	return service.repo.RecordResult(ctx, id, result)
+}
+
+// validate fills in a target's defaults and checks it, including that its host exists,
+// so a bad target is turned away rather than failing on every scrape
+func (service *ScrapeTargetService) validate(ctx context.Context, target *entities.ScrapeTarget) error {
+	applyScrapeTargetDefaults(target)
+	if err := ValidateScrapeTarget(target); err != nil {
+		return err
+	}
+
+	hosts, err := service.hostRepo.FindByFilters(ctx, &entities.HostQueryParams{ID: target.HostID})
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return ErrHostNotFound
+	}
+	return nil
+}
+
+// changed tells the OnChange function, if any, that the targets changed
+func (service *ScrapeTargetService) changed() {
+	if service.onChange != nil {
+		service.onChange()
+	}
+}
+
+// applyScrapeTargetDefaults fills in optional scrape target settings
+func applyScrapeTargetDefaults(target *entities.ScrapeTarget) {
+	if target.Format == "" {
+		target.Format = entities.ScrapeFormatPrometheus
+	}
+	if target.IntervalSeconds == 0 {
+		target.IntervalSeconds = defaultScrapeIntervalSeconds
+	}
+	if target.TimeoutSeconds == 0 {
+		target.TimeoutSeconds = min(defaultScrapeTimeoutSeconds, target.IntervalSeconds)
+	}
+	if target.Name == "" {
+		target.Name = target.URL
+	}
+}
@@ -0,0 +1,234 @@
+// nolint
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// ScrapeTargetServiceTestSuite is the test suite for ScrapeTargetService
+type ScrapeTargetServiceTestSuite struct {
+	suite.Suite
+	mockRepo     *mocks.MockScrapeTargetRepository
+	mockHostRepo *mocks.MockHostRepository
+	service      *ScrapeTargetService
+	changes      int
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ScrapeTargetServiceTestSuite) SetupTest() {
+	suite.mockRepo = new(mocks.MockScrapeTargetRepository)
+	suite.mockHostRepo = new(mocks.MockHostRepository)
+	suite.service = NewScrapeTargetService(suite.mockRepo, suite.mockHostRepo)
+	suite.changes = 0
+	suite.service.OnChange(func() { suite.changes++ })
+}
+
+// TearDownTest runs after each test
+func (suite *ScrapeTargetServiceTestSuite) TearDownTest() {
+	suite.mockRepo.AssertExpectations(suite.T())
+	suite.mockHostRepo.AssertExpectations(suite.T())
+}
+
+// expectHost sets up the lookup of the target's host
+func (suite *ScrapeTargetServiceTestSuite) expectHost(id int64, exists bool) {
+	var hosts []entities.Host
+	if exists {
+		hosts = []entities.Host{{ID: id, Hostname: "nas"}}
+	}
+	suite.mockHostRepo.On("FindByFilters", mock.Anything, &entities.HostQueryParams{ID: id}).Return(hosts, nil).Once()
+}
+
+// TestCreateTarget tests the CreateTarget method
+func (suite *ScrapeTargetServiceTestSuite) TestCreateTarget() {
+	tests := []struct {
+		name          string
+		target        *entities.ScrapeTarget
+		setupMock     func()
+		expectedID    int64
+		expectedError error
+	}{
+		{
+			name:   "defaults_applied",
+			target: &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 1},
+			setupMock: func() {
+				suite.expectHost(1, true)
+				suite.mockRepo.On("Create", mock.Anything, &entities.ScrapeTarget{
+					Name:            "http://nas:9100/metrics",
+					URL:             "http://nas:9100/metrics",
+					HostID:          1,
+					Format:          entities.ScrapeFormatPrometheus,
+					IntervalSeconds: 30,
+					TimeoutSeconds:  10,
+				}).Return(int64(1), nil).Once()
+			},
+			expectedID: 1,
+		},
+		{
+			name:          "invalid_host_id",
+			target:        &entities.ScrapeTarget{URL: "http://nas:9100/metrics"},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidHostID,
+		},
+		{
+			name:          "unknown_host",
+			target:        &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 4},
+			setupMock:     func() { suite.expectHost(4, false) },
+			expectedID:    -1,
+			expectedError: ErrHostNotFound,
+		},
+		{
+			name:          "invalid_url_scheme",
+			target:        &entities.ScrapeTarget{URL: "ftp://nas/metrics", HostID: 1},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidScrapeURL,
+		},
+		{
+			name:          "invalid_format",
+			target:        &entities.ScrapeTarget{URL: "http://nas/metrics", HostID: 1, Format: "xml"},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidScrapeFormat,
+		},
+		{
+			name:          "interval_too_short",
+			target:        &entities.ScrapeTarget{URL: "http://nas/metrics", HostID: 1, IntervalSeconds: 1},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidScrapeInterval,
+		},
+		{
+			name:          "timeout_longer_than_interval",
+			target:        &entities.ScrapeTarget{URL: "http://nas/metrics", HostID: 1, IntervalSeconds: 10, TimeoutSeconds: 20},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidScrapeTimeout,
+		},
+		{
+			name:          "json_without_mapping",
+			target:        &entities.ScrapeTarget{URL: "http://ups/status", HostID: 1, Format: "json"},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrMissingScrapeJSONPath,
+		},
+		{
+			name: "json_with_unknown_field",
+			target: &entities.ScrapeTarget{
+				URL: "http://ups/status", HostID: 1, Format: "json",
+				JSONMapping: map[string]string{"temperature": "temp"},
+			},
+			setupMock:     func() {},
+			expectedID:    -1,
+			expectedError: ErrInvalidScrapeJSONField,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			test.setupMock()
+
+			id, err := suite.service.CreateTarget(context.Background(), test.target)
+
+			assert.Equal(suite.T(), test.expectedID, id)
+			assert.Equal(suite.T(), test.expectedError, err)
+			if test.expectedError == nil {
+				assert.Equal(suite.T(), 1, suite.changes)
+			} else {
+				assert.Zero(suite.T(), suite.changes)
+			}
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestGetTargets tests the GetTargets method
+func (suite *ScrapeTargetServiceTestSuite) TestGetTargets() {
+	params := &entities.ScrapeTargetQueryParams{ActiveOnly: true}
+	expected := []entities.ScrapeTarget{{ID: 1}}
+	suite.mockRepo.On("FindByFilters", mock.Anything, params).Return(expected, nil).Once()
+
+	targets, err := suite.service.GetTargets(context.Background(), params)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), expected, targets)
+
+	_, err = suite.service.GetTargets(context.Background(), nil)
+	assert.Equal(suite.T(), ErrNilQueryParams, err)
+}
+
+// TestUpdateTarget tests the UpdateTarget method
+func (suite *ScrapeTargetServiceTestSuite) TestUpdateTarget() {
+	tests := []struct {
+		name            string
+		hostMissing     bool
+		repoError       error
+		expectedError   error
+		expectedChanges int
+	}{
+		{name: "successful_update", expectedChanges: 1},
+		{name: "host_not_found", hostMissing: true, expectedError: ErrHostNotFound},
+		{name: "target_not_found", repoError: sql.ErrNoRows, expectedError: ErrScrapeTargetNotFound},
+		{name: "database_error", repoError: errors.New("disk I/O error"), expectedError: errors.New("disk I/O error")},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			target := &entities.ScrapeTarget{URL: "http://nas:9100/metrics", HostID: 1}
+			suite.expectHost(1, !test.hostMissing)
+			if !test.hostMissing {
+				suite.mockRepo.On("Update", mock.Anything, int64(5), target).Return(test.repoError).Once()
+			}
+
+			err := suite.service.UpdateTarget(context.Background(), 5, target)
+
+			assert.Equal(suite.T(), test.expectedError, err)
+			assert.Equal(suite.T(), test.expectedChanges, suite.changes)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// TestDeleteTarget tests the DeleteTarget method
+func (suite *ScrapeTargetServiceTestSuite) TestDeleteTarget() {
+	suite.mockRepo.On("Delete", mock.Anything, int64(9)).Return(sql.ErrNoRows).Once()
+	suite.mockRepo.On("Delete", mock.Anything, int64(10)).Return(nil).Once()
+
+	err := suite.service.DeleteTarget(context.Background(), 9)
+	assert.Equal(suite.T(), ErrScrapeTargetNotFound, err)
+	assert.Zero(suite.T(), suite.changes)
+
+	err = suite.service.DeleteTarget(context.Background(), 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.changes)
+}
+
+// TestRecordResult tests the RecordResult method
+func (suite *ScrapeTargetServiceTestSuite) TestRecordResult() {
+	result := &entities.ScrapeResult{Timestamp: 100, Status: entities.ScrapeStatusOK}
+	suite.mockRepo.On("RecordResult", mock.Anything, int64(2), result).Return(nil).Once()
+
+	err := suite.service.RecordResult(context.Background(), 2, result)
+
+	assert.NoError(suite.T(), err)
+}
+
+// Run the test suite
+func TestScrapeTargetServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(ScrapeTargetServiceTestSuite))
+}
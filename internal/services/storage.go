@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/repository"
+	"github.com/gabrielg2020/monitor-api/internal/telemetry"
+)
+
+// storagePollInterval is how often the monitor reads the database file and volume sizes
+const storagePollInterval = 30 * time.Second
+
+// StorageMonitor watches the database files and the free space on the volume holding
+// them. Below the free space threshold the disk health check fails and, if enabled,
+// low-priority writes are paused until space is freed, so agent pushes keep working
+// for as long as possible.
+type StorageMonitor struct {
+	repo             repository.HealthRepositoryInterface
+	minFreeBytes     uint64 // 0 disables the threshold
+	pauseLowPriority bool
+	interval         time.Duration
+	instruments      *telemetry.Metrics
+
+	low atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewStorageMonitor(
+	repo repository.HealthRepositoryInterface,
+	minFreeBytes uint64,
+	pauseLowPriority bool,
+	instruments *telemetry.Metrics,
+) *StorageMonitor {
+	return &StorageMonitor{
+		repo:             repo,
+		minFreeBytes:     minFreeBytes,
+		pauseLowPriority: pauseLowPriority,
+		interval:         storagePollInterval,
+		instruments:      instruments,
+	}
+}
+
+// Start reads the sizes straight away, then on every interval in the background
+func (monitor *StorageMonitor) Start(ctx context.Context) {
+	ctx, monitor.cancel = context.WithCancel(ctx)
+
+	monitor.wg.Add(1)
+	go func() {
+		defer monitor.wg.Done()
+
+		ticker := time.NewTicker(monitor.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := monitor.refresh(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "failed to read storage statistics", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the polling loop
+func (monitor *StorageMonitor) Stop() {
+	if monitor.cancel != nil {
+		monitor.cancel()
+	}
+	monitor.wg.Wait()
+}
+
+// LowPriorityPaused reports whether scrapes and archive imports should hold off because
+// the volume is nearly full. A nil monitor never pauses.
+func (monitor *StorageMonitor) LowPriorityPaused() bool {
+	return monitor != nil && monitor.pauseLowPriority && monitor.low.Load()
+}
+
+// Check reads the current sizes and fails when free space is below the threshold, for
+// the health checks
+func (monitor *StorageMonitor) Check(ctx context.Context) (map[string]interface{}, error) {
+	stats, err := monitor.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{
+		"page_count":     stats.PageCount,
+		"page_size":      stats.PageSize,
+		"freelist_count": stats.FreelistCount,
+		"file_bytes":     stats.FileBytes,
+		"wal_bytes":      stats.WALBytes,
+	}
+	if stats.Volume == nil {
+		return details, nil
+	}
+
+	details["volume_path"] = stats.Volume.Path
+	details["volume_free_bytes"] = stats.Volume.FreeBytes
+	details["volume_total_bytes"] = stats.Volume.TotalBytes
+	details["min_free_bytes"] = monitor.minFreeBytes
+	details["low_priority_writes_paused"] = monitor.LowPriorityPaused()
+
+	if monitor.low.Load() {
+		return details, fmt.Errorf("only %d MiB free on %s, below the %d MiB threshold",
+			stats.Volume.FreeBytes>>20, stats.Volume.Path, monitor.minFreeBytes>>20)
+	}
+	return details, nil
+}
+
+// refresh reads the storage statistics, updates the low space state and logs when it
+// changes
+func (monitor *StorageMonitor) refresh(ctx context.Context) (*entities.StorageStats, error) {
+	stats, err := monitor.repo.GetStorageStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.instruments.SetStorage("file", float64(stats.FileBytes))
+	monitor.instruments.SetStorage("wal", float64(stats.WALBytes))
+	monitor.instruments.SetStorage("freelist", float64(stats.FreelistCount*stats.PageSize))
+	if stats.Volume == nil {
+		return stats, nil
+	}
+	monitor.instruments.SetStorage("volume_free", float64(stats.Volume.FreeBytes))
+	monitor.instruments.SetStorage("volume_total", float64(stats.Volume.TotalBytes))
+
+	low := monitor.minFreeBytes > 0 && stats.Volume.FreeBytes < monitor.minFreeBytes
+	if monitor.low.Swap(low) != low {
+		if low {
+			slog.WarnContext(ctx, "free disk space is below the threshold",
+				"path", stats.Volume.Path,
+				"free_bytes", stats.Volume.FreeBytes,
+				"min_free_bytes", monitor.minFreeBytes,
+				"low_priority_writes_paused", monitor.pauseLowPriority)
+		} else {
+			slog.InfoContext(ctx, "free disk space is above the threshold again",
+				"path", stats.Volume.Path,
+				"free_bytes", stats.Volume.FreeBytes)
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,152 @@
+// nolint
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// StorageMonitorTestSuite is the test suite for StorageMonitor
+type StorageMonitorTestSuite struct {
+	suite.Suite
+	mockRepo *mocks.MockHealthRepository
+}
+
+// SetupTest runs before each test in the suite
+func (suite *StorageMonitorTestSuite) SetupTest() {
+	suite.mockRepo = new(mocks.MockHealthRepository)
+}
+
+// TearDownTest runs after each test
+func (suite *StorageMonitorTestSuite) TearDownTest() {
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+// storageStats returns stats for a database on a volume with freeMiB left
+func storageStats(freeMiB uint64) *entities.StorageStats {
+	return &entities.StorageStats{
+		PageCount:     2560,
+		PageSize:      4096,
+		FreelistCount: 16,
+		FileBytes:     10 << 20,
+		WALBytes:      1 << 20,
+		Volume:        &entities.VolumeStats{Path: "/data", FreeBytes: freeMiB << 20, TotalBytes: 32 << 30},
+	}
+}
+
+// TestCheck tests that the check fails below the free space threshold
+func (suite *StorageMonitorTestSuite) TestCheck() {
+	tests := []struct {
+		name           string
+		stats          *entities.StorageStats
+		minFreeMiB     uint64
+		pause          bool
+		expectedError  string
+		expectedPaused bool
+	}{
+		{name: "plenty_of_space", stats: storageStats(4096), minFreeMiB: 512, pause: true},
+		{
+			name:           "below_threshold",
+			stats:          storageStats(100),
+			minFreeMiB:     512,
+			pause:          true,
+			expectedError:  "only 100 MiB free on /data, below the 512 MiB threshold",
+			expectedPaused: true,
+		},
+		{
+			name:          "below_threshold_without_pausing",
+			stats:         storageStats(100),
+			minFreeMiB:    512,
+			expectedError: "only 100 MiB free on /data, below the 512 MiB threshold",
+		},
+		{name: "threshold_disabled", stats: storageStats(1), minFreeMiB: 0, pause: true},
+		{name: "no_volume", stats: &entities.StorageStats{FileBytes: 1 << 20}, minFreeMiB: 512, pause: true},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.mockRepo.On("GetStorageStats", mock.Anything).Return(test.stats, nil).Once()
+			monitor := NewStorageMonitor(suite.mockRepo, test.minFreeMiB<<20, test.pause, nil)
+
+			details, err := monitor.Check(context.Background())
+
+			if test.expectedError != "" {
+				assert.EqualError(suite.T(), err, test.expectedError)
+			} else {
+				assert.NoError(suite.T(), err)
+			}
+			assert.Equal(suite.T(), test.stats.FileBytes, details["file_bytes"])
+			assert.Equal(suite.T(), test.expectedPaused, monitor.LowPriorityPaused())
+		})
+	}
+}
+
+// TestCheckRecovers tests that writes resume once space is freed
+func (suite *StorageMonitorTestSuite) TestCheckRecovers() {
+	monitor := NewStorageMonitor(suite.mockRepo, 512<<20, true, nil)
+
+	suite.mockRepo.On("GetStorageStats", mock.Anything).Return(storageStats(100), nil).Once()
+	_, err := monitor.Check(context.Background())
+	assert.Error(suite.T(), err)
+	assert.True(suite.T(), monitor.LowPriorityPaused())
+
+	suite.mockRepo.On("GetStorageStats", mock.Anything).Return(storageStats(2048), nil).Once()
+	details, err := monitor.Check(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), monitor.LowPriorityPaused())
+	assert.Equal(suite.T(), false, details["low_priority_writes_paused"])
+}
+
+// TestCheckRepositoryError tests that a failure to read the sizes fails the check
+func (suite *StorageMonitorTestSuite) TestCheckRepositoryError() {
+	suite.mockRepo.On("GetStorageStats", mock.Anything).Return(nil, errors.New("failed to read free space: permission denied")).Once()
+	monitor := NewStorageMonitor(suite.mockRepo, 512<<20, true, nil)
+
+	details, err := monitor.Check(context.Background())
+
+	assert.Nil(suite.T(), details)
+	assert.EqualError(suite.T(), err, "failed to read free space: permission denied")
+	assert.False(suite.T(), monitor.LowPriorityPaused())
+}
+
+// TestNilMonitorNeverPauses tests that components built without a monitor keep writing
+func (suite *StorageMonitorTestSuite) TestNilMonitorNeverPauses() {
+	var monitor *StorageMonitor
+
+	assert.False(suite.T(), monitor.LowPriorityPaused())
+}
+
+// TestStartStop tests that the monitor polls until it is stopped
+func (suite *StorageMonitorTestSuite) TestStartStop() {
+	polled := make(chan struct{}, 10)
+	suite.mockRepo.On("GetStorageStats", mock.Anything).Run(func(mock.Arguments) {
+		polled <- struct{}{}
+	}).Return(storageStats(100), nil)
+
+	monitor := NewStorageMonitor(suite.mockRepo, 512<<20, true, nil)
+	monitor.interval = 5 * time.Millisecond
+	monitor.Start(context.Background())
+
+	for range 2 {
+		select {
+		case <-polled:
+		case <-time.After(time.Second):
+			suite.Fail("storage was not polled")
+		}
+	}
+	monitor.Stop()
+	assert.True(suite.T(), monitor.LowPriorityPaused())
+}
+
+// Run the test suite
+func TestStorageMonitorTestSuite(t *testing.T) {
+	suite.Run(t, new(StorageMonitorTestSuite))
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/internal/tracing"
+)
+
+// TracedArchiveService records a span for every call to an ArchiveServiceInterface
+type TracedArchiveService struct {
+	next ArchiveServiceInterface
+}
+
+func NewTracedArchiveService(next ArchiveServiceInterface) *TracedArchiveService {
+	return &TracedArchiveService{next: next}
+}
+
+func (service *TracedArchiveService) Archive(ctx context.Context, req *entities.ArchiveRequest) (result *entities.ArchiveResult, err error) {
+	ctx, span := tracing.Start(ctx, "ArchiveService.Archive")
+	defer tracing.End(span, &err)
+	return service.next.Archive(ctx, req)
+}
+
+func (service *TracedArchiveService) Import(ctx context.Context, req *entities.ImportRequest) (result *entities.ImportResult, err error) {
+	ctx, span := tracing.Start(ctx, "ArchiveService.Import")
+	defer tracing.End(span, &err)
+	return service.next.Import(ctx, req)
+}
+
+// TracedBackupService records a span for every call to a BackupServiceInterface
+type TracedBackupService struct {
+	next BackupServiceInterface
+}
+
+func NewTracedBackupService(next BackupServiceInterface) *TracedBackupService {
+	return &TracedBackupService{next: next}
+}
+
+func (service *TracedBackupService) CreateBackup(ctx context.Context) (backup *entities.Backup, err error) {
+	ctx, span := tracing.Start(ctx, "BackupService.CreateBackup")
+	defer tracing.End(span, &err)
+	return service.next.CreateBackup(ctx)
+}
+
+// ListBackups only reads the backup directory, so it isn't traced
+func (service *TracedBackupService) ListBackups() ([]entities.Backup, error) {
+	return service.next.ListBackups()
+}
+
+// TracedHealthService records a span for every call to a HealthServiceInterface
+type TracedHealthService struct {
+	next HealthServiceInterface
+}
+
+func NewTracedHealthService(next HealthServiceInterface) *TracedHealthService {
+	return &TracedHealthService{next: next}
+}
+
+func (service *TracedHealthService) CheckHealth(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "HealthService.CheckHealth")
+	defer tracing.End(span, &err)
+	return service.next.CheckHealth(ctx)
+}
+
+func (service *TracedHealthService) CheckReadiness(ctx context.Context) *entities.HealthReport {
+	ctx, span := tracing.Start(ctx, "HealthService.CheckReadiness")
+	defer span.End()
+	return service.next.CheckReadiness(ctx)
+}
+
+func (service *TracedHealthService) GetDetailedHealth(ctx context.Context) *entities.DetailedHealth {
+	ctx, span := tracing.Start(ctx, "HealthService.GetDetailedHealth")
+	defer span.End()
+	return service.next.GetDetailedHealth(ctx)
+}
+
+// TracedMaintenanceService records a span for every call to a MaintenanceServiceInterface
+type TracedMaintenanceService struct {
+	next MaintenanceServiceInterface
+}
+
+func NewTracedMaintenanceService(next MaintenanceServiceInterface) *TracedMaintenanceService {
+	return &TracedMaintenanceService{next: next}
+}
+
+func (service *TracedMaintenanceService) RunMaintenance(ctx context.Context, trigger string, tasks []string) (run *entities.MaintenanceRun, err error) {
+	ctx, span := tracing.Start(ctx, "MaintenanceService.RunMaintenance")
+	defer tracing.End(span, &err)
+	return service.next.RunMaintenance(ctx, trigger, tasks)
+}
+
+// LastRun only reads the recorded run, so it isn't traced
+func (service *TracedMaintenanceService) LastRun() (*entities.MaintenanceRun, error) {
+	return service.next.LastRun()
+}
+
+// TracedHostService records a span for every call to a HostServiceInterface
+type TracedHostService struct {
+	next HostServiceInterface
+}
+
+func NewTracedHostService(next HostServiceInterface) *TracedHostService {
+	return &TracedHostService{next: next}
+}
+
+func (service *TracedHostService) CreateHost(ctx context.Context, host *entities.Host) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "HostService.CreateHost")
+	defer tracing.End(span, &err)
+	return service.next.CreateHost(ctx, host)
+}
+
+func (service *TracedHostService) GetHosts(ctx context.Context, params *entities.HostQueryParams) (hosts []entities.Host, page entities.PageInfo, err error) {
+	ctx, span := tracing.Start(ctx, "HostService.GetHosts")
+	defer tracing.End(span, &err)
+	return service.next.GetHosts(ctx, params)
+}
+
+func (service *TracedHostService) UpdateHost(ctx context.Context, id int64, host *entities.Host) (err error) {
+	ctx, span := tracing.Start(ctx, "HostService.UpdateHost")
+	defer tracing.End(span, &err)
+	return service.next.UpdateHost(ctx, id, host)
+}
+
+func (service *TracedHostService) DeleteHost(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "HostService.DeleteHost")
+	defer tracing.End(span, &err)
+	return service.next.DeleteHost(ctx, id)
+}
+
+// TracedMetricService records a span for every call to a MetricServiceInterface
+type TracedMetricService struct {
+	next MetricServiceInterface
+}
+
+func NewTracedMetricService(next MetricServiceInterface) *TracedMetricService {
+	return &TracedMetricService{next: next}
+}
+
+func (service *TracedMetricService) CreateMetric(ctx context.Context, metric *entities.SystemMetric) (write entities.MetricWrite, err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.CreateMetric")
+	defer tracing.End(span, &err)
+	return service.next.CreateMetric(ctx, metric)
+}
+
+func (service *TracedMetricService) AcceptMetric(ctx context.Context, metric *entities.SystemMetric) (write entities.MetricWrite, spooled bool, err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.AcceptMetric")
+	defer tracing.End(span, &err)
+	return service.next.AcceptMetric(ctx, metric)
+}
+
+func (service *TracedMetricService) GetMetrics(ctx context.Context, params *entities.MetricQueryParams) (metrics []entities.SystemMetric, page entities.PageInfo, err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.GetMetrics")
+	defer tracing.End(span, &err)
+	return service.next.GetMetrics(ctx, params)
+}
+
+func (service *TracedMetricService) ExportMetrics(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) (err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.ExportMetrics")
+	defer tracing.End(span, &err)
+	return service.next.ExportMetrics(ctx, params, fn)
+}
+
+func (service *TracedMetricService) GetLatestMetric(ctx context.Context, hostID *int64) (metric *entities.SystemMetric, err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.GetLatestMetric")
+	defer tracing.End(span, &err)
+	return service.next.GetLatestMetric(ctx, hostID)
+}
+
+func (service *TracedMetricService) GetMetricsAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) (metrics []entities.SystemMetric, err error) {
+	ctx, span := tracing.Start(ctx, "MetricService.GetMetricsAfterID")
+	defer tracing.End(span, &err)
+	return service.next.GetMetricsAfterID(ctx, afterID, hostID, limit)
+}
+
+// TracedScrapeTargetService records a span for every call to a ScrapeTargetServiceInterface
+type TracedScrapeTargetService struct {
+	next ScrapeTargetServiceInterface
+}
+
+func NewTracedScrapeTargetService(next ScrapeTargetServiceInterface) *TracedScrapeTargetService {
+	return &TracedScrapeTargetService{next: next}
+}
+
+func (service *TracedScrapeTargetService) CreateTarget(ctx context.Context, target *entities.ScrapeTarget) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "ScrapeTargetService.CreateTarget")
+	defer tracing.End(span, &err)
+	return service.next.CreateTarget(ctx, target)
+}
+
+func (service *TracedScrapeTargetService) GetTargets(ctx context.Context, params *entities.ScrapeTargetQueryParams) (targets []entities.ScrapeTarget, err error) {
+	ctx, span := tracing.Start(ctx, "ScrapeTargetService.GetTargets")
+	defer tracing.End(span, &err)
+	return service.next.GetTargets(ctx, params)
+}
+
+func (service *TracedScrapeTargetService) UpdateTarget(ctx context.Context, id int64, target *entities.ScrapeTarget) (err error) {
+	ctx, span := tracing.Start(ctx, "ScrapeTargetService.UpdateTarget")
+	defer tracing.End(span, &err)
+	return service.next.UpdateTarget(ctx, id, target)
+}
+
+func (service *TracedScrapeTargetService) DeleteTarget(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ScrapeTargetService.DeleteTarget")
+	defer tracing.End(span, &err)
+	return service.next.DeleteTarget(ctx, id)
+}
+
+func (service *TracedScrapeTargetService) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) (err error) {
+	ctx, span := tracing.Start(ctx, "ScrapeTargetService.RecordResult")
+	defer tracing.End(span, &err)
+	return service.next.RecordResult(ctx, id, result)
+}
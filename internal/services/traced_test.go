@@ -0,0 +1,80 @@
+// nolint
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+	"github.com/gabrielg2020/monitor-api/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedServiceTestSuite is the test suite for the traced services
+type TracedServiceTestSuite struct {
+	suite.Suite
+	recorder *tracetest.SpanRecorder
+	previous trace.TracerProvider
+}
+
+// SetupTest runs before each test in the suite
+func (suite *TracedServiceTestSuite) SetupTest() {
+	suite.recorder = tracetest.NewSpanRecorder()
+	suite.previous = otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(suite.recorder)))
+}
+
+// TearDownTest runs after each test
+func (suite *TracedServiceTestSuite) TearDownTest() {
+	otel.SetTracerProvider(suite.previous)
+}
+
+// TestHostService tests that calls are wrapped in spans that record failures
+func (suite *TracedServiceTestSuite) TestHostService() {
+	mockService := new(mocks.MockHostService)
+	mockService.On("CreateHost", mock.Anything, mock.Anything).Return(int64(7), nil).Once()
+	mockService.On("DeleteHost", mock.Anything, int64(7)).Return(ErrHostNotFound).Once()
+
+	service := NewTracedHostService(mockService)
+
+	id, err := service.CreateHost(context.Background(), &entities.Host{Hostname: "pi-1"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(7), id)
+
+	err = service.DeleteHost(context.Background(), 7)
+	assert.True(suite.T(), errors.Is(err, ErrHostNotFound))
+
+	spans := suite.recorder.Ended()
+	suite.Require().Len(spans, 2)
+	assert.Equal(suite.T(), "HostService.CreateHost", spans[0].Name())
+	assert.Equal(suite.T(), codes.Unset, spans[0].Status().Code)
+	assert.Equal(suite.T(), "HostService.DeleteHost", spans[1].Name())
+	assert.Equal(suite.T(), codes.Error, spans[1].Status().Code)
+	mockService.AssertExpectations(suite.T())
+}
+
+// TestSpanPropagation tests that the wrapped service receives the span's context
+func (suite *TracedServiceTestSuite) TestSpanPropagation() {
+	mockService := new(mocks.MockMetricService)
+	mockService.On("GetLatestMetric", mock.Anything, (*int64)(nil)).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		assert.True(suite.T(), trace.SpanContextFromContext(ctx).IsValid())
+	}).Return(&entities.SystemMetric{ID: 1}, nil).Once()
+
+	_, err := NewTracedMetricService(mockService).GetLatestMetric(context.Background(), nil)
+	assert.NoError(suite.T(), err)
+	mockService.AssertExpectations(suite.T())
+}
+
+// Run the test suite
+func TestTracedServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(TracedServiceTestSuite))
+}
@@ -1,32 +1,275 @@
 package services
 
-import "github.com/gabrielg2020/monitor-api/internal/entities"
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
 
-// ValidateSystemMetric validates metric data
+	"github.com/gabrielg2020/monitor-api/internal/entities"
+)
+
+// consistencyTolerance is how many percentage points a usage percentage may be from
+// the one its byte counts give, and how far past the total used plus available bytes
+// may come, as an agent doesn't read every counter at the same instant
+const consistencyTolerance = 5.0
+
+// ValidationError lists every rule a metric breaks. errors.Is matches it against
+// ErrInvalidMetric and the error of each rule broken, such as ErrInvalidCPUUsage.
+type ValidationError struct {
+	Violations []entities.FieldViolation
+	errs       []error
+}
+
+func (invalid *ValidationError) Error() string {
+	messages := make([]string, len(invalid.Violations))
+	for i, violation := range invalid.Violations {
+		messages[i] = violation.Field + " " + violation.Message
+	}
+	return ErrInvalidMetric.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (invalid *ValidationError) Unwrap() []error {
+	return append([]error{ErrInvalidMetric}, invalid.errs...)
+}
+
+// add records that field breaks the rule err stands for
+func (invalid *ValidationError) add(field string, err error, message string) {
+	invalid.Violations = append(invalid.Violations, entities.FieldViolation{Field: field, Message: message})
+	invalid.errs = append(invalid.errs, err)
+}
+
+// orNil returns the error, or nil when no rule was broken
+func (invalid *ValidationError) orNil() error {
+	if len(invalid.Violations) == 0 {
+		return nil
+	}
+	return invalid
+}
+
+// usage points at the fields describing how much of one resource a host is using
+type usage struct {
+	name                   string // prefix of the field names
+	rangeErr               error  // the percentage is outside 0-100
+	inconsistentErr        error  // the percentage and byte counts disagree
+	percent                *float64
+	total, used, available *int64
+}
+
+// usagesOf returns the memory and disk usage fields of a metric
+func usagesOf(metric *entities.SystemMetric) []usage {
+	return []usage{
+		{
+			name:            "memory",
+			rangeErr:        ErrInvalidMemoryUsage,
+			inconsistentErr: ErrInconsistentMemory,
+			percent:         &metric.MemoryUsagePercent,
+			total:           &metric.MemoryTotalBytes,
+			used:            &metric.MemoryUsedBytes,
+			available:       &metric.MemoryAvailableBytes,
+		},
+		{
+			name:            "disk",
+			rangeErr:        ErrInvalidDiskUsage,
+			inconsistentErr: ErrInconsistentDisk,
+			percent:         &metric.DiskUsagePercent,
+			total:           &metric.DiskTotalBytes,
+			used:            &metric.DiskUsedBytes,
+			available:       &metric.DiskAvailableBytes,
+		},
+	}
+}
+
+// ValidateSystemMetric checks that each field of a metric is within its range,
+// returning a *ValidationError listing every field that isn't
 func ValidateSystemMetric(params *entities.SystemMetric) error {
+	invalid := &ValidationError{}
+	validateRanges(params, invalid)
+	return invalid.orNil()
+}
+
+// validateRanges adds a violation for each field of the metric outside its range
+func validateRanges(params *entities.SystemMetric, invalid *ValidationError) {
 	// ID
 	if params.ID < 0 {
-		return ErrInvalidHostID
+		invalid.add("id", ErrInvalidHostID, "must not be negative")
 	}
 
 	// HostID
 	if params.HostID <= 0 {
-		return ErrInvalidHostID
+		invalid.add("host_id", ErrInvalidHostID, "must be positive")
 	}
 
 	// CPU Usage
 	if params.CPUUsage < 0 || params.CPUUsage > 100 {
-		return ErrInvalidCPUUsage
+		invalid.add("cpu_usage", ErrInvalidCPUUsage, fmt.Sprintf("must be between 0 and 100, got %g", params.CPUUsage))
+	}
+
+	// Memory and Disk Usage
+	for _, usage := range usagesOf(params) {
+		if *usage.percent < 0 || *usage.percent > 100 {
+			invalid.add(usage.name+"_usage_percent", usage.rangeErr, fmt.Sprintf("must be between 0 and 100, got %g", *usage.percent))
+		}
+		suffixes := [...]string{"total", "used", "available"}
+		for i, bytes := range [...]int64{*usage.total, *usage.used, *usage.available} {
+			if bytes < 0 {
+				invalid.add(usage.name+"_"+suffixes[i]+"_bytes", ErrNegativeByteCount, fmt.Sprintf("must not be negative, got %d", bytes))
+			}
+		}
+	}
+}
+
+// consistencyRule is a relationship between a resource's usage fields. check describes
+// how the fields break it, or returns "" when they don't, and correct changes them so
+// they no longer do. Rules are only checked when the total is known.
+type consistencyRule struct {
+	field   string // suffix of the field the violation is reported against
+	check   func(usage usage) string
+	correct func(usage usage)
+}
+
+// consistencyRules are checked in order, so a correction is made before the rules
+// after it see the fields. The byte counts are corrected first as the percentage is
+// derived from them.
+var consistencyRules = []consistencyRule{
+	{
+		field: "used_bytes",
+		check: func(usage usage) string {
+			if *usage.used <= *usage.total {
+				return ""
+			}
+			return fmt.Sprintf("is %d, more than %s_total_bytes (%d)", *usage.used, usage.name, *usage.total)
+		},
+		correct: func(usage usage) { *usage.used = *usage.total },
+	},
+	{
+		field: "available_bytes",
+		check: func(usage usage) string {
+			if *usage.available <= *usage.total {
+				return ""
+			}
+			return fmt.Sprintf("is %d, more than %s_total_bytes (%d)", *usage.available, usage.name, *usage.total)
+		},
+		correct: func(usage usage) { *usage.available = *usage.total },
+	},
+	{
+		field: "available_bytes",
+		check: func(usage usage) string {
+			sum := *usage.used + *usage.available
+			if float64(sum) <= float64(*usage.total)*(1+consistencyTolerance/100) {
+				return ""
+			}
+			return fmt.Sprintf("plus %s_used_bytes is %d, more than %s_total_bytes (%d)", usage.name, sum, usage.name, *usage.total)
+		},
+		correct: func(usage usage) { *usage.available = *usage.total - *usage.used },
+	},
+	{
+		field: "usage_percent",
+		check: func(usage usage) string {
+			if *usage.used == 0 && *usage.available == 0 {
+				return ""
+			}
+			// Agents divide by the total, or like df by used plus available, and some
+			// count memory as used when it isn't available
+			derived := []float64{
+				percentOf(*usage.used, *usage.total),
+				percentOf(*usage.total-*usage.available, *usage.total),
+				percentOf(*usage.used, *usage.used+*usage.available),
+			}
+			for _, percent := range derived {
+				if math.Abs(*usage.percent-percent) <= consistencyTolerance {
+					return ""
+				}
+			}
+			return fmt.Sprintf("is %g, but the byte counts give %g", *usage.percent, derivedPercent(usage))
+		},
+		correct: func(usage usage) { *usage.percent = derivedPercent(usage) },
+	},
+}
+
+// derivedPercent is the usage percentage the byte counts give, from the used bytes when
+// they were sent and otherwise from the available bytes
+func derivedPercent(usage usage) float64 {
+	percent := percentOf(*usage.used, *usage.total)
+	if *usage.used == 0 {
+		percent = percentOf(*usage.total-*usage.available, *usage.total)
+	}
+	return math.Round(percent*100) / 100
+}
+
+// percentOf returns part as a percentage of whole, or 0 when whole is 0
+func percentOf(part, whole int64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+// CheckMetricConsistency checks that each resource's usage percentage and byte counts
+// agree, as the mode says: a metric breaking a rule fails with a *ValidationError in
+// reject mode, is left as it is in warn mode, and has the fields derived from its byte
+// counts in correct mode. The violations found are returned in every mode.
+func CheckMetricConsistency(metric *entities.SystemMetric, mode string) ([]entities.FieldViolation, error) {
+	inconsistent := &ValidationError{}
+	for _, usage := range usagesOf(metric) {
+		if *usage.total <= 0 {
+			continue
+		}
+		for _, rule := range consistencyRules {
+			message := rule.check(usage)
+			if message == "" {
+				continue
+			}
+			inconsistent.add(usage.name+"_"+rule.field, usage.inconsistentErr, message)
+			if mode == entities.ValidationCorrect {
+				rule.correct(usage)
+				inconsistent.Violations[len(inconsistent.Violations)-1].Corrected = true
+			}
+		}
+	}
+
+	if mode == entities.ValidationReject {
+		return inconsistent.Violations, inconsistent.orNil()
+	}
+	return inconsistent.Violations, nil
+}
+
+// ValidateScrapeTarget validates scrape target configuration
+func ValidateScrapeTarget(target *entities.ScrapeTarget) error {
+	// HostID
+	if target.HostID <= 0 {
+		return ErrInvalidHostID
 	}
 
-	// Memory Usage
-	if params.MemoryUsagePercent < 0 || params.MemoryUsagePercent > 100 {
-		return ErrInvalidMemoryUsage
+	// URL
+	parsed, err := url.Parse(target.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrInvalidScrapeURL
+	}
+
+	// Interval and timeout
+	if target.IntervalSeconds < 5 || target.IntervalSeconds > 86400 {
+		return ErrInvalidScrapeInterval
+	}
+	if target.TimeoutSeconds <= 0 || target.TimeoutSeconds > target.IntervalSeconds {
+		return ErrInvalidScrapeTimeout
 	}
 
-	// Disk Usage
-	if params.DiskUsagePercent < 0 || params.DiskUsagePercent > 100 {
-		return ErrInvalidDiskUsage
+	// Format and mapping
+	switch target.Format {
+	case entities.ScrapeFormatPrometheus:
+	case entities.ScrapeFormatJSON:
+		if len(target.JSONMapping) == 0 {
+			return ErrMissingScrapeJSONPath
+		}
+		for field, path := range target.JSONMapping {
+			if !slices.Contains(entities.ScrapeMappableFields, field) || path == "" {
+				return ErrInvalidScrapeJSONField
+			}
+		}
+	default:
+		return ErrInvalidScrapeFormat
 	}
 
 	return nil
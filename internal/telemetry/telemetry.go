@@ -0,0 +1,332 @@
+package telemetry
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Job outcomes
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+// defaultBuckets are the histogram upper bounds in seconds, from 1ms to 10s
+var defaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// batchBuckets are the histogram upper bounds for rows written per transaction
+var batchBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// Metrics holds the API's own request, database, ingest and background job metrics.
+// A nil *Metrics records nothing, so components can be built without it in tests.
+type Metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	ingested      prometheus.Counter
+	ingestRejects prometheus.Counter
+	ingestDedups  prometheus.Counter
+	ingestBatches prometheus.Histogram
+	jobRuns       *prometheus.CounterVec
+	jobDuration   *prometheus.HistogramVec
+
+	mu          sync.Mutex
+	pools       map[string]*sql.DB
+	storage     map[string]float64
+	ingestQueue func() (depth, capacity int)
+}
+
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_api_http_requests_total",
+			Help: "HTTP requests handled, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "monitor_api_http_request_duration_seconds",
+			Help:    "HTTP request latency, by method and route.",
+			Buckets: defaultBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "monitor_api_db_query_duration_seconds",
+			Help:    "Database call latency, by repository and method.",
+			Buckets: defaultBuckets,
+		}, []string{"repository", "method"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_api_db_query_errors_total",
+			Help: "Database calls that failed, by repository and method.",
+		}, []string{"repository", "method"}),
+		ingested: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "monitor_api_metrics_ingested_total",
+			Help: "System metric samples stored, from the API, scrapes and archive imports.",
+		}),
+		ingestRejects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "monitor_api_ingest_rejected_total",
+			Help: "Metric submissions turned away because the ingest queue was full.",
+		}),
+		ingestDedups: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "monitor_api_ingest_deduplicated_total",
+			Help: "Metric samples for a host and timestamp that already had one, whatever the duplicate policy did with them.",
+		}),
+		ingestBatches: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "monitor_api_ingest_batch_size",
+			Help:    "Metric samples written per ingest queue transaction.",
+			Buckets: batchBuckets,
+		}),
+		jobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "monitor_api_job_runs_total",
+			Help: "Background job runs, by job and outcome.",
+		}, []string{"job", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "monitor_api_job_duration_seconds",
+			Help:    "Background job run time, by job.",
+			Buckets: defaultBuckets,
+		}, []string{"job"}),
+		pools:   make(map[string]*sql.DB),
+		storage: make(map[string]float64),
+	}
+
+	metrics.registry.MustRegister(
+		metrics.httpRequests,
+		metrics.httpDuration,
+		metrics.queryDuration,
+		metrics.queryErrors,
+		metrics.ingested,
+		metrics.ingestRejects,
+		metrics.ingestDedups,
+		metrics.ingestBatches,
+		metrics.jobRuns,
+		metrics.jobDuration,
+		&stateCollector{metrics: metrics},
+		collectors.NewGoCollector(),
+	)
+	metrics.handler = promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+
+	return metrics
+}
+
+// ObserveRequest records a handled HTTP request. route is the matched route pattern,
+// not the raw path, so IDs in URLs don't create a series each.
+func (metrics *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if metrics == nil {
+		return
+	}
+	metrics.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	metrics.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a repository call. sql.ErrNoRows means nothing matched and
+// isn't counted as an error.
+func (metrics *Metrics) ObserveQuery(repository, method string, duration time.Duration, err error) {
+	if metrics == nil {
+		return
+	}
+	metrics.queryDuration.WithLabelValues(repository, method).Observe(duration.Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		metrics.queryErrors.WithLabelValues(repository, method).Inc()
+	}
+}
+
+// AddIngested counts stored metric samples
+func (metrics *Metrics) AddIngested(count int64) {
+	if metrics == nil || count <= 0 {
+		return
+	}
+	metrics.ingested.Add(float64(count))
+}
+
+// AddIngestRejected counts a metric submission turned away by a full ingest queue
+func (metrics *Metrics) AddIngestRejected() {
+	if metrics == nil {
+		return
+	}
+	metrics.ingestRejects.Inc()
+}
+
+// AddIngestDeduplicated counts metric samples whose host already had one at the timestamp
+func (metrics *Metrics) AddIngestDeduplicated(count int64) {
+	if metrics == nil || count <= 0 {
+		return
+	}
+	metrics.ingestDedups.Add(float64(count))
+}
+
+// ObserveIngestBatch records the number of samples written in one ingest queue transaction
+func (metrics *Metrics) ObserveIngestBatch(size int) {
+	if metrics == nil {
+		return
+	}
+	metrics.ingestBatches.Observe(float64(size))
+}
+
+// RegisterIngestQueue reports the depth and capacity of the ingest queue, read from
+// stats when the metrics are written
+func (metrics *Metrics) RegisterIngestQueue(stats func() (depth, capacity int)) {
+	if metrics == nil {
+		return
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.ingestQueue = stats
+}
+
+// ObserveJob records a background job run
+func (metrics *Metrics) ObserveJob(job, outcome string, duration time.Duration) {
+	if metrics == nil {
+		return
+	}
+	metrics.jobRuns.WithLabelValues(job, outcome).Inc()
+	metrics.jobDuration.WithLabelValues(job).Observe(duration.Seconds())
+}
+
+// RegisterPool reports the connection pool stats of db under the given pool name
+func (metrics *Metrics) RegisterPool(name string, db *sql.DB) {
+	if metrics == nil {
+		return
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.pools[name] = db
+}
+
+// SetStorage records a database file size or the free space on its volume, in bytes
+func (metrics *Metrics) SetStorage(kind string, bytes float64) {
+	if metrics == nil {
+		return
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.storage[kind] = bytes
+}
+
+// Handler serves the metrics, in the Prometheus text format unless the scraper asks
+// for another one it supports
+func (metrics *Metrics) Handler() http.Handler {
+	if metrics == nil {
+		return promhttp.HandlerFor(prometheus.NewRegistry(), promhttp.HandlerOpts{})
+	}
+	return metrics.handler
+}
+
+// WritePrometheus writes every metric in the Prometheus text exposition format
+func (metrics *Metrics) WritePrometheus(w io.Writer) error {
+	if metrics == nil {
+		return nil
+	}
+
+	families, err := metrics.registry.Gather()
+	if err != nil {
+		return err
+	}
+	encoder := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolStat is a connection pool stat reported for every registered pool
+type poolStat struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(stats sql.DBStats) float64
+}
+
+var (
+	poolStats = []poolStat{
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_max_open_connections", "Maximum open connections allowed in the pool."),
+			valueType: prometheus.GaugeValue,
+			value:     func(stats sql.DBStats) float64 { return float64(stats.MaxOpenConnections) },
+		},
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_open_connections", "Open connections in the pool."),
+			valueType: prometheus.GaugeValue,
+			value:     func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) },
+		},
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_in_use_connections", "Connections currently in use."),
+			valueType: prometheus.GaugeValue,
+			value:     func(stats sql.DBStats) float64 { return float64(stats.InUse) },
+		},
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_idle_connections", "Idle connections in the pool."),
+			valueType: prometheus.GaugeValue,
+			value:     func(stats sql.DBStats) float64 { return float64(stats.Idle) },
+		},
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_wait_count_total", "Times a caller waited for a free connection."),
+			valueType: prometheus.CounterValue,
+			value:     func(stats sql.DBStats) float64 { return float64(stats.WaitCount) },
+		},
+		{
+			desc:      newPoolDesc("monitor_api_db_pool_wait_duration_seconds_total", "Total time spent waiting for a free connection."),
+			valueType: prometheus.CounterValue,
+			value:     func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() },
+		},
+	}
+
+	storageDesc = prometheus.NewDesc("monitor_api_storage_bytes",
+		"Database file sizes and free space on their volume, by kind.", []string{"kind"}, nil)
+	ingestQueueDepthDesc = prometheus.NewDesc("monitor_api_ingest_queue_depth",
+		"Metric submissions waiting in the ingest queue.", nil, nil)
+	ingestQueueCapacityDesc = prometheus.NewDesc("monitor_api_ingest_queue_capacity",
+		"Metric submissions the ingest queue holds before turning more away.", nil, nil)
+)
+
+func newPoolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, []string{"pool"}, nil)
+}
+
+// stateCollector reports values read when the metrics are gathered: connection pool
+// stats, the storage sizes recorded with SetStorage and the ingest queue, if registered
+type stateCollector struct {
+	metrics *Metrics
+}
+
+func (collector *stateCollector) Describe(descs chan<- *prometheus.Desc) {
+	for _, stat := range poolStats {
+		descs <- stat.desc
+	}
+	descs <- storageDesc
+	descs <- ingestQueueDepthDesc
+	descs <- ingestQueueCapacityDesc
+}
+
+func (collector *stateCollector) Collect(samples chan<- prometheus.Metric) {
+	metrics := collector.metrics
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	for name, db := range metrics.pools {
+		stats := db.Stats()
+		for _, stat := range poolStats {
+			samples <- prometheus.MustNewConstMetric(stat.desc, stat.valueType, stat.value(stats), name)
+		}
+	}
+	for kind, bytes := range metrics.storage {
+		samples <- prometheus.MustNewConstMetric(storageDesc, prometheus.GaugeValue, bytes, kind)
+	}
+	if metrics.ingestQueue != nil {
+		depth, capacity := metrics.ingestQueue()
+		samples <- prometheus.MustNewConstMetric(ingestQueueDepthDesc, prometheus.GaugeValue, float64(depth))
+		samples <- prometheus.MustNewConstMetric(ingestQueueCapacityDesc, prometheus.GaugeValue, float64(capacity))
+	}
+}
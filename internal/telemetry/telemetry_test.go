@@ -0,0 +1,151 @@
+// nolint
+package telemetry
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// TelemetryTestSuite is the test suite for the self-instrumentation metrics
+type TelemetryTestSuite struct {
+	suite.Suite
+	metrics *Metrics
+}
+
+// SetupTest runs before each test in the suite
+func (suite *TelemetryTestSuite) SetupTest() {
+	suite.metrics = New()
+}
+
+// write renders the metrics in the Prometheus text format
+func (suite *TelemetryTestSuite) write() string {
+	var output bytes.Buffer
+	suite.Require().NoError(suite.metrics.WritePrometheus(&output))
+	return output.String()
+}
+
+// TestHistogram tests cumulative buckets, sum and count
+func (suite *TelemetryTestSuite) TestHistogram() {
+	suite.metrics.ObserveRequest("GET", "/health", 200, 3*time.Millisecond)
+	suite.metrics.ObserveRequest("GET", "/health", 200, 300*time.Millisecond)
+	suite.metrics.ObserveRequest("GET", "/health", 200, 30*time.Second)
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, "# TYPE monitor_api_http_request_duration_seconds histogram\n")
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_bucket{method="GET",route="/health",le="0.001"} 0`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_bucket{method="GET",route="/health",le="0.005"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_bucket{method="GET",route="/health",le="0.5"} 2`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_bucket{method="GET",route="/health",le="10"} 2`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_bucket{method="GET",route="/health",le="+Inf"} 3`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_sum{method="GET",route="/health"} 30.303`)
+	assert.Contains(suite.T(), output, `monitor_api_http_request_duration_seconds_count{method="GET",route="/health"} 3`)
+	assert.Contains(suite.T(), output, `monitor_api_http_requests_total{method="GET",route="/health",status="200"} 3`)
+}
+
+// TestQueries tests query durations and error counts
+func (suite *TelemetryTestSuite) TestQueries() {
+	suite.metrics.ObserveQuery("host", "Create", time.Millisecond, nil)
+	suite.metrics.ObserveQuery("host", "Update", time.Millisecond, sql.ErrNoRows)
+	suite.metrics.ObserveQuery("host", "Delete", time.Millisecond, errors.New("database is locked"))
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, `monitor_api_db_query_duration_seconds_count{method="Create",repository="host"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_db_query_errors_total{method="Delete",repository="host"} 1`)
+	assert.NotContains(suite.T(), output, `monitor_api_db_query_errors_total{method="Update",repository="host"}`)
+}
+
+// TestCountersAndJobs tests ingest and background job counters
+func (suite *TelemetryTestSuite) TestCountersAndJobs() {
+	suite.metrics.AddIngested(1)
+	suite.metrics.AddIngested(250)
+	suite.metrics.ObserveJob("backup", OutcomeOK, time.Second)
+	suite.metrics.ObserveJob("backup", OutcomeError, time.Second)
+	suite.metrics.ObserveJob("backup", OutcomeOK, time.Second)
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, "monitor_api_metrics_ingested_total 251\n")
+	assert.Contains(suite.T(), output, `monitor_api_job_runs_total{job="backup",outcome="error"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_job_runs_total{job="backup",outcome="ok"} 2`)
+	assert.Contains(suite.T(), output, `monitor_api_job_duration_seconds_count{job="backup"} 3`)
+}
+
+// TestPools tests connection pool stats
+func (suite *TelemetryTestSuite) TestPools() {
+	db, _, err := sqlmock.New()
+	suite.Require().NoError(err)
+	defer db.Close()
+	db.SetMaxOpenConns(4)
+
+	suite.metrics.RegisterPool("write", db)
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, `monitor_api_db_pool_max_open_connections{pool="write"} 4`)
+	assert.Contains(suite.T(), output, "# TYPE monitor_api_db_pool_wait_count_total counter\n")
+	assert.Contains(suite.T(), output, "# TYPE go_goroutines gauge\n")
+}
+
+// TestStorage tests that the latest storage sizes are reported
+func (suite *TelemetryTestSuite) TestStorage() {
+	suite.metrics.SetStorage("file", 4096)
+	suite.metrics.SetStorage("volume_free", 1e9)
+	suite.metrics.SetStorage("file", 8192)
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, `monitor_api_storage_bytes{kind="file"} 8192`)
+	assert.Contains(suite.T(), output, `monitor_api_storage_bytes{kind="volume_free"} 1e+09`)
+}
+
+// TestIngestQueue tests the ingest queue gauges, rejections and batch sizes
+func (suite *TelemetryTestSuite) TestIngestQueue() {
+	assert.NotContains(suite.T(), suite.write(), "monitor_api_ingest_queue_depth 0")
+
+	suite.metrics.RegisterIngestQueue(func() (int, int) { return 3, 1000 })
+	suite.metrics.AddIngestRejected()
+	suite.metrics.ObserveIngestBatch(1)
+	suite.metrics.ObserveIngestBatch(40)
+
+	output := suite.write()
+	assert.Contains(suite.T(), output, "monitor_api_ingest_queue_depth 3\n")
+	assert.Contains(suite.T(), output, "monitor_api_ingest_queue_capacity 1000\n")
+	assert.Contains(suite.T(), output, "monitor_api_ingest_rejected_total 1\n")
+	assert.Contains(suite.T(), output, `monitor_api_ingest_batch_size_bucket{le="1"} 1`)
+	assert.Contains(suite.T(), output, `monitor_api_ingest_batch_size_bucket{le="50"} 2`)
+	assert.Contains(suite.T(), output, "monitor_api_ingest_batch_size_sum 41\n")
+}
+
+// TestEscaping tests that label values are escaped
+func (suite *TelemetryTestSuite) TestEscaping() {
+	suite.metrics.ObserveJob("a\"b\\c\nd", OutcomeOK, time.Second)
+
+	assert.Contains(suite.T(), suite.write(), `monitor_api_job_runs_total{job="a\"b\\c\nd",outcome="ok"} 1`)
+}
+
+// TestNilMetrics tests that a nil *Metrics records nothing
+func (suite *TelemetryTestSuite) TestNilMetrics() {
+	var metrics *Metrics
+
+	assert.NotPanics(suite.T(), func() {
+		metrics.ObserveRequest("GET", "/health", 200, time.Second)
+		metrics.ObserveQuery("host", "Create", time.Second, nil)
+		metrics.AddIngested(1)
+		metrics.ObserveJob("backup", OutcomeOK, time.Second)
+		metrics.RegisterPool("write", nil)
+		metrics.SetStorage("file", 1)
+		metrics.AddIngestRejected()
+		metrics.ObserveIngestBatch(1)
+		metrics.RegisterIngestQueue(func() (int, int) { return 0, 0 })
+		assert.NoError(suite.T(), metrics.WritePrometheus(&bytes.Buffer{}))
+	})
+}
+
+// Run the test suite
+func TestTelemetryTestSuite(t *testing.T) {
+	suite.Run(t, new(TelemetryTestSuite))
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrIncompatibleBackup is returned when a backup cannot be restored by this build
+var ErrIncompatibleBackup = errors.New("backup is not compatible with this version")
+
+// ErrDatabaseInUse is returned when restoring over a database that is open
+var ErrDatabaseInUse = errors.New("database is in use")
+
+// rename is os.Rename, replaced in tests to simulate failures
+var rename = os.Rename
+
+// ValidateBackup checks that a backup file is an intact monitoring database that this
+// build can migrate, and returns its schema version
+func ValidateBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	// Open read-only so validation never creates or changes the file
+	uri := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	db, err := sql.Open(driverName, uri.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var integrity string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("failed to check backup integrity: %w", err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("%w: integrity check failed: %s", ErrIncompatibleBackup, integrity)
+	}
+
+	for _, table := range []string{"hosts", "system_metrics", "schema_migrations"} {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: missing table %s", ErrIncompatibleBackup, table)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read backup schema: %w", err)
+		}
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return 0, fmt.Errorf("%w: schema version %d is newer than %d", ErrIncompatibleBackup, version, LatestSchemaVersion())
+	}
+
+	return version, nil
+}
+
+// Restore replaces the database at dbPath with a validated copy of backupPath and
+// returns where the replaced database was kept and the backup's schema version. It
+// fails with ErrDatabaseInUse while the database is open. The replaced database and
+// its WAL files are kept beside it with a .pre-restore-<unix time> suffix. If the
+// backup can't be moved into place the current database is put back.
+func Restore(backupPath, dbPath string) (string, int, error) {
+	if err := checkNotInUse(dbPath); err != nil {
+		return "", 0, err
+	}
+	version, err := ValidateBackup(backupPath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Copy next to the target first so the final swap is a rename on one filesystem
+	staged := dbPath + ".restore.tmp"
+	_ = os.Remove(staged)
+	if err := copyFile(backupPath, staged); err != nil {
+		_ = os.Remove(staged)
+		return "", 0, fmt.Errorf("failed to stage backup: %w", err)
+	}
+
+	suffix := fmt.Sprintf(".pre-restore-%d", time.Now().Unix())
+	kept := ""
+	var moved []string
+	for _, ext := range []string{"", "-wal", "-shm"} {
+		err := rename(dbPath+ext, dbPath+suffix+ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			_ = os.Remove(staged)
+			return "", 0, errors.Join(
+				fmt.Errorf("failed to move current database aside: %w", err),
+				moveBack(dbPath, suffix, moved),
+			)
+		}
+		moved = append(moved, ext)
+		if ext == "" {
+			kept = dbPath + suffix
+		}
+	}
+
+	if err := rename(staged, dbPath); err != nil {
+		_ = os.Remove(staged)
+		return "", 0, errors.Join(
+			fmt.Errorf("failed to move backup into place: %w", err),
+			moveBack(dbPath, suffix, moved),
+		)
+	}
+
+	return kept, version, nil
+}
+
+// checkNotInUse fails if the database at dbPath is open. In WAL mode, the default, every
+// open database has -wal and -shm files beside it, and SQLite deletes them when the
+// last connection closes. They are also left behind by a crash, holding writes not
+// yet in the database file, which a restore would set aside.
+func checkNotInUse(dbPath string) error {
+	for _, ext := range []string{"-wal", "-shm"} {
+		_, err := os.Stat(dbPath + ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s exists; stop the API before restoring, or if it crashed, start and stop it "+
+			"once so its last writes are saved", ErrDatabaseInUse, dbPath+ext)
+	}
+	return nil
+}
+
+// moveBack returns database files moved aside by Restore to their original names
+func moveBack(dbPath, suffix string, moved []string) error {
+	var errs []error
+	for _, ext := range moved {
+		if err := rename(dbPath+suffix+ext, dbPath+ext); err != nil {
+			errs = append(errs, fmt.Errorf("failed to put back %s: %w", dbPath+ext, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyFile copies src to a new file at dst and syncs it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,121 @@
+// nolint
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// BackupTestSuite is the test suite for backup validation and restore
+type BackupTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest runs before each test in the suite
+func (suite *BackupTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	rename = os.Rename
+}
+
+// TearDownTest runs after each test
+func (suite *BackupTestSuite) TearDownTest() {
+	rename = os.Rename
+}
+
+// createDatabase creates a migrated database at path holding a single host
+func (suite *BackupTestSuite) createDatabase(path, hostname string) {
+	db, err := Connect(path, DefaultOptions())
+	require.NoError(suite.T(), err)
+	defer Close(db)
+
+	require.NoError(suite.T(), Migrate(db))
+	_, err = db.Write.Exec("INSERT INTO hosts (hostname, ip_address, role, created_at, last_seen) VALUES (?, '10.0.0.1', 'nas', 1, 1)", hostname)
+	require.NoError(suite.T(), err)
+}
+
+// hostname returns the hostname stored in the database at path
+func (suite *BackupTestSuite) hostname(path string) string {
+	db, err := Connect(path, DefaultOptions())
+	require.NoError(suite.T(), err)
+	defer Close(db)
+
+	var hostname string
+	require.NoError(suite.T(), db.Read.QueryRow("SELECT hostname FROM hosts").Scan(&hostname))
+	return hostname
+}
+
+// TestRestore tests that a backup replaces the database and the old one is kept
+func (suite *BackupTestSuite) TestRestore() {
+	backupPath := filepath.Join(suite.dir, "backup.db")
+	dbPath := filepath.Join(suite.dir, "monitor.db")
+	suite.createDatabase(backupPath, "from-backup")
+	suite.createDatabase(dbPath, "current")
+
+	kept, version, err := Restore(backupPath, dbPath)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), LatestSchemaVersion(), version)
+	assert.Equal(suite.T(), "from-backup", suite.hostname(dbPath))
+	assert.Equal(suite.T(), "current", suite.hostname(kept))
+}
+
+// TestRestoreInUse tests that a database that is open is not replaced
+func (suite *BackupTestSuite) TestRestoreInUse() {
+	backupPath := filepath.Join(suite.dir, "backup.db")
+	dbPath := filepath.Join(suite.dir, "monitor.db")
+	suite.createDatabase(backupPath, "from-backup")
+	suite.createDatabase(dbPath, "current")
+
+	db, err := Connect(dbPath, DefaultOptions())
+	require.NoError(suite.T(), err)
+	defer Close(db)
+	_, err = db.Write.Exec("UPDATE hosts SET role = 'server'")
+	require.NoError(suite.T(), err)
+
+	kept, _, err := Restore(backupPath, dbPath)
+
+	assert.ErrorIs(suite.T(), err, ErrDatabaseInUse)
+	assert.Empty(suite.T(), kept)
+	assert.Equal(suite.T(), "current", suite.hostname(dbPath))
+	matches, _ := filepath.Glob(dbPath + ".pre-restore-*")
+	assert.Empty(suite.T(), matches)
+}
+
+// TestRestoreRollsBack tests that the current database is put back when the backup can't be moved into place
+func (suite *BackupTestSuite) TestRestoreRollsBack() {
+	backupPath := filepath.Join(suite.dir, "backup.db")
+	dbPath := filepath.Join(suite.dir, "monitor.db")
+	suite.createDatabase(backupPath, "from-backup")
+	suite.createDatabase(dbPath, "current")
+
+	staged := dbPath + ".restore.tmp"
+	rename = func(oldPath, newPath string) error {
+		if oldPath == staged {
+			return errors.New("disk on fire")
+		}
+		return os.Rename(oldPath, newPath)
+	}
+
+	kept, _, err := Restore(backupPath, dbPath)
+
+	assert.ErrorContains(suite.T(), err, "disk on fire")
+	assert.Empty(suite.T(), kept)
+	assert.NoFileExists(suite.T(), staged)
+	rename = os.Rename
+	assert.Equal(suite.T(), "current", suite.hostname(dbPath))
+
+	matches, _ := filepath.Glob(dbPath + ".pre-restore-*")
+	assert.Empty(suite.T(), matches)
+}
+
+// Run the test suite
+func TestBackupTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
@@ -0,0 +1,35 @@
+//go:build !purego
+
+package database
+
+import (
+	"net/url"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// driverName is the database/sql driver used for SQLite. The default build uses
+// go-sqlite3, which needs CGO; build with -tags purego for a CGO-free binary.
+const driverName = "sqlite3"
+
+// dsn builds a go-sqlite3 connection string. The driver applies the pragmas to each new
+// connection, so they hold for every connection in the pool.
+func dsn(dbPath string, opts Options, writer bool) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	params.Set("_synchronous", opts.Synchronous)
+	params.Set("_foreign_keys", strconv.FormatBool(opts.ForeignKeys))
+	// A negative cache size is in KiB rather than pages
+	params.Set("_cache_size", strconv.Itoa(-opts.CacheSizeKiB))
+
+	if !writer {
+		return withParams(dbPath, params)
+	}
+
+	params.Set("_journal_mode", opts.JournalMode)
+	// Take the write lock when a transaction begins so it never fails to upgrade
+	params.Set("_txlock", "immediate")
+
+	return withParams(dbPath, params)
+}
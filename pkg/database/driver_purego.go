@@ -0,0 +1,36 @@
+//go:build purego
+
+package database
+
+import (
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// driverName is the database/sql driver used for SQLite. The purego build uses
+// modernc.org/sqlite, a translation of SQLite to Go that needs no C toolchain.
+const driverName = "sqlite"
+
+// dsn builds a modernc.org/sqlite connection string. Each _pragma is run on every new
+// connection, so the settings hold for every connection in the pool. busy_timeout
+// comes first so the others wait for a lock rather than failing.
+func dsn(dbPath string, opts Options, writer bool) string {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()))
+	params.Add("_pragma", fmt.Sprintf("synchronous(%s)", opts.Synchronous))
+	params.Add("_pragma", fmt.Sprintf("foreign_keys(%t)", opts.ForeignKeys))
+	// A negative cache size is in KiB rather than pages
+	params.Add("_pragma", fmt.Sprintf("cache_size(%d)", -opts.CacheSizeKiB))
+
+	if !writer {
+		return withParams(dbPath, params)
+	}
+
+	params.Add("_pragma", fmt.Sprintf("journal_mode(%s)", opts.JournalMode))
+	// Take the write lock when a transaction begins so it never fails to upgrade
+	params.Set("_txlock", "immediate")
+
+	return withParams(dbPath, params)
+}
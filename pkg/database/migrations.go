@@ -0,0 +1,282 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Migration is a single, ordered schema change, written once per dialect
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string // SQLite
+	Postgres    []string
+
+	// SetAside names a table the statements copy removed rows into. How many rows it
+	// holds is logged, and the table is dropped again if it is empty.
+	SetAside string
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Existing entries must never be edited; add a new version instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create hosts and system_metrics tables",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS hosts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname TEXT NOT NULL UNIQUE,
+				ip_address TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT '',
+				created_at INTEGER NOT NULL,
+				last_seen INTEGER NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS system_metrics (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+				timestamp INTEGER NOT NULL,
+				cpu_usage REAL NOT NULL DEFAULT 0,
+				memory_usage_percent REAL NOT NULL DEFAULT 0,
+				memory_total_bytes INTEGER NOT NULL DEFAULT 0,
+				memory_used_bytes INTEGER NOT NULL DEFAULT 0,
+				memory_available_bytes INTEGER NOT NULL DEFAULT 0,
+				disk_usage_percent REAL NOT NULL DEFAULT 0,
+				disk_total_bytes INTEGER NOT NULL DEFAULT 0,
+				disk_used_bytes INTEGER NOT NULL DEFAULT 0,
+				disk_available_bytes INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+		Postgres: []string{
+			`CREATE TABLE IF NOT EXISTS hosts (
+				id BIGSERIAL PRIMARY KEY,
+				hostname TEXT NOT NULL UNIQUE,
+				ip_address TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT '',
+				created_at BIGINT NOT NULL,
+				last_seen BIGINT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS system_metrics (
+				id BIGSERIAL PRIMARY KEY,
+				host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+				timestamp BIGINT NOT NULL,
+				cpu_usage DOUBLE PRECISION NOT NULL DEFAULT 0,
+				memory_usage_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+				memory_total_bytes BIGINT NOT NULL DEFAULT 0,
+				memory_used_bytes BIGINT NOT NULL DEFAULT 0,
+				memory_available_bytes BIGINT NOT NULL DEFAULT 0,
+				disk_usage_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+				disk_total_bytes BIGINT NOT NULL DEFAULT 0,
+				disk_used_bytes BIGINT NOT NULL DEFAULT 0,
+				disk_available_bytes BIGINT NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create scrape_targets table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS scrape_targets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				url TEXT NOT NULL,
+				host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+				format TEXT NOT NULL,
+				interval_seconds INTEGER NOT NULL,
+				timeout_seconds INTEGER NOT NULL,
+				json_mapping TEXT NOT NULL DEFAULT '{}',
+				paused INTEGER NOT NULL DEFAULT 0,
+				last_scrape_at INTEGER NOT NULL DEFAULT 0,
+				last_status TEXT NOT NULL DEFAULT '',
+				last_error TEXT NOT NULL DEFAULT '',
+				last_duration_ms INTEGER NOT NULL DEFAULT 0,
+				consecutive_failures INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+		Postgres: []string{
+			`CREATE TABLE IF NOT EXISTS scrape_targets (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				url TEXT NOT NULL,
+				host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+				format TEXT NOT NULL,
+				interval_seconds INTEGER NOT NULL,
+				timeout_seconds INTEGER NOT NULL,
+				json_mapping TEXT NOT NULL DEFAULT '{}',
+				paused BOOLEAN NOT NULL DEFAULT FALSE,
+				last_scrape_at BIGINT NOT NULL DEFAULT 0,
+				last_status TEXT NOT NULL DEFAULT '',
+				last_error TEXT NOT NULL DEFAULT '',
+				last_duration_ms BIGINT NOT NULL DEFAULT 0,
+				consecutive_failures INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "index system_metrics by host and timestamp",
+		Statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_host_timestamp
+				ON system_metrics (host_id, timestamp)`,
+		},
+		// SQLite appends the rowid to every index, which breaks ties between equal
+		// timestamps; PostgreSQL needs the id spelled out to sort without a second pass
+		Postgres: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_host_timestamp
+				ON system_metrics (host_id, timestamp, id)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "index system_metrics by timestamp",
+		Statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_timestamp
+				ON system_metrics (timestamp)`,
+		},
+		Postgres: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_timestamp
+				ON system_metrics (timestamp, id)`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "make system_metrics unique by host and timestamp",
+		// Retried pushes left duplicate rows behind; the first one stored is kept and the
+		// rest are copied aside rather than lost
+		SetAside: "system_metrics_duplicates",
+		Statements: []string{
+			`CREATE TABLE system_metrics_duplicates AS SELECT * FROM system_metrics
+				WHERE id NOT IN (SELECT MIN(id) FROM system_metrics GROUP BY host_id, timestamp)`,
+			`DELETE FROM system_metrics
+				WHERE id NOT IN (SELECT MIN(id) FROM system_metrics GROUP BY host_id, timestamp)`,
+			`DROP INDEX IF EXISTS idx_system_metrics_host_timestamp`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_system_metrics_host_timestamp
+				ON system_metrics (host_id, timestamp)`,
+		},
+		// The existing index keeps the id for sorting, so the constraint gets its own
+		Postgres: []string{
+			`CREATE TABLE system_metrics_duplicates AS SELECT * FROM system_metrics
+				WHERE id NOT IN (SELECT MIN(id) FROM system_metrics GROUP BY host_id, timestamp)`,
+			`DELETE FROM system_metrics newer
+				USING system_metrics older
+				WHERE newer.host_id = older.host_id
+					AND newer.timestamp = older.timestamp
+					AND newer.id > older.id`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_system_metrics_host_timestamp_unique
+				ON system_metrics (host_id, timestamp)`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "record when system_metrics were received",
+		// Metrics stored before this migration keep 0, as their receive time is unknown
+		Statements: []string{
+			`ALTER TABLE system_metrics ADD COLUMN received_at INTEGER NOT NULL DEFAULT 0`,
+		},
+		Postgres: []string{
+			`ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS received_at BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// Migrate applies any migrations that have not yet been recorded in schema_migrations,
+// using the statements written for the database's dialect
+func Migrate(db *DB) error {
+	createSQL := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at BIGINT NOT NULL
+		)`
+	if _, err := db.Write.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := SchemaVersion(db.Write)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if err := applyMigration(db, migration); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an unmigrated database
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// LatestSchemaVersion returns the version this build migrates databases to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// applyMigration runs a migration and records it in a single transaction
+func applyMigration(db *DB, migration Migration) error {
+	statements := migration.Statements
+	insertSQL := `INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`
+	if db.Dialect == Postgres {
+		statements = migration.Postgres
+		insertSQL = `INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)`
+	}
+	if len(statements) == 0 {
+		return fmt.Errorf("no statements for the %s dialect", db.Dialect)
+	}
+
+	tx, err := db.Write.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if migration.SetAside != "" {
+		if err := reportSetAside(tx, migration); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(insertSQL, migration.Version, migration.Description, time.Now().Unix()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reportSetAside logs how many rows a migration copied aside, dropping the table if none were
+func reportSetAside(tx *sql.Tx, migration Migration) error {
+	var rows int64
+	if err := tx.QueryRow("SELECT COUNT(*) FROM " + migration.SetAside).Scan(&rows); err != nil {
+		return err
+	}
+	if rows == 0 {
+		_, err := tx.Exec("DROP TABLE " + migration.SetAside)
+		return err
+	}
+
+	slog.Warn("migration removed rows and kept a copy of them",
+		"version", migration.Version, "description", migration.Description,
+		"rows", rows, "table", migration.SetAside)
+	return nil
+}
@@ -0,0 +1,102 @@
+// nolint
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// MigrationsTestSuite is the test suite for schema migrations
+type MigrationsTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test in the suite
+func (suite *MigrationsTestSuite) SetupTest() {
+	db, err := Connect(filepath.Join(suite.T().TempDir(), "monitor.db"), DefaultOptions())
+	require.NoError(suite.T(), err)
+	suite.db = db
+}
+
+// TearDownTest runs after each test
+func (suite *MigrationsTestSuite) TearDownTest() {
+	_ = Close(suite.db)
+}
+
+// migrateTo applies the migrations up to and including version
+func (suite *MigrationsTestSuite) migrateTo(version int) {
+	all := migrations
+	defer func() { migrations = all }()
+
+	for i, migration := range all {
+		if migration.Version == version {
+			migrations = all[:i+1]
+		}
+	}
+	require.NoError(suite.T(), Migrate(suite.db))
+}
+
+// setAsideRows returns how many rows are in the duplicates table, or -1 if it doesn't exist
+func (suite *MigrationsTestSuite) setAsideRows() int {
+	var tables int
+	require.NoError(suite.T(), suite.db.Read.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'system_metrics_duplicates'").Scan(&tables))
+	if tables == 0 {
+		return -1
+	}
+
+	var rows int
+	require.NoError(suite.T(), suite.db.Read.QueryRow("SELECT COUNT(*) FROM system_metrics_duplicates").Scan(&rows))
+	return rows
+}
+
+// TestDuplicatesSetAside tests that removing duplicate metrics keeps a copy of every removed row
+func (suite *MigrationsTestSuite) TestDuplicatesSetAside() {
+	tests := []struct {
+		name       string
+		timestamps []int64
+		kept       int
+		setAside   int
+	}{
+		{name: "duplicates", timestamps: []int64{1000, 1000, 1000, 1015}, kept: 2, setAside: 2},
+		{name: "no_duplicates", timestamps: []int64{1000, 1015}, kept: 2, setAside: -1},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			suite.migrateTo(4)
+			_, err := suite.db.Write.Exec("INSERT INTO hosts (id, hostname, ip_address, created_at, last_seen) VALUES (1, 'pi-01', '10.0.0.1', 1, 1)")
+			require.NoError(suite.T(), err)
+			for i, timestamp := range test.timestamps {
+				_, err := suite.db.Write.Exec("INSERT INTO system_metrics (host_id, timestamp, cpu_usage) VALUES (1, ?, ?)", timestamp, i)
+				require.NoError(suite.T(), err)
+			}
+
+			require.NoError(suite.T(), Migrate(suite.db))
+
+			var kept int
+			require.NoError(suite.T(), suite.db.Read.QueryRow("SELECT COUNT(*) FROM system_metrics").Scan(&kept))
+			assert.Equal(suite.T(), test.kept, kept)
+			assert.Equal(suite.T(), test.setAside, suite.setAsideRows())
+
+			// The first metric stored at a timestamp is the one kept
+			var cpu float64
+			require.NoError(suite.T(), suite.db.Read.QueryRow("SELECT cpu_usage FROM system_metrics WHERE timestamp = 1000").Scan(&cpu))
+			assert.Equal(suite.T(), 0.0, cpu)
+		})
+
+		// Reset for next test
+		suite.TearDownTest()
+		suite.SetupTest()
+	}
+}
+
+// Run the test suite
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
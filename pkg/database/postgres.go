@@ -0,0 +1,26 @@
+package database
+
+import (
+	"fmt"
+
+	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// ConnectPostgres opens the PostgreSQL database at the connection URL. PostgreSQL
+// handles concurrent writers itself, so one pool serves both reads and writes.
+func ConnectPostgres(url string, opts Options) (*DB, error) {
+	db, err := openPool("postgres", url, semconv.DBSystemNamePostgreSQL, opts.Trace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &DB{Write: db, Read: db, Dialect: Postgres}, nil
+}
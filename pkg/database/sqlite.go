@@ -2,29 +2,115 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
-// Connect opens a connection to the SQLite database
-func Connect(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// Dialect identifies the SQL database behind a DB
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Options are the connection settings applied to every pooled connection. The
+// pool sizes apply to every dialect; the rest are SQLite pragmas.
+type Options struct {
+	JournalMode  string
+	Synchronous  string
+	BusyTimeout  time.Duration
+	CacheSizeKiB int
+	ForeignKeys  bool
+	MaxOpenConns int // read pool only; the write pool always has a single connection
+	MaxIdleConns int
+	Trace        bool // record a span for every statement
+}
+
+// DefaultOptions returns settings suited to concurrent agent writes and dashboard reads
+func DefaultOptions() Options {
+	return Options{
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		CacheSizeKiB: 20000,
+		ForeignKeys:  true,
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+	}
+}
+
+// DB holds separate pools for writes and reads. SQLite allows a single writer, so the
+// write pool has one connection and queues writers in Go rather than failing with
+// "database is locked"; in WAL mode queries and snapshots run alongside it on the
+// read pool.
+type DB struct {
+	Write   *sql.DB
+	Read    *sql.DB
+	Dialect Dialect
+	Path    string // the SQLite database file; empty for PostgreSQL
+}
+
+// withParams appends connection parameters to dbPath, after any query string it
+// already has, such as the one in a file: URI
+func withParams(dbPath string, params url.Values) string {
+	if strings.Contains(dbPath, "?") {
+		return dbPath + "&" + params.Encode()
+	}
+	return dbPath + "?" + params.Encode()
+}
+
+// Connect opens the SQLite database at dbPath with the given options
+func Connect(dbPath string, opts Options) (*DB, error) {
+	write, err := open(dsn(dbPath, opts, true), opts.Trace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
+	write.SetMaxOpenConns(1)
+	write.SetMaxIdleConns(1)
+	write.SetConnMaxLifetime(0)
 
-	if err := db.Ping(); err != nil {
+	// The write connection sets the journal mode, which is stored in the file, before any reader opens it
+	if err := write.Ping(); err != nil {
+		_ = write.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return db, nil
+	read, err := open(dsn(dbPath, opts, false), opts.Trace)
+	if err != nil {
+		_ = write.Close()
+		return nil, err
+	}
+	read.SetMaxOpenConns(opts.MaxOpenConns)
+	read.SetMaxIdleConns(opts.MaxIdleConns)
+
+	if err := read.Ping(); err != nil {
+		_ = write.Close()
+		_ = read.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &DB{Write: write, Read: read, Dialect: SQLite, Path: dbPath}, nil
 }
 
-// Close safely closes the database connection
-func Close(db *sql.DB) error {
-	if err := db.Close(); err != nil {
+// Close safely closes both connection pools, which may be the same pool
+func Close(db *DB) error {
+	if err := errors.Join(db.Read.Close(), db.Write.Close()); err != nil {
 		return fmt.Errorf("failed to close database: %w", err)
 	}
 	return nil
 }
+
+// open opens a pool for the DSN
+func open(dsn string, trace bool) (*sql.DB, error) {
+	db, err := openPool(driverName, dsn, semconv.DBSystemNameSQLite, trace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
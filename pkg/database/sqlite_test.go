@@ -0,0 +1,54 @@
+// nolint
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// SQLiteTestSuite is the test suite for SQLite connections
+type SQLiteTestSuite struct {
+	suite.Suite
+}
+
+// TestConnectOptions tests that both pools apply the options, whether or not the
+// database path already has a query string
+func (suite *SQLiteTestSuite) TestConnectOptions() {
+	dir := suite.T().TempDir()
+	tests := []struct {
+		name   string
+		dbPath string
+	}{
+		{name: "file path", dbPath: filepath.Join(dir, "plain.db")},
+		{name: "URI with a query string", dbPath: "file:" + filepath.Join(dir, "uri.db") + "?mode=rwc"},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			opts := DefaultOptions()
+			opts.ForeignKeys = false
+			db, err := Connect(tt.dbPath, opts)
+			require.NoError(suite.T(), err)
+			defer Close(db)
+
+			for _, pool := range []*sql.DB{db.Write, db.Read} {
+				var busyTimeout int64
+				var foreignKeys bool
+				require.NoError(suite.T(), pool.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout))
+				require.NoError(suite.T(), pool.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys))
+				assert.Equal(suite.T(), opts.BusyTimeout.Milliseconds(), busyTimeout)
+				assert.False(suite.T(), foreignKeys)
+			}
+		})
+	}
+}
+
+// Run the test suite
+func TestSQLiteTestSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteTestSuite))
+}
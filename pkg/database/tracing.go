@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// openPool opens a pool for the DSN. With trace set, every statement records a
+// client span tagged with its sanitized text.
+func openPool(name, dsn string, system attribute.KeyValue, trace bool) (*sql.DB, error) {
+	if !trace {
+		return sql.Open(name, dsn)
+	}
+
+	return otelsql.Open(name, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableQuery:         true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+		otelsql.WithAttributesGetter(func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) []attribute.KeyValue {
+			if query == "" {
+				return nil
+			}
+			return []attribute.KeyValue{semconv.DBQueryText(SanitizeQuery(query))}
+		}),
+	)
+}
+
+// SanitizeQuery replaces string and number literals in a statement with ? and
+// collapses whitespace, so traces show its shape without the values it was built
+// from. Placeholders such as ? and $1 are kept.
+func SanitizeQuery(query string) string {
+	var builder strings.Builder
+	builder.Grow(len(query))
+
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = builder.Len() > 0
+			continue
+		case c == '\'':
+			// Skip to the closing quote; a doubled quote is an escaped one
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			c = '?'
+		case isDigit(c) && (i == 0 || !isIdentifierByte(query[i-1])):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		}
+
+		if space {
+			builder.WriteByte(' ')
+			space = false
+		}
+		builder.WriteByte(c)
+	}
+
+	return builder.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentifierByte reports whether c can precede a digit within an identifier or
+// a $1 placeholder
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,68 @@
+// nolint
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// TracingTestSuite is the test suite for statement tracing helpers
+type TracingTestSuite struct {
+	suite.Suite
+}
+
+// TestSanitizeQuery tests that literals are masked and placeholders kept
+func (suite *TracingTestSuite) TestSanitizeQuery() {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "placeholders_kept",
+			query:    "SELECT id FROM hosts WHERE id = ? LIMIT ?",
+			expected: "SELECT id FROM hosts WHERE id = ? LIMIT ?",
+		},
+		{
+			name:     "postgres_placeholders_kept",
+			query:    "UPDATE hosts SET role = $1 WHERE id = $2",
+			expected: "UPDATE hosts SET role = $1 WHERE id = $2",
+		},
+		{
+			name:     "string_literals_masked",
+			query:    "VACUUM INTO '/backups/monitor-it''s.db'",
+			expected: "VACUUM INTO ?",
+		},
+		{
+			name:     "number_literals_masked",
+			query:    "PRAGMA busy_timeout = 5000; SELECT * FROM system_metrics WHERE cpu_usage > 99.5",
+			expected: "PRAGMA busy_timeout = ?; SELECT * FROM system_metrics WHERE cpu_usage > ?",
+		},
+		{
+			name:     "identifiers_with_digits_kept",
+			query:    "SELECT sha256 FROM t1",
+			expected: "SELECT sha256 FROM t1",
+		},
+		{
+			name: "whitespace_collapsed",
+			query: `
+				SELECT id
+				FROM hosts
+			`,
+			expected: "SELECT id FROM hosts",
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			assert.Equal(suite.T(), test.expected, SanitizeQuery(test.query))
+		})
+	}
+}
+
+// Run the test suite
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
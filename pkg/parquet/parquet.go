@@ -0,0 +1,51 @@
+// Package parquet reads and writes Apache Parquet files with flat schemas of INT64,
+// DOUBLE and string columns, using github.com/parquet-go/parquet-go. Files are
+// written zstd compressed with dictionary encoded strings. Any file with a flat
+// schema of those types can be read, whatever compression and encodings the
+// writing tool chose, as long as the library supports them.
+package parquet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is written
+const DefaultRowGroupSize = 65536
+
+// Type is the physical type of a column
+type Type int
+
+// Supported column types
+const (
+	Int64 Type = iota
+	Double
+	String
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int64:
+		return "INT64"
+	case Double:
+		return "DOUBLE"
+	case String:
+		return "UTF8"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Column describes one field of a flat schema
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Row holds one value per column: int64 for Int64, float64 for Double and string
+// for String columns
+type Row []interface{}
+
+// ErrUnsupported is returned when reading a file that uses Parquet features this
+// package can't read, such as nested columns or an unknown compression codec
+var ErrUnsupported = errors.New("parquet: unsupported feature")
@@ -0,0 +1,355 @@
+// nolint
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	parquetgo "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/encoding/thrift"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// ParquetTestSuite is the test suite for the Parquet reader and writer
+type ParquetTestSuite struct {
+	suite.Suite
+	columns []Column
+}
+
+// SetupTest runs before each test in the suite
+func (suite *ParquetTestSuite) SetupTest() {
+	suite.columns = []Column{
+		{Name: "id", Type: Int64},
+		{Name: "hostname", Type: String},
+		{Name: "cpu_usage", Type: Double},
+	}
+}
+
+// writeFile writes rows with the suite schema and returns the encoded file
+func (suite *ParquetTestSuite) writeFile(rows []Row, rowGroupSize int) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, suite.columns)
+	assert.NoError(suite.T(), err)
+	writer.SetRowGroupSize(rowGroupSize)
+	for _, row := range rows {
+		assert.NoError(suite.T(), writer.Write(row))
+	}
+	assert.NoError(suite.T(), writer.Close())
+	return buf.Bytes()
+}
+
+// readFile reads every row of an encoded file
+func (suite *ParquetTestSuite) readFile(data []byte) (*Reader, []Row, error) {
+	reader, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []Row
+	err = reader.Read(func(row Row) error {
+		rows = append(rows, append(Row(nil), row...))
+		return nil
+	})
+	return reader, rows, err
+}
+
+// TestRoundTrip tests that written rows are read back unchanged
+func (suite *ParquetTestSuite) TestRoundTrip() {
+	tests := []struct {
+		name         string
+		rows         int
+		rowGroupSize int
+	}{
+		{name: "empty_file", rows: 0, rowGroupSize: 10},
+		{name: "single_row_group", rows: 5, rowGroupSize: 10},
+		{name: "exact_row_groups", rows: 20, rowGroupSize: 10},
+		{name: "partial_last_row_group", rows: 25, rowGroupSize: 10},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var rows []Row
+			for i := 0; i < test.rows; i++ {
+				rows = append(rows, Row{int64(i) - 3, fmt.Sprintf("pi-%02d", i), float64(i) * 1.5})
+			}
+
+			data := suite.writeFile(rows, test.rowGroupSize)
+			reader, read, err := suite.readFile(data)
+
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), suite.columns, reader.Columns())
+			assert.Equal(suite.T(), int64(test.rows), reader.NumRows())
+			assert.Equal(suite.T(), rows, read)
+		})
+	}
+}
+
+// TestLongStringsAndLists tests values and schemas past the compact protocol's short forms
+func (suite *ParquetTestSuite) TestLongStringsAndLists() {
+	suite.columns = nil
+	row := Row{}
+	for i := 0; i < 20; i++ {
+		suite.columns = append(suite.columns, Column{Name: fmt.Sprintf("column_%d", i), Type: String})
+		row = append(row, string(bytes.Repeat([]byte{'a' + byte(i)}, 200*i)))
+	}
+
+	_, read, err := suite.readFile(suite.writeFile([]Row{row}, 10))
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []Row{row}, read)
+}
+
+// TestFileLayout tests the magic bytes, footer framing and compression
+func (suite *ParquetTestSuite) TestFileLayout() {
+	data := suite.writeFile([]Row{{int64(1), "pi-01", 12.5}}, 10)
+
+	assert.Equal(suite.T(), "PAR1", string(data[:4]))
+	assert.Equal(suite.T(), "PAR1", string(data[len(data)-4:]))
+
+	footerSize := binary.LittleEndian.Uint32(data[len(data)-8:])
+	assert.Less(suite.T(), int(footerSize), len(data)-12)
+
+	file, err := parquetgo.OpenFile(bytes.NewReader(data), int64(len(data)))
+	suite.Require().NoError(err)
+	for _, chunk := range file.Metadata().RowGroups[0].Columns {
+		assert.Equal(suite.T(), format.Zstd, chunk.MetaData.Codec)
+	}
+	assert.Contains(suite.T(), file.Metadata().RowGroups[0].Columns[1].MetaData.Encoding, format.RLEDictionary)
+}
+
+// TestWriteErrors tests that invalid schemas and rows are rejected
+func (suite *ParquetTestSuite) TestWriteErrors() {
+	_, err := NewWriter(&bytes.Buffer{}, nil)
+	assert.Error(suite.T(), err)
+
+	_, err = NewWriter(&bytes.Buffer{}, []Column{{Name: "id", Type: Int64}, {Name: "id", Type: Double}})
+	assert.Error(suite.T(), err)
+
+	writer, err := NewWriter(&bytes.Buffer{}, suite.columns)
+	assert.NoError(suite.T(), err)
+
+	tests := []struct {
+		name string
+		row  Row
+	}{
+		{name: "too_few_values", row: Row{int64(1), "pi-01"}},
+		{name: "wrong_type", row: Row{1, "pi-01", 12.5}},
+		{name: "nil_value", row: Row{int64(1), nil, 12.5}},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			assert.Error(suite.T(), writer.Write(test.row))
+		})
+	}
+
+	// Rejected rows leave the columns aligned
+	assert.NoError(suite.T(), writer.Write(Row{int64(1), "pi-01", 12.5}))
+	assert.NoError(suite.T(), writer.Close())
+	assert.Error(suite.T(), writer.Write(Row{int64(2), "pi-02", 1.0}))
+}
+
+// TestReadErrors tests that corrupt and unsupported files are rejected
+func (suite *ParquetTestSuite) TestReadErrors() {
+	valid := suite.writeFile([]Row{{int64(1), "pi-01", 12.5}}, 10)
+
+	corrupt := func(modify func(data []byte) []byte) []byte {
+		return modify(append([]byte(nil), valid...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "too_small", data: []byte("PAR1PAR1")},
+		{name: "bad_magic", data: corrupt(func(data []byte) []byte { data[0] = 'X'; return data })},
+		{name: "footer_too_long", data: corrupt(func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[len(data)-8:], uint32(len(data)))
+			return data
+		})},
+		{name: "truncated_footer", data: corrupt(func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[len(data)-8:], 3)
+			return data
+		})},
+		{name: "truncated_pages", data: append([]byte("PAR1"), valid[len(valid)-200:]...)},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			_, _, err := suite.readFile(test.data)
+			assert.Error(suite.T(), err)
+		})
+	}
+}
+
+// TestUnsupportedFeatures tests that files the library can't read are refused
+func (suite *ParquetTestSuite) TestUnsupportedFeatures() {
+	tests := []struct {
+		name string
+		data func() []byte
+	}{
+		{
+			name: "nested_column",
+			data: func() []byte {
+				return writeGroup(parquetgo.Group{"tags": parquetgo.Repeated(parquetgo.String())}, parquetgo.Row{})
+			},
+		},
+		{
+			name: "boolean_column",
+			data: func() []byte {
+				return writeGroup(parquetgo.Group{"flag": parquetgo.Leaf(parquetgo.BooleanType)},
+					parquetgo.Row{parquetgo.BooleanValue(true).Level(0, 0, 0)})
+			},
+		},
+		{
+			name: "lzo_compression",
+			data: func() []byte {
+				return suite.rewriteFooter(func(metadata *format.FileMetaData) {
+					metadata.RowGroups[0].Columns[0].MetaData.Codec = format.LZO
+				})
+			},
+		},
+		{
+			name: "unknown_encoding",
+			data: func() []byte {
+				return suite.rewriteFooter(func(metadata *format.FileMetaData) {
+					chunk := &metadata.RowGroups[0].Columns[2].MetaData
+					chunk.Encoding = append(chunk.Encoding, format.Encoding(99))
+				})
+			},
+		},
+		{
+			name: "null_value",
+			data: func() []byte {
+				return writeGroup(parquetgo.Group{"id": parquetgo.Optional(parquetgo.Int(64))},
+					parquetgo.Row{parquetgo.NullValue().Level(0, 0, 0)})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			_, _, err := suite.readFile(test.data())
+			assert.ErrorIs(suite.T(), err, ErrUnsupported)
+		})
+	}
+}
+
+// writeGroup writes rows with a parquet-go schema, for files this package wouldn't write
+func writeGroup(group parquetgo.Group, rows ...parquetgo.Row) []byte {
+	var buf bytes.Buffer
+	writer := parquetgo.NewWriter(&buf, parquetgo.NewSchema("test", group))
+	_, _ = writer.WriteRows(rows)
+	_ = writer.Close()
+	return buf.Bytes()
+}
+
+// rewriteFooter writes a valid file and replaces its footer with a modified copy
+func (suite *ParquetTestSuite) rewriteFooter(modify func(metadata *format.FileMetaData)) []byte {
+	data := suite.writeFile([]Row{{int64(1), "pi-01", 12.5}}, 10)
+	file, err := parquetgo.OpenFile(bytes.NewReader(data), int64(len(data)))
+	suite.Require().NoError(err)
+
+	metadata := *file.Metadata()
+	modify(&metadata)
+	footer, err := thrift.Marshal(new(thrift.CompactProtocol), &metadata)
+	suite.Require().NoError(err)
+
+	footerSize := binary.LittleEndian.Uint32(data[len(data)-8:])
+	data = append(data[:len(data)-8-int(footerSize)], footer...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(footer)))
+	return append(data, "PAR1"...)
+}
+
+// TestDuckDBFiles tests reading files written by DuckDB with its default snappy
+// compression and dictionary encoding, and with zstd
+func (suite *ParquetTestSuite) TestDuckDBFiles() {
+	// The fixtures hold the archive columns for 1,000 rows across three hosts,
+	// generated with range(1000) and these expressions
+	var expected []Row
+	for i := int64(0); i < 1000; i++ {
+		role := "worker"
+		if i%3 == 0 {
+			role = "master"
+		}
+		expected = append(expected, Row{
+			i + 1, i%3 + 1, fmt.Sprintf("pi-0%d", i%3+1), fmt.Sprintf("192.168.1.1%d", i%3), role, 1717200000 + 60*(i/3),
+			float64(i%100) / 4, 40 + float64(i%7)/2, int64(8000000000), 3000000000 + i, 5000000000 - i, 55 + float64(i%5)/4,
+			int64(64000000000), 35000000000 + 1000*i, 29000000000 - 1000*i, 1717200000 + 60*(i/3) + 1,
+		})
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		codec format.CompressionCodec
+	}{
+		{name: "snappy", file: "testdata/duckdb_metrics.parquet", codec: format.Snappy},
+		{name: "zstd", file: "testdata/duckdb_metrics_zstd.parquet", codec: format.Zstd},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			data, err := os.ReadFile(test.file)
+			suite.Require().NoError(err)
+
+			// The fixture exercises compression and dictionary pages
+			file, err := parquetgo.OpenFile(bytes.NewReader(data), int64(len(data)))
+			suite.Require().NoError(err)
+			hostname := file.Metadata().RowGroups[0].Columns[2].MetaData
+			assert.Equal(suite.T(), test.codec, hostname.Codec)
+			assert.Contains(suite.T(), hostname.Encoding, format.RLEDictionary)
+
+			reader, read, err := suite.readFile(data)
+			suite.Require().NoError(err)
+			assert.Equal(suite.T(), "hostname", reader.Columns()[2].Name)
+			assert.Equal(suite.T(), String, reader.Columns()[2].Type)
+			assert.Equal(suite.T(), expected, read)
+
+			// Rows from DuckDB written back out read the same
+			suite.columns = reader.Columns()
+			_, again, err := suite.readFile(suite.writeFile(read, 400))
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), expected, again)
+		})
+	}
+}
+
+// TestDuckDBNull tests that a null written by DuckDB is reported with its column
+func (suite *ParquetTestSuite) TestDuckDBNull() {
+	data, err := os.ReadFile("testdata/duckdb_metrics_null.parquet")
+	suite.Require().NoError(err)
+
+	_, _, err = suite.readFile(data)
+
+	assert.ErrorIs(suite.T(), err, ErrUnsupported)
+	assert.ErrorContains(suite.T(), err, `"cpu_usage"`)
+}
+
+// TestReadStopsOnCallbackError tests that a callback error ends the read
+func (suite *ParquetTestSuite) TestReadStopsOnCallbackError() {
+	data := suite.writeFile([]Row{{int64(1), "a", 1.0}, {int64(2), "b", 2.0}}, 1)
+	reader, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(suite.T(), err)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = reader.Read(func(Row) error {
+		calls++
+		return stop
+	})
+
+	assert.Equal(suite.T(), stop, err)
+	assert.Equal(suite.T(), 1, calls)
+}
+
+// Run the test suite
+func TestParquetTestSuite(t *testing.T) {
+	suite.Run(t, new(ParquetTestSuite))
+}
@@ -0,0 +1,176 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	parquetgo "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/encoding"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// readBatchSize is the number of rows decoded at a time
+const readBatchSize = 1024
+
+// Reader reads rows from a Parquet file with a flat schema of supported columns
+type Reader struct {
+	file    *parquetgo.File
+	columns []Column
+}
+
+// NewReader parses the footer of a Parquet file of the given size and checks that
+// its schema, compression codecs and encodings can be read
+func NewReader(reader io.ReaderAt, size int64) (*Reader, error) {
+	file, err := parquetgo.OpenFile(reader, size, parquetgo.SkipPageIndex(true), parquetgo.SkipBloomFilters(true))
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+
+	columns, err := parseSchema(file.Schema())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkColumnChunks(file.Metadata()); err != nil {
+		return nil, err
+	}
+	return &Reader{file: file, columns: columns}, nil
+}
+
+// Columns returns the file's schema
+func (parquetReader *Reader) Columns() []Column {
+	return parquetReader.columns
+}
+
+// NumRows returns the number of rows recorded in the footer
+func (parquetReader *Reader) NumRows() int64 {
+	return parquetReader.file.NumRows()
+}
+
+// Read calls fn for every row in file order, stopping at the first error fn returns.
+// The row slice is reused between calls.
+func (parquetReader *Reader) Read(fn func(row Row) error) error {
+	row := make(Row, len(parquetReader.columns))
+	for g, group := range parquetReader.file.RowGroups() {
+		if err := parquetReader.readRowGroup(g, group, row, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRowGroup decodes the rows of a row group in batches. Errors from fn are
+// returned as they are.
+func (parquetReader *Reader) readRowGroup(g int, group parquetgo.RowGroup, row Row, fn func(row Row) error) error {
+	rows := group.Rows()
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	batch := make([]parquetgo.Row, readBatchSize)
+	for {
+		n, err := rows.ReadRows(batch)
+		for _, values := range batch[:n] {
+			if err := parquetReader.convertRow(values, row); err != nil {
+				return fmt.Errorf("parquet: row group %d: %w", g, err)
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parquet: row group %d: %w", g, err)
+		}
+	}
+}
+
+// convertRow copies the values of a decoded row into row
+func (parquetReader *Reader) convertRow(values parquetgo.Row, row Row) error {
+	if len(values) != len(row) {
+		return fmt.Errorf("row has %d values, schema has %d columns", len(values), len(row))
+	}
+
+	for _, value := range values {
+		column := parquetReader.columns[value.Column()]
+		if value.IsNull() {
+			return fmt.Errorf("%w: column %q has a null value", ErrUnsupported, column.Name)
+		}
+
+		switch column.Type {
+		case Int64:
+			row[value.Column()] = value.Int64()
+		case Double:
+			row[value.Column()] = value.Double()
+		case String:
+			row[value.Column()] = string(value.ByteArray())
+		}
+	}
+	return nil
+}
+
+// parseSchema converts a flat schema of INT64, DOUBLE and byte array columns.
+// Optional columns are accepted, since most tools write them by default, but a
+// null value fails the read with ErrUnsupported.
+func parseSchema(schema *parquetgo.Schema) ([]Column, error) {
+	fields := schema.Fields()
+	if len(fields) == 0 {
+		return nil, errors.New("parquet: schema has no columns")
+	}
+
+	columns := make([]Column, 0, len(fields))
+	for _, field := range fields {
+		name := field.Name()
+		if !field.Leaf() || field.Repeated() {
+			return nil, fmt.Errorf("%w: nested column %q", ErrUnsupported, name)
+		}
+
+		var columnType Type
+		switch kind := field.Type().Kind(); kind {
+		case parquetgo.Int64:
+			columnType = Int64
+		case parquetgo.Double:
+			columnType = Double
+		case parquetgo.ByteArray:
+			columnType = String
+		default:
+			return nil, fmt.Errorf("%w: column %q has physical type %v", ErrUnsupported, name, kind)
+		}
+		columns = append(columns, Column{Name: name, Type: columnType})
+	}
+	return columns, nil
+}
+
+// checkColumnChunks checks that every column chunk uses a compression codec and
+// encodings the library can decode, so an unreadable file fails before any rows
+// are returned
+func checkColumnChunks(metadata *format.FileMetaData) error {
+	for _, group := range metadata.RowGroups {
+		for _, chunk := range group.Columns {
+			name := strings.Join(chunk.MetaData.PathInSchema, ".")
+			codec := chunk.MetaData.Codec
+			if !supportedCodecs[codec] {
+				return fmt.Errorf("%w: column %q uses compression codec %v", ErrUnsupported, name, codec)
+			}
+			for _, enc := range chunk.MetaData.Encoding {
+				if parquetgo.LookupEncoding(enc) == (encoding.NotSupported{}) {
+					return fmt.Errorf("%w: column %q uses encoding %v", ErrUnsupported, name, enc)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// supportedCodecs are the compression codecs parquet-go decodes
+var supportedCodecs = map[format.CompressionCodec]bool{
+	format.Uncompressed: true,
+	format.Snappy:       true,
+	format.Gzip:         true,
+	format.Brotli:       true,
+	format.Zstd:         true,
+	format.Lz4Raw:       true,
+}
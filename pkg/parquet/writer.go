@@ -0,0 +1,128 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	parquetgo "github.com/parquet-go/parquet-go"
+)
+
+// Writer writes rows to a Parquet file. Rows are buffered in memory until a row
+// group is full, and the footer is written by Close.
+type Writer struct {
+	writer       *parquetgo.Writer
+	columns      []Column
+	rowGroupSize int
+	rows         int
+	row          parquetgo.Row
+	closed       bool
+}
+
+// NewWriter starts a Parquet file with the given schema
+func NewWriter(writer io.Writer, columns []Column) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: schema has no columns")
+	}
+
+	seen := make(map[string]bool, len(columns))
+	fields := make([]reflect.StructField, len(columns))
+	for i, column := range columns {
+		if column.Name == "" || seen[column.Name] {
+			return nil, fmt.Errorf("parquet: invalid or duplicate column name %q", column.Name)
+		}
+		seen[column.Name] = true
+
+		// The schema is built from a struct type rather than a parquet-go Group,
+		// which would sort the columns by name
+		fields[i] = reflect.StructField{Name: "Column" + strconv.Itoa(i)}
+		switch column.Type {
+		case Int64:
+			fields[i].Type = reflect.TypeOf(int64(0))
+			fields[i].Tag = reflect.StructTag(`parquet:"` + column.Name + `"`)
+		case Double:
+			fields[i].Type = reflect.TypeOf(float64(0))
+			fields[i].Tag = reflect.StructTag(`parquet:"` + column.Name + `"`)
+		case String:
+			fields[i].Type = reflect.TypeOf("")
+			fields[i].Tag = reflect.StructTag(`parquet:"` + column.Name + `,dict"`)
+		default:
+			return nil, fmt.Errorf("parquet: column %q has unknown type %v", column.Name, column.Type)
+		}
+	}
+
+	schema := parquetgo.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface())
+	if len(schema.Columns()) != len(columns) {
+		return nil, errors.New("parquet: invalid column names")
+	}
+
+	return &Writer{
+		writer:       parquetgo.NewWriter(writer, schema, parquetgo.Compression(&parquetgo.Zstd)),
+		columns:      columns,
+		rowGroupSize: DefaultRowGroupSize,
+		row:          make(parquetgo.Row, len(columns)),
+	}, nil
+}
+
+// SetRowGroupSize changes how many rows are buffered per row group
+func (parquetWriter *Writer) SetRowGroupSize(rows int) {
+	if rows > 0 {
+		parquetWriter.rowGroupSize = rows
+	}
+}
+
+// Write appends a row, flushing a row group once enough rows are buffered
+func (parquetWriter *Writer) Write(row Row) error {
+	if parquetWriter.closed {
+		return errors.New("parquet: write to closed writer")
+	}
+	if len(row) != len(parquetWriter.columns) {
+		return fmt.Errorf("parquet: row has %d values, schema has %d columns", len(row), len(parquetWriter.columns))
+	}
+
+	// Check every value before writing any, so a bad row leaves the columns aligned
+	for i, column := range parquetWriter.columns {
+		var value parquetgo.Value
+		var ok bool
+		switch column.Type {
+		case Int64:
+			var v int64
+			v, ok = row[i].(int64)
+			value = parquetgo.Int64Value(v)
+		case Double:
+			var v float64
+			v, ok = row[i].(float64)
+			value = parquetgo.DoubleValue(v)
+		case String:
+			var v string
+			v, ok = row[i].(string)
+			value = parquetgo.ByteArrayValue([]byte(v))
+		}
+		if !ok {
+			return fmt.Errorf("parquet: column %q expects %v, got %T", column.Name, column.Type, row[i])
+		}
+		parquetWriter.row[i] = value.Level(0, 0, i)
+	}
+
+	if _, err := parquetWriter.writer.WriteRows([]parquetgo.Row{parquetWriter.row}); err != nil {
+		return err
+	}
+	parquetWriter.rows++
+	if parquetWriter.rows == parquetWriter.rowGroupSize {
+		parquetWriter.rows = 0
+		return parquetWriter.writer.Flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. It does not close the
+// underlying writer.
+func (parquetWriter *Writer) Close() error {
+	if parquetWriter.closed {
+		return nil
+	}
+	parquetWriter.closed = true
+	return parquetWriter.writer.Close()
+}
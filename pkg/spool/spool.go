@@ -0,0 +1,422 @@
+// Package spool is an append-only log of records kept in a directory of segment files.
+// A record is on disk once Append returns, so it survives a crash or power cut, and is
+// deleted with its segment once the caller has marked every record in it done. Records
+// still in the log when it is opened again are read back with Replay.
+//
+// Each segment starts with an 8 byte header, followed by records framed as a 4 byte
+// length, a 4 byte CRC-32C of the payload and the payload, little-endian. A record cut
+// short at the end of a segment is a write that a crash interrupted, so it was never
+// acknowledged and is skipped. A bad header, length or checksum means the segment is
+// corrupt.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// header starts every segment file, and changes if the record format does
+const header = "MAPISPL1"
+
+// Segment file names are the segment's sequence number and this extension
+const (
+	segmentExt = ".seg"
+	corruptExt = ".corrupt"
+)
+
+// recordOverhead is the length and checksum written before each payload
+const recordOverhead = 8
+
+// MaxRecordSize is the largest payload Append accepts
+const MaxRecordSize = 1 << 20
+
+// DefaultSegmentSize is the size a segment grows to before the log moves to a new one
+const DefaultSegmentSize = 16 << 20
+
+var (
+	// ErrClosed is returned by Append after Close
+	ErrClosed = errors.New("spool: log is closed")
+	// ErrRecordTooLarge is returned by Append for payloads over MaxRecordSize
+	ErrRecordTooLarge = errors.New("spool: record is too large")
+	// errCorrupt marks a segment header, record length or checksum that is wrong
+	errCorrupt = errors.New("spool: corrupt record")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Position identifies the segment a record was appended to
+type Position struct {
+	Segment uint64
+}
+
+// ReplayStats describes what Replay read
+type ReplayStats struct {
+	Segments int      // segment files read
+	Records  int      // records passed to the callback
+	Torn     bool     // a segment ended in a partly written record
+	Corrupt  []string // segment files set aside with the corruptExt extension
+}
+
+// Log is an append-only record log. It is safe for concurrent use.
+type Log struct {
+	dir         string
+	segmentSize int64
+
+	mu       sync.Mutex
+	file     *os.File
+	segment  uint64
+	size     int64
+	pending  map[uint64]int // records not yet marked done, by segment
+	written  uint64         // records appended
+	synced   uint64         // records known to be on disk
+	replay   []uint64       // segments left by an earlier process, oldest first
+	closed   bool
+	syncLock sync.Mutex // one fsync at a time, covering every record written before it
+}
+
+// Open opens the log in dir, creating the directory if needed, and starts a new segment.
+// Segments left by an earlier process are kept for Replay.
+func Open(dir string, segmentSize int64) (*Log, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &Log{dir: dir, segmentSize: segmentSize, pending: make(map[uint64]int), replay: segments}
+	next := uint64(1)
+	if len(segments) > 0 {
+		next = segments[len(segments)-1] + 1
+	}
+	if err := log.openSegment(next); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// Append writes a record and returns once it is on disk. Appends that arrive while an
+// fsync is running share the next one.
+func (log *Log) Append(payload []byte) (Position, error) {
+	if len(payload) > MaxRecordSize {
+		return Position{}, ErrRecordTooLarge
+	}
+
+	record := make([]byte, recordOverhead+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, castagnoli))
+	copy(record[recordOverhead:], payload)
+
+	log.mu.Lock()
+	if log.closed {
+		log.mu.Unlock()
+		return Position{}, ErrClosed
+	}
+	if log.size+int64(len(record)) > log.segmentSize && log.size > int64(len(header)) {
+		if err := log.rotate(); err != nil {
+			log.mu.Unlock()
+			return Position{}, err
+		}
+	}
+	if _, err := log.file.Write(record); err != nil {
+		// Drop the partial record so the next one starts on a record boundary
+		_ = log.file.Truncate(log.size)
+		_, _ = log.file.Seek(log.size, io.SeekStart)
+		log.mu.Unlock()
+		return Position{}, fmt.Errorf("failed to write spool record: %w", err)
+	}
+	log.size += int64(len(record))
+	log.pending[log.segment]++
+	log.written++
+	position, mine := Position{Segment: log.segment}, log.written
+	log.mu.Unlock()
+
+	if err := log.sync(mine); err != nil {
+		log.Done(position)
+		return Position{}, err
+	}
+	return position, nil
+}
+
+// sync returns once the first upTo records written are on disk
+func (log *Log) sync(upTo uint64) error {
+	log.syncLock.Lock()
+	defer log.syncLock.Unlock()
+
+	log.mu.Lock()
+	if log.synced >= upTo {
+		log.mu.Unlock()
+		return nil
+	}
+	file, target := log.file, log.written
+	log.mu.Unlock()
+
+	err := file.Sync()
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	// A rotation since then synced and closed the file, which covers upTo as well
+	if err != nil && log.synced >= upTo {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	if target > log.synced {
+		log.synced = target
+	}
+	return nil
+}
+
+// Dir returns the directory holding the log's segments
+func (log *Log) Dir() string {
+	return log.dir
+}
+
+// Done marks a record as written to its destination. A segment is deleted once it is
+// no longer being appended to and every record in it is done.
+func (log *Log) Done(position Position) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	log.pending[position.Segment]--
+	if log.pending[position.Segment] > 0 || position.Segment == log.segment {
+		return
+	}
+	delete(log.pending, position.Segment)
+	_ = os.Remove(log.path(position.Segment, segmentExt))
+}
+
+// Replay passes the records left by an earlier process to fn, oldest first, up to
+// batchSize at a time, and deletes each segment once all its records have been passed.
+// If fn fails, Replay stops and the segments not yet finished are kept for the next
+// Open. Corrupt segments are renamed with the corruptExt extension so they are not read
+// again but can be examined; the records before the damage are still replayed.
+func (log *Log) Replay(batchSize int, fn func(payloads [][]byte) error) (ReplayStats, error) {
+	var stats ReplayStats
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	log.mu.Lock()
+	segments := log.replay
+	log.mu.Unlock()
+
+	for _, segment := range segments {
+		path := log.path(segment, segmentExt)
+
+		payloads, err := readSegment(path)
+		stats.Segments++
+		for start := 0; start < len(payloads); start += batchSize {
+			batch := payloads[start:min(start+batchSize, len(payloads))]
+			if err := fn(batch); err != nil {
+				return stats, err
+			}
+			stats.Records += len(batch)
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			stats.Torn = true
+		case errors.Is(err, errCorrupt):
+			corrupt := log.path(segment, segmentExt+corruptExt)
+			if err := os.Rename(path, corrupt); err != nil {
+				return stats, fmt.Errorf("failed to set aside corrupt spool segment: %w", err)
+			}
+			stats.Corrupt = append(stats.Corrupt, filepath.Base(corrupt))
+			log.replayed()
+			continue
+		default:
+			return stats, err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return stats, fmt.Errorf("failed to remove replayed spool segment: %w", err)
+		}
+		log.replayed()
+	}
+	return stats, nil
+}
+
+// replayed drops the oldest segment from those left to replay
+func (log *Log) replayed() {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.replay = log.replay[1:]
+}
+
+// Close syncs and closes the current segment, deleting it if every record in it is done
+func (log *Log) Close() error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if log.closed {
+		return nil
+	}
+	log.closed = true
+	return log.closeSegment()
+}
+
+// rotate closes the current segment and starts the next one
+func (log *Log) rotate() error {
+	next := log.segment + 1
+	if err := log.closeSegment(); err != nil {
+		return err
+	}
+	return log.openSegment(next)
+}
+
+// closeSegment syncs and closes the current segment, deleting it if nothing in it is
+// still pending
+func (log *Log) closeSegment() error {
+	if err := log.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	log.synced = log.written
+	if err := log.file.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+	if log.pending[log.segment] <= 0 {
+		delete(log.pending, log.segment)
+		return os.Remove(log.path(log.segment, segmentExt))
+	}
+	return nil
+}
+
+// openSegment creates a segment file and writes its header
+func (log *Log) openSegment(segment uint64) error {
+	path := log.path(segment, segmentExt)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+	if _, err := file.WriteString(header); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write spool segment header: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	// Make the new file's directory entry durable too
+	if err := syncDir(log.dir); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	log.file = file
+	log.segment = segment
+	log.size = int64(len(header))
+	return nil
+}
+
+func (log *Log) path(segment uint64, ext string) string {
+	return filepath.Join(log.dir, fmt.Sprintf("%016d%s", segment, ext))
+}
+
+// readSegment returns the records in a segment file, up to the first one it can't read.
+// The error wraps io.ErrUnexpectedEOF if the file ends part way through a record, and
+// errCorrupt if a header, length or checksum is wrong.
+func readSegment(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	reader := bufio.NewReader(file)
+
+	magic := make([]byte, len(header))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		if errors.Is(err, io.EOF) {
+			// Created but never written to
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if string(magic) != header {
+		return nil, fmt.Errorf("%s: %w: unknown segment header", filepath.Base(path), errCorrupt)
+	}
+
+	var payloads [][]byte
+	prefix := make([]byte, recordOverhead)
+	for {
+		if _, err := io.ReadFull(reader, prefix); err != nil {
+			if errors.Is(err, io.EOF) {
+				return payloads, nil
+			}
+			return payloads, fmt.Errorf("%s: record %d: %w", filepath.Base(path), len(payloads)+1, err)
+		}
+
+		length := binary.LittleEndian.Uint32(prefix[0:4])
+		if length > MaxRecordSize {
+			return payloads, fmt.Errorf("%s: record %d: %w: length %d", filepath.Base(path), len(payloads)+1, errCorrupt, length)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return payloads, fmt.Errorf("%s: record %d: %w", filepath.Base(path), len(payloads)+1, err)
+		}
+		if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(prefix[4:8]) {
+			return payloads, fmt.Errorf("%s: record %d: %w: checksum mismatch", filepath.Base(path), len(payloads)+1, errCorrupt)
+		}
+		payloads = append(payloads, payload)
+	}
+}
+
+// listSegments returns the sequence numbers of the segment files in dir, oldest first
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		segment, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// syncDir flushes a directory so files created or removed in it survive a power cut
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open spool directory: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool directory: %w", err)
+	}
+	return nil
+}
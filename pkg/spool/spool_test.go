@@ -0,0 +1,230 @@
+// nolint
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// SpoolTestSuite is the test suite for the segment log
+type SpoolTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+// SetupTest runs before each test in the suite
+func (suite *SpoolTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+// open opens the log in the suite directory
+func (suite *SpoolTestSuite) open(segmentSize int64) *Log {
+	log, err := Open(suite.dir, segmentSize)
+	suite.Require().NoError(err)
+	return log
+}
+
+// files lists the files in the suite directory
+func (suite *SpoolTestSuite) files() []string {
+	entries, err := os.ReadDir(suite.dir)
+	suite.Require().NoError(err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replay reopens the log and returns the records left in it
+func (suite *SpoolTestSuite) replay() ([]string, ReplayStats) {
+	log := suite.open(0)
+	defer log.Close()
+
+	var records []string
+	stats, err := log.Replay(2, func(payloads [][]byte) error {
+		for _, payload := range payloads {
+			records = append(records, string(payload))
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+	return records, stats
+}
+
+// TestAppendAndReplay tests that records not marked done are replayed in order
+func (suite *SpoolTestSuite) TestAppendAndReplay() {
+	log := suite.open(0)
+	for _, record := range []string{"a", "b", "c"} {
+		_, err := log.Append([]byte(record))
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(log.Close())
+
+	records, stats := suite.replay()
+
+	assert.Equal(suite.T(), []string{"a", "b", "c"}, records)
+	assert.Equal(suite.T(), ReplayStats{Segments: 1, Records: 3}, stats)
+
+	// Replayed segments are deleted, and so is the empty segment the replay opened
+	assert.Empty(suite.T(), suite.files())
+}
+
+// TestRotationAndDone tests that segments rotate at the size limit and are deleted once
+// every record in them is done
+func (suite *SpoolTestSuite) TestRotationAndDone() {
+	// Room for the header and two 8 byte records
+	log := suite.open(int64(len(header) + 2*(recordOverhead+8)))
+
+	var positions []Position
+	for i := 0; i < 5; i++ {
+		position, err := log.Append([]byte(fmt.Sprintf("record-%d", i)))
+		suite.Require().NoError(err)
+		positions = append(positions, position)
+	}
+	assert.Equal(suite.T(), []Position{{1}, {1}, {2}, {2}, {3}}, positions)
+	assert.Equal(suite.T(), []string{"0000000000000001.seg", "0000000000000002.seg", "0000000000000003.seg"}, suite.files())
+
+	log.Done(positions[0])
+	log.Done(positions[2])
+	log.Done(positions[3])
+	assert.Equal(suite.T(), []string{"0000000000000001.seg", "0000000000000003.seg"}, suite.files())
+
+	log.Done(positions[1])
+	log.Done(positions[4])
+	assert.Equal(suite.T(), []string{"0000000000000003.seg"}, suite.files())
+
+	suite.Require().NoError(log.Close())
+	assert.Empty(suite.T(), suite.files())
+}
+
+// TestTornRecord tests that a record cut short by a crash is skipped
+func (suite *SpoolTestSuite) TestTornRecord() {
+	log := suite.open(0)
+	_, err := log.Append([]byte("complete"))
+	suite.Require().NoError(err)
+	suite.Require().NoError(log.Close())
+
+	// The length and checksum of a record whose payload never reached the disk
+	file, err := os.OpenFile(filepath.Join(suite.dir, "0000000000000001.seg"), os.O_APPEND|os.O_WRONLY, 0)
+	suite.Require().NoError(err)
+	_, err = file.Write([]byte{20, 0, 0, 0, 1, 2, 3, 4, 'p', 'a'})
+	suite.Require().NoError(err)
+	suite.Require().NoError(file.Close())
+
+	records, stats := suite.replay()
+
+	assert.Equal(suite.T(), []string{"complete"}, records)
+	assert.True(suite.T(), stats.Torn)
+	assert.Empty(suite.T(), stats.Corrupt)
+	assert.Empty(suite.T(), suite.files())
+}
+
+// TestCorruptSegment tests that a segment failing its checksum is set aside and the
+// segments after it are still replayed
+func (suite *SpoolTestSuite) TestCorruptSegment() {
+	log := suite.open(int64(len(header) + 2*(recordOverhead+1)))
+	for _, record := range []string{"a", "b", "c"} {
+		_, err := log.Append([]byte(record))
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(log.Close())
+
+	// Flip the payload of the second record in the first segment
+	path := filepath.Join(suite.dir, "0000000000000001.seg")
+	data, err := os.ReadFile(path)
+	suite.Require().NoError(err)
+	data[len(data)-1] = 'x'
+	suite.Require().NoError(os.WriteFile(path, data, 0o640))
+
+	records, stats := suite.replay()
+
+	assert.Equal(suite.T(), []string{"a", "c"}, records)
+	assert.Equal(suite.T(), []string{"0000000000000001.seg.corrupt"}, stats.Corrupt)
+	assert.Equal(suite.T(), []string{"0000000000000001.seg.corrupt"}, suite.files())
+}
+
+// TestUnknownHeader tests that a file with another format is set aside
+func (suite *SpoolTestSuite) TestUnknownHeader() {
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "0000000000000001.seg"), []byte("MAPISPL9"), 0o640))
+
+	records, stats := suite.replay()
+
+	assert.Empty(suite.T(), records)
+	assert.Equal(suite.T(), []string{"0000000000000001.seg.corrupt"}, stats.Corrupt)
+}
+
+// TestReplayError tests that a failed replay keeps the segment for the next attempt
+func (suite *SpoolTestSuite) TestReplayError() {
+	log := suite.open(0)
+	for _, record := range []string{"a", "b"} {
+		_, err := log.Append([]byte(record))
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(log.Close())
+
+	log = suite.open(0)
+	writeErr := errors.New("database is locked")
+	stats, err := log.Replay(1, func(payloads [][]byte) error {
+		if string(payloads[0]) == "b" {
+			return writeErr
+		}
+		return nil
+	})
+	suite.Require().NoError(log.Close())
+
+	assert.Equal(suite.T(), writeErr, err)
+	assert.Equal(suite.T(), 1, stats.Records)
+
+	records, _ := suite.replay()
+	assert.Equal(suite.T(), []string{"a", "b"}, records)
+}
+
+// TestConcurrentAppend tests that concurrent appends are all written whole
+func (suite *SpoolTestSuite) TestConcurrentAppend() {
+	log := suite.open(256)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := log.Append([]byte(fmt.Sprintf("record-%02d", i)))
+			assert.NoError(suite.T(), err)
+		}(i)
+	}
+	wg.Wait()
+	suite.Require().NoError(log.Close())
+
+	records, stats := suite.replay()
+
+	assert.Len(suite.T(), records, 50)
+	assert.Greater(suite.T(), stats.Segments, 1)
+	assert.Empty(suite.T(), stats.Corrupt)
+}
+
+// TestAppendErrors tests oversized records and appends after Close
+func (suite *SpoolTestSuite) TestAppendErrors() {
+	log := suite.open(0)
+
+	_, err := log.Append(make([]byte, MaxRecordSize+1))
+	assert.ErrorIs(suite.T(), err, ErrRecordTooLarge)
+
+	suite.Require().NoError(log.Close())
+	_, err = log.Append([]byte("late"))
+	assert.ErrorIs(suite.T(), err, ErrClosed)
+}
+
+// Run the test suite
+func TestSpoolTestSuite(t *testing.T) {
+	suite.Run(t, new(SpoolTestSuite))
+}
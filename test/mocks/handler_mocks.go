@@ -5,6 +5,36 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// MockArchiveHandler is a mock implementation of ArchiveHandlerInterface
+type MockArchiveHandler struct {
+	mock.Mock
+}
+
+// Archive mocks the Archive handler method
+func (m *MockArchiveHandler) Archive(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Import mocks the Import handler method
+func (m *MockArchiveHandler) Import(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// MockBackupHandler is a mock implementation of BackupHandlerInterface
+type MockBackupHandler struct {
+	mock.Mock
+}
+
+// Create mocks the Create handler method
+func (m *MockBackupHandler) Create(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Get mocks the Get handler method
+func (m *MockBackupHandler) Get(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
 // MockHealthHandler is a mock implementation of HealthHandlerInterface
 type MockHealthHandler struct {
 	mock.Mock
@@ -15,11 +45,36 @@ func (m *MockHealthHandler) GetHealth(ctx *gin.Context) {
 	m.Called(ctx)
 }
 
+// GetLiveness mocks the GetLiveness handler method
+func (m *MockHealthHandler) GetLiveness(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// GetReadiness mocks the GetReadiness handler method
+func (m *MockHealthHandler) GetReadiness(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
 // GetDetailedHealth mocks the GetDetailedHealth handler method
 func (m *MockHealthHandler) GetDetailedHealth(ctx *gin.Context) {
 	m.Called(ctx)
 }
 
+// MockMaintenanceHandler is a mock implementation of MaintenanceHandlerInterface
+type MockMaintenanceHandler struct {
+	mock.Mock
+}
+
+// Run mocks the Run handler method
+func (m *MockMaintenanceHandler) Run(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Get mocks the Get handler method
+func (m *MockMaintenanceHandler) Get(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
 // MockHostHandler is a mock implementation of HostHandlerInterface
 type MockHostHandler struct {
 	mock.Mock
@@ -64,3 +119,58 @@ func (m *MockMetricHandler) Get(ctx *gin.Context) {
 func (m *MockMetricHandler) GetLatest(ctx *gin.Context) {
 	m.Called(ctx)
 }
+
+// MockScrapeTargetHandler is a mock implementation of ScrapeTargetHandlerInterface
+type MockScrapeTargetHandler struct {
+	mock.Mock
+}
+
+// Create mocks the Create handler method
+func (m *MockScrapeTargetHandler) Create(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Get mocks the Get handler method
+func (m *MockScrapeTargetHandler) Get(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Update mocks the Update handler method
+func (m *MockScrapeTargetHandler) Update(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// Delete mocks the Delete handler method
+func (m *MockScrapeTargetHandler) Delete(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// MockStreamHandler is a mock implementation of StreamHandlerInterface
+type MockStreamHandler struct {
+	mock.Mock
+}
+
+// Metrics mocks the Metrics handler method
+func (m *MockStreamHandler) Metrics(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// MockTelemetryHandler is a mock implementation of TelemetryHandlerInterface
+type MockTelemetryHandler struct {
+	mock.Mock
+}
+
+// Metrics mocks the Metrics handler method
+func (m *MockTelemetryHandler) Metrics(ctx *gin.Context) {
+	m.Called(ctx)
+}
+
+// MockWebSocketHandler is a mock implementation of WebSocketHandlerInterface
+type MockWebSocketHandler struct {
+	mock.Mock
+}
+
+// Connect mocks the Connect handler method
+func (m *MockWebSocketHandler) Connect(ctx *gin.Context) {
+	m.Called(ctx)
+}
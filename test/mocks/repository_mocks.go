@@ -1,18 +1,31 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/stretchr/testify/mock"
 )
 
+// MockBackupRepository is a mock implementation of BackupRepositoryInterface
+type MockBackupRepository struct {
+	mock.Mock
+}
+
+// Snapshot mocks writing a database snapshot
+func (mock *MockBackupRepository) Snapshot(ctx context.Context, dest string) error {
+	args := mock.Called(ctx, dest)
+	return args.Error(0)
+}
+
 // MockHealthRepository is a mock implementation of HealthRepositoryInterface
 type MockHealthRepository struct {
 	mock.Mock
 }
 
 // CheckDatabaseConnection mocks the database connection check
-func (mock *MockHealthRepository) CheckDatabaseConnection() error {
-	args := mock.Called()
+func (mock *MockHealthRepository) CheckDatabaseConnection(ctx context.Context) error {
+	args := mock.Called(ctx)
 	return args.Error(0)
 }
 
@@ -25,23 +38,79 @@ func (mock *MockHealthRepository) GetDatabaseStats() (map[string]interface{}, er
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
+// GetDatabaseSettings mocks getting the effective database settings
+func (mock *MockHealthRepository) GetDatabaseSettings(ctx context.Context) (map[string]interface{}, error) {
+	args := mock.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
 // GetTableCounts mocks getting table counts
-func (mock *MockHealthRepository) GetTableCounts() (map[string]int, error) {
-	args := mock.Called()
+func (mock *MockHealthRepository) GetTableCounts(ctx context.Context) (map[string]int, error) {
+	args := mock.Called(ctx)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(map[string]int), args.Error(1)
 }
 
+// GetSchemaVersion mocks getting the applied schema version
+func (mock *MockHealthRepository) GetSchemaVersion(ctx context.Context) (int, error) {
+	args := mock.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+// GetStorageStats mocks getting database file and volume sizes
+func (mock *MockHealthRepository) GetStorageStats(ctx context.Context) (*entities.StorageStats, error) {
+	args := mock.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entities.StorageStats), args.Error(1)
+}
+
+// MockMaintenanceRepository is a mock implementation of MaintenanceRepositoryInterface
+type MockMaintenanceRepository struct {
+	mock.Mock
+}
+
+// IncrementalVacuum mocks returning free pages to the filesystem
+func (mock *MockMaintenanceRepository) IncrementalVacuum(ctx context.Context) (int64, error) {
+	args := mock.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// Analyze mocks refreshing query planner statistics
+func (mock *MockMaintenanceRepository) Analyze(ctx context.Context) error {
+	args := mock.Called(ctx)
+	return args.Error(0)
+}
+
+// Optimize mocks running PRAGMA optimize
+func (mock *MockMaintenanceRepository) Optimize(ctx context.Context) error {
+	args := mock.Called(ctx)
+	return args.Error(0)
+}
+
+// IntegrityCheck mocks verifying the database file
+func (mock *MockMaintenanceRepository) IntegrityCheck(ctx context.Context) ([]string, error) {
+	args := mock.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 // MockHostRepository is a mock implementation of HostRepositoryInterface
 type MockHostRepository struct {
 	mock.Mock
 }
 
 // FindByFilters mocks finding hosts by filters
-func (mock *MockHostRepository) FindByFilters(params *entities.HostQueryParams) ([]entities.Host, error) {
-	args := mock.Called(params)
+func (mock *MockHostRepository) FindByFilters(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, error) {
+	args := mock.Called(ctx, params)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -49,20 +118,20 @@ func (mock *MockHostRepository) FindByFilters(params *entities.HostQueryParams)
 }
 
 // Create mocks creating a new host
-func (mock *MockHostRepository) Create(host *entities.Host) (int64, error) {
-	args := mock.Called(host)
+func (mock *MockHostRepository) Create(ctx context.Context, host *entities.Host) (int64, error) {
+	args := mock.Called(ctx, host)
 	return args.Get(0).(int64), args.Error(1)
 }
 
 // Update mocks updating a host
-func (mock *MockHostRepository) Update(id int64, host *entities.Host) error {
-	args := mock.Called(id, host)
+func (mock *MockHostRepository) Update(ctx context.Context, id int64, host *entities.Host) error {
+	args := mock.Called(ctx, id, host)
 	return args.Error(0)
 }
 
 // Delete mocks deleting a host
-func (mock *MockHostRepository) Delete(id int64) error {
-	args := mock.Called(id)
+func (mock *MockHostRepository) Delete(ctx context.Context, id int64) error {
+	args := mock.Called(ctx, id)
 	return args.Error(0)
 }
 
@@ -72,25 +141,106 @@ type MockMetricRepository struct {
 }
 
 // FindByFilters mocks finding metrics by filters
-func (mock *MockMetricRepository) FindByFilters(params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
-	args := mock.Called(params)
+func (mock *MockMetricRepository) FindByFilters(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
+	args := mock.Called(ctx, params)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]entities.SystemMetric), args.Error(1)
 }
 
+// StreamByFilters mocks streaming metrics, calling fn for each row given to Return
+func (mock *MockMetricRepository) StreamByFilters(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error {
+	args := mock.Called(ctx, params)
+	if rows, ok := args.Get(0).([]entities.MetricExportRow); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
 // FindLatest mocks finding the latest metric
-func (mock *MockMetricRepository) FindLatest(hostID *int64) (*entities.SystemMetric, error) {
-	args := mock.Called(hostID)
+func (mock *MockMetricRepository) FindLatest(ctx context.Context, hostID *int64) (*entities.SystemMetric, error) {
+	args := mock.Called(ctx, hostID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entities.SystemMetric), args.Error(1)
 }
 
+// FindAfterID mocks finding metrics stored after an ID
+func (mock *MockMetricRepository) FindAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error) {
+	args := mock.Called(ctx, afterID, hostID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.SystemMetric), args.Error(1)
+}
+
 // Create mocks creating a new metric
-func (mock *MockMetricRepository) Create(metric *entities.SystemMetric) (int64, error) {
-	args := mock.Called(metric)
+func (mock *MockMetricRepository) Create(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	args := mock.Called(ctx, metric)
+	return args.Get(0).(entities.MetricWrite), args.Error(1)
+}
+
+// CreateBatch mocks creating metrics in one transaction
+func (mock *MockMetricRepository) CreateBatch(ctx context.Context, metrics []entities.SystemMetric) ([]entities.MetricWrite, error) {
+	args := mock.Called(ctx, metrics)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.MetricWrite), args.Error(1)
+}
+
+// DeleteRange mocks deleting metrics in a time range
+func (mock *MockMetricRepository) DeleteRange(ctx context.Context, startTime, endTime, maxID int64) (int64, error) {
+	args := mock.Called(ctx, startTime, endTime, maxID)
 	return args.Get(0).(int64), args.Error(1)
 }
+
+// InsertMissing mocks inserting metrics that are not already stored
+func (mock *MockMetricRepository) InsertMissing(ctx context.Context, metrics []entities.SystemMetric) (int64, error) {
+	args := mock.Called(ctx, metrics)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockScrapeTargetRepository is a mock implementation of ScrapeTargetRepositoryInterface
+type MockScrapeTargetRepository struct {
+	mock.Mock
+}
+
+// FindByFilters mocks finding scrape targets by filters
+func (mock *MockScrapeTargetRepository) FindByFilters(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error) {
+	args := mock.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.ScrapeTarget), args.Error(1)
+}
+
+// Create mocks creating a new scrape target
+func (mock *MockScrapeTargetRepository) Create(ctx context.Context, target *entities.ScrapeTarget) (int64, error) {
+	args := mock.Called(ctx, target)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// Update mocks updating a scrape target
+func (mock *MockScrapeTargetRepository) Update(ctx context.Context, id int64, target *entities.ScrapeTarget) error {
+	args := mock.Called(ctx, id, target)
+	return args.Error(0)
+}
+
+// Delete mocks deleting a scrape target
+func (mock *MockScrapeTargetRepository) Delete(ctx context.Context, id int64) error {
+	args := mock.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RecordResult mocks recording the result of a scrape
+func (mock *MockScrapeTargetRepository) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error {
+	args := mock.Called(ctx, id, result)
+	return args.Error(0)
+}
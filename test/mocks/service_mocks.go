@@ -1,28 +1,102 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/gabrielg2020/monitor-api/internal/entities"
 	"github.com/stretchr/testify/mock"
 )
 
+// MockArchiveService is a mock implementation of ArchiveServiceInterface
+type MockArchiveService struct {
+	mock.Mock
+}
+
+// Archive mocks archiving metrics to Parquet files
+func (m *MockArchiveService) Archive(ctx context.Context, req *entities.ArchiveRequest) (*entities.ArchiveResult, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entities.ArchiveResult), args.Error(1)
+}
+
+// Import mocks importing Parquet archive files
+func (m *MockArchiveService) Import(ctx context.Context, req *entities.ImportRequest) (*entities.ImportResult, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entities.ImportResult), args.Error(1)
+}
+
+// MockBackupService is a mock implementation of BackupServiceInterface
+type MockBackupService struct {
+	mock.Mock
+}
+
+// CreateBackup mocks taking a database backup
+func (m *MockBackupService) CreateBackup(ctx context.Context) (*entities.Backup, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entities.Backup), args.Error(1)
+}
+
+// ListBackups mocks listing database backups
+func (m *MockBackupService) ListBackups() ([]entities.Backup, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.Backup), args.Error(1)
+}
+
 // MockHealthService is a mock implementation of HealthServiceInterface
 type MockHealthService struct {
 	mock.Mock
 }
 
 // CheckHealth mocks the health check
-func (m *MockHealthService) CheckHealth() error {
-	args := m.Called()
+func (m *MockHealthService) CheckHealth(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 
+// CheckReadiness mocks running the registered health checks
+func (m *MockHealthService) CheckReadiness(ctx context.Context) *entities.HealthReport {
+	args := m.Called(ctx)
+	return args.Get(0).(*entities.HealthReport)
+}
+
 // GetDetailedHealth mocks getting detailed health information
-func (m *MockHealthService) GetDetailedHealth() (map[string]interface{}, error) {
+func (m *MockHealthService) GetDetailedHealth(ctx context.Context) *entities.DetailedHealth {
+	args := m.Called(ctx)
+	return args.Get(0).(*entities.DetailedHealth)
+}
+
+// MockMaintenanceService is a mock implementation of MaintenanceServiceInterface
+type MockMaintenanceService struct {
+	mock.Mock
+}
+
+// RunMaintenance mocks running database maintenance tasks
+func (m *MockMaintenanceService) RunMaintenance(ctx context.Context, trigger string, tasks []string) (*entities.MaintenanceRun, error) {
+	args := m.Called(ctx, trigger, tasks)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entities.MaintenanceRun), args.Error(1)
+}
+
+// LastRun mocks getting the most recent maintenance run
+func (m *MockMaintenanceService) LastRun() (*entities.MaintenanceRun, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(map[string]interface{}), args.Error(1)
+	return args.Get(0).(*entities.MaintenanceRun), args.Error(1)
 }
 
 // MockHostService is a mock implementation of HostServiceInterface
@@ -31,29 +105,29 @@ type MockHostService struct {
 }
 
 // CreateHost mocks creating a new host
-func (m *MockHostService) CreateHost(host *entities.Host) (int64, error) {
-	args := m.Called(host)
+func (m *MockHostService) CreateHost(ctx context.Context, host *entities.Host) (int64, error) {
+	args := m.Called(ctx, host)
 	return args.Get(0).(int64), args.Error(1)
 }
 
 // GetHosts mocks getting a host by ID
-func (m *MockHostService) GetHosts(params *entities.HostQueryParams) ([]entities.Host, error) {
-	args := m.Called(params)
+func (m *MockHostService) GetHosts(ctx context.Context, params *entities.HostQueryParams) ([]entities.Host, entities.PageInfo, error) {
+	args := m.Called(ctx, params)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, args.Get(1).(entities.PageInfo), args.Error(2)
 	}
-	return args.Get(0).([]entities.Host), args.Error(1)
+	return args.Get(0).([]entities.Host), args.Get(1).(entities.PageInfo), args.Error(2)
 }
 
 // UpdateHost mocks updating a host
-func (m *MockHostService) UpdateHost(id int64, host *entities.Host) error {
-	args := m.Called(id, host)
+func (m *MockHostService) UpdateHost(ctx context.Context, id int64, host *entities.Host) error {
+	args := m.Called(ctx, id, host)
 	return args.Error(0)
 }
 
 // DeleteHost mocks deleting a host
-func (m *MockHostService) DeleteHost(id int64) error {
-	args := m.Called(id)
+func (m *MockHostService) DeleteHost(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
@@ -63,25 +137,91 @@ type MockMetricService struct {
 }
 
 // CreateMetric mocks creating a new metric
-func (m *MockMetricService) CreateMetric(metric *entities.SystemMetric) (int64, error) {
-	args := m.Called(metric)
-	return args.Get(0).(int64), args.Error(1)
+func (m *MockMetricService) CreateMetric(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, error) {
+	args := m.Called(ctx, metric)
+	return args.Get(0).(entities.MetricWrite), args.Error(1)
+}
+
+// AcceptMetric mocks accepting a metric pushed by an agent
+func (m *MockMetricService) AcceptMetric(ctx context.Context, metric *entities.SystemMetric) (entities.MetricWrite, bool, error) {
+	args := m.Called(ctx, metric)
+	return args.Get(0).(entities.MetricWrite), args.Bool(1), args.Error(2)
 }
 
 // GetMetrics mocks getting metrics based on query parameters
-func (m *MockMetricService) GetMetrics(params *entities.MetricQueryParams) ([]entities.SystemMetric, error) {
-	args := m.Called(params)
+func (m *MockMetricService) GetMetrics(ctx context.Context, params *entities.MetricQueryParams) ([]entities.SystemMetric, entities.PageInfo, error) {
+	args := m.Called(ctx, params)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, args.Get(1).(entities.PageInfo), args.Error(2)
 	}
-	return args.Get(0).([]entities.SystemMetric), args.Error(1)
+	return args.Get(0).([]entities.SystemMetric), args.Get(1).(entities.PageInfo), args.Error(2)
+}
+
+// ExportMetrics mocks streaming metrics, calling fn for each row given to Return
+func (m *MockMetricService) ExportMetrics(ctx context.Context, params *entities.MetricQueryParams, fn func(row entities.MetricExportRow) error) error {
+	args := m.Called(ctx, params)
+	if rows, ok := args.Get(0).([]entities.MetricExportRow); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
 }
 
 // GetLatestMetric mocks getting the latest metric for a specific host or all hosts
-func (m *MockMetricService) GetLatestMetric(hostID *int64) (*entities.SystemMetric, error) {
-	args := m.Called(hostID)
+func (m *MockMetricService) GetLatestMetric(ctx context.Context, hostID *int64) (*entities.SystemMetric, error) {
+	args := m.Called(ctx, hostID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entities.SystemMetric), args.Error(1)
 }
+
+// GetMetricsAfterID mocks getting metrics stored after an ID
+func (m *MockMetricService) GetMetricsAfterID(ctx context.Context, afterID int64, hostID *int64, limit int) ([]entities.SystemMetric, error) {
+	args := m.Called(ctx, afterID, hostID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.SystemMetric), args.Error(1)
+}
+
+// MockScrapeTargetService is a mock implementation of ScrapeTargetServiceInterface
+type MockScrapeTargetService struct {
+	mock.Mock
+}
+
+// CreateTarget mocks registering a new scrape target
+func (m *MockScrapeTargetService) CreateTarget(ctx context.Context, target *entities.ScrapeTarget) (int64, error) {
+	args := m.Called(ctx, target)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetTargets mocks getting scrape targets based on query parameters
+func (m *MockScrapeTargetService) GetTargets(ctx context.Context, params *entities.ScrapeTargetQueryParams) ([]entities.ScrapeTarget, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entities.ScrapeTarget), args.Error(1)
+}
+
+// UpdateTarget mocks updating a scrape target
+func (m *MockScrapeTargetService) UpdateTarget(ctx context.Context, id int64, target *entities.ScrapeTarget) error {
+	args := m.Called(ctx, id, target)
+	return args.Error(0)
+}
+
+// DeleteTarget mocks deleting a scrape target
+func (m *MockScrapeTargetService) DeleteTarget(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RecordResult mocks recording the result of a scrape
+func (m *MockScrapeTargetService) RecordResult(ctx context.Context, id int64, result *entities.ScrapeResult) error {
+	args := m.Called(ctx, id, result)
+	return args.Error(0)
+}